@@ -22,18 +22,85 @@ CREATE TABLE IF NOT EXISTS machines(
 	PRIMARY KEY (id)
 );
 
+-- Machine in maintenance can not be unlocked
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS maintenance boolean NOT NULL DEFAULT false;
+
+-- Job positions of users with sets of permissions, see entities.Permissions
+CREATE TABLE IF NOT EXISTS roles(
+  name varchar(32) PRIMARY KEY,
+  description text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions(
+  role varchar(32) NOT NULL,
+  permission varchar(64) NOT NULL,
+
+  PRIMARY KEY (role, permission),
+  FOREIGN KEY (role) REFERENCES roles (name) ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description) VALUES
+  ('admin', 'full access'),
+  ('worker', 'operates machines'),
+  ('supervisor', 'oversees workers, force-finishes sessions and views reports'),
+  ('technician', 'services machines'),
+  ('site_admin', 'full access within own site')
+ON CONFLICT (name) DO NOTHING;
+
+-- Admin gets every permission on each run, other roles are seeded only while they have no permissions,
+-- so changes made by admin are kept
+INSERT INTO role_permissions (role, permission) VALUES
+  ('admin', 'users.read'), ('admin', 'users.manage'),
+  ('admin', 'machines.read'), ('admin', 'machines.manage'), ('admin', 'machines.maintenance'),
+  ('admin', 'parkings.read'), ('admin', 'parkings.manage'),
+  ('admin', 'sessions.read'), ('admin', 'sessions.use'), ('admin', 'sessions.force'),
+  ('admin', 'incidents.read'), ('admin', 'incidents.manage'),
+  ('admin', 'alerts.read'), ('admin', 'alerts.manage'),
+  ('admin', 'reports.read'), ('admin', 'sites.manage')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT d.role, d.permission FROM (VALUES
+  ('worker', 'sessions.use'),
+  ('supervisor', 'users.read'), ('supervisor', 'machines.read'), ('supervisor', 'parkings.read'),
+  ('supervisor', 'sessions.read'), ('supervisor', 'sessions.use'), ('supervisor', 'sessions.force'),
+  ('supervisor', 'reports.read'), ('supervisor', 'incidents.read'), ('supervisor', 'incidents.manage'),
+  ('supervisor', 'alerts.read'), ('supervisor', 'alerts.manage'),
+  ('technician', 'machines.read'), ('technician', 'machines.maintenance'), ('technician', 'parkings.read'),
+  ('technician', 'sessions.use'), ('technician', 'incidents.read'), ('technician', 'incidents.manage'),
+  ('technician', 'alerts.read'),
+  ('site_admin', 'users.read'), ('site_admin', 'users.manage'),
+  ('site_admin', 'machines.read'), ('site_admin', 'machines.manage'), ('site_admin', 'machines.maintenance'),
+  ('site_admin', 'parkings.read'), ('site_admin', 'parkings.manage'),
+  ('site_admin', 'sessions.read'), ('site_admin', 'sessions.use'), ('site_admin', 'sessions.force'),
+  ('site_admin', 'incidents.read'), ('site_admin', 'incidents.manage'),
+  ('site_admin', 'alerts.read'), ('site_admin', 'alerts.manage'),
+  ('site_admin', 'reports.read')
+) AS d (role, permission)
+WHERE NOT EXISTS (SELECT 1 FROM role_permissions p WHERE p.role = d.role);
+
 CREATE TABLE IF NOT EXISTS users(
   id SERIAL,
   name text NOT NULL,
 	phone_number varchar(11) NOT NULL UNIQUE,
-	job_position varchar(8) NOT NULL,
+	job_position varchar(32) NOT NULL,
   password varchar (128) NOT NUll,
 
-	CHECK (job_position IN ('worker', 'admin')),
   CHECK (LENGTH(password) >= 8),
-	PRIMARY KEY (id)
+	PRIMARY KEY (id),
+  CONSTRAINT users_job_position_fkey FOREIGN KEY (job_position) REFERENCES roles (name)
 );
 
+-- Databases created before roles had fixed job positions
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_job_position_check;
+ALTER TABLE users ALTER COLUMN job_position TYPE varchar(32);
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_job_position_fkey') THEN
+    ALTER TABLE users ADD CONSTRAINT users_job_position_fkey FOREIGN KEY (job_position) REFERENCES roles (name);
+  END IF;
+END $$;
+
 CREATE TABLE IF NOT EXISTS sessions(
   id SERIAL,
   state integer DEFAULT 0,
@@ -41,6 +108,8 @@ CREATE TABLE IF NOT EXISTS sessions(
   worker_id integer NOT NULL,
   datetime_start bigint NOT NULL,
   datetime_finish bigint NOT NULL,
+  start_parking_id integer DEFAULT 0,
+  finish_parking_id integer DEFAULT 0,
 
   CHECK (state IN (0, 1, 2)),
 
@@ -49,3 +118,316 @@ CREATE TABLE IF NOT EXISTS sessions(
   FOREIGN KEY (machine_id) REFERENCES machines (id) ON DELETE CASCADE,
   FOREIGN KEY (worker_id) REFERENCES users (id) ON DELETE CASCADE
 );
+
+-- Parkings of sessions are used in reports, columns are added for databases created before them
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS start_parking_id integer DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS finish_parking_id integer DEFAULT 0;
+
+-- Indexes for filtered and sorted lists, id is the last column because it
+-- breaks ties of sort column in cursor pagination.
+CREATE INDEX IF NOT EXISTS sessions_datetime_start_idx ON sessions (datetime_start, id);
+CREATE INDEX IF NOT EXISTS sessions_datetime_finish_idx ON sessions (datetime_finish, id);
+CREATE INDEX IF NOT EXISTS sessions_state_datetime_start_idx ON sessions (state, datetime_start, id);
+CREATE INDEX IF NOT EXISTS sessions_worker_datetime_start_idx ON sessions (worker_id, datetime_start, id);
+CREATE INDEX IF NOT EXISTS sessions_machine_datetime_start_idx ON sessions (machine_id, datetime_start, id);
+
+CREATE INDEX IF NOT EXISTS machines_parking_idx ON machines (parking_id, id);
+CREATE INDEX IF NOT EXISTS machines_state_idx ON machines (state, id);
+
+CREATE INDEX IF NOT EXISTS users_name_idx ON users (name, id);
+CREATE INDEX IF NOT EXISTS users_job_position_idx ON users (job_position, id);
+CREATE INDEX IF NOT EXISTS sessions_start_parking_idx ON sessions (start_parking_id, datetime_start);
+CREATE INDEX IF NOT EXISTS sessions_finish_parking_idx ON sessions (finish_parking_id, datetime_finish);
+
+-- Outcomes of commands sent with Idempotency-Key header, status is 0 while command is in progress
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+  user_id integer NOT NULL,
+  key varchar(128) NOT NULL,
+  request_hash varchar(64) NOT NULL,
+  status integer DEFAULT 0,
+  content_type text DEFAULT '',
+  body bytea,
+  created_at bigint NOT NULL,
+
+  PRIMARY KEY (user_id, key),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+-- Failed logins by phone number, phone numbers of unknown users are counted too.
+-- Times are unix seconds, login is rejected until locked_until.
+CREATE TABLE IF NOT EXISTS login_failures(
+  phone_number varchar(11) PRIMARY KEY,
+  failures integer NOT NULL,
+  last_failure_at bigint NOT NULL,
+  locked_until bigint
+);
+
+-- Models of machines, workers need valid certification of the type to unlock its machines.
+-- Machines with type_id 0 have no type and need no certification.
+CREATE TABLE IF NOT EXISTS machine_types(
+  id SERIAL PRIMARY KEY,
+  name varchar(64) NOT NULL UNIQUE,
+  description text NOT NULL DEFAULT ''
+);
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS type_id integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS machines_type_idx ON machines (type_id, id);
+
+-- Certifications of users, times are unix seconds
+CREATE TABLE IF NOT EXISTS certifications(
+  id SERIAL PRIMARY KEY,
+  user_id integer NOT NULL,
+  type_id integer NOT NULL,
+  number varchar(64) NOT NULL DEFAULT '',
+  issued_at bigint NOT NULL,
+  expires_at bigint NOT NULL,
+
+  CHECK (expires_at > issued_at),
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (type_id) REFERENCES machine_types (id)
+);
+CREATE INDEX IF NOT EXISTS certifications_user_type_idx ON certifications (user_id, type_id);
+CREATE INDEX IF NOT EXISTS certifications_expires_at_idx ON certifications (expires_at);
+
+-- Pre-use checklists of machine types, items are replaced as a whole when checklist is changed
+CREATE TABLE IF NOT EXISTS checklist_items(
+  id SERIAL PRIMARY KEY,
+  type_id integer NOT NULL,
+  position integer NOT NULL,
+  text text NOT NULL,
+  critical boolean NOT NULL DEFAULT false,
+
+  FOREIGN KEY (type_id) REFERENCES machine_types (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS checklist_items_type_idx ON checklist_items (type_id, position);
+
+-- Checklists filled before unlock, session_id is 0 when unlock was refused.
+-- Answers keep text of items, because items may be changed after submission.
+CREATE TABLE IF NOT EXISTS checklist_submissions(
+  id SERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL,
+  user_id integer NOT NULL,
+  session_id integer NOT NULL DEFAULT 0,
+  passed boolean NOT NULL,
+  submitted_at bigint NOT NULL,
+
+  FOREIGN KEY (machine_id) REFERENCES machines (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS checklist_submissions_machine_idx ON checklist_submissions (machine_id, id);
+CREATE INDEX IF NOT EXISTS checklist_submissions_user_idx ON checklist_submissions (user_id, id);
+CREATE INDEX IF NOT EXISTS checklist_submissions_session_idx ON checklist_submissions (session_id);
+
+CREATE TABLE IF NOT EXISTS checklist_answers(
+  submission_id integer NOT NULL,
+  item_id integer NOT NULL,
+  text text NOT NULL,
+  critical boolean NOT NULL,
+  ok boolean NOT NULL,
+  comment text NOT NULL DEFAULT '',
+
+  PRIMARY KEY (submission_id, item_id),
+  FOREIGN KEY (submission_id) REFERENCES checklist_submissions (id) ON DELETE CASCADE
+);
+
+-- Problems with machines reported by workers, session_id is 0 if reporter had no session with the machine.
+-- Photo is kept in storage from `incidents` config under photo_key. Times are unix seconds.
+CREATE TABLE IF NOT EXISTS incidents(
+  id SERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL,
+  session_id integer NOT NULL DEFAULT 0,
+  reporter_id integer NOT NULL,
+  category varchar(16) NOT NULL,
+  severity varchar(16) NOT NULL,
+  description text NOT NULL DEFAULT '',
+  status varchar(16) NOT NULL DEFAULT 'open',
+  resolution text NOT NULL DEFAULT '',
+  triaged_by integer NOT NULL DEFAULT 0,
+  photo_key text NOT NULL DEFAULT '',
+  photo_content_type varchar(32) NOT NULL DEFAULT '',
+  created_at bigint NOT NULL,
+  updated_at bigint NOT NULL,
+  closed_at bigint,
+
+  CHECK (category IN ('damage', 'malfunction', 'accident', 'near_miss', 'other')),
+  CHECK (severity IN ('low', 'medium', 'high', 'critical')),
+  CHECK (status IN ('open', 'acknowledged', 'resolved', 'rejected')),
+
+  FOREIGN KEY (machine_id) REFERENCES machines (id) ON DELETE CASCADE,
+  FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS incidents_status_idx ON incidents (status, id);
+CREATE INDEX IF NOT EXISTS incidents_machine_idx ON incidents (machine_id, id);
+CREATE INDEX IF NOT EXISTS incidents_created_at_idx ON incidents (created_at, id);
+CREATE INDEX IF NOT EXISTS incidents_updated_at_idx ON incidents (updated_at, id);
+
+-- Service intervals of machine types: service is due every every_hours of operating time,
+-- machine can be used grace_hours more before unlock is refused.
+CREATE TABLE IF NOT EXISTS service_intervals(
+  id SERIAL PRIMARY KEY,
+  type_id integer NOT NULL,
+  name varchar(128) NOT NULL,
+  every_hours integer NOT NULL,
+  grace_hours integer NOT NULL DEFAULT 0,
+
+  CHECK (every_hours > 0),
+  CHECK (grace_hours >= 0),
+
+  FOREIGN KEY (type_id) REFERENCES machine_types (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS service_intervals_type_idx ON service_intervals (type_id, id);
+
+-- Work orders created when service of the machine is due. Hours are operating hours of the machine
+-- (sum of its sessions), interval_id is NULL after interval is deleted. Times are unix seconds.
+CREATE TABLE IF NOT EXISTS work_orders(
+  id SERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL,
+  interval_id integer,
+  name varchar(128) NOT NULL,
+  due_hours double precision NOT NULL,
+  overdue_hours double precision NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'open',
+  notes text NOT NULL DEFAULT '',
+  created_at bigint NOT NULL,
+  closed_at bigint,
+  closed_by integer NOT NULL DEFAULT 0,
+  closed_at_hours double precision NOT NULL DEFAULT 0,
+
+  CHECK (status IN ('open', 'closed')),
+
+  FOREIGN KEY (machine_id) REFERENCES machines (id) ON DELETE CASCADE,
+  FOREIGN KEY (interval_id) REFERENCES service_intervals (id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS work_orders_machine_idx ON work_orders (machine_id, id);
+CREATE INDEX IF NOT EXISTS work_orders_status_idx ON work_orders (status, id);
+CREATE INDEX IF NOT EXISTS work_orders_created_at_idx ON work_orders (created_at, id);
+-- one open order per interval of the machine
+CREATE UNIQUE INDEX IF NOT EXISTS work_orders_open_idx ON work_orders (machine_id, interval_id) WHERE status = 'open';
+
+-- Parkings where the user is allowed to unlock and return machines, user without rows may use any parking
+CREATE TABLE IF NOT EXISTS user_parkings(
+  user_id integer NOT NULL,
+  parking_id integer NOT NULL,
+
+  PRIMARY KEY (user_id, parking_id),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (parking_id) REFERENCES parkings (id) ON DELETE CASCADE
+);
+
+-- Shifts are time windows "HH:MM" in time zone on days of week (0 is Sunday), shift ends next day if end_time <= start_time
+CREATE TABLE IF NOT EXISTS shifts(
+  id SERIAL PRIMARY KEY,
+  name varchar(64) NOT NULL UNIQUE,
+  start_time varchar(5) NOT NULL,
+  end_time varchar(5) NOT NULL,
+  days integer[] NOT NULL,
+  timezone varchar(64) NOT NULL DEFAULT 'UTC'
+);
+
+-- Shifts of the user, user without shifts may unlock machines at any time
+CREATE TABLE IF NOT EXISTS user_shifts(
+  user_id integer NOT NULL,
+  shift_id integer NOT NULL,
+
+  PRIMARY KEY (user_id, shift_id),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (shift_id) REFERENCES shifts (id) ON DELETE CASCADE
+);
+
+-- Sites are warehouses, parkings, machines and users belong to one site.
+-- Data created before sites belongs to the default site.
+CREATE TABLE IF NOT EXISTS sites(
+  id SERIAL PRIMARY KEY,
+  name varchar(64) NOT NULL UNIQUE
+);
+
+INSERT INTO sites (id, name) VALUES (1, 'default') ON CONFLICT DO NOTHING;
+SELECT setval(pg_get_serial_sequence('sites', 'id'), (SELECT MAX(id) FROM sites));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS site_id integer NOT NULL DEFAULT 1 REFERENCES sites (id);
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS site_id integer NOT NULL DEFAULT 1 REFERENCES sites (id);
+ALTER TABLE parkings ADD COLUMN IF NOT EXISTS site_id integer NOT NULL DEFAULT 1 REFERENCES sites (id);
+
+CREATE INDEX IF NOT EXISTS users_site_idx ON users (site_id, id);
+CREATE INDEX IF NOT EXISTS machines_site_idx ON machines (site_id, id);
+CREATE INDEX IF NOT EXISTS parkings_site_idx ON parkings (site_id, id);
+
+-- Geofences are areas of the site described by BSSIDs of access points, kind is allowed or forbidden
+CREATE TABLE IF NOT EXISTS geofences(
+  id SERIAL PRIMARY KEY,
+  name varchar(64) NOT NULL,
+  kind varchar(16) NOT NULL,
+  bssids varchar(64)[] NOT NULL,
+  site_id integer NOT NULL REFERENCES sites (id),
+
+  CHECK (kind IN ('allowed', 'forbidden'))
+);
+
+CREATE INDEX IF NOT EXISTS geofences_site_idx ON geofences (site_id, id);
+
+-- Alerts raised when machine in use leaves permitted area, geofence_id is 0 for alerts outside allowed geofences
+CREATE TABLE IF NOT EXISTS geofence_alerts(
+  id SERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL REFERENCES machines (id) ON DELETE CASCADE,
+  session_id integer NOT NULL DEFAULT 0,
+  worker_id integer NOT NULL DEFAULT 0,
+  site_id integer NOT NULL,
+  geofence_id integer NOT NULL DEFAULT 0,
+  kind varchar(32) NOT NULL,
+  bssid varchar(64) NOT NULL,
+  paused boolean NOT NULL DEFAULT false,
+  created_at bigint NOT NULL,
+  resolved_at bigint
+);
+
+CREATE INDEX IF NOT EXISTS geofence_alerts_machine_idx ON geofence_alerts (machine_id, id);
+CREATE INDEX IF NOT EXISTS geofence_alerts_created_at_idx ON geofence_alerts (created_at, id);
+-- one unresolved alert per machine
+CREATE UNIQUE INDEX IF NOT EXISTS geofence_alerts_open_idx ON geofence_alerts (machine_id) WHERE resolved_at IS NULL;
+
+-- Access points seen by controllers of machines, parking_id and geofence_id are 0 if bssid was not at any of them
+CREATE TABLE IF NOT EXISTS machine_sightings(
+  id BIGSERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL REFERENCES machines (id) ON DELETE CASCADE,
+  session_id integer NOT NULL DEFAULT 0,
+  bssid varchar(64) NOT NULL,
+  rssi integer NOT NULL DEFAULT 0,
+  parking_id integer NOT NULL DEFAULT 0,
+  geofence_id integer NOT NULL DEFAULT 0,
+  seen_at bigint NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS machine_sightings_machine_idx ON machine_sightings (machine_id, id);
+CREATE INDEX IF NOT EXISTS machine_sightings_session_idx ON machine_sightings (session_id, id) WHERE session_id <> 0;
+-- Sightings older than `sightings.retention` are deleted in background
+CREATE INDEX IF NOT EXISTS machine_sightings_seen_idx ON machine_sightings (seen_at);
+
+-- Alerts about devices, sessions and parkings, key is id of machine, session or parking the alert is about
+CREATE TABLE IF NOT EXISTS alerts(
+  id SERIAL PRIMARY KEY,
+  type varchar(32) NOT NULL,
+  key varchar(64) NOT NULL,
+  site_id integer NOT NULL DEFAULT 0,
+  message text NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'open',
+  count integer NOT NULL DEFAULT 1,
+  created_at bigint NOT NULL,
+  last_seen_at bigint NOT NULL,
+  notified_at bigint,
+  acknowledged_by integer NOT NULL DEFAULT 0,
+  acknowledged_at bigint,
+  resolved_at bigint
+);
+
+CREATE INDEX IF NOT EXISTS alerts_created_at_idx ON alerts (created_at, id);
+-- one unresolved alert per subject, repeated occurrences are counted in it
+CREATE UNIQUE INDEX IF NOT EXISTS alerts_open_idx ON alerts (type, key) WHERE resolved_at IS NULL;
+
+-- Version of the schema, app is not ready while it is older than postgres.SchemaVersion.
+-- Keep this block at the end and bump both when changing the schema and add the same changes as migration to internal/dbs/postgres/migrations.
+CREATE TABLE IF NOT EXISTS schema_version(
+  version integer NOT NULL
+);
+DELETE FROM schema_version;
+INSERT INTO schema_version (version) VALUES (14);
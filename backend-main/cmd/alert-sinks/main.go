@@ -0,0 +1,142 @@
+// alert-sinks stands in for receivers of alerts and prints everything it gets: webhook, Telegram Bot API and SMTP server.
+// It is used to check `alerts` config locally, e.g. with config/local.yml:
+//
+//	go run ./cmd/alert-sinks -http :9100 -smtp :2525
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func main() {
+	httpAddr := flag.String("http", ":9100", "address of webhook (POST /webhook) and Telegram Bot API (POST /bot<token>/sendMessage)")
+	smtpAddr := flag.String("smtp", ":2525", "address of SMTP server, empty to disable")
+	flag.Parse()
+
+	if *smtpAddr != "" {
+		ln, err := net.Listen("tcp", *smtpAddr)
+		if err != nil {
+			log.Fatalf("listen smtp: %v", err)
+		}
+		log.Printf("smtp is listening on %s", *smtpAddr)
+		go serveSMTP(ln)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /webhook", webhookHandler)
+	mux.HandleFunc("POST /{bot}/sendMessage", telegramHandler)
+
+	log.Printf("webhook and telegram are listening on %s", *httpAddr)
+	log.Fatal(http.ListenAndServe(*httpAddr, mux))
+}
+
+func webhookHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("webhook: %s", body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// telegramHandler answers sendMessage of any bot like Bot API does
+func telegramHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.PathValue("bot"), "bot")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var msg struct {
+		ChatId string `json:"chat_id"`
+		Text   string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"ok": false, "error_code": 400, "description": %q}`, err.Error())
+		return
+	}
+
+	log.Printf("telegram: bot %s..., chat %s: %q", token[:min(len(token), 4)], msg.ChatId, msg.Text)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"ok": true, "result": {"message_id": %d}}`, time.Now().Unix())
+}
+
+// serveSMTP accepts mails without authentication and TLS
+func serveSMTP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("accept smtp: %v", err)
+			return
+		}
+		go handleSMTP(conn)
+	}
+}
+
+func handleSMTP(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Minute))
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		fmt.Fprintf(conn, "%s\r\n", line)
+	}
+
+	reply("220 alert-sinks ESMTP")
+	var from string
+	var to []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 alert-sinks")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			from, to = strings.TrimSpace(line[len("MAIL FROM:"):]), nil
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			to = append(to, strings.TrimSpace(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if strings.TrimRight(l, "\r\n") == "." {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			log.Printf("smtp: from %s to %s:\n%s", from, strings.Join(to, ", "), strings.ReplaceAll(data.String(), "\r\n", "\n"))
+			reply("250 OK")
+		case cmd == "RSET":
+			from, to = "", nil
+			reply("250 OK")
+		case cmd == "NOOP":
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
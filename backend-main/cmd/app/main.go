@@ -2,31 +2,25 @@ package main
 
 import (
 	"log/slog"
-	"os"
-	"path"
+	_ "time/tzdata" // time zones of reports, docker image has no tzdata
 
 	"github.com/ecol-master/sharing-wh-machines/internal/app"
 	"github.com/ecol-master/sharing-wh-machines/internal/config"
-	"github.com/ecol-master/sharing-wh-machines/internal/libs/csv"
 	"github.com/ecol-master/sharing-wh-machines/internal/logger"
 	"github.com/pkg/errors"
 )
 
 func main() {
 	cfg := config.MustLoad()
-	setupLoggers(cfg)
+
+	logFile, err := logger.Setup(cfg.Log)
+	if err != nil {
+		panic(errors.Wrap(err, "setup logger"))
+	}
+	defer logFile.Close()
 
 	a := app.New(cfg)
 	if err := a.Run(); err != nil {
 		slog.Error(err.Error())
 	}
 }
-
-func setupLoggers(cfg *config.Config) {
-	if err := os.Mkdir(cfg.Log.OutDir, 0750); err != nil && !os.IsExist(err) {
-		panic(errors.Wrap(err, "create logs folder"))
-	}
-
-	logger.Setup(path.Join(cfg.Log.OutDir, cfg.Log.Dev))
-	csv.Setup(path.Join(cfg.Log.OutDir, cfg.Log.CSV))
-}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/config"
+	"github.com/ecol-master/sharing-wh-machines/internal/dbs/postgres"
+	"github.com/ecol-master/sharing-wh-machines/internal/logger"
+	"github.com/pkg/errors"
+)
+
+// migrate upgrades schema of existing postgres database to the version the app works with
+func main() {
+	cfg := config.MustLoad()
+
+	logFile, err := logger.Setup(cfg.Log)
+	if err != nil {
+		panic(errors.Wrap(err, "setup logger"))
+	}
+
+	err = migrate(cfg.Postgres)
+	if err != nil {
+		slog.Error("failed to migrate", slog.String("error", err.Error()))
+	} else {
+		slog.Info("schema is up to date", slog.Int("version", postgres.SchemaVersion))
+	}
+
+	logFile.Close()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func migrate(cfg config.PostgresConfig) error {
+	db, err := postgres.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return postgres.Migrate(context.Background(), db)
+}
@@ -0,0 +1,226 @@
+// Package alerts raises alerts about devices, sessions and parkings by rules from config and sends them to sinks.
+// Alert is stored once per subject until the condition is gone, repeated occurrences are counted in it,
+// acknowledged alerts are not sent again.
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/config"
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/notify"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/pkg/errors"
+)
+
+// Events of alert lifecycle which are sent to sinks
+const (
+	EventRaised   = "raised"
+	EventRepeated = "repeated" // unacknowledged alert still occurs after rule repeat
+	EventResolved = "resolved"
+)
+
+// Notification is data of message sent to sinks
+type Notification struct {
+	Event string         `json:"event"`
+	Alert entities.Alert `json:"alert"`
+}
+
+type delivery struct {
+	sinks []string
+	msg   notify.Message
+}
+
+type Engine struct {
+	cfg   config.AlertsConfig
+	store service.Alert
+	sinks map[string]notify.Sink
+	queue chan delivery
+}
+
+// New checks rules of config, every sink used by rules should be in sinks
+func New(cfg config.AlertsConfig, store service.Alert, sinks map[string]notify.Sink) (*Engine, error) {
+	if cfg.CheckInterval <= 0 || cfg.SendTimeout <= 0 {
+		return nil, errors.New("check_interval and send_timeout should be positive")
+	}
+	for alertType, rule := range cfg.Rules {
+		if !slices.Contains(entities.AlertTypes, alertType) {
+			return nil, errors.Errorf("unknown alert type %q", alertType)
+		}
+		if (alertType == entities.AlertDeviceOffline || alertType == entities.AlertSessionStuck) && rule.After <= 0 {
+			return nil, errors.Errorf("rule %q: after is required", alertType)
+		}
+		for _, name := range rule.Sinks {
+			if _, ok := sinks[name]; !ok {
+				return nil, errors.Errorf("rule %q: unknown sink %q", alertType, name)
+			}
+		}
+	}
+
+	return &Engine{cfg: cfg, store: store, sinks: sinks, queue: make(chan delivery, max(cfg.QueueSize, 1))}, nil
+}
+
+// NewSinks creates sinks which are used by rules of config, stdout sink writes to out
+func NewSinks(cfg config.AlertsConfig, out io.Writer, client *http.Client) (map[string]notify.Sink, error) {
+	sinks := make(map[string]notify.Sink)
+	for _, rule := range cfg.Rules {
+		for _, name := range rule.Sinks {
+			if _, ok := sinks[name]; ok {
+				continue
+			}
+
+			var (
+				sink notify.Sink
+				err  error
+			)
+			switch name {
+			case notify.SinkStdout:
+				sink = notify.NewWriter(out)
+			case notify.SinkWebhook:
+				sink, err = notify.NewWebhook(cfg.Webhook, client)
+			case notify.SinkSMTP:
+				sink, err = notify.NewSMTP(cfg.SMTP)
+			case notify.SinkTelegram:
+				sink, err = notify.NewTelegram(cfg.Telegram, client)
+			default:
+				err = errors.New("unknown sink")
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "sink %q", name)
+			}
+			sinks[name] = sink
+		}
+	}
+	return sinks, nil
+}
+
+// Enabled reports if alert type has rule
+func (e *Engine) Enabled(alertType entities.AlertType) bool {
+	_, ok := e.cfg.Rules[alertType]
+	return ok
+}
+
+// Raise records occurrence of the condition and sends alert by rule of its type.
+// Nil is returned for types without rule.
+func (e *Engine) Raise(ctx context.Context, alert entities.Alert) (*entities.Alert, error) {
+	rule, ok := e.cfg.Rules[alert.Type]
+	if !ok {
+		return nil, nil
+	}
+
+	now := time.Now()
+	alert.LastSeenAt = now
+	raised, created, err := e.store.RaiseAlert(ctx, alert)
+	if err != nil {
+		return nil, errors.Wrap(err, "raise alert")
+	}
+
+	event := EventRaised
+	if created {
+		slog.WarnContext(ctx, "alert is raised", alertAttrs(raised, slog.String("op", "alerts.Raise"))...)
+	} else {
+		event = EventRepeated
+		if raised.Status != entities.AlertOpen || rule.Repeat <= 0 || (raised.NotifiedAt != nil && now.Sub(*raised.NotifiedAt) < rule.Repeat) {
+			return raised, nil
+		}
+	}
+
+	e.send(ctx, rule, raised, event)
+	return raised, nil
+}
+
+// Resolve resolves unresolved alert of the type and key. Alerts of types without rule are kept as they are.
+func (e *Engine) Resolve(ctx context.Context, alertType entities.AlertType, key string) error {
+	rule, ok := e.cfg.Rules[alertType]
+	if !ok {
+		return nil
+	}
+
+	resolved, err := e.store.ResolveAlert(ctx, alertType, key, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "resolve alert")
+	}
+	if resolved == nil {
+		return nil
+	}
+
+	slog.InfoContext(ctx, "alert is resolved", alertAttrs(resolved, slog.String("op", "alerts.Resolve"))...)
+	if rule.NotifyResolved && resolved.NotifiedAt != nil {
+		e.send(ctx, rule, resolved, EventResolved)
+	}
+	return nil
+}
+
+// send marks alert as notified and queues message to sinks of the rule, message is dropped if the queue is full
+func (e *Engine) send(ctx context.Context, rule config.AlertRule, alert *entities.Alert, event string) {
+	if len(rule.Sinks) == 0 {
+		return
+	}
+
+	if event != EventResolved {
+		now := time.Now()
+		if err := e.store.SetAlertNotified(ctx, alert.Id, now); err != nil {
+			slog.ErrorContext(ctx, "set alert notified", slog.Int("alert_id", alert.Id), slog.String("error", err.Error()))
+		}
+		notifiedAt := time.Unix(now.Unix(), 0)
+		alert.NotifiedAt = &notifiedAt
+	}
+
+	select {
+	case e.queue <- delivery{sinks: rule.Sinks, msg: message(alert, event)}:
+	default:
+		slog.ErrorContext(ctx, "alert queue is full, notification is dropped", alertAttrs(alert, slog.String("event", event))...)
+	}
+}
+
+// Dispatch sends queued messages to sinks until ctx is done, every sink gets `alerts.send_timeout`
+func (e *Engine) Dispatch(ctx context.Context) {
+	op := slog.String("op", "alerts.Dispatch")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-e.queue:
+			for _, name := range d.sinks {
+				sendCtx, cancel := context.WithTimeout(ctx, e.cfg.SendTimeout)
+				err := e.sinks[name].Send(sendCtx, d.msg)
+				cancel()
+				if err != nil {
+					slog.Error("send alert", op, slog.String("sink", name), slog.String("subject", d.msg.Subject),
+						slog.String("error", err.Error()))
+				}
+			}
+		}
+	}
+}
+
+func message(alert *entities.Alert, event string) notify.Message {
+	subject := fmt.Sprintf("[%s] %s", alert.Type, alert.Message)
+	if event == EventResolved {
+		subject = fmt.Sprintf("[%s] resolved: %s", alert.Type, alert.Message)
+	}
+
+	text := fmt.Sprintf("%s\nalert %d, site %d, %s %s, seen %d times since %s", alert.Message, alert.Id, alert.SiteId,
+		alert.Type, alert.Key, alert.Count, alert.CreatedAt.UTC().Format(time.RFC3339))
+	if event == EventResolved && alert.ResolvedAt != nil {
+		text += fmt.Sprintf("\nresolved at %s", alert.ResolvedAt.UTC().Format(time.RFC3339))
+	}
+
+	return notify.Message{Subject: subject, Text: text, Data: Notification{Event: event, Alert: *alert}}
+}
+
+func alertAttrs(alert *entities.Alert, attrs ...any) []any {
+	return append(attrs,
+		slog.Int("alert_id", alert.Id),
+		slog.String("type", alert.Type),
+		slog.String("key", alert.Key),
+		slog.String("message", alert.Message),
+	)
+}
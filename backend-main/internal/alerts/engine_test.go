@@ -0,0 +1,215 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/config"
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/notify"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+)
+
+// recorder is a sink which passes sent messages to channel
+type recorder chan notify.Message
+
+func (r recorder) Send(_ context.Context, msg notify.Message) error {
+	r <- msg
+	return nil
+}
+
+// newEngine starts dispatching of engine with one rule which sends to recorder
+func newEngine(t *testing.T, alertType entities.AlertType, rule config.AlertRule) (*Engine, *service.Service, recorder) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	svc, err := service.NewInMemory(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule.Sinks = []string{"recorder"}
+	cfg := config.AlertsConfig{
+		Rules:         map[string]config.AlertRule{alertType: rule},
+		CheckInterval: time.Minute,
+		SendTimeout:   time.Second,
+		QueueSize:     10,
+	}
+	sink := make(recorder, 10)
+	engine, err := New(cfg, svc, map[string]notify.Sink{"recorder": sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go engine.Dispatch(ctx)
+	return engine, svc, sink
+}
+
+// expect waits for message with the event
+func (r recorder) expect(t *testing.T, event string) notify.Message {
+	t.Helper()
+	select {
+	case msg := <-r:
+		if n := msg.Data.(Notification); n.Event != event {
+			t.Errorf("event %q is sent, want %q", n.Event, event)
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatalf("%q is not sent", event)
+		return notify.Message{}
+	}
+}
+
+// expectNone checks that nothing is sent
+func (r recorder) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case msg := <-r:
+		t.Errorf("unexpected message %q", msg.Subject)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRaiseAndResolve(t *testing.T) {
+	ctx := context.Background()
+	engine, svc, sink := newEngine(t, entities.AlertParkingFull, config.AlertRule{NotifyResolved: true})
+
+	raised, err := engine.Raise(ctx, entities.Alert{Type: entities.AlertParkingFull, Key: "1", Message: "parking is full"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raised.Status != entities.AlertOpen || raised.Count != 1 || raised.NotifiedAt == nil {
+		t.Errorf("raised alert %+v, want open, counted once and notified", raised)
+	}
+	msg := sink.expect(t, EventRaised)
+	if msg.Subject != "[parking_full] parking is full" {
+		t.Errorf("subject %q", msg.Subject)
+	}
+
+	// repeated occurrence is counted in the same alert and is not sent without repeat
+	repeated, err := engine.Raise(ctx, entities.Alert{Type: entities.AlertParkingFull, Key: "1", Message: "parking is still full"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repeated.Id != raised.Id || repeated.Count != 2 || repeated.Message != "parking is still full" {
+		t.Errorf("repeated alert %+v, want alert %d counted twice", repeated, raised.Id)
+	}
+	sink.expectNone(t)
+
+	if err = engine.Resolve(ctx, entities.AlertParkingFull, "1"); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := svc.GetAlert(ctx, raised.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Status != entities.AlertResolved || resolved.ResolvedAt == nil {
+		t.Errorf("alert %+v, want resolved", resolved)
+	}
+	sink.expect(t, EventResolved)
+
+	// the next occurrence opens new alert
+	next, err := engine.Raise(ctx, entities.Alert{Type: entities.AlertParkingFull, Key: "1", Message: "parking is full"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Id == raised.Id || next.Count != 1 {
+		t.Errorf("alert %+v after resolution, want new one", next)
+	}
+	sink.expect(t, EventRaised)
+}
+
+func TestRaiseRepeatsUnacknowledged(t *testing.T) {
+	ctx := context.Background()
+	engine, svc, sink := newEngine(t, entities.AlertRelayFailed, config.AlertRule{Repeat: time.Nanosecond})
+	alert := entities.Alert{Type: entities.AlertRelayFailed, Key: "M1", Message: "relay failed"}
+
+	raised, err := engine.Raise(ctx, alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.expect(t, EventRaised)
+	if _, err = engine.Raise(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+	sink.expect(t, EventRepeated)
+
+	// acknowledged alert is counted but not sent again
+	if _, err = svc.AcknowledgeAlert(ctx, raised.Id, 1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	acknowledged, err := engine.Raise(ctx, alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acknowledged.Count != 3 {
+		t.Errorf("alert is counted %d times, want 3", acknowledged.Count)
+	}
+	sink.expectNone(t)
+
+	// resolution is not sent without notify_resolved
+	if err = engine.Resolve(ctx, entities.AlertRelayFailed, "M1"); err != nil {
+		t.Fatal(err)
+	}
+	sink.expectNone(t)
+}
+
+func TestTypeWithoutRuleIsIgnored(t *testing.T) {
+	ctx := context.Background()
+	engine, svc, sink := newEngine(t, entities.AlertParkingFull, config.AlertRule{NotifyResolved: true})
+
+	raised, err := engine.Raise(ctx, entities.Alert{Type: entities.AlertRelayFailed, Key: "M1", Message: "relay failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raised != nil {
+		t.Errorf("alert %+v is raised for type without rule", raised)
+	}
+	unresolved, err := svc.UnresolvedAlerts(ctx, entities.AlertRelayFailed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unresolved) != 0 {
+		t.Errorf("%d alerts are stored for type without rule", len(unresolved))
+	}
+	sink.expectNone(t)
+}
+
+func TestGeofenceViolationFollowsGeofenceAlert(t *testing.T) {
+	ctx := context.Background()
+	engine, svc, sink := newEngine(t, entities.AlertGeofenceViolation, config.AlertRule{NotifyResolved: true})
+	detect := func(ctx context.Context, now time.Time) (map[string]entities.Alert, error) {
+		return geofenceViolations(ctx, svc)
+	}
+
+	if _, err := svc.InsertMachine(ctx, "M1", "127.0.0.1:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.InsertGeofenceAlert(ctx, entities.GeofenceAlert{MachineId: "M1", SiteId: 1,
+		Kind: entities.AlertForbiddenZone, Bssid: "aa:bb", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := engine.check(ctx, entities.AlertGeofenceViolation, detect); err != nil {
+		t.Fatal(err)
+	}
+	unresolved, err := svc.UnresolvedAlerts(ctx, entities.AlertGeofenceViolation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unresolved) != 1 || unresolved[0].Key != "M1" || unresolved[0].SiteId != 1 {
+		t.Fatalf("unresolved alerts %+v, want one of M1", unresolved)
+	}
+	sink.expect(t, EventRaised)
+
+	if _, err = svc.ResolveGeofenceAlerts(ctx, "M1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err = engine.check(ctx, entities.AlertGeofenceViolation, detect); err != nil {
+		t.Fatal(err)
+	}
+	if unresolved, err = svc.UnresolvedAlerts(ctx, entities.AlertGeofenceViolation); err != nil || len(unresolved) != 0 {
+		t.Errorf("unresolved alerts %+v after geofence alert is resolved, error %v", unresolved, err)
+	}
+	sink.expect(t, EventResolved)
+}
@@ -0,0 +1,210 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/pkg/errors"
+)
+
+// detector returns alerts for conditions of one type which occur now by their keys
+type detector func(ctx context.Context, now time.Time) (map[string]entities.Alert, error)
+
+// Monitor checks offline devices, stuck sessions, full parkings and geofence alerts every `alerts.check_interval` until ctx is done.
+// Alerts of conditions which are gone are resolved.
+func (e *Engine) Monitor(ctx context.Context, svc *service.Service, hb *health.Heartbeat) {
+	op := slog.String("op", "alerts.Monitor")
+
+	detectors := map[entities.AlertType]detector{
+		entities.AlertDeviceOffline: func(ctx context.Context, now time.Time) (map[string]entities.Alert, error) {
+			return offlineDevices(ctx, svc, now.Add(-e.cfg.Rules[entities.AlertDeviceOffline].After))
+		},
+		entities.AlertSessionStuck: func(ctx context.Context, now time.Time) (map[string]entities.Alert, error) {
+			return stuckSessions(ctx, svc, now.Add(-e.cfg.Rules[entities.AlertSessionStuck].After))
+		},
+		entities.AlertParkingFull: func(ctx context.Context, now time.Time) (map[string]entities.Alert, error) {
+			return fullParkings(ctx, svc)
+		},
+		entities.AlertGeofenceViolation: func(ctx context.Context, now time.Time) (map[string]entities.Alert, error) {
+			return geofenceViolations(ctx, svc)
+		},
+	}
+
+	ticker := time.NewTicker(e.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		for alertType, detect := range detectors {
+			if !e.Enabled(alertType) {
+				continue
+			}
+			if err := e.check(ctx, alertType, detect); err != nil && ctx.Err() == nil {
+				slog.Error("check alerts", op, slog.String("type", alertType), slog.String("error", err.Error()))
+			}
+		}
+		hb.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check raises alerts of conditions found by detector and resolves unresolved alerts of the type which are not found
+func (e *Engine) check(ctx context.Context, alertType entities.AlertType, detect detector) error {
+	found, err := detect(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range found {
+		alert.Type = alertType
+		if _, err = e.Raise(ctx, alert); err != nil {
+			return err
+		}
+	}
+
+	unresolved, err := e.store.UnresolvedAlerts(ctx, alertType)
+	if err != nil {
+		return errors.Wrap(err, "get unresolved alerts")
+	}
+	for _, alert := range unresolved {
+		if _, ok := found[alert.Key]; ok {
+			continue
+		}
+		if err = e.Resolve(ctx, alertType, alert.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offlineDevices finds machines with active sessions which did not report bssid since the time,
+// machines which did not report at all are offline if session started before it
+func offlineDevices(ctx context.Context, svc *service.Service, since time.Time) (map[string]entities.Alert, error) {
+	state := entities.SessionActive
+	sessions, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Session], error) {
+		return svc.ListSessions(ctx, entities.SessionFilter{ListParams: p, State: &state})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list active sessions")
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.MachineId)
+	}
+	last, err := svc.LastSightings(ctx, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "get last sightings")
+	}
+
+	found := make(map[string]entities.Alert)
+	for _, s := range sessions {
+		seen := s.DatetimeStart
+		if sighting, ok := last[s.MachineId]; ok && sighting.SeenAt.After(seen) {
+			seen = sighting.SeenAt
+		}
+		if !seen.Before(since) {
+			continue
+		}
+
+		machine, err := svc.GetMachineByID(ctx, s.MachineId)
+		if err != nil {
+			return nil, errors.Wrap(err, "get machine")
+		}
+		found[s.MachineId] = entities.Alert{
+			Key:     s.MachineId,
+			SiteId:  machine.SiteId,
+			Message: fmt.Sprintf("machine %s in use did not report since %s", s.MachineId, seen.UTC().Format(time.RFC3339)),
+		}
+	}
+	return found, nil
+}
+
+// stuckSessions finds active and paused sessions started before the time
+func stuckSessions(ctx context.Context, svc *service.Service, before time.Time) (map[string]entities.Alert, error) {
+	found := make(map[string]entities.Alert)
+	for _, state := range []entities.SessionState{entities.SessionActive, entities.SessionPause} {
+		sessions, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Session], error) {
+			return svc.ListSessions(ctx, entities.SessionFilter{ListParams: p, State: &state, To: before})
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "list unfinished sessions")
+		}
+
+		for _, s := range sessions {
+			machine, err := svc.GetMachineByID(ctx, s.MachineId)
+			if err != nil {
+				return nil, errors.Wrap(err, "get machine")
+			}
+
+			key := strconv.Itoa(s.Id)
+			found[key] = entities.Alert{
+				Key:    key,
+				SiteId: machine.SiteId,
+				Message: fmt.Sprintf("session %d of worker %d with machine %s is unfinished since %s", s.Id, s.WorkerId,
+					s.MachineId, s.DatetimeStart.UTC().Format(time.RFC3339)),
+			}
+		}
+	}
+	return found, nil
+}
+
+// fullParkings finds active parkings with limited capacity which have no free places
+func fullParkings(ctx context.Context, svc *service.Service) (map[string]entities.Alert, error) {
+	state := entities.ParkingActive
+	parkings, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Parking], error) {
+		return svc.ListParkings(ctx, entities.ParkingFilter{ListParams: p, State: &state})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list parkings")
+	}
+
+	found := make(map[string]entities.Alert)
+	for _, p := range parkings {
+		if p.Capacity == entities.UnlimitedCapacity || p.Machines < int(p.Capacity) {
+			continue
+		}
+
+		key := strconv.Itoa(p.Id)
+		found[key] = entities.Alert{
+			Key:     key,
+			SiteId:  p.SiteId,
+			Message: fmt.Sprintf("parking %s is full: %d of %d machines", p.Name, p.Machines, p.Capacity),
+		}
+	}
+	return found, nil
+}
+
+// geofenceViolations finds machines with unresolved geofence alerts, the alerts are raised and resolved by reports
+// of the machines, so there is the only store of violations
+func geofenceViolations(ctx context.Context, svc *service.Service) (map[string]entities.Alert, error) {
+	open := true
+	violations, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.GeofenceAlert], error) {
+		return svc.ListGeofenceAlerts(ctx, entities.GeofenceAlertFilter{ListParams: p, Open: &open})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list geofence alerts")
+	}
+
+	found := make(map[string]entities.Alert)
+	for _, v := range violations {
+		found[v.MachineId] = entities.Alert{
+			Key:    v.MachineId,
+			SiteId: v.SiteId,
+			Message: fmt.Sprintf("machine %s left permitted area: %s at %s since %s, geofence alert %d", v.MachineId, v.Kind,
+				v.Bssid, v.CreatedAt.UTC().Format(time.RFC3339), v.Id),
+		}
+	}
+	return found, nil
+}
@@ -1,40 +1,231 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/ecol-master/sharing-wh-machines/internal/alerts"
 	"github.com/ecol-master/sharing-wh-machines/internal/config"
 	"github.com/ecol-master/sharing-wh-machines/internal/dbs/postgres"
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/export"
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
 	"github.com/ecol-master/sharing-wh-machines/internal/http/handler"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/blob"
+	"github.com/ecol-master/sharing-wh-machines/internal/metrics"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/ecol-master/sharing-wh-machines/internal/sightings"
+	"github.com/ecol-master/sharing-wh-machines/internal/workorders"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 type App struct {
 	server *http.Server
 	cfg    *config.Config
+	health *health.Checker
 }
 
 func New(cfg *config.Config) *App {
 	return &App{
-		server: &http.Server{},
+		server: &http.Server{
+			Addr:              fmt.Sprintf("%s:%d", cfg.App.Addr, cfg.App.Port),
+			ReadTimeout:       cfg.App.ReadTimeout,
+			ReadHeaderTimeout: cfg.App.ReadHeaderTimeout,
+			WriteTimeout:      cfg.App.WriteTimeout,
+			IdleTimeout:       cfg.App.IdleTimeout,
+		},
 		cfg:    cfg,
+		health: health.New(),
 	}
 }
 
-// Function will panic if can not connect to db
+// Function will panic if storage, session export, photo storage or alert sinks can not be created
+// or interval of background worker is not positive.
+// Unreachable db does not stop the app, it is reported by /readyz.
+// Run returns after SIGINT or SIGTERM when in-flight requests are finished or app.shutdown_timeout is exceeded.
 func (a *App) Run() error {
-	db, err := postgres.New(a.cfg.Postgres)
+	stopCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// stops background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc, err := a.newService(ctx)
 	if err != nil {
-		panic(errors.Wrap(err, "failed to connect to postgres db"))
+		panic(err)
 	}
 
-	slog.Info("successfully connect to database")
-	handler := handler.New(db, a.cfg).MakeHTTPHandler()
+	prometheus.MustRegister(metrics.NewStateCollector(svc))
+
+	sessionLog, err := a.newSessionLog()
+	if err != nil {
+		panic(err)
+	}
+	defer sessionLog.Close()
+
+	photos, err := a.newPhotoStore()
+	if err != nil {
+		panic(err)
+	}
+
+	alertEngine, err := a.newAlertEngine(svc)
+	if err != nil {
+		panic(err)
+	}
+	go alertEngine.Dispatch(ctx)
+	go alertEngine.Monitor(ctx, svc, a.health.Worker("alerts_monitor", 3*a.cfg.Alerts.CheckInterval))
+
+	interval := a.cfg.Maintenance.CheckInterval
+	if interval <= 0 {
+		panic("maintenance.check_interval should be positive")
+	}
+	go workorders.Monitor(ctx, svc, interval, a.health.Worker("workorders_monitor", 3*interval))
+
+	retention, cleanup := a.cfg.Sightings.Retention, a.cfg.Sightings.CleanupInterval
+	if retention <= 0 || cleanup <= 0 {
+		panic("sightings.retention and sightings.cleanup_interval should be positive")
+	}
+	go sightings.Monitor(ctx, svc, retention, cleanup, a.health.Worker("sightings_cleanup", 3*cleanup))
+
+	a.server.Handler = handler.New(svc, a.cfg, sessionLog, a.health, photos, alertEngine).MakeHTTPHandler()
 	slog.Info("successfully initialize http handlers")
 
-	addr := fmt.Sprintf("%s:%d", a.cfg.App.Addr, a.cfg.App.Port)
-	slog.Info("staring app", slog.String("address", addr))
-	return http.ListenAndServe(addr, handler)
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("staring app", slog.String("address", a.server.Addr))
+		serveErr <- a.server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return errors.Wrap(err, "listen and serve")
+	case <-stopCtx.Done():
+	}
+
+	// unlocks and other requests in progress are finished, new connections are refused
+	slog.Info("shutting down, waiting for in-flight requests", slog.Duration("timeout", a.cfg.App.ShutdownTimeout))
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), a.cfg.App.ShutdownTimeout)
+	defer cancelShutdown()
+
+	if err := a.server.Shutdown(shutdownCtx); err != nil {
+		return errors.Wrap(err, "shutdown http server")
+	}
+	slog.Info("app is stopped")
+	return nil
+}
+
+// newSessionLog creates writer of finished sessions with formats from config
+func (a *App) newSessionLog() (*export.FileWriter, error) {
+	formats := make([]export.Format, 0, len(a.cfg.Export.Formats))
+	for _, f := range a.cfg.Export.Formats {
+		format, err := export.ParseFormat(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "export format %q", f)
+		}
+		formats = append(formats, format)
+	}
+
+	w, err := export.NewFileWriter(a.cfg.Export.Dir, formats)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create session export")
+	}
+	return w, nil
+}
+
+// newPhotoStore creates storage of incident photos selected in config
+func (a *App) newPhotoStore() (blob.Store, error) {
+	switch a.cfg.Incidents.PhotoStorage {
+	case config.PhotoStorageDisk, "":
+		store, err := blob.NewDisk(a.cfg.Incidents.PhotoDir)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create photo dir")
+		}
+		return store, nil
+
+	case config.PhotoStorageS3:
+		store, err := blob.NewS3(a.cfg.Incidents.S3, &http.Client{Timeout: 30 * time.Second})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create s3 photo storage")
+		}
+		return store, nil
+
+	default:
+		return nil, errors.Errorf("unknown photo storage %q", a.cfg.Incidents.PhotoStorage)
+	}
+}
+
+// newAlertEngine creates alert engine with rules and sinks from config, stdout sink writes to output of the app
+func (a *App) newAlertEngine(svc *service.Service) (*alerts.Engine, error) {
+	sinks, err := alerts.NewSinks(a.cfg.Alerts, os.Stdout, &http.Client{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create alert sinks")
+	}
+
+	engine, err := alerts.New(a.cfg.Alerts, svc, sinks)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid alert rules")
+	}
+	slog.Info("alerts are enabled", slog.Int("rules", len(a.cfg.Alerts.Rules)), slog.Int("sinks", len(sinks)))
+	return engine, nil
+}
+
+// newService creates service with storage selected in config and adds readiness checks of the storage
+func (a *App) newService(ctx context.Context) (*service.Service, error) {
+	switch a.cfg.App.Storage {
+	case config.StorageMemory:
+		users := make([]entities.User, 0, len(a.cfg.Demo.Users))
+		for _, u := range a.cfg.Demo.Users {
+			users = append(users, entities.User{
+				Name:        u.Name,
+				PhoneNumber: u.PhoneNumber,
+				JobPosition: u.JobPosition,
+				Password:    u.Password,
+			})
+		}
+
+		svc, err := service.NewInMemory(ctx, users)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create in-memory storage")
+		}
+
+		slog.Warn("app is running in demo mode with in-memory storage", slog.Int("users", len(users)))
+		return svc, nil
+
+	case config.StoragePostgres, "":
+		db, err := postgres.New(a.cfg.Postgres)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open postgres db")
+		}
+
+		timeout := a.cfg.Postgres.ConnTimeExceed
+		a.health.AddCheck("postgres", func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return db.PingContext(ctx)
+		})
+		a.health.AddCheck("schema", func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return postgres.CheckSchema(ctx, db)
+		})
+
+		interval := a.cfg.Postgres.PingInterval
+		go postgres.Monitor(ctx, db, interval, timeout, a.health.Worker("postgres_monitor", 2*interval+timeout))
+
+		slog.Info("successfully open database", slog.String("addr", a.cfg.Postgres.Addr))
+		prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB, a.cfg.Postgres.DB))
+		return service.New(db), nil
+
+	default:
+		return nil, errors.Errorf("unknown storage %q", a.cfg.App.Storage)
+	}
 }
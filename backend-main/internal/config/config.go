@@ -5,42 +5,175 @@ import (
 	"os"
 	"time"
 
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/blob"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/notify"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/pkg/errors"
 )
 
 type Config struct {
-	TokenTTL time.Duration `yaml:"token_ttl" env-required:"true"`
-	Secret   string        `yaml:"secret" env-required:"true"`
-	App      AppConfig
-	Postgres PostgresConfig
-	MC       MicrocontrollerConfig
-	Log      LogConfig
+	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
+	Secret      string        `yaml:"secret" env-required:"true"`
+	App         AppConfig
+	Postgres    PostgresConfig
+	MC          MicrocontrollerConfig
+	Log         LogConfig
+	Export      ExportConfig
+	Reports     ReportsConfig
+	Idempotency IdempotencyConfig
+	Login       LoginConfig
+	Incidents   IncidentsConfig
+	Maintenance MaintenanceConfig
+	Geofence    GeofenceConfig
+	Sightings   SightingsConfig
+	Alerts      AlertsConfig
+	Demo        DemoConfig
 }
 
 type AppConfig struct {
 	Port        uint16 `yaml:"port"`
 	Addr        string `yaml:"addr"`
 	MachineAddr string `yaml:"machine_local_addr"`
+	Storage     string `yaml:"storage" env-default:"postgres"`
+
+	// Timeouts of http server, WriteTimeout is not applied to session export
+	ReadTimeout       time.Duration `yaml:"read_timeout" env-default:"10s"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env-default:"5s"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env-default:"30s"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	// Time to finish in-flight requests after SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"15s"`
 }
 
+// Storage backends which can be selected with `app.storage`.
+// StorageMemory runs the app without postgres, all data is lost on restart.
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
 type PostgresConfig struct {
 	Addr           string        `yaml:"addr"`
 	Port           uint16        `yaml:"port"`
 	User           string        `yaml:"user"`
 	Password       string        `yaml:"password"`
 	DB             string        `yaml:"db"`
-	ConnTimeExceed time.Duration `yaml:"conn_time_exceed"`
+	ConnTimeExceed time.Duration `yaml:"conn_time_exceed" env-default:"3s"` // timeout of connect and ping
+	PingInterval   time.Duration `yaml:"ping_interval" env-default:"5s"`
 }
 
 type MicrocontrollerConfig struct {
 	RequestTimeout time.Duration `yaml:"request_timeout" env-required:"true"`
+	// DeviceSecret signs tokens of controllers, see middlewares.DeviceToken
+	DeviceSecret string `yaml:"device_secret" env:"DEVICE_SECRET" env-required:"true"`
 }
 
+// LogConfig describes json logs which are written to stdout and to file Dev in OutDir.
+// File is rotated after MaxSizeMB, MaxBackups previous files are kept.
 type LogConfig struct {
-	OutDir string `yaml:"out_dir"`
-	Dev    string `yaml:"dev"`
-	CSV    string `yaml:"csv"`
+	OutDir     string `yaml:"out_dir" env-default:"logs"`
+	Dev        string `yaml:"dev" env-default:"dev_logs.log"`
+	Level      string `yaml:"level" env-default:"info"` // debug, info, warn, error
+	MaxSizeMB  int    `yaml:"max_size_mb" env-default:"100"`
+	MaxBackups int    `yaml:"max_backups" env-default:"5"`
+}
+
+// ExportConfig describes files finished sessions are written to, one file per day and format
+type ExportConfig struct {
+	Dir     string   `yaml:"dir" env-default:"logs/sessions"`
+	Formats []string `yaml:"formats" env-default:"csv"` // csv, jsonl
+}
+
+type ReportsConfig struct {
+	// Default time zone of peak hours report, IANA name like "Europe/Moscow"
+	Timezone string `yaml:"timezone" env-default:"UTC"`
+}
+
+type IdempotencyConfig struct {
+	// Time the outcome of command is replayed for repeated Idempotency-Key
+	TTL time.Duration `yaml:"ttl" env-default:"24h"`
+}
+
+// LoginConfig limits login attempts to protect passwords from guessing.
+// Limits are per minute and kept in memory of the instance, failures and lockouts are stored in the database.
+type LoginConfig struct {
+	IPLimit       int           `yaml:"ip_limit" env-default:"20"`
+	PhoneLimit    int           `yaml:"phone_limit" env-default:"5"`
+	MaxFailures   int           `yaml:"max_failures" env-default:"5"` // failures within FailureWindow before lockout
+	FailureWindow time.Duration `yaml:"failure_window" env-default:"15m"`
+	Lockout       time.Duration `yaml:"lockout" env-default:"15m"`
+	// Header with address of the client set by reverse proxy, like X-Real-IP.
+	// Address of connection is used if it is empty, never set it without proxy.
+	ClientIPHeader string `yaml:"client_ip_header"`
+}
+
+// IncidentsConfig describes storage of photos attached to incidents
+type IncidentsConfig struct {
+	PhotoStorage string        `yaml:"photo_storage" env-default:"disk"` // disk | s3
+	PhotoDir     string        `yaml:"photo_dir" env-default:"logs/incidents"`
+	MaxPhotoMB   int           `yaml:"max_photo_mb" env-default:"10"`
+	S3           blob.S3Config `yaml:"s3"`
+}
+
+// Storages of incident photos which can be selected with `incidents.photo_storage`
+const (
+	PhotoStorageDisk = "disk"
+	PhotoStorageS3   = "s3"
+)
+
+// MaintenanceConfig describes background creation of work orders of machines which reached due hours of service
+type MaintenanceConfig struct {
+	CheckInterval time.Duration `yaml:"check_interval" env-default:"10m"`
+}
+
+// SightingsConfig describes how long reports of access points are kept,
+// older ones are deleted in background every CleanupInterval
+type SightingsConfig struct {
+	Retention       time.Duration `yaml:"retention" env-default:"720h"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1h"`
+}
+
+// GeofenceConfig describes reaction to machines in use which leave permitted area of their site
+type GeofenceConfig struct {
+	// Session is paused like with stop command when alert is raised, otherwise alert is only recorded
+	PauseOnViolation bool `yaml:"pause_on_violation" env-default:"false"`
+}
+
+// AlertsConfig describes alerts about devices, sessions and parkings and sinks they are sent to.
+// Alert type is enabled by its rule, alerts of types without rules are neither stored nor sent.
+type AlertsConfig struct {
+	Rules map[string]AlertRule `yaml:"rules"` // by alert type: device_offline, session_stuck, parking_full, relay_failed, geofence_violation
+	// Offline devices, stuck sessions and full parkings are checked with the interval
+	CheckInterval time.Duration `yaml:"check_interval" env-default:"1m"`
+	SendTimeout   time.Duration `yaml:"send_timeout" env-default:"10s"`
+	QueueSize     int           `yaml:"queue_size" env-default:"100"` // messages waiting for sinks, new ones are dropped when it is full
+
+	Webhook  notify.WebhookConfig  `yaml:"webhook"`
+	SMTP     notify.SMTPConfig     `yaml:"smtp"`
+	Telegram notify.TelegramConfig `yaml:"telegram"`
+}
+
+// AlertRule describes how alerts of one type are raised and sent
+type AlertRule struct {
+	Sinks []string `yaml:"sinks"` // stdout, webhook, smtp, telegram; alerts are only stored without sinks
+	// Device is offline if machine in use did not report bssid for After, session is stuck if it is unfinished for After
+	After time.Duration `yaml:"after"`
+	// Unacknowledged alert is sent again if it still occurs after Repeat, 0 means it is sent once
+	Repeat time.Duration `yaml:"repeat"`
+	// Resolution of alert which was sent is sent too
+	NotifyResolved bool `yaml:"notify_resolved"`
+}
+
+// DemoConfig describes data which is loaded into in-memory storage on startup
+type DemoConfig struct {
+	Users []DemoUser `yaml:"users"`
+}
+
+type DemoUser struct {
+	Name        string `yaml:"name"`
+	PhoneNumber string `yaml:"phone_number"`
+	JobPosition string `yaml:"job_position"`
+	Password    string `yaml:"password"`
 }
 
 // Function will panic if can not read config file or environment variables
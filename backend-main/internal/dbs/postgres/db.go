@@ -1,8 +1,8 @@
 package postgres
 
 import (
+	"context"
 	"fmt"
-	"time"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/config"
 	"github.com/jmoiron/sqlx"
@@ -10,26 +10,32 @@ import (
 	"github.com/pkg/errors"
 )
 
-func New(cfg config.PostgresConfig) (*sqlx.DB, error) {
-	dataSource := fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=disable", cfg.User, cfg.Password, cfg.Addr, cfg.Port, cfg.DB)
-
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+// SchemaVersion is version of assets/postgres/init.sql the app works with,
+// bump it together with the version inserted by init.sql and add migration with the same number
+const SchemaVersion = 14
 
-	timeoutExceed := time.After(cfg.ConnTimeExceed)
-	for {
-		select {
-		case <-timeoutExceed:
-			return nil, errors.New("")
-		case <-ticker.C:
+// New opens pool of connections to postgres. Connections are created lazily and recreated
+// after failures, so the app starts when db is unreachable and queries fail until it is up.
+func New(cfg config.PostgresConfig) (*sqlx.DB, error) {
+	dataSource := fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=disable connect_timeout=%d",
+		cfg.User, cfg.Password, cfg.Addr, cfg.Port, cfg.DB, max(int(cfg.ConnTimeExceed.Seconds()), 1))
 
-			if conn, err := sqlx.Connect("postgres", dataSource); err == nil {
-				if err = conn.Ping(); err == nil {
-					return conn, err
-				}
-			}
+	db, err := sqlx.Open("postgres", dataSource)
+	if err != nil {
+		return nil, errors.Wrap(err, "open postgres")
+	}
+	return db, nil
+}
 
-		}
+// CheckSchema returns error if schema of db is older than SchemaVersion
+func CheckSchema(ctx context.Context, db *sqlx.DB) error {
+	var version int
+	if err := db.GetContext(ctx, &version, `SELECT COALESCE(MAX(version), 0) FROM schema_version`); err != nil {
+		return errors.Wrap(err, "select schema version")
 	}
 
+	if version < SchemaVersion {
+		return errors.Errorf("schema version %d is older than %d, run cmd/migrate", version, SchemaVersion)
+	}
+	return nil
 }
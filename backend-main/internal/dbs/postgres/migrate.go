@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// migrations bring existing databases to SchemaVersion, file NNNN_name.sql upgrades schema to version NNNN.
+// Fresh databases get the latest schema from assets/postgres/init.sql.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type migration struct {
+	version int
+	name    string
+}
+
+// listMigrations returns migrations sorted by version
+func listMigrations() ([]migration, error) {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return nil, errors.Wrap(err, "list migrations")
+	}
+
+	list := make([]migration, 0, len(names))
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(path.Base(name), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, errors.Errorf("migration %s has no version prefix", name)
+		}
+		list = append(list, migration{version: version, name: name})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	return list, nil
+}
+
+// Migrate applies migrations newer than version of db, each one in transaction with the version bump.
+// Databases created before schema versions have no schema_version table and get all migrations.
+func Migrate(ctx context.Context, db *sqlx.DB) error {
+	op := slog.String("op", "postgres.Migrate")
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version(version integer NOT NULL)`); err != nil {
+		return errors.Wrap(err, "create schema_version table")
+	}
+
+	var current int
+	if err := db.GetContext(ctx, &current, `SELECT COALESCE(MAX(version), 0) FROM schema_version`); err != nil {
+		return errors.Wrap(err, "select schema version")
+	}
+
+	list, err := listMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range list {
+		if m.version <= current {
+			continue
+		}
+
+		if err := applyMigration(ctx, db, m); err != nil {
+			return errors.Wrapf(err, "apply migration %s", m.name)
+		}
+		slog.Info("migration applied", op, slog.String("migration", m.name), slog.Int("version", m.version))
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sqlx.DB, m migration) error {
+	query, err := migrations.ReadFile(m.name)
+	if err != nil {
+		return errors.Wrap(err, "read migration")
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, string(query)); err != nil {
+		return errors.Wrap(err, "exec migration")
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM schema_version`); err != nil {
+		return errors.Wrap(err, "delete schema version")
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES ($1)`, m.version); err != nil {
+		return errors.Wrap(err, "insert schema version")
+	}
+	return errors.Wrap(tx.Commit(), "commit migration")
+}
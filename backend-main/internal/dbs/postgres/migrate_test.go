@@ -0,0 +1,19 @@
+package postgres
+
+import "testing"
+
+func TestMigrationsReachSchemaVersion(t *testing.T) {
+	list, err := listMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range list {
+		if m.version != i+1 {
+			t.Fatalf("migration %s has version %d, want %d", m.name, m.version, i+1)
+		}
+	}
+	if len(list) == 0 || list[len(list)-1].version != SchemaVersion {
+		t.Errorf("last migration of %d does not bring schema to version %d", len(list), SchemaVersion)
+	}
+}
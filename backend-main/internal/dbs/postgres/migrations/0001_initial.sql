@@ -0,0 +1,76 @@
+-- Schema of assets/postgres/init.sql at version 1. Statements are idempotent, so the migration
+-- also brings databases created before schema versions to it.
+
+CREATE TABLE IF NOT EXISTS parkings(
+  id SERIAL,
+  name text NOT NULL UNIQUE,
+  mac_addr varchar(20) NOT NULL UNIQUE,
+  machines integer DEFAULT 0,
+  capacity integer DEFAULT 0,
+  state integer DEFAULT 1,
+
+  CHECK (state IN (0, 1)),
+  PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS machines(
+	id varchar(16) NOT NULL,
+	state integer DEFAULT 0,
+  parking_id integer DEFAULT 0,
+  voltage integer DEFAULT 0,
+  ip_addr varchar(22) NOT NULL,
+		
+	CHECK (state IN (0, 1, 2)),
+  CHECK (voltage >= 0),
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS users(
+  id SERIAL,
+  name text NOT NULL,
+	phone_number varchar(11) NOT NULL UNIQUE,
+	job_position varchar(8) NOT NULL,
+  password varchar (128) NOT NUll,
+
+	CHECK (job_position IN ('worker', 'admin')),
+  CHECK (LENGTH(password) >= 8),
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS sessions(
+  id SERIAL,
+  state integer DEFAULT 0,
+  machine_id varchar(16) NOT NULL,
+  worker_id integer NOT NULL,
+  datetime_start bigint NOT NULL,
+  datetime_finish bigint NOT NULL,
+  start_parking_id integer DEFAULT 0,
+  finish_parking_id integer DEFAULT 0,
+
+  CHECK (state IN (0, 1, 2)),
+
+  PRIMARY KEY (id),
+
+  FOREIGN KEY (machine_id) REFERENCES machines (id) ON DELETE CASCADE,
+  FOREIGN KEY (worker_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Parkings of sessions are used in reports, columns are added for databases created before them
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS start_parking_id integer DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS finish_parking_id integer DEFAULT 0;
+
+-- Indexes for filtered and sorted lists, id is the last column because it
+-- breaks ties of sort column in cursor pagination.
+CREATE INDEX IF NOT EXISTS sessions_datetime_start_idx ON sessions (datetime_start, id);
+CREATE INDEX IF NOT EXISTS sessions_datetime_finish_idx ON sessions (datetime_finish, id);
+CREATE INDEX IF NOT EXISTS sessions_state_datetime_start_idx ON sessions (state, datetime_start, id);
+CREATE INDEX IF NOT EXISTS sessions_worker_datetime_start_idx ON sessions (worker_id, datetime_start, id);
+CREATE INDEX IF NOT EXISTS sessions_machine_datetime_start_idx ON sessions (machine_id, datetime_start, id);
+
+CREATE INDEX IF NOT EXISTS machines_parking_idx ON machines (parking_id, id);
+CREATE INDEX IF NOT EXISTS machines_state_idx ON machines (state, id);
+
+CREATE INDEX IF NOT EXISTS users_name_idx ON users (name, id);
+CREATE INDEX IF NOT EXISTS users_job_position_idx ON users (job_position, id);
+CREATE INDEX IF NOT EXISTS sessions_start_parking_idx ON sessions (start_parking_id, datetime_start);
+CREATE INDEX IF NOT EXISTS sessions_finish_parking_idx ON sessions (finish_parking_id, datetime_finish);
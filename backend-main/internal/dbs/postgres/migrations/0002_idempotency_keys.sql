@@ -0,0 +1,14 @@
+-- Outcomes of commands sent with Idempotency-Key header, status is 0 while command is in progress
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+  user_id integer NOT NULL,
+  key varchar(128) NOT NULL,
+  request_hash varchar(64) NOT NULL,
+  status integer DEFAULT 0,
+  content_type text DEFAULT '',
+  body bytea,
+  created_at bigint NOT NULL,
+
+  PRIMARY KEY (user_id, key),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
@@ -0,0 +1,8 @@
+-- Failed logins by phone number, phone numbers of unknown users are counted too.
+-- Times are unix seconds, login is rejected until locked_until.
+CREATE TABLE IF NOT EXISTS login_failures(
+  phone_number varchar(11) PRIMARY KEY,
+  failures integer NOT NULL,
+  last_failure_at bigint NOT NULL,
+  locked_until bigint
+);
@@ -0,0 +1,54 @@
+-- Machine in maintenance can not be unlocked
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS maintenance boolean NOT NULL DEFAULT false;
+
+-- Job positions of users with sets of permissions, see entities.Permissions
+CREATE TABLE IF NOT EXISTS roles(
+  name varchar(32) PRIMARY KEY,
+  description text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions(
+  role varchar(32) NOT NULL,
+  permission varchar(64) NOT NULL,
+
+  PRIMARY KEY (role, permission),
+  FOREIGN KEY (role) REFERENCES roles (name) ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description) VALUES
+  ('admin', 'full access'),
+  ('worker', 'operates machines'),
+  ('supervisor', 'oversees workers, force-finishes sessions and views reports'),
+  ('technician', 'services machines')
+ON CONFLICT (name) DO NOTHING;
+
+-- Admin gets every permission on each run, other roles are seeded only while they have no permissions,
+-- so changes made by admin are kept
+INSERT INTO role_permissions (role, permission) VALUES
+  ('admin', 'users.read'), ('admin', 'users.manage'),
+  ('admin', 'machines.read'), ('admin', 'machines.manage'), ('admin', 'machines.maintenance'),
+  ('admin', 'parkings.read'), ('admin', 'parkings.manage'),
+  ('admin', 'sessions.read'), ('admin', 'sessions.use'), ('admin', 'sessions.force'),
+  ('admin', 'reports.read')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT d.role, d.permission FROM (VALUES
+  ('worker', 'sessions.use'),
+  ('supervisor', 'users.read'), ('supervisor', 'machines.read'), ('supervisor', 'parkings.read'),
+  ('supervisor', 'sessions.read'), ('supervisor', 'sessions.use'), ('supervisor', 'sessions.force'),
+  ('supervisor', 'reports.read'),
+  ('technician', 'machines.read'), ('technician', 'machines.maintenance'), ('technician', 'parkings.read'),
+  ('technician', 'sessions.use')
+) AS d (role, permission)
+WHERE NOT EXISTS (SELECT 1 FROM role_permissions p WHERE p.role = d.role);
+
+-- Databases created before roles had fixed job positions
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_job_position_check;
+ALTER TABLE users ALTER COLUMN job_position TYPE varchar(32);
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_job_position_fkey') THEN
+    ALTER TABLE users ADD CONSTRAINT users_job_position_fkey FOREIGN KEY (job_position) REFERENCES roles (name);
+  END IF;
+END $$;
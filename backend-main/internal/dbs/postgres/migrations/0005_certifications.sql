@@ -0,0 +1,26 @@
+-- Models of machines, workers need valid certification of the type to unlock its machines.
+-- Machines with type_id 0 have no type and need no certification.
+CREATE TABLE IF NOT EXISTS machine_types(
+  id SERIAL PRIMARY KEY,
+  name varchar(64) NOT NULL UNIQUE,
+  description text NOT NULL DEFAULT ''
+);
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS type_id integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS machines_type_idx ON machines (type_id, id);
+
+-- Certifications of users, times are unix seconds
+CREATE TABLE IF NOT EXISTS certifications(
+  id SERIAL PRIMARY KEY,
+  user_id integer NOT NULL,
+  type_id integer NOT NULL,
+  number varchar(64) NOT NULL DEFAULT '',
+  issued_at bigint NOT NULL,
+  expires_at bigint NOT NULL,
+
+  CHECK (expires_at > issued_at),
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (type_id) REFERENCES machine_types (id)
+);
+CREATE INDEX IF NOT EXISTS certifications_user_type_idx ON certifications (user_id, type_id);
+CREATE INDEX IF NOT EXISTS certifications_expires_at_idx ON certifications (expires_at);
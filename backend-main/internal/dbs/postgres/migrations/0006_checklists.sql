@@ -0,0 +1,40 @@
+-- Pre-use checklists of machine types, items are replaced as a whole when checklist is changed
+CREATE TABLE IF NOT EXISTS checklist_items(
+  id SERIAL PRIMARY KEY,
+  type_id integer NOT NULL,
+  position integer NOT NULL,
+  text text NOT NULL,
+  critical boolean NOT NULL DEFAULT false,
+
+  FOREIGN KEY (type_id) REFERENCES machine_types (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS checklist_items_type_idx ON checklist_items (type_id, position);
+
+-- Checklists filled before unlock, session_id is 0 when unlock was refused.
+-- Answers keep text of items, because items may be changed after submission.
+CREATE TABLE IF NOT EXISTS checklist_submissions(
+  id SERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL,
+  user_id integer NOT NULL,
+  session_id integer NOT NULL DEFAULT 0,
+  passed boolean NOT NULL,
+  submitted_at bigint NOT NULL,
+
+  FOREIGN KEY (machine_id) REFERENCES machines (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS checklist_submissions_machine_idx ON checklist_submissions (machine_id, id);
+CREATE INDEX IF NOT EXISTS checklist_submissions_user_idx ON checklist_submissions (user_id, id);
+CREATE INDEX IF NOT EXISTS checklist_submissions_session_idx ON checklist_submissions (session_id);
+
+CREATE TABLE IF NOT EXISTS checklist_answers(
+  submission_id integer NOT NULL,
+  item_id integer NOT NULL,
+  text text NOT NULL,
+  critical boolean NOT NULL,
+  ok boolean NOT NULL,
+  comment text NOT NULL DEFAULT '',
+
+  PRIMARY KEY (submission_id, item_id),
+  FOREIGN KEY (submission_id) REFERENCES checklist_submissions (id) ON DELETE CASCADE
+);
@@ -0,0 +1,36 @@
+-- New permissions are granted to roles which get them in init.sql, admin could not remove them before they existed
+INSERT INTO role_permissions (role, permission)
+SELECT r.name, p.permission FROM roles r, (VALUES ('incidents.read'), ('incidents.manage')) AS p (permission)
+WHERE r.name IN ('admin', 'supervisor', 'technician')
+ON CONFLICT DO NOTHING;
+
+-- Problems with machines reported by workers, session_id is 0 if reporter had no session with the machine.
+-- Photo is kept in storage from `incidents` config under photo_key. Times are unix seconds.
+CREATE TABLE IF NOT EXISTS incidents(
+  id SERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL,
+  session_id integer NOT NULL DEFAULT 0,
+  reporter_id integer NOT NULL,
+  category varchar(16) NOT NULL,
+  severity varchar(16) NOT NULL,
+  description text NOT NULL DEFAULT '',
+  status varchar(16) NOT NULL DEFAULT 'open',
+  resolution text NOT NULL DEFAULT '',
+  triaged_by integer NOT NULL DEFAULT 0,
+  photo_key text NOT NULL DEFAULT '',
+  photo_content_type varchar(32) NOT NULL DEFAULT '',
+  created_at bigint NOT NULL,
+  updated_at bigint NOT NULL,
+  closed_at bigint,
+
+  CHECK (category IN ('damage', 'malfunction', 'accident', 'near_miss', 'other')),
+  CHECK (severity IN ('low', 'medium', 'high', 'critical')),
+  CHECK (status IN ('open', 'acknowledged', 'resolved', 'rejected')),
+
+  FOREIGN KEY (machine_id) REFERENCES machines (id) ON DELETE CASCADE,
+  FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS incidents_status_idx ON incidents (status, id);
+CREATE INDEX IF NOT EXISTS incidents_machine_idx ON incidents (machine_id, id);
+CREATE INDEX IF NOT EXISTS incidents_created_at_idx ON incidents (created_at, id);
+CREATE INDEX IF NOT EXISTS incidents_updated_at_idx ON incidents (updated_at, id);
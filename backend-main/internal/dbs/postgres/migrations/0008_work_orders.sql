@@ -0,0 +1,42 @@
+-- Service intervals of machine types: service is due every every_hours of operating time,
+-- machine can be used grace_hours more before unlock is refused.
+CREATE TABLE IF NOT EXISTS service_intervals(
+  id SERIAL PRIMARY KEY,
+  type_id integer NOT NULL,
+  name varchar(128) NOT NULL,
+  every_hours integer NOT NULL,
+  grace_hours integer NOT NULL DEFAULT 0,
+
+  CHECK (every_hours > 0),
+  CHECK (grace_hours >= 0),
+
+  FOREIGN KEY (type_id) REFERENCES machine_types (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS service_intervals_type_idx ON service_intervals (type_id, id);
+
+-- Work orders created when service of the machine is due. Hours are operating hours of the machine
+-- (sum of its sessions), interval_id is NULL after interval is deleted. Times are unix seconds.
+CREATE TABLE IF NOT EXISTS work_orders(
+  id SERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL,
+  interval_id integer,
+  name varchar(128) NOT NULL,
+  due_hours double precision NOT NULL,
+  overdue_hours double precision NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'open',
+  notes text NOT NULL DEFAULT '',
+  created_at bigint NOT NULL,
+  closed_at bigint,
+  closed_by integer NOT NULL DEFAULT 0,
+  closed_at_hours double precision NOT NULL DEFAULT 0,
+
+  CHECK (status IN ('open', 'closed')),
+
+  FOREIGN KEY (machine_id) REFERENCES machines (id) ON DELETE CASCADE,
+  FOREIGN KEY (interval_id) REFERENCES service_intervals (id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS work_orders_machine_idx ON work_orders (machine_id, id);
+CREATE INDEX IF NOT EXISTS work_orders_status_idx ON work_orders (status, id);
+CREATE INDEX IF NOT EXISTS work_orders_created_at_idx ON work_orders (created_at, id);
+-- one open order per interval of the machine
+CREATE UNIQUE INDEX IF NOT EXISTS work_orders_open_idx ON work_orders (machine_id, interval_id) WHERE status = 'open';
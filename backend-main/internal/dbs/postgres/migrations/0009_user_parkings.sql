@@ -0,0 +1,9 @@
+-- Parkings where the user is allowed to unlock and return machines, user without rows may use any parking
+CREATE TABLE IF NOT EXISTS user_parkings(
+  user_id integer NOT NULL,
+  parking_id integer NOT NULL,
+
+  PRIMARY KEY (user_id, parking_id),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (parking_id) REFERENCES parkings (id) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+-- Shifts are time windows "HH:MM" in time zone on days of week (0 is Sunday), shift ends next day if end_time <= start_time
+CREATE TABLE IF NOT EXISTS shifts(
+  id SERIAL PRIMARY KEY,
+  name varchar(64) NOT NULL UNIQUE,
+  start_time varchar(5) NOT NULL,
+  end_time varchar(5) NOT NULL,
+  days integer[] NOT NULL,
+  timezone varchar(64) NOT NULL DEFAULT 'UTC'
+);
+
+-- Shifts of the user, user without shifts may unlock machines at any time
+CREATE TABLE IF NOT EXISTS user_shifts(
+  user_id integer NOT NULL,
+  shift_id integer NOT NULL,
+
+  PRIMARY KEY (user_id, shift_id),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (shift_id) REFERENCES shifts (id) ON DELETE CASCADE
+);
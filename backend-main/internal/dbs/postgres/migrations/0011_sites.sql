@@ -0,0 +1,32 @@
+INSERT INTO roles (name, description) VALUES ('site_admin', 'full access within own site') ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'sites.manage') ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'site_admin', p.permission FROM (VALUES
+  ('users.read'), ('users.manage'),
+  ('machines.read'), ('machines.manage'), ('machines.maintenance'),
+  ('parkings.read'), ('parkings.manage'),
+  ('sessions.read'), ('sessions.use'), ('sessions.force'),
+  ('incidents.read'), ('incidents.manage'),
+  ('reports.read')
+) AS p (permission)
+WHERE NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role = 'site_admin');
+
+-- Sites are warehouses, parkings, machines and users belong to one site.
+-- Data created before sites belongs to the default site.
+CREATE TABLE IF NOT EXISTS sites(
+  id SERIAL PRIMARY KEY,
+  name varchar(64) NOT NULL UNIQUE
+);
+
+INSERT INTO sites (id, name) VALUES (1, 'default') ON CONFLICT DO NOTHING;
+SELECT setval(pg_get_serial_sequence('sites', 'id'), (SELECT MAX(id) FROM sites));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS site_id integer NOT NULL DEFAULT 1 REFERENCES sites (id);
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS site_id integer NOT NULL DEFAULT 1 REFERENCES sites (id);
+ALTER TABLE parkings ADD COLUMN IF NOT EXISTS site_id integer NOT NULL DEFAULT 1 REFERENCES sites (id);
+
+CREATE INDEX IF NOT EXISTS users_site_idx ON users (site_id, id);
+CREATE INDEX IF NOT EXISTS machines_site_idx ON machines (site_id, id);
+CREATE INDEX IF NOT EXISTS parkings_site_idx ON parkings (site_id, id);
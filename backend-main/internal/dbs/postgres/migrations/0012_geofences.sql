@@ -0,0 +1,35 @@
+-- Geofences are areas of the site described by BSSIDs of access points, kind is allowed or forbidden
+CREATE TABLE IF NOT EXISTS geofences(
+  id SERIAL PRIMARY KEY,
+  name varchar(64) NOT NULL,
+  kind varchar(16) NOT NULL,
+  bssids varchar(64)[] NOT NULL,
+  site_id integer NOT NULL REFERENCES sites (id),
+
+  CHECK (kind IN ('allowed', 'forbidden'))
+);
+
+CREATE INDEX IF NOT EXISTS geofences_site_idx ON geofences (site_id, id);
+
+-- Alerts raised when machine in use leaves permitted area, geofence_id is 0 for alerts outside allowed geofences
+CREATE TABLE IF NOT EXISTS geofence_alerts(
+  id SERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL REFERENCES machines (id) ON DELETE CASCADE,
+  session_id integer NOT NULL DEFAULT 0,
+  worker_id integer NOT NULL DEFAULT 0,
+  site_id integer NOT NULL,
+  geofence_id integer NOT NULL DEFAULT 0,
+  kind varchar(32) NOT NULL,
+  bssid varchar(64) NOT NULL,
+  paused boolean NOT NULL DEFAULT false,
+  created_at bigint NOT NULL,
+  resolved_at bigint
+);
+
+CREATE INDEX IF NOT EXISTS geofence_alerts_machine_idx ON geofence_alerts (machine_id, id);
+CREATE INDEX IF NOT EXISTS geofence_alerts_created_at_idx ON geofence_alerts (created_at, id);
+-- one unresolved alert per machine
+CREATE UNIQUE INDEX IF NOT EXISTS geofence_alerts_open_idx ON geofence_alerts (machine_id) WHERE resolved_at IS NULL;
+
+-- BSSIDs are compared in lower case, parkings created before keep mac_addr as it was entered
+UPDATE parkings SET mac_addr = lower(mac_addr) WHERE mac_addr <> lower(mac_addr);
@@ -0,0 +1,16 @@
+-- Access points seen by controllers of machines, parking_id and geofence_id are 0 if bssid was not at any of them
+CREATE TABLE IF NOT EXISTS machine_sightings(
+  id BIGSERIAL PRIMARY KEY,
+  machine_id varchar(16) NOT NULL REFERENCES machines (id) ON DELETE CASCADE,
+  session_id integer NOT NULL DEFAULT 0,
+  bssid varchar(64) NOT NULL,
+  rssi integer NOT NULL DEFAULT 0,
+  parking_id integer NOT NULL DEFAULT 0,
+  geofence_id integer NOT NULL DEFAULT 0,
+  seen_at bigint NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS machine_sightings_machine_idx ON machine_sightings (machine_id, id);
+CREATE INDEX IF NOT EXISTS machine_sightings_session_idx ON machine_sightings (session_id, id) WHERE session_id <> 0;
+-- Sightings older than `sightings.retention` are deleted in background
+CREATE INDEX IF NOT EXISTS machine_sightings_seen_idx ON machine_sightings (seen_at);
@@ -0,0 +1,29 @@
+-- New permissions are granted to roles which get them in init.sql, admin could not remove them before they existed
+INSERT INTO role_permissions (role, permission)
+SELECT r.name, p.permission FROM roles r, (VALUES ('alerts.read'), ('alerts.manage')) AS p (permission)
+WHERE r.name IN ('admin', 'supervisor', 'site_admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES ('technician', 'alerts.read')
+ON CONFLICT DO NOTHING;
+
+-- Alerts about devices, sessions and parkings, key is id of machine, session or parking the alert is about
+CREATE TABLE IF NOT EXISTS alerts(
+  id SERIAL PRIMARY KEY,
+  type varchar(32) NOT NULL,
+  key varchar(64) NOT NULL,
+  site_id integer NOT NULL DEFAULT 0,
+  message text NOT NULL,
+  status varchar(16) NOT NULL DEFAULT 'open',
+  count integer NOT NULL DEFAULT 1,
+  created_at bigint NOT NULL,
+  last_seen_at bigint NOT NULL,
+  notified_at bigint,
+  acknowledged_by integer NOT NULL DEFAULT 0,
+  acknowledged_at bigint,
+  resolved_at bigint
+);
+
+CREATE INDEX IF NOT EXISTS alerts_created_at_idx ON alerts (created_at, id);
+-- one unresolved alert per subject, repeated occurrences are counted in it
+CREATE UNIQUE INDEX IF NOT EXISTS alerts_open_idx ON alerts (type, key) WHERE resolved_at IS NULL;
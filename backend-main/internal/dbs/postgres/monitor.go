@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
+	"github.com/jmoiron/sqlx"
+)
+
+// Monitor pings db every interval until ctx is done and logs when db goes down and up again.
+// Ping also restores connections of the pool before requests need them.
+func Monitor(ctx context.Context, db *sqlx.DB, interval, timeout time.Duration, hb *health.Heartbeat) {
+	op := slog.String("op", "postgres.Monitor")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	up := true
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := db.PingContext(pingCtx)
+		cancel()
+		hb.Beat()
+
+		switch {
+		case err != nil && up && ctx.Err() == nil:
+			slog.Error("database is unreachable", op, slog.String("error", err.Error()))
+		case err == nil && !up:
+			slog.Info("database connection is restored", op)
+		}
+		up = err == nil
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
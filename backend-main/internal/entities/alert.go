@@ -0,0 +1,58 @@
+package entities
+
+import "time"
+
+type (
+	AlertType   = string
+	AlertStatus = string
+)
+
+// Events alerts are raised for, each type is enabled by its rule in `alerts.rules`
+const (
+	AlertDeviceOffline     = AlertType("device_offline")     // controller of machine in use stopped reporting
+	AlertSessionStuck      = AlertType("session_stuck")      // session is unfinished for too long
+	AlertParkingFull       = AlertType("parking_full")       // parking has no free places
+	AlertRelayFailed       = AlertType("relay_failed")       // command was not delivered to controller
+	AlertGeofenceViolation = AlertType("geofence_violation") // machine in use left permitted area
+)
+
+// Alert goes open -> acknowledged -> resolved, it is resolved when the condition is gone even if nobody acknowledged it
+const (
+	AlertOpen         = AlertStatus("open")
+	AlertAcknowledged = AlertStatus("acknowledged")
+	AlertResolved     = AlertStatus("resolved")
+)
+
+var (
+	AlertTypes    = []AlertType{AlertDeviceOffline, AlertSessionStuck, AlertParkingFull, AlertRelayFailed, AlertGeofenceViolation}
+	AlertStatuses = []AlertStatus{AlertOpen, AlertAcknowledged, AlertResolved}
+)
+
+// Alert is a condition which needs attention. Key identifies subject of the condition within the type
+// (machine, session or parking id), there is at most one unresolved alert with type and key:
+// repeated occurrences increase Count of it instead of new notifications.
+type Alert struct {
+	Id             int         `json:"id"`
+	Type           AlertType   `json:"type"`
+	Key            string      `json:"key"`
+	SiteId         int         `json:"site_id"`
+	Message        string      `json:"message"`
+	Status         AlertStatus `json:"status"`
+	Count          int         `json:"count"`
+	CreatedAt      time.Time   `json:"created_at"`
+	LastSeenAt     time.Time   `json:"last_seen_at"`
+	NotifiedAt     *time.Time  `json:"notified_at"` // last time alert was sent to sinks, nil if it was not sent
+	AcknowledgedBy int         `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time  `json:"acknowledged_at"`
+	ResolvedAt     *time.Time  `json:"resolved_at"`
+}
+
+// AlertFilter filters alerts, zero values mean no filter
+type AlertFilter struct {
+	ListParams
+	Type   AlertType
+	Key    string
+	Status AlertStatus
+	SiteId int
+	Open   *bool // unresolved alerts, both open and acknowledged
+}
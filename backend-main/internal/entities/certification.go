@@ -0,0 +1,49 @@
+package entities
+
+import "time"
+
+// MachineType is model of machines like forklift or reach truck, workers need certification to use it
+type MachineType struct {
+	Id          int    `db:"id" json:"id"`
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+}
+
+// Certification is licence of the user to operate machines of the type
+type Certification struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id"`
+	TypeId    int       `json:"machine_type_id"`
+	Number    string    `json:"number"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (c Certification) Valid(now time.Time) bool {
+	return !now.Before(c.IssuedAt) && now.Before(c.ExpiresAt)
+}
+
+// CertificationFilter filters certifications, zero fields match any value.
+// SiteId matches certifications of users of the site.
+type CertificationFilter struct {
+	UserId int
+	TypeId int
+	SiteId int
+}
+
+// ExpiringCertification is certification which expires within report range, DaysLeft is negative for expired ones
+type ExpiringCertification struct {
+	CertificationId int       `json:"certification_id"`
+	UserId          int       `json:"user_id"`
+	UserName        string    `json:"user_name"`
+	TypeId          int       `json:"machine_type_id"`
+	TypeName        string    `json:"machine_type"`
+	Number          string    `json:"number"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	DaysLeft        int       `json:"days_left"`
+}
+
+// DaysUntil returns whole days left until t, it is negative when t is passed
+func DaysUntil(now, t time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
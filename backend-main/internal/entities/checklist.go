@@ -0,0 +1,55 @@
+package entities
+
+import "time"
+
+// ChecklistItem is a point of pre-use inspection of machines of the type.
+// Unlock is refused and machine is put into maintenance when critical item fails.
+type ChecklistItem struct {
+	Id       int    `json:"id"`
+	TypeId   int    `json:"machine_type_id"`
+	Position int    `json:"position"`
+	Text     string `json:"text"`
+	Critical bool   `json:"critical"`
+}
+
+// ChecklistAnswer is answer of the worker to checklist item.
+// Text and Critical are copied from the item, so answers stay readable after checklist is changed.
+type ChecklistAnswer struct {
+	ItemId   int    `json:"item_id"`
+	Text     string `json:"text"`
+	Critical bool   `json:"critical"`
+	Ok       bool   `json:"ok"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// ChecklistSubmission is checklist filled before unlock, SessionId is 0 when unlock was refused
+type ChecklistSubmission struct {
+	Id          int               `json:"id"`
+	MachineId   string            `json:"machine_id"`
+	UserId      int               `json:"user_id"`
+	SessionId   int               `json:"session_id"`
+	Passed      bool              `json:"passed"`
+	SubmittedAt time.Time         `json:"submitted_at"`
+	Answers     []ChecklistAnswer `json:"answers"`
+}
+
+// FailedCritical returns critical items answered not ok
+func (s ChecklistSubmission) FailedCritical() []ChecklistAnswer {
+	failed := make([]ChecklistAnswer, 0)
+	for _, a := range s.Answers {
+		if a.Critical && !a.Ok {
+			failed = append(failed, a)
+		}
+	}
+	return failed
+}
+
+// ChecklistSubmissionFilter filters submissions, zero values mean no filter.
+// SiteId matches submissions of machines which are currently at the site.
+type ChecklistSubmissionFilter struct {
+	ListParams
+	MachineId string
+	UserId    int
+	SessionId int
+	SiteId    int
+}
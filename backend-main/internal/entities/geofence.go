@@ -0,0 +1,110 @@
+package entities
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	GeofenceKind      = string
+	GeofenceAlertKind = string
+)
+
+// Machine in use should stay within allowed geofences of its site, if the site has any, and out of forbidden ones
+const (
+	GeofenceAllowed   = GeofenceKind("allowed")
+	GeofenceForbidden = GeofenceKind("forbidden")
+)
+
+const (
+	AlertForbiddenZone  = GeofenceAlertKind("forbidden_zone")
+	AlertOutsideAllowed = GeofenceAlertKind("outside_allowed")
+)
+
+var GeofenceKinds = []GeofenceKind{GeofenceAllowed, GeofenceForbidden}
+
+// Geofence is an area of the site described by BSSIDs of access points seen by controllers in it
+type Geofence struct {
+	Id     int          `json:"id"`
+	Name   string       `json:"name"`
+	Kind   GeofenceKind `json:"kind"`
+	Bssids []string     `json:"bssids"`
+	SiteId int          `json:"site_id"`
+}
+
+// Validate checks kind and BSSIDs of the geofence
+func (g Geofence) Validate() error {
+	if g.Kind != GeofenceAllowed && g.Kind != GeofenceForbidden {
+		return errors.Errorf("unknown kind %q", g.Kind)
+	}
+	if len(g.Bssids) == 0 {
+		return errors.New("geofence should have at least one bssid")
+	}
+	for _, b := range g.Bssids {
+		if strings.TrimSpace(b) == "" {
+			return errors.New("bssid should not be empty")
+		}
+	}
+	return nil
+}
+
+// NormalizeBssid brings BSSID to the form it is stored and compared in, so reports of controllers match
+// geofences and parkings whatever case they use
+func NormalizeBssid(bssid string) string {
+	return strings.ToLower(strings.TrimSpace(bssid))
+}
+
+// Contains reports if access point is in the geofence, bssid should be normalized
+func (g Geofence) Contains(bssid string) bool {
+	return slices.Contains(g.Bssids, bssid)
+}
+
+// CheckGeofences returns kind of violation and the geofence which is violated (nil for outside allowed ones),
+// empty kind means machine at bssid is within permitted area. Parkings of the site are always permitted.
+func CheckGeofences(geofences []Geofence, bssid string, atParking bool) (GeofenceAlertKind, *Geofence) {
+	hasAllowed, allowed := false, false
+	for i, g := range geofences {
+		switch g.Kind {
+		case GeofenceForbidden:
+			if g.Contains(bssid) {
+				return AlertForbiddenZone, &geofences[i]
+			}
+		case GeofenceAllowed:
+			hasAllowed = true
+			allowed = allowed || g.Contains(bssid)
+		}
+	}
+
+	if hasAllowed && !allowed && !atParking {
+		return AlertOutsideAllowed, nil
+	}
+	return "", nil
+}
+
+// GeofenceAlert is raised when machine in use leaves permitted area, it is resolved when machine returns.
+// Machine has at most one unresolved alert, GeofenceId is 0 for alerts outside allowed geofences.
+type GeofenceAlert struct {
+	Id         int               `json:"id"`
+	MachineId  string            `json:"machine_id"`
+	SessionId  int               `json:"session_id"`
+	WorkerId   int               `json:"worker_id"`
+	SiteId     int               `json:"site_id"`
+	GeofenceId int               `json:"geofence_id"`
+	Kind       GeofenceAlertKind `json:"kind"`
+	Bssid      string            `json:"bssid"`
+	Paused     bool              `json:"paused"` // session was paused because of the alert
+	CreatedAt  time.Time         `json:"created_at"`
+	ResolvedAt *time.Time        `json:"resolved_at"`
+}
+
+// GeofenceAlertFilter filters alerts, zero values mean no filter
+type GeofenceAlertFilter struct {
+	ListParams
+	MachineId string
+	SessionId int
+	SiteId    int
+	Open      *bool
+}
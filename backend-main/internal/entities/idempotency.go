@@ -0,0 +1,19 @@
+package entities
+
+import "time"
+
+// IdempotencyRecord is outcome of command sent with Idempotency-Key header.
+// Status is 0 while the command is in progress.
+type IdempotencyRecord struct {
+	UserId      int       `db:"user_id"`
+	Key         string    `db:"key"`
+	RequestHash string    `db:"request_hash"`
+	Status      int       `db:"status"`
+	ContentType string    `db:"content_type"`
+	Body        []byte    `db:"body"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (r IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
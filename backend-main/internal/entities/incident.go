@@ -0,0 +1,74 @@
+package entities
+
+import "time"
+
+type (
+	IncidentCategory = string
+	IncidentSeverity = string
+	IncidentStatus   = string
+)
+
+const (
+	IncidentDamage      = IncidentCategory("damage")
+	IncidentMalfunction = IncidentCategory("malfunction")
+	IncidentAccident    = IncidentCategory("accident")
+	IncidentNearMiss    = IncidentCategory("near_miss")
+	IncidentOther       = IncidentCategory("other")
+)
+
+const (
+	SeverityLow      = IncidentSeverity("low")
+	SeverityMedium   = IncidentSeverity("medium")
+	SeverityHigh     = IncidentSeverity("high")
+	SeverityCritical = IncidentSeverity("critical") // machine is put into maintenance
+)
+
+// Incident goes open -> acknowledged -> resolved or rejected, it can be closed without acknowledgement
+const (
+	IncidentOpen         = IncidentStatus("open")
+	IncidentAcknowledged = IncidentStatus("acknowledged")
+	IncidentResolved     = IncidentStatus("resolved")
+	IncidentRejected     = IncidentStatus("rejected")
+)
+
+var (
+	IncidentCategories = []IncidentCategory{IncidentDamage, IncidentMalfunction, IncidentAccident, IncidentNearMiss, IncidentOther}
+	IncidentSeverities = []IncidentSeverity{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+	IncidentStatuses   = []IncidentStatus{IncidentOpen, IncidentAcknowledged, IncidentResolved, IncidentRejected}
+)
+
+// Incident is problem with machine reported by worker, SessionId is 0 if reporter had no session with the machine.
+// Photo is kept in blob storage under PhotoKey.
+type Incident struct {
+	Id               int              `json:"id"`
+	MachineId        string           `json:"machine_id"`
+	SessionId        int              `json:"session_id"`
+	ReporterId       int              `json:"reporter_id"`
+	Category         IncidentCategory `json:"category"`
+	Severity         IncidentSeverity `json:"severity"`
+	Description      string           `json:"description"`
+	Status           IncidentStatus   `json:"status"`
+	Resolution       string           `json:"resolution"`
+	TriagedBy        int              `json:"triaged_by"` // user who changed status last time, 0 while incident is new
+	PhotoKey         string           `json:"-"`
+	PhotoContentType string           `json:"-"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	ClosedAt         *time.Time       `json:"closed_at"`
+}
+
+func (i Incident) Closed() bool {
+	return i.Status == IncidentResolved || i.Status == IncidentRejected
+}
+
+// IncidentFilter filters incidents, zero values mean no filter.
+// SiteId matches incidents of machines which are currently at the site.
+type IncidentFilter struct {
+	ListParams
+	MachineId  string
+	ReporterId int
+	Status     IncidentStatus
+	Severity   IncidentSeverity
+	Category   IncidentCategory
+	SiteId     int
+}
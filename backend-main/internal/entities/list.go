@@ -0,0 +1,76 @@
+package entities
+
+import "time"
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// ListParams describes which page of list is requested.
+// Cursor is opaque string returned as NextCursor of previous page.
+type ListParams struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Desc   bool
+}
+
+// Page is a part of list, NextCursor is empty for the last page
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Fields lists can be sorted by, `id` is the default for every list
+var (
+	UserSorts    = []string{"id", "name"}
+	MachineSorts = []string{"id", "state", "parking_id"}
+	ParkingSorts = []string{"id", "name", "machines"}
+	SessionSorts = []string{"id", "datetime_start", "datetime_finish"}
+
+	ChecklistSubmissionSorts = []string{"id", "submitted_at"}
+	IncidentSorts            = []string{"id", "created_at", "updated_at"}
+	WorkOrderSorts           = []string{"id", "created_at"}
+	GeofenceAlertSorts       = []string{"id", "created_at"}
+	SightingSorts            = []string{"id", "seen_at"}
+	AlertSorts               = []string{"id", "created_at", "last_seen_at"}
+)
+
+// UserFilter filters users, zero SiteId means any site
+type UserFilter struct {
+	ListParams
+	JobPosition UserJob
+	SiteId      int
+}
+
+// MachineFilter filters machines, nil ParkingId means any parking,
+// zero ParkingId means machines which are not at parking. Zero SiteId means any site.
+type MachineFilter struct {
+	ListParams
+	State     *MachineState
+	ParkingId *int
+	TypeId    *int
+	SiteId    int
+}
+
+// ParkingFilter filters parkings, zero SiteId means any site
+type ParkingFilter struct {
+	ListParams
+	State  *ParkingState
+	SiteId int
+}
+
+// SessionFilter filters sessions, zero values mean no filter.
+// ParkingId and SiteId match sessions of machines which are currently at parking or site.
+// From and To limit session start time: From <= start < To.
+type SessionFilter struct {
+	ListParams
+	State     *SessionState
+	WorkerId  int
+	MachineId string
+	ParkingId int
+	SiteId    int
+	From      time.Time
+	To        time.Time
+}
@@ -0,0 +1,42 @@
+package entities
+
+import "time"
+
+// Sighting is access point seen by controller of machine. BSSID is mapped to parking and geofence of the machine site
+// when sighting is stored, 0 means the access point is not at any of them.
+type Sighting struct {
+	Id         int       `json:"id"`
+	MachineId  string    `json:"machine_id"`
+	SessionId  int       `json:"session_id"` // active or paused session of the machine, 0 if there was none
+	Bssid      string    `json:"bssid"`
+	Rssi       int       `json:"rssi"` // dBm, 0 if controller did not report it
+	ParkingId  int       `json:"parking_id"`
+	GeofenceId int       `json:"geofence_id"`
+	SeenAt     time.Time `json:"seen_at"`
+}
+
+// SightingFilter filters sightings, zero values mean no filter, To is exclusive
+type SightingFilter struct {
+	ListParams
+	MachineId string
+	SessionId int
+	From      time.Time
+	To        time.Time
+}
+
+// LocateGeofence returns geofence the access point is in, forbidden geofences go first, nil if there is none
+func LocateGeofence(geofences []Geofence, bssid string) *Geofence {
+	var found *Geofence
+	for i, g := range geofences {
+		if !g.Contains(bssid) {
+			continue
+		}
+		if g.Kind == GeofenceForbidden {
+			return &geofences[i]
+		}
+		if found == nil {
+			found = &geofences[i]
+		}
+	}
+	return found
+}
@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+// LoginFailures counts failed logins with the phone number, it exists for unknown phone numbers too.
+// Logins with the phone number are rejected until LockedUntil.
+type LoginFailures struct {
+	PhoneNumber   string     `json:"phone_number"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+func (f LoginFailures) Locked(now time.Time) bool {
+	return f.LockedUntil != nil && now.Before(*f.LockedUntil)
+}
@@ -12,4 +12,14 @@ type Machine struct {
 	ParkingId int          `db:"parking_id" json:"parking_id"`
 	Voltage   int          `db:"voltage" json:"voltage"`
 	IPAddr    string       `db:"ip_addr" json:"ipAddr"`
+	SiteId    int          `db:"site_id" json:"site_id"`
+
+	// Workers need certification for the type to unlock machine, 0 means machine has no type
+	TypeId int `db:"type_id" json:"type_id"`
+
+	// Machine in maintenance can not be unlocked, it is set for free machines only
+	Maintenance bool `db:"maintenance" json:"maintenance"`
+
+	// Last sighting reported by controller, it is filled only in responses with machines, nil if there is none
+	LastLocation *Sighting `db:"-" json:"last_location"`
 }
@@ -16,4 +16,5 @@ type Parking struct {
 	Machines int          `db:"machines" json:"machines"`
 	Capacity Capacity     `db:"capacity" json:"capacity"`
 	State    ParkingState `db:"state" json:"state"`
+	SiteId   int          `db:"site_id" json:"site_id"`
 }
@@ -0,0 +1,57 @@
+package entities
+
+import "time"
+
+// ReportRange is period of report: From <= t < To.
+// Sessions are clipped to the range, unfinished sessions last until now.
+// Non-zero SiteId limits report to machines, workers and parkings of the site.
+type ReportRange struct {
+	From   time.Time
+	To     time.Time
+	SiteId int
+}
+
+// Seconds returns length of the range which is already passed
+func (r ReportRange) Seconds(now time.Time) int64 {
+	end := r.To
+	if now.Before(end) {
+		end = now
+	}
+	return max(int64(end.Sub(r.From).Seconds()), 0)
+}
+
+// MachineUsage is time machine was used and idle within report range
+type MachineUsage struct {
+	MachineId          string     `json:"machine_id"`
+	Sessions           int        `json:"sessions"`
+	UsedSeconds        int64      `json:"used_seconds"`
+	Utilisation        float64    `json:"utilisation_percent"`
+	IdleSeconds        int64      `json:"idle_seconds"`
+	LongestIdleSeconds int64      `json:"longest_idle_seconds"`
+	LastUsed           *time.Time `json:"last_used"`
+}
+
+type WorkerHours struct {
+	WorkerId int     `json:"worker_id"`
+	Name     string  `json:"name"`
+	Sessions int     `json:"sessions"`
+	Machines int     `json:"machines"`
+	Hours    float64 `json:"hours"`
+}
+
+// ParkingTurnover counts machines taken from parking (departures) and returned to it (arrivals)
+type ParkingTurnover struct {
+	ParkingId  int    `json:"parking_id"`
+	Name       string `json:"name"`
+	Departures int    `json:"departures"`
+	Arrivals   int    `json:"arrivals"`
+	Turnover   int    `json:"turnover"`
+}
+
+// PeakHour is one cell of heatmap: weekday (0 is Sunday) and hour in report time zone
+type PeakHour struct {
+	Weekday         int     `json:"weekday"`
+	Hour            int     `json:"hour"`
+	SessionsStarted int     `json:"sessions_started"`
+	MachineHours    float64 `json:"machine_hours"`
+}
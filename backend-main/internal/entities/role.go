@@ -0,0 +1,73 @@
+package entities
+
+import "slices"
+
+// Permission allows a group of api routes, roles are sets of permissions
+type Permission = string
+
+const (
+	PermUsersRead   = Permission("users.read")
+	PermUsersManage = Permission("users.manage") // roles, certifications, shifts and login lockouts
+
+	PermMachinesRead        = Permission("machines.read")
+	PermMachinesManage      = Permission("machines.manage") // manual move to parking and machine types
+	PermMachinesMaintenance = Permission("machines.maintenance")
+
+	PermParkingsRead   = Permission("parkings.read")
+	PermParkingsManage = Permission("parkings.manage")
+
+	PermSessionsRead  = Permission("sessions.read")
+	PermSessionsUse   = Permission("sessions.use")   // commands with own sessions and finish with qr-code
+	PermSessionsForce = Permission("sessions.force") // commands with sessions of other users
+
+	PermIncidentsRead   = Permission("incidents.read")
+	PermIncidentsManage = Permission("incidents.manage") // triage, reporting needs sessions.use
+
+	PermAlertsRead   = Permission("alerts.read")
+	PermAlertsManage = Permission("alerts.manage") // acknowledge alerts
+
+	PermReportsRead = Permission("reports.read") // reports and session export
+
+	PermSitesManage = Permission("sites.manage") // users with it work with all sites
+)
+
+// Permissions are all known permissions with descriptions
+var Permissions = map[Permission]string{
+	PermUsersRead:           "list users",
+	PermUsersManage:         "manage roles, certifications, shifts and login lockouts",
+	PermMachinesRead:        "list machines",
+	PermMachinesManage:      "move machines between parkings, manage machine types",
+	PermMachinesMaintenance: "put machines into maintenance and back",
+	PermParkingsRead:        "list parkings",
+	PermParkingsManage:      "create parkings, change their state and capacity",
+	PermSessionsRead:        "list sessions",
+	PermSessionsUse:         "unlock, pause and finish own sessions, report incidents",
+	PermSessionsForce:       "pause and finish sessions of other users, unlock several machines at once",
+	PermIncidentsRead:       "list incidents and their photos",
+	PermIncidentsManage:     "acknowledge, resolve and reject incidents",
+	PermAlertsRead:          "list alerts about devices, sessions and parkings",
+	PermAlertsManage:        "acknowledge alerts",
+	PermReportsRead:         "view reports and export sessions",
+	PermSitesManage:         "manage sites, see and change data of all sites",
+}
+
+// AllPermissions returns sorted names of all known permissions
+func AllPermissions() []Permission {
+	all := make([]Permission, 0, len(Permissions))
+	for permission := range Permissions {
+		all = append(all, permission)
+	}
+	slices.Sort(all)
+	return all
+}
+
+// Role is job position of users. Admin role always has all permissions and can not be changed.
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+func (r Role) Has(permission Permission) bool {
+	return slices.Contains(r.Permissions, permission)
+}
@@ -10,11 +10,15 @@ const (
 	SessionFinished = SessionState(2)
 )
 
+// Session is usage of machine by worker. StartParkingId is parking the machine
+// was taken from and FinishParkingId is parking it was returned to, 0 if none.
 type Session struct {
-	Id             int          `db:"id" json:"id"`
-	State          SessionState `db:"state" json:"state"`
-	MachineId      string       `db:"machine_id" json:"machineId"`
-	WorkerId       int          `db:"worker_id" json:"workerId"`
-	DatetimeStart  time.Time    `json:"datetimeStart"`
-	DatetimeFinish time.Time    `json:"datetimeFinish"`
+	Id              int          `db:"id" json:"id"`
+	State           SessionState `db:"state" json:"state"`
+	MachineId       string       `db:"machine_id" json:"machineId"`
+	WorkerId        int          `db:"worker_id" json:"workerId"`
+	DatetimeStart   time.Time    `json:"datetimeStart"`
+	DatetimeFinish  time.Time    `json:"datetimeFinish"`
+	StartParkingId  int          `db:"start_parking_id" json:"startParkingId"`
+	FinishParkingId int          `db:"finish_parking_id" json:"finishParkingId"`
 }
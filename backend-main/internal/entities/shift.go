@@ -0,0 +1,77 @@
+package entities
+
+import (
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Shift is time window on days of week in its time zone. Start and End are "15:04",
+// shift ends next day if End is not after Start, Days are weekdays of start (0 is Sunday).
+type Shift struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Days     []int  `json:"days"`
+	Timezone string `json:"timezone"`
+}
+
+// Validate checks times, days and time zone of the shift
+func (s Shift) Validate() error {
+	if _, err := clockMinutes(s.Start); err != nil {
+		return errors.Wrap(err, "start")
+	}
+	if _, err := clockMinutes(s.End); err != nil {
+		return errors.Wrap(err, "end")
+	}
+	for _, d := range s.Days {
+		if d < 0 || d > 6 {
+			return errors.Errorf("day %d should be from 0 (Sunday) to 6", d)
+		}
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return errors.Errorf("unknown time zone %q", s.Timezone)
+	}
+	return nil
+}
+
+// Contains reports if t is within the shift, shift should be valid
+func (s Shift) Contains(t time.Time) bool {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+	start, _ := clockMinutes(s.Start)
+	end, _ := clockMinutes(s.End)
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	today := slices.Contains(s.Days, int(local.Weekday()))
+
+	if start < end {
+		return today && minute >= start && minute < end
+	}
+
+	// shift crosses midnight, the part after midnight belongs to shift started yesterday
+	yesterday := slices.Contains(s.Days, int(local.AddDate(0, 0, -1).Weekday()))
+	return (today && minute >= start) || (yesterday && minute < end)
+}
+
+func clockMinutes(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, errors.Errorf("%q should be time like 08:00", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ShiftUsage is sessions started within shifts, ShiftId is 0 for sessions outside of shifts of their workers
+type ShiftUsage struct {
+	ShiftId  int     `json:"shift_id"`
+	Name     string  `json:"name"`
+	Sessions int     `json:"sessions"`
+	Workers  int     `json:"workers"`
+	Hours    float64 `json:"hours"`
+}
@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShiftContains(t *testing.T) {
+	night := Shift{Start: "22:00", End: "06:00", Days: []int{int(time.Friday)}, Timezone: "Europe/Moscow"}
+	day := Shift{Start: "08:00", End: "17:00", Days: []int{int(time.Monday)}, Timezone: "UTC"}
+
+	for _, tc := range []struct {
+		name  string
+		shift Shift
+		at    string
+		want  bool
+	}{
+		{"night shift start in its zone", night, "2024-03-01T19:00:00Z", true},            // Friday 22:00 in Moscow
+		{"night shift before start", night, "2024-03-01T18:59:00Z", false},                // Friday 21:59 in Moscow
+		{"night shift after midnight", night, "2024-03-02T02:59:00Z", true},               // Saturday 05:59 in Moscow
+		{"night shift end", night, "2024-03-02T03:00:00Z", false},                         // Saturday 06:00 in Moscow
+		{"night shift after midnight of other day", night, "2024-03-01T01:00:00Z", false}, // Friday 04:00, started Thursday
+		{"day shift", day, "2024-03-04T08:00:00Z", true},
+		{"day shift end", day, "2024-03-04T17:00:00Z", false},
+		{"day shift other day", day, "2024-03-05T12:00:00Z", false},
+	} {
+		at, err := time.Parse(time.RFC3339, tc.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tc.shift.Contains(at); got != tc.want {
+			t.Errorf("%s: Contains(%s) = %v, want %v", tc.name, tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestShiftValidate(t *testing.T) {
+	for _, s := range []Shift{
+		{Start: "8:00pm", End: "17:00", Timezone: "UTC"},
+		{Start: "08:00", End: "17:00", Days: []int{7}, Timezone: "UTC"},
+		{Start: "08:00", End: "17:00", Timezone: "Mars/Olympus"},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("shift %+v is valid, want error", s)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package entities
+
+// DefaultSiteId is site created with the database, data created before sites belongs to it
+const DefaultSiteId = 1
+
+// Site is a warehouse, its parkings, machines and users are not visible from other sites
+type Site struct {
+	Id   int    `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+}
@@ -2,8 +2,12 @@ package entities
 
 type UserJob = string
 
+// Roles created with the database, other roles can be added by admin
 const Worker = UserJob("worker")
 const Admin = UserJob("admin")
+const Supervisor = UserJob("supervisor")
+const Technician = UserJob("technician")
+const SiteAdmin = UserJob("site_admin")
 
 type User struct {
 	Id          int     `db:"id" json:"id"`
@@ -11,4 +15,5 @@ type User struct {
 	PhoneNumber string  `db:"phone_number" json:"phoneNumber"`
 	JobPosition UserJob `db:"job_position" json:"jobPosition"`
 	Password    string  `db:"password" json:"password"`
+	SiteId      int     `db:"site_id" json:"site_id"`
 }
@@ -0,0 +1,74 @@
+package entities
+
+import (
+	"math"
+	"time"
+)
+
+// ServiceInterval is periodic service of machines of the type: it is due every EveryHours of operating time,
+// machine can be used GraceHours more before unlock is refused
+type ServiceInterval struct {
+	Id         int    `db:"id" json:"id"`
+	TypeId     int    `db:"type_id" json:"machine_type_id"`
+	Name       string `db:"name" json:"name"`
+	EveryHours int    `db:"every_hours" json:"every_hours"`
+	GraceHours int    `db:"grace_hours" json:"grace_hours"`
+}
+
+type WorkOrderStatus = string
+
+const (
+	WorkOrderOpen   = WorkOrderStatus("open")
+	WorkOrderClosed = WorkOrderStatus("closed")
+)
+
+// WorkOrder is service of the machine created when it is due. Hours are operating hours of the machine,
+// IntervalId is 0 if interval is deleted and Name is kept from it.
+type WorkOrder struct {
+	Id            int             `json:"id"`
+	MachineId     string          `json:"machine_id"`
+	IntervalId    int             `json:"interval_id"`
+	Name          string          `json:"name"`
+	DueHours      float64         `json:"due_hours"`
+	OverdueHours  float64         `json:"overdue_hours"` // unlock is refused from this time until order is closed
+	Status        WorkOrderStatus `json:"status"`
+	Notes         string          `json:"notes"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ClosedAt      *time.Time      `json:"closed_at"`
+	ClosedBy      int             `json:"closed_by"`
+	ClosedAtHours float64         `json:"closed_at_hours"`
+}
+
+func (o WorkOrder) Overdue(hours float64) bool {
+	return o.Status == WorkOrderOpen && hours >= o.OverdueHours
+}
+
+// WorkOrderFilter filters work orders, zero values mean no filter.
+// SiteId matches orders of machines which are currently at the site.
+type WorkOrderFilter struct {
+	ListParams
+	MachineId string
+	Status    WorkOrderStatus
+	SiteId    int
+}
+
+// ServiceDue is the next service of machine by interval, WorkOrderId is 0 until service is due
+type ServiceDue struct {
+	IntervalId   int     `json:"interval_id"`
+	Name         string  `json:"name"`
+	DueHours     float64 `json:"due_hours"`
+	OverdueHours float64 `json:"overdue_hours"`
+	WorkOrderId  int     `json:"work_order_id"`
+}
+
+// MachineService is operating hours of machine with services by intervals of its type
+type MachineService struct {
+	MachineId      string       `json:"machine_id"`
+	OperatingHours float64      `json:"operating_hours"`
+	Schedule       []ServiceDue `json:"schedule"`
+}
+
+// OperatingHours converts operating seconds to hours rounded to hundredths
+func OperatingHours(seconds int64) float64 {
+	return math.Round(float64(seconds)/36) / 100
+}
@@ -0,0 +1,90 @@
+package errs
+
+// Predefined errors. Codes are part of public api, the frontend relies on them.
+var (
+	ErrInternal       = New(KindInternal, "internal", "internal server error")
+	ErrInvalidRequest = Validation("invalid_request", "failed to parse request data")
+	ErrAlreadyExists  = Conflict("already_exists", "object already exists")
+
+	ErrMissingToken = Unauthorized("missing_token", "missing authorization token")
+	ErrInvalidToken = Unauthorized("invalid_token", "token is invalid")
+	ErrTokenExpired = Unauthorized("token_expired", "token is expired")
+	ErrAccessDenied = Forbidden("access_denied", "user have no access to this resource")
+	ErrUserNotFound = NotFound("user_not_found", "user not found")
+
+	// machine controllers authenticate with X-Device-Token, see middlewares.DeviceToken
+	ErrInvalidDeviceToken = Unauthorized("invalid_device_token", "device token is not valid for the machine")
+
+	// Login answers the same for unknown phone number and wrong password, so phone numbers can not be enumerated
+	ErrInvalidCredentials   = Unauthorized("invalid_credentials", "phone number or password is not correct")
+	ErrTooManyLoginAttempts = TooManyRequests("too_many_attempts", "too many login attempts, try again later")
+	ErrLockoutNotFound      = NotFound("lockout_not_found", "phone number has no failed login attempts")
+
+	ErrRoleNotFound      = NotFound("role_not_found", "role not found")
+	ErrRoleInUse         = Conflict("role_in_use", "role is assigned to users")
+	ErrRoleReadOnly      = Forbidden("role_read_only", "admin role can not be changed")
+	ErrInvalidRoleName   = Validation("invalid_role_name", "role name should be 1-32 lowercase latin letters, digits or `_`")
+	ErrUnknownPermission = Validation("unknown_permission", "unknown permission")
+
+	ErrMachineNotFound      = NotFound("machine_not_found", "machine with such id doesn't exists")
+	ErrMachineNotFree       = Conflict("machine_not_free", "machine is not free at the moment")
+	ErrMachineNotInUse      = Conflict("machine_not_in_use", "machine is not in use at the moment")
+	ErrMachineNotStopped    = Conflict("machine_not_stopped", "machine is not in stop at the moment")
+	ErrMachineBusy          = Conflict("machine_busy", "another command with the machine is in progress")
+	ErrMachineInMaintenance = Conflict("machine_in_maintenance", "machine is in maintenance")
+	ErrMachineUnreachable   = DeviceUnreachable("device_unreachable", "machine can not be used at the current moment")
+
+	ErrMachineTypeNotFound       = NotFound("machine_type_not_found", "machine type not found")
+	ErrMachineTypeInUse          = Conflict("machine_type_in_use", "machines or certifications of the type exist")
+	ErrCertificationNotFound     = NotFound("certification_not_found", "certification not found")
+	ErrNotCertified              = Forbidden("not_certified", "user has no valid certification for the machine type")
+	ErrInvalidCertificationDates = Validation("invalid_certification_dates", "expires_at should be after issued_at")
+
+	ErrChecklistSubmissionNotFound = NotFound("checklist_submission_not_found", "checklist submission not found")
+	ErrChecklistRequired           = Validation("checklist_required", "answers to all checklist items of the machine type are required")
+	ErrUnknownChecklistItem        = Validation("unknown_checklist_item", "answer to item which is not in checklist of the machine type")
+	ErrChecklistFailed             = Conflict("checklist_failed", "critical checklist item failed, machine is put into maintenance")
+
+	ErrIncidentNotFound      = NotFound("incident_not_found", "incident not found")
+	ErrIncidentClosed        = Conflict("incident_closed", "incident is resolved or rejected")
+	ErrInvalidIncidentStatus = Validation("invalid_incident_status", "incident can not be moved to the status")
+	ErrPhotoNotFound         = NotFound("photo_not_found", "incident has no photo")
+	ErrUnsupportedPhoto      = Validation("unsupported_photo", "photo should be jpeg, png or webp image")
+	ErrPhotoTooLarge         = Validation("photo_too_large", "photo is too large")
+
+	ErrSiteNotFound = NotFound("site_not_found", "site not found")
+	ErrSiteMismatch = Conflict("site_mismatch", "objects belong to different sites")
+
+	ErrGeofenceNotFound = NotFound("geofence_not_found", "geofence not found")
+	ErrInvalidGeofence  = Validation("invalid_geofence", "geofence kind or bssids are not valid")
+
+	ErrAlertNotFound = NotFound("alert_not_found", "alert not found")
+	ErrAlertResolved = Conflict("alert_resolved", "alert is already resolved")
+
+	ErrShiftNotFound = NotFound("shift_not_found", "shift not found")
+	ErrInvalidShift  = Validation("invalid_shift", "shift times, days or time zone are not valid")
+	ErrOutsideShift  = Forbidden("outside_shift", "user is out of shift now")
+
+	ErrServiceIntervalNotFound = NotFound("service_interval_not_found", "service interval not found")
+	ErrWorkOrderNotFound       = NotFound("work_order_not_found", "work order not found")
+	ErrWorkOrderClosed         = Conflict("work_order_closed", "work order is already closed")
+	ErrMaintenanceOverdue      = Conflict("maintenance_overdue", "service of machine is overdue, close its work orders first")
+
+	ErrSessionNotFound        = NotFound("session_not_found", "session not found")
+	ErrUnfinishedSession      = Conflict("unfinished_session", "user has unfinished sessions")
+	ErrNoActiveSession        = Conflict("no_active_session", "there is no active session with machine")
+	ErrNoPausedSession        = Conflict("no_paused_session", "there is no paused session with machine")
+	ErrSeveralSessions        = Conflict("several_sessions", "there are several sessions with machine")
+	ErrUnknownJobPosition     = Forbidden("unknown_job_position", "user has unknown job position")
+	ErrInvalidQrKey           = Validation("invalid_qr_key", "data.Key does not match with handler's QrKey")
+	ErrParkingNotFound        = NotFound("parking_not_found", "parking not found")
+	ErrParkingFull            = Conflict("parking_full", "parking machines is more or equals than capacity")
+	ErrParkingInactive        = Conflict("parking_inactive", "parking is inactive for now")
+	ErrParkingMismatch        = Conflict("parking_mismatch", "user trying to end session using qr from other parking place")
+	ErrParkingNotAllowed      = Forbidden("parking_not_allowed", "user is not allowed to use machines at this parking")
+	ErrInvalidParkingState    = Validation("invalid_parking_state", "invalid parking state. Use 0 or 1")
+	ErrInvalidParkingCapacity = Validation("invalid_parking_capacity", "capacity is less than machines that now at the parking")
+
+	ErrIdempotencyKeyReused  = Validation("idempotency_key_reused", "idempotency key is already used for other request")
+	ErrIdempotencyInProgress = Conflict("idempotency_in_progress", "request with this idempotency key is in progress")
+)
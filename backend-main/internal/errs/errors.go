@@ -0,0 +1,144 @@
+package errs
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Kind groups errors by the way they should be reported to the client
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindDeviceUnreachable
+	KindTooManyRequests
+)
+
+// Error is a domain error with machine-readable code.
+// Code is returned to the client in `{"error": ..., "code": ...}` body.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+
+	// RetryAfter is sent in Retry-After header if it is not zero
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports errors with the same code as equal, so wrapped copies
+// of predefined errors still match them with errors.Is
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns copy of the error with the given cause
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// WithMessage returns copy of the error with more specific message
+func (e *Error) WithMessage(msg string) *Error {
+	wrapped := *e
+	wrapped.Message = msg
+	return &wrapped
+}
+
+// WithRetryAfter returns copy of the error which tells the client when to retry
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	wrapped := *e
+	wrapped.RetryAfter = d
+	return &wrapped
+}
+
+func New(kind Kind, code, msg string) *Error {
+	return &Error{Kind: kind, Code: code, Message: msg}
+}
+
+func Validation(code, msg string) *Error {
+	return New(KindValidation, code, msg)
+}
+
+func Unauthorized(code, msg string) *Error {
+	return New(KindUnauthorized, code, msg)
+}
+
+func Forbidden(code, msg string) *Error {
+	return New(KindForbidden, code, msg)
+}
+
+func NotFound(code, msg string) *Error {
+	return New(KindNotFound, code, msg)
+}
+
+func Conflict(code, msg string) *Error {
+	return New(KindConflict, code, msg)
+}
+
+func DeviceUnreachable(code, msg string) *Error {
+	return New(KindDeviceUnreachable, code, msg)
+}
+
+func TooManyRequests(code, msg string) *Error {
+	return New(KindTooManyRequests, code, msg)
+}
+
+// As finds the first *Error in err's chain
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// KindOf returns kind of the error, errors without *Error in chain are internal
+func KindOf(err error) Kind {
+	if e, ok := As(err); ok {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// uniqueViolation is postgres error code for unique constraint violation
+const uniqueViolation = "23505"
+
+// FromSQL converts errors of database/sql driver to domain errors:
+// sql.ErrNoRows becomes notFound, unique violation becomes ErrAlreadyExists.
+// Other errors are returned as is.
+func FromSQL(err error, notFound *Error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) && notFound != nil {
+		return notFound.Wrap(err)
+	}
+
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) && stateErr.SQLState() == uniqueViolation {
+		return ErrAlreadyExists.Wrap(err)
+	}
+
+	return err
+}
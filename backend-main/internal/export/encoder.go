@@ -0,0 +1,75 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/pkg/errors"
+)
+
+type Format string
+
+const (
+	FormatCSV   = Format("csv")
+	FormatJSONL = Format("jsonl")
+)
+
+// ParseFormat checks that format is supported
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatJSONL:
+		return f, nil
+	default:
+		return "", errs.ErrInvalidRequest.WithMessage("export format should be one of csv, jsonl")
+	}
+}
+
+func (f Format) ContentType() string {
+	if f == FormatJSONL {
+		return "application/jsonl; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Encoder writes records to w in format, Flush should be called after the last record
+type Encoder struct {
+	format Format
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func NewEncoder(w io.Writer, format Format) *Encoder {
+	e := &Encoder{format: format}
+	if format == FormatJSONL {
+		e.json = json.NewEncoder(w)
+	} else {
+		e.csv = csv.NewWriter(w)
+		e.csv.UseCRLF = true
+	}
+	return e
+}
+
+// WriteHeader writes csv header, it does nothing for json lines
+func (e *Encoder) WriteHeader() error {
+	if e.csv == nil {
+		return nil
+	}
+	return errors.Wrap(e.csv.Write(csvHeader), "write csv header")
+}
+
+func (e *Encoder) Encode(r Record) error {
+	if e.json != nil {
+		return errors.Wrap(e.json.Encode(r), "encode json line")
+	}
+	return errors.Wrap(e.csv.Write(r.csvRow()), "write csv record")
+}
+
+func (e *Encoder) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return errors.Wrap(e.csv.Error(), "flush csv")
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FileWriter appends records to one file per day and format: sessions-2024-11-01.csv.
+// Every record is synced to disk before Write returns.
+type FileWriter struct {
+	mu      sync.Mutex
+	dir     string
+	formats []Format
+
+	day   string
+	files map[Format]*os.File
+}
+
+func NewFileWriter(dir string, formats []Format) (*FileWriter, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, errors.Wrap(err, "create export dir")
+	}
+	return &FileWriter{dir: dir, formats: formats, files: make(map[Format]*os.File)}, nil
+}
+
+// Write appends record to files of the day the session was finished
+func (w *FileWriter) Write(r Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.rotate(r.DatetimeFinish.Local().Format(time.DateOnly)); err != nil {
+		return err
+	}
+
+	for _, format := range w.formats {
+		file := w.files[format]
+
+		e := NewEncoder(file, format)
+		if err := e.Encode(r); err != nil {
+			return err
+		}
+		if err := e.Flush(); err != nil {
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			return errors.Wrap(err, "sync export file")
+		}
+	}
+	return nil
+}
+
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.closeFiles()
+}
+
+// rotate opens files of the day if other day files are opened now.
+// Header is written to csv file when it is created.
+func (w *FileWriter) rotate(day string) error {
+	if day == w.day {
+		return nil
+	}
+	if err := w.closeFiles(); err != nil {
+		return err
+	}
+
+	for _, format := range w.formats {
+		path := filepath.Join(w.dir, "sessions-"+day+"."+string(format))
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			return errors.Wrap(err, "open export file")
+		}
+		w.files[format] = file
+
+		info, err := file.Stat()
+		if err != nil {
+			return errors.Wrap(err, "stat export file")
+		}
+		if info.Size() == 0 {
+			e := NewEncoder(file, format)
+			if err = e.WriteHeader(); err != nil {
+				return err
+			}
+			if err = e.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.day = day
+	return nil
+}
+
+func (w *FileWriter) closeFiles() error {
+	var firstErr error
+	for format, file := range w.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = errors.Wrap(err, "close export file")
+		}
+		delete(w.files, format)
+	}
+	w.day = ""
+	return firstErr
+}
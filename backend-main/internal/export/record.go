@@ -0,0 +1,57 @@
+// Package export writes finished sessions as CSV (RFC 4180, with header) or JSON Lines.
+// FileWriter appends sessions to daily files as they finish, Encoder is used to
+// regenerate the same files from database.
+package export
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+// Record is one exported session
+type Record struct {
+	SessionId       int       `json:"session_id"`
+	WorkerId        int       `json:"worker_id"`
+	WorkerName      string    `json:"worker_name"`
+	MachineId       string    `json:"machine_id"`
+	StartParkingId  int       `json:"start_parking_id"`
+	FinishParkingId int       `json:"finish_parking_id"`
+	DatetimeStart   time.Time `json:"datetime_start"`
+	DatetimeFinish  time.Time `json:"datetime_finish"`
+	DurationSeconds int64     `json:"duration_seconds"`
+}
+
+var csvHeader = []string{
+	"session_id", "worker_id", "worker_name", "machine_id", "start_parking_id",
+	"finish_parking_id", "datetime_start", "datetime_finish", "duration_seconds",
+}
+
+func NewRecord(session entities.Session, worker entities.User) Record {
+	return Record{
+		SessionId:       session.Id,
+		WorkerId:        worker.Id,
+		WorkerName:      worker.Name,
+		MachineId:       session.MachineId,
+		StartParkingId:  session.StartParkingId,
+		FinishParkingId: session.FinishParkingId,
+		DatetimeStart:   session.DatetimeStart,
+		DatetimeFinish:  session.DatetimeFinish,
+		DurationSeconds: int64(session.DatetimeFinish.Sub(session.DatetimeStart).Seconds()),
+	}
+}
+
+func (r Record) csvRow() []string {
+	return []string{
+		strconv.Itoa(r.SessionId),
+		strconv.Itoa(r.WorkerId),
+		r.WorkerName,
+		r.MachineId,
+		strconv.Itoa(r.StartParkingId),
+		strconv.Itoa(r.FinishParkingId),
+		r.DatetimeStart.Format(time.RFC3339),
+		r.DatetimeFinish.Format(time.RFC3339),
+		strconv.FormatInt(r.DurationSeconds, 10),
+	}
+}
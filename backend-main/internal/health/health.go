@@ -0,0 +1,136 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Statuses of checks and of the whole report
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check returns error if dependency is not ready
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Checker keeps checks of dependencies and heartbeats of background workers
+type Checker struct {
+	mu      sync.Mutex
+	checks  map[string]Check
+	workers map[string]*Heartbeat
+}
+
+func New() *Checker {
+	return &Checker{checks: make(map[string]Check), workers: make(map[string]*Heartbeat)}
+}
+
+// AddCheck adds dependency check which is run on every readiness request
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Worker registers background worker, it is considered dead if Beat of returned heartbeat
+// was not called for longer than maxInterval
+func (c *Checker) Worker(name string, maxInterval time.Duration) *Heartbeat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hb := &Heartbeat{maxInterval: maxInterval, last: time.Now()}
+	c.workers[name] = hb
+	return hb
+}
+
+// Live reports state of background workers only, failed liveness means the app should be restarted
+func (c *Checker) Live() Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.workers))}
+	now := time.Now()
+	for name, hb := range c.workers {
+		report.add("worker:"+name, hb.check(now))
+	}
+	return report
+}
+
+// Ready runs all checks in parallel and adds state of background workers
+func (c *Checker) Ready(ctx context.Context) Report {
+	report := c.Live()
+
+	c.mu.Lock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.Unlock()
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.add(name, err)
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (r *Report) add(name string, err error) {
+	if err == nil {
+		r.Checks[name] = CheckResult{Status: StatusOK}
+		return
+	}
+	r.Status = StatusFail
+	r.Checks[name] = CheckResult{Status: StatusFail, Error: err.Error()}
+}
+
+// Heartbeat is beaten by background worker on every iteration
+type Heartbeat struct {
+	mu          sync.Mutex
+	maxInterval time.Duration
+	last        time.Time
+}
+
+func (h *Heartbeat) Beat() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last = time.Now()
+}
+
+func (h *Heartbeat) check(now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if since := now.Sub(h.last); since > h.maxInterval {
+		return fmt.Errorf("no heartbeat for %s", since.Truncate(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/pkg/errors"
+)
+
+func (h *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.AlertSorts)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	filter := entities.AlertFilter{ListParams: params, Type: q.Get("type"), Key: q.Get("key"), Status: q.Get("status")}
+	if filter.SiteId, err = listSite(r); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if filter.Open, err = queryBool(q, "open"); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	page, err := h.service.ListAlerts(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list alerts", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+func (h *Handler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	alert, err := h.pathAlert(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, alert)
+}
+
+// AcknowledgeAlert stops repeated notifications of the alert, it stays unresolved until the condition is gone
+func (h *Handler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.AcknowledgeAlert")
+
+	alert, err := h.pathAlert(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	userId, err := userIdFromContext(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user_id from context", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	acknowledged, err := h.service.AcknowledgeAlert(r.Context(), alert.Id, int(userId), time.Now())
+	if err != nil {
+		if !errors.Is(err, errs.ErrAlertResolved) {
+			slog.ErrorContext(r.Context(), "acknowledge alert", op, slog.Int("alert_id", alert.Id), slog.String("error", err.Error()))
+		}
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "alert is acknowledged", op, slog.Int("alert_id", alert.Id), slog.Int("user_id", int(userId)))
+	respondJSON(w, r, http.StatusOK, acknowledged)
+}
+
+// pathAlert returns alert from `id` path parameter, alerts of other sites are not found
+func (h *Handler) pathAlert(r *http.Request) (*entities.Alert, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	alert, err := h.service.GetAlert(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if !inScope(r.Context(), alert.SiteId) {
+		return nil, errs.ErrAlertNotFound
+	}
+	return alert, nil
+}
+
+// sendMachineState sends state to controller of the machine. Undelivered state raises relay_failed alert of the machine,
+// delivered one resolves it.
+func (h *Handler) sendMachineState(ctx context.Context, machine *entities.Machine) error {
+	err := sendMachineCurrentState(ctx, machine, h.cfg.MC.RequestTimeout)
+	switch {
+	case err == nil:
+		h.resolveAlert(context.WithoutCancel(ctx), entities.AlertRelayFailed, machine.Id)
+	case errors.Is(err, errs.ErrMachineUnreachable) && ctx.Err() == nil:
+		cause := err
+		if unwrapped := errors.Unwrap(err); unwrapped != nil {
+			cause = unwrapped
+		}
+		h.raiseAlert(context.WithoutCancel(ctx), entities.Alert{
+			Type:    entities.AlertRelayFailed,
+			Key:     machine.Id,
+			SiteId:  machine.SiteId,
+			Message: fmt.Sprintf("state %d was not delivered to machine %s: %s", machine.State, machine.Id, cause),
+		})
+	}
+	return err
+}
+
+// raiseAlert raises alert by rules from config, failure is only logged because the alert is not the point of request
+func (h *Handler) raiseAlert(ctx context.Context, alert entities.Alert) {
+	if _, err := h.alerts.Raise(ctx, alert); err != nil {
+		slog.ErrorContext(ctx, "raise alert", slog.String("type", alert.Type), slog.String("key", alert.Key),
+			slog.String("error", err.Error()))
+	}
+}
+
+func (h *Handler) resolveAlert(ctx context.Context, alertType entities.AlertType, key string) {
+	if err := h.alerts.Resolve(ctx, alertType, key); err != nil {
+		slog.ErrorContext(ctx, "resolve alert", slog.String("type", alertType), slog.String("key", key),
+			slog.String("error", err.Error()))
+	}
+}
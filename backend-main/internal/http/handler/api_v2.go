@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+)
+
+func (h *Handler) LoginV2(w http.ResponseWriter, r *http.Request) {
+	var data loginRequest
+
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	token, err := h.login(r.Context(), h.clientIP(r), data.PhoneNumber, data.Password)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respondJSON(w, r, http.StatusOK, tokenResponse{Token: token})
+}
+
+func (h *Handler) ListUsersV2(w http.ResponseWriter, r *http.Request) {
+	page, err := h.listUsers(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+func (h *Handler) GetUserV2(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	user, err := h.getUser(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, user)
+}
+
+func (h *Handler) ListMachinesV2(w http.ResponseWriter, r *http.Request) {
+	page, err := h.listMachines(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+func (h *Handler) GetMachineV2(w http.ResponseWriter, r *http.Request) {
+	machine, err := h.getMachine(r.Context(), r.PathValue("id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if err = h.withLastLocation(r.Context(), machine); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, machine)
+}
+
+func (h *Handler) RegisterMachineV2(w http.ResponseWriter, r *http.Request) {
+	var data registerMachineV2Request
+
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	machine, err := h.registerMachine(r.Context(), r.PathValue("id"), data.IPAddr)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, machine)
+}
+
+// GetMachineDeviceTokenV2 responds with token which controller of the machine sends with its reports
+func (h *Handler) GetMachineDeviceTokenV2(w http.ResponseWriter, r *http.Request) {
+	machine, err := h.getMachine(r.Context(), r.PathValue("id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, deviceTokenResponse{MachineId: machine.Id, DeviceToken: middlewares.DeviceToken(h.cfg.MC.DeviceSecret, machine.Id)})
+}
+
+func (h *Handler) MoveMachineV2(w http.ResponseWriter, r *http.Request) {
+	var data moveMachineV2Request
+
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	machine, err := h.moveMachineToParking(r.Context(), r.PathValue("id"), data.ParkingId)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, machine)
+}
+
+func (h *Handler) UnlockMachineV2(w http.ResponseWriter, r *http.Request) {
+	var data unlockMachineV2Request
+
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	h.machineCommandV2(w, r, func(ctx context.Context, userId int64, machineId string) (*entities.Session, error) {
+		return h.unlockMachine(ctx, userId, machineId, data.Checklist)
+	}, http.StatusCreated)
+}
+
+func (h *Handler) LockMachineV2(w http.ResponseWriter, r *http.Request) {
+	h.machineCommandV2(w, r, h.lockMachine, http.StatusOK)
+}
+
+func (h *Handler) StopMachineV2(w http.ResponseWriter, r *http.Request) {
+	h.machineCommandV2(w, r, h.stopMachine, http.StatusOK)
+}
+
+func (h *Handler) UnstopMachineV2(w http.ResponseWriter, r *http.Request) {
+	h.machineCommandV2(w, r, h.unstopMachine, http.StatusOK)
+}
+
+// machineCommandV2 runs command for machine from path and responds with affected session
+func (h *Handler) machineCommandV2(w http.ResponseWriter, r *http.Request,
+	command func(ctx context.Context, userId int64, machineId string) (*entities.Session, error), successCode int) {
+	userId, err := userIdFromContext(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user_id from context", slog.String("path", r.URL.Path), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	session, err := command(r.Context(), userId, r.PathValue("id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, successCode, session)
+}
+
+func (h *Handler) ListParkingsV2(w http.ResponseWriter, r *http.Request) {
+	page, err := h.listParkings(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+func (h *Handler) CreateParkingV2(w http.ResponseWriter, r *http.Request) {
+	data := createParkingRequest{State: entities.ParkingActive}
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	siteId, err := h.targetSite(r.Context(), data.SiteId)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	parking, err := h.service.InsertParking(r.Context(), data.Name, entities.NormalizeBssid(data.MacAddr), data.Capacity, data.State, siteId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to create parking", slog.String("parkingName", data.Name), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusCreated, parking)
+}
+
+func (h *Handler) GetParkingV2(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	parking, err := h.getParking(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, parking)
+}
+
+// UpdateParkingV2 partially updates parking, only fields present in body are changed
+func (h *Handler) UpdateParkingV2(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data updateParkingV2Request
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	parking, err := h.getParking(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if data.Capacity != nil {
+		if parking, err = h.updateParkingCapacity(r.Context(), id, *data.Capacity); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	if data.State != nil {
+		if parking, err = h.updateParkingState(r.Context(), id, *data.State); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+	respondJSON(w, r, http.StatusOK, parking)
+}
+
+func (h *Handler) GetParkingMachinesV2(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err = h.getParking(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	machines, err := h.service.GetMachinesByParkingId(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, machines)
+}
+
+func (h *Handler) ListSessionsV2(w http.ResponseWriter, r *http.Request) {
+	page, err := h.listSessions(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list sessions", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+func (h *Handler) GetSessionV2(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	session, err := h.getSession(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, session)
+}
+
+func (h *Handler) FinishSessionsV2(w http.ResponseWriter, r *http.Request) {
+	var data finishSessionRequest
+
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	userId, err := userIdFromContext(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user_id from context", slog.String("path", r.URL.Path), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	sessions, err := h.finishSessions(r.Context(), userId, data.Key, data.ParkingName)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, sessions)
+}
+
+// pathInt parses integer path value
+func pathInt(r *http.Request, name string) (int, error) {
+	value, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return 0, errs.ErrInvalidRequest.WithMessage(name + " should be integer")
+	}
+	return value, nil
+}
@@ -1,64 +1,71 @@
 package handler
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
 	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
 )
 
 func (h *Handler) RegisterMachine(w http.ResponseWriter, r *http.Request) {
 	op := slog.String("op", "handler.RegisterMachine")
-	var data struct {
-		MachineId string `json:"machine_id"`
-		IPAddr    string `json:"ip_addr"`
-	}
+	var data registerMachineRequest
 
 	if err := utils.ParseRequestData(r.Body, &data); err != nil {
-		slog.Error("parse req data", op, slog.String("error", err.Error()))
-		if err := utils.RespondWith400(w, "failed to parse request data"); err != nil {
-			slog.Error("failed to respond with 400", op, slog.String("error", err.Error()))
-		}
+		slog.ErrorContext(r.Context(), "parse req data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
 		return
 	}
 
 	idAttr, ipAttr := slog.String("machineId", data.MachineId), slog.String("ipAddr", data.IPAddr)
 
-	machine, err := h.service.GetMachineByID(data.MachineId)
+	machine, err := h.registerMachine(r.Context(), data.MachineId, data.IPAddr)
 	if err != nil {
-		machine, err = h.service.InsertMachine(data.MachineId, data.IPAddr)
-		if err != nil {
-			slog.Error("failed to create new in machine", idAttr, ipAttr, slog.String("error", err.Error()))
-			if err = utils.RespondWith400(w, "failed to create new machine"); err != nil {
-				slog.Error("failed to respond with 400", op, slog.String("error", err.Error()))
-			}
-			return
-		}
+		respondError(w, r, err)
+		return
+	}
 
-	} else {
-		machine, err = h.service.UpdateMachineIPAddr(machine.Id, data.IPAddr)
-		if err != nil {
-			slog.Error("update machine IP", idAttr, ipAttr, slog.String("error", err.Error()))
+	payload := currentStateResponse{CurrentState: machine.State}
 
-			if err = utils.RespondWith400(w, "failed to update machine IP Address"); err != nil {
-				slog.Error("failed to respond with 400", op, slog.String("error", err.Error()))
-			}
-			return
-		}
+	if err = utils.SuccessRespondWith200(w, payload); err != nil {
+		slog.ErrorContext(r.Context(), "failed to respond Success(200) with paylod on RegisterMachine",
+			slog.Any("payload", payload), idAttr, ipAttr,
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("error", err.Error()),
+		)
 	}
+}
 
-	payload := struct {
-		CurrentState int `json:"current_state"`
-	}{CurrentState: machine.State}
+// registerMachine creates new machine or updates ip address of the known one
+func (h *Handler) registerMachine(ctx context.Context, machineId, ipAddr string) (*entities.Machine, error) {
+	op := slog.String("op", "handler.registerMachine")
+	idAttr, ipAttr := slog.String("machineId", machineId), slog.String("ipAddr", ipAddr)
 
-	if err = utils.SuccessRespondWith200(w, payload); err != nil {
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond Success(200) with paylod on RegisterMachine",
-				slog.Any("payload", payload), idAttr, ipAttr,
-				slog.String("method", r.Method),
-				slog.String("path", r.URL.Path),
-				slog.String("error", err.Error()),
-			)
+	machine, err := h.service.GetMachineByID(ctx, machineId)
+	switch {
+	case errors.Is(err, errs.ErrMachineNotFound):
+		machine, err = h.service.InsertMachine(ctx, machineId, ipAddr)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to create new in machine", op, idAttr, ipAttr, slog.String("error", err.Error()))
+			return nil, err
+		}
+
+	case err != nil:
+		slog.ErrorContext(ctx, "get machine by id", op, idAttr, slog.String("error", err.Error()))
+		return nil, err
+
+	default:
+		machine, err = h.service.UpdateMachineIPAddr(ctx, machine.Id, ipAddr)
+		if err != nil {
+			slog.ErrorContext(ctx, "update machine IP", op, idAttr, ipAttr, slog.String("error", err.Error()))
+			return nil, err
 		}
 	}
+
+	return machine, nil
 }
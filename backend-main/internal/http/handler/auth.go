@@ -1,61 +1,186 @@
 package handler
 
 import (
+	"context"
+	"crypto/subtle"
 	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
 	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
 )
 
+// maxPhoneNumberLen is the length of phone_number column, failures with longer numbers are not stored
+const maxPhoneNumberLen = 11
+
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	op := slog.String("op", "handler.Login")
 
-	var data struct {
-		PhoneNumber string `json:"phone_number"`
-		Password    string `json:"password"`
-	}
+	var data loginRequest
 
 	if err := utils.ParseRequestData(r.Body, &data); err != nil {
-		slog.Error("parse req data", op, slog.String("error", err.Error()))
-		if err := utils.RespondWith400(w, "failed to parse request data"); err != nil {
-			slog.Error("failed to respond with 400", op, slog.String("error", err.Error()))
-		}
+		slog.ErrorContext(r.Context(), "parse req data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	token, err := h.login(r.Context(), h.clientIP(r), data.PhoneNumber, data.Password)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	user, err := h.service.GetUserByPhoneNumber(data.PhoneNumber)
+	response := tokenResponse{Token: token}
+
+	if err := utils.RespondWithJSON(w, 200, response); err != nil {
+		slog.ErrorContext(r.Context(), "failed to respond with JSON with JWT token", op, slog.String("error", err.Error()))
+	}
+}
+
+// login checks user's credentials and generates new JWT token.
+// Unknown phone number and wrong password are both reported as errs.ErrInvalidCredentials.
+func (h *Handler) login(ctx context.Context, clientIP, phoneNumber, password string) (string, error) {
+	op := slog.String("op", "handler.login")
+
+	if ok, retryAfter := h.ipLimiter.Allow(clientIP); !ok {
+		slog.WarnContext(ctx, "login limit of ip is exceeded", op, slog.String("ip", clientIP))
+		return "", errs.ErrTooManyLoginAttempts.WithRetryAfter(retryAfter)
+	}
+	if ok, retryAfter := h.phoneLimiter.Allow(phoneNumber); !ok {
+		slog.WarnContext(ctx, "login limit of phone number is exceeded", op, slog.String("phone_number", phoneNumber),
+			slog.String("ip", clientIP))
+		return "", errs.ErrTooManyLoginAttempts.WithRetryAfter(retryAfter)
+	}
+
+	failures, err := h.service.GetLoginFailures(ctx, phoneNumber)
+	if err != nil && !errors.Is(err, errs.ErrLockoutNotFound) {
+		slog.ErrorContext(ctx, "get login failures", op, slog.String("phone_number", phoneNumber), slog.String("error", err.Error()))
+		return "", err
+	}
+	if failures != nil && failures.Locked(time.Now()) {
+		return "", errs.ErrTooManyLoginAttempts.WithRetryAfter(time.Until(*failures.LockedUntil))
+	}
+
+	user, err := h.service.GetUserByPhoneNumber(ctx, phoneNumber)
+	if errors.Is(err, errs.ErrUserNotFound) {
+		h.registerLoginFailure(ctx, clientIP, phoneNumber)
+		return "", errs.ErrInvalidCredentials
+	}
 	if err != nil {
-		slog.Error("get user by phone number", op, slog.String("phone_number", data.PhoneNumber),
+		slog.ErrorContext(ctx, "get user by phone number", op, slog.String("phone_number", phoneNumber),
 			slog.String("error", err.Error()))
+		return "", err
+	}
 
-		if err = utils.RespondWith400(w, "user with such phone number doesn't exists"); err != nil {
-			slog.Error("failed to respond with 400", slog.String("error", err.Error()))
+	if subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) != 1 {
+		h.registerLoginFailure(ctx, clientIP, phoneNumber)
+		return "", errs.ErrInvalidCredentials
+	}
+
+	if failures != nil {
+		if err = h.service.ResetLoginFailures(ctx, phoneNumber); err != nil && !errors.Is(err, errs.ErrLockoutNotFound) {
+			slog.ErrorContext(ctx, "reset login failures", op, slog.String("phone_number", phoneNumber), slog.String("error", err.Error()))
 		}
+	}
+
+	token, err := h.service.GenerateToken(*user, h.cfg.Secret, h.cfg.TokenTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to generate JWT token", op, slog.Any("user", user), slog.String("error", err.Error()))
+		return "", err
+	}
+	return token, nil
+}
+
+// registerLoginFailure counts failed login, the client gets the same answer even if it is not saved
+func (h *Handler) registerLoginFailure(ctx context.Context, clientIP, phoneNumber string) {
+	op := slog.String("op", "handler.registerLoginFailure")
+
+	if len(phoneNumber) > maxPhoneNumberLen {
+		return
+	}
+
+	cfg := h.cfg.Login
+	failures, err := h.service.RegisterLoginFailure(ctx, phoneNumber, time.Now().Add(-cfg.FailureWindow), cfg.MaxFailures, cfg.Lockout)
+	if err != nil {
+		slog.ErrorContext(ctx, "register login failure", op, slog.String("phone_number", phoneNumber), slog.String("error", err.Error()))
 		return
 	}
 
-	if data.Password != user.Password {
-		if err = utils.RespondWith400(w, "user password is not correct"); err != nil {
-			slog.Error("failed to respond with 400", slog.String("error", err.Error()))
+	if failures.Locked(time.Now()) {
+		slog.WarnContext(ctx, "phone number is locked out after failed logins", op,
+			slog.String("phone_number", phoneNumber),
+			slog.String("ip", clientIP),
+			slog.Int("failures", failures.Failures),
+			slog.Time("locked_until", *failures.LockedUntil),
+		)
+	}
+}
+
+// clientIP returns address of the client, it is taken from login.client_ip_header if the app is behind proxy.
+// The last address of the header is used, because the proxy appends it to the ones sent by the client.
+func (h *Handler) clientIP(r *http.Request) string {
+	if header := h.cfg.Login.ClientIPHeader; header != "" {
+		values := strings.Split(r.Header.Get(header), ",")
+		if ip := strings.TrimSpace(values[len(values)-1]); ip != "" {
+			return ip
 		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ListLockouts lists phone numbers with recent failed logins, locked ones included.
+// Phone numbers of unknown users are listed only to users with sites.manage.
+func (h *Handler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	siteId, err := listSite(r)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	token, err := h.service.GenerateToken(*user, h.cfg.Secret, h.cfg.TokenTTL)
+	list, err := h.service.ListLoginFailures(r.Context(), time.Now().Add(-h.cfg.Login.FailureWindow), siteId)
 	if err != nil {
-		slog.Error("failed to generate JWT token", slog.Any("user", user), slog.String("error", err.Error()))
-		if err = utils.RespondWith400(w, "failed to generate JWT token"); err != nil {
-			slog.Error("failed to respond with 400", slog.String("error", err.Error()))
-		}
+		slog.ErrorContext(r.Context(), "list login failures", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	resp := make([]lockoutResponse, 0, len(list))
+	for _, failures := range list {
+		resp = append(resp, lockoutResponse{LoginFailures: failures, Locked: failures.Locked(now)})
 	}
+	respondJSON(w, r, http.StatusOK, resp)
+}
 
-	response := struct {
-		Token string `json:"token"`
-	}{Token: token}
+// DeleteLockout unlocks phone number and forgets its failed logins
+func (h *Handler) DeleteLockout(w http.ResponseWriter, r *http.Request) {
+	phoneNumber := r.PathValue("phone")
 
-	if err := utils.RespondWithJSON(w, 200, response); err != nil {
-		if err = utils.RespondWith400(w, "failed to respond successfully with JSON"); err != nil {
-			slog.Error("failed to respond with JSON with JWT token", slog.String("error", err.Error()))
+	if scope := siteScope(r.Context()); scope != 0 {
+		user, err := h.service.GetUserByPhoneNumber(r.Context(), phoneNumber)
+		if errors.Is(err, errs.ErrUserNotFound) || err == nil && user.SiteId != scope {
+			respondError(w, r, errs.ErrLockoutNotFound)
+			return
+		} else if err != nil {
+			respondError(w, r, err)
+			return
 		}
 	}
+
+	if err := h.service.ResetLoginFailures(r.Context(), phoneNumber); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "login lockout is removed by admin", slog.String("phone_number", phoneNumber))
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/config"
+)
+
+func (a *testApp) login(phoneNumber, password string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	return a.do("POST", "/api/v2/auth/login", "", loginRequest{PhoneNumber: phoneNumber, Password: password})
+}
+
+func TestPhoneNumberIsLockedOutAfterFailures(t *testing.T) {
+	app := newTestApp(t, func(cfg *config.Config) { cfg.Login.MaxFailures = 3 })
+
+	for i := 0; i < 3; i++ {
+		if w := app.login("200", "wrong"); w.Code != http.StatusUnauthorized || errorCode(t, w) != "invalid_credentials" {
+			t.Fatalf("failure %d: status %d, want 401 invalid_credentials: %s", i+1, w.Code, w.Body.String())
+		}
+	}
+
+	// correct password is rejected too while phone number is locked
+	w := app.login("200", "worker")
+	if w.Code != http.StatusTooManyRequests || errorCode(t, w) != "too_many_attempts" || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("locked login: status %d, Retry-After %q, want 429 too_many_attempts: %s",
+			w.Code, w.Header().Get("Retry-After"), w.Body.String())
+	}
+	if w := app.login("201", "worker"); w.Code != http.StatusOK {
+		t.Errorf("other phone number: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	admin := app.token("100")
+	lockouts := decode[[]lockoutResponse](t, app.do("GET", "/api/v2/auth/lockouts", admin, nil), http.StatusOK)
+	if len(lockouts) != 1 || lockouts[0].PhoneNumber != "200" || !lockouts[0].Locked || lockouts[0].Failures != 3 {
+		t.Errorf("lockouts %+v, want locked 200 with 3 failures", lockouts)
+	}
+
+	if w := app.do("DELETE", "/api/v2/auth/lockouts/200", admin, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete lockout: status %d, want 204: %s", w.Code, w.Body.String())
+	}
+	if resp := decode[tokenResponse](t, app.login("200", "worker"), http.StatusOK); resp.Token == "" {
+		t.Errorf("no token after lockout is removed")
+	}
+}
+
+func TestLoginAttemptsOfPhoneNumberAreLimited(t *testing.T) {
+	app := newTestApp(t, func(cfg *config.Config) { cfg.Login.PhoneLimit = 2 })
+
+	for i := 0; i < 2; i++ {
+		if w := app.login("201", "worker"); w.Code != http.StatusOK {
+			t.Fatalf("attempt %d: status %d, want 200: %s", i+1, w.Code, w.Body.String())
+		}
+	}
+	if w := app.login("201", "worker"); w.Code != http.StatusTooManyRequests || errorCode(t, w) != "too_many_attempts" {
+		t.Errorf("status %d, want 429 too_many_attempts: %s", w.Code, w.Body.String())
+	}
+}
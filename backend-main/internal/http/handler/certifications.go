@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
+)
+
+func (h *Handler) ListMachineTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := h.service.ListMachineTypes(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list machine types", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, types)
+}
+
+func (h *Handler) GetMachineType(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	machineType, err := h.service.GetMachineTypeById(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, machineType)
+}
+
+func (h *Handler) CreateMachineType(w http.ResponseWriter, r *http.Request) {
+	var data machineTypeRequest
+
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	machineType, err := h.service.InsertMachineType(r.Context(), data.Name, data.Description)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "machine type is created", slog.Int("type_id", machineType.Id), slog.String("name", machineType.Name))
+	respondJSON(w, r, http.StatusCreated, machineType)
+}
+
+func (h *Handler) UpdateMachineType(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data machineTypeRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	machineType, err := h.service.UpdateMachineType(r.Context(), id, data.Name, data.Description)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, machineType)
+}
+
+// DeleteMachineType removes type which is not assigned to machines and has no certifications
+func (h *Handler) DeleteMachineType(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	certifications, err := h.service.ListCertifications(r.Context(), entities.CertificationFilter{TypeId: id})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list certifications of type", slog.Int("type_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	if len(certifications) != 0 {
+		respondError(w, r, errs.ErrMachineTypeInUse)
+		return
+	}
+
+	if err = h.service.DeleteMachineType(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "machine type is deleted", slog.Int("type_id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetMachineTypeV2 assigns type to machine, type_id 0 means that machine needs no certification
+func (h *Handler) SetMachineTypeV2(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.SetMachineTypeV2")
+	machineId := r.PathValue("id")
+
+	var data machineTypeIdRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	if data.TypeId != 0 {
+		if _, err := h.service.GetMachineTypeById(r.Context(), data.TypeId); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	machine, err := h.service.UpdateMachineTypeId(r.Context(), machineId, data.TypeId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update machine type", op, slog.String("machine_id", machineId), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "machine type is changed", op, slog.String("machine_id", machineId), slog.Int("type_id", data.TypeId))
+	respondJSON(w, r, http.StatusOK, machine)
+}
+
+func (h *Handler) ListCertifications(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var (
+		filter entities.CertificationFilter
+		err    error
+	)
+	if filter.SiteId, err = listSite(r); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	for name, dst := range map[string]*int{"user_id": &filter.UserId, "type_id": &filter.TypeId} {
+		value, err := queryInt(q, name)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if value != nil {
+			*dst = *value
+		}
+	}
+
+	certifications, err := h.service.ListCertifications(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list certifications", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, certifications)
+}
+
+func (h *Handler) GetCertification(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	certification, err := h.getCertification(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, certification)
+}
+
+func (h *Handler) CreateCertification(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.CreateCertification")
+
+	var data createCertificationRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+	if !data.IssuedAt.Before(data.ExpiresAt) {
+		respondError(w, r, errs.ErrInvalidCertificationDates)
+		return
+	}
+
+	if _, err := h.getUser(r.Context(), data.UserId); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if _, err := h.service.GetMachineTypeById(r.Context(), data.TypeId); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	certification, err := h.service.InsertCertification(r.Context(), entities.Certification{
+		UserId:    data.UserId,
+		TypeId:    data.TypeId,
+		Number:    data.Number,
+		IssuedAt:  data.IssuedAt,
+		ExpiresAt: data.ExpiresAt,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "insert certification", op, slog.Int("user_id", data.UserId), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "certification is created", op,
+		slog.Int("certification_id", certification.Id),
+		slog.Int("user_id", certification.UserId),
+		slog.Int("type_id", certification.TypeId),
+		slog.Time("expires_at", certification.ExpiresAt),
+	)
+	respondJSON(w, r, http.StatusCreated, certification)
+}
+
+// UpdateCertification replaces number and dates, e.g. when certification is renewed
+func (h *Handler) UpdateCertification(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data updateCertificationRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+	if !data.IssuedAt.Before(data.ExpiresAt) {
+		respondError(w, r, errs.ErrInvalidCertificationDates)
+		return
+	}
+
+	if _, err = h.getCertification(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	certification, err := h.service.UpdateCertification(r.Context(), entities.Certification{
+		Id:        id,
+		Number:    data.Number,
+		IssuedAt:  data.IssuedAt,
+		ExpiresAt: data.ExpiresAt,
+	})
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "certification is updated", slog.Int("certification_id", id), slog.Time("expires_at", certification.ExpiresAt))
+	respondJSON(w, r, http.StatusOK, certification)
+}
+
+func (h *Handler) DeleteCertification(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err = h.getCertification(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err = h.service.DeleteCertification(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "certification is deleted", slog.Int("certification_id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkCertification returns errs.ErrNotCertified if machine has type and user has no valid certification of it
+func checkCertification(ctx context.Context, svc *service.Service, user *entities.User, machine *entities.Machine) error {
+	if machine.TypeId == 0 {
+		return nil
+	}
+
+	certifications, err := svc.ListCertifications(ctx, entities.CertificationFilter{UserId: user.Id, TypeId: machine.TypeId})
+	if err != nil {
+		return errors.Wrap(err, "list certifications of user")
+	}
+
+	now := time.Now()
+	for _, c := range certifications {
+		if c.Valid(now) {
+			return nil
+		}
+	}
+	return errs.ErrNotCertified
+}
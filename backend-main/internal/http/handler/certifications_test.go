@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+func TestUnlockRequiresValidCertification(t *testing.T) {
+	app := newTestApp(t, nil)
+	d := newDevice(t)
+	app.machine("M1", d)
+	admin, worker := app.token("100"), app.token("200")
+
+	user, err := app.svc.GetUserByPhoneNumber(context.Background(), "200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forklift := decode[entities.MachineType](t, app.do("POST", "/api/v2/machine-types", admin,
+		machineTypeRequest{Name: "Forklift"}), http.StatusCreated)
+	if w := app.do("PUT", "/api/v2/machines/M1/type", admin, machineTypeIdRequest{TypeId: forklift.Id}); w.Code != http.StatusOK {
+		t.Fatalf("set machine type: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	if w := app.do("POST", "/api/v2/machines/M1/unlock", worker, nil); w.Code != http.StatusForbidden || errorCode(t, w) != "not_certified" {
+		t.Fatalf("unlock without certification: status %d, want 403 not_certified: %s", w.Code, w.Body.String())
+	}
+
+	now := time.Now()
+	expired := createCertificationRequest{UserId: user.Id, TypeId: forklift.Id, IssuedAt: now.AddDate(-1, 0, 0), ExpiresAt: now.Add(-time.Hour)}
+	decode[entities.Certification](t, app.do("POST", "/api/v2/certifications", admin, expired), http.StatusCreated)
+	if w := app.do("POST", "/api/v2/machines/M1/unlock", worker, nil); w.Code != http.StatusForbidden || errorCode(t, w) != "not_certified" {
+		t.Fatalf("unlock with expired certification: status %d, want 403 not_certified: %s", w.Code, w.Body.String())
+	}
+	if n := d.requests.Load(); n != 0 {
+		t.Errorf("device got %d requests, want none", n)
+	}
+
+	valid := createCertificationRequest{UserId: user.Id, TypeId: forklift.Id, IssuedAt: now.AddDate(0, -1, 0), ExpiresAt: now.AddDate(1, 0, 0)}
+	decode[entities.Certification](t, app.do("POST", "/api/v2/certifications", admin, valid), http.StatusCreated)
+	decode[entities.Session](t, app.do("POST", "/api/v2/machines/M1/unlock", worker, nil), http.StatusCreated)
+}
+
+func TestCertificationShouldExpireAfterIssue(t *testing.T) {
+	app := newTestApp(t, nil)
+	admin := app.token("100")
+
+	forklift := decode[entities.MachineType](t, app.do("POST", "/api/v2/machine-types", admin,
+		machineTypeRequest{Name: "Forklift"}), http.StatusCreated)
+
+	now := time.Now()
+	w := app.do("POST", "/api/v2/certifications", admin,
+		createCertificationRequest{UserId: 2, TypeId: forklift.Id, IssuedAt: now, ExpiresAt: now.AddDate(0, 0, -1)})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_certification_dates" {
+		t.Errorf("status %d, want 400 invalid_certification_dates: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+)
+
+func (h *Handler) GetChecklist(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err = h.service.GetMachineTypeById(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	items, err := h.service.ListChecklistItems(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list checklist items", slog.Int("type_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, items)
+}
+
+// ReplaceChecklist replaces all items of checklist of the type, empty list removes checklist
+func (h *Handler) ReplaceChecklist(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.ReplaceChecklist")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data replaceChecklistRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	if _, err = h.service.GetMachineTypeById(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	items := make([]entities.ChecklistItem, 0, len(data.Items))
+	for _, item := range data.Items {
+		items = append(items, entities.ChecklistItem{Text: item.Text, Critical: item.Critical})
+	}
+
+	items, err = h.service.ReplaceChecklist(r.Context(), id, items)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "replace checklist", op, slog.Int("type_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "checklist is replaced", op, slog.Int("type_id", id), slog.Int("items", len(items)))
+	respondJSON(w, r, http.StatusOK, items)
+}
+
+// GetMachineChecklistV2 responds with checklist which should be answered to unlock the machine
+func (h *Handler) GetMachineChecklistV2(w http.ResponseWriter, r *http.Request) {
+	machine, err := h.getMachine(r.Context(), r.PathValue("id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	items := make([]entities.ChecklistItem, 0)
+	if machine.TypeId != 0 {
+		if items, err = h.service.ListChecklistItems(r.Context(), machine.TypeId); err != nil {
+			slog.ErrorContext(r.Context(), "list checklist items", slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+			respondError(w, r, err)
+			return
+		}
+	}
+	respondJSON(w, r, http.StatusOK, items)
+}
+
+func (h *Handler) ListChecklistSubmissions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.ChecklistSubmissionSorts)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	filter := entities.ChecklistSubmissionFilter{ListParams: params, MachineId: q.Get("machine_id")}
+	if filter.SiteId, err = listSite(r); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	for name, dst := range map[string]*int{"user_id": &filter.UserId, "session_id": &filter.SessionId} {
+		value, err := queryInt(q, name)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if value != nil {
+			*dst = *value
+		}
+	}
+
+	page, err := h.service.ListChecklistSubmissions(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list checklist submissions", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+func (h *Handler) GetChecklistSubmission(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	submission, err := h.getChecklistSubmission(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, submission)
+}
+
+// checkChecklist checks answers to checklist of the machine type before unlock.
+// It returns nil submission if the type has no checklist. When critical item fails,
+// the submission is saved, machine is put into maintenance and errs.ErrChecklistFailed is returned.
+// Machine should be acquired by caller.
+func (h *Handler) checkChecklist(ctx context.Context, user *entities.User, machine *entities.Machine, answers []checklistAnswerRequest) (*entities.ChecklistSubmission, error) {
+	op := slog.String("op", "handler.checkChecklist")
+
+	if machine.TypeId == 0 {
+		return nil, nil
+	}
+
+	items, err := h.service.ListChecklistItems(ctx, machine.TypeId)
+	if err != nil {
+		slog.ErrorContext(ctx, "list checklist items", op, slog.Int("type_id", machine.TypeId), slog.String("error", err.Error()))
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	submission, err := fillChecklist(items, answers)
+	if err != nil {
+		return nil, err
+	}
+	submission.MachineId, submission.UserId = machine.Id, user.Id
+
+	failed := submission.FailedCritical()
+	if len(failed) == 0 {
+		return submission, nil
+	}
+
+	// refused unlock is recorded even if the request is cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	if _, err = h.service.InsertChecklistSubmission(ctx, *submission); err != nil {
+		slog.ErrorContext(ctx, "insert failed checklist submission", op, slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		return nil, err
+	}
+	if _, err = h.service.UpdateMachineMaintenance(ctx, machine.Id, true); err != nil {
+		slog.ErrorContext(ctx, "put machine into maintenance", op, slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	texts := make([]string, 0, len(failed))
+	for _, a := range failed {
+		texts = append(texts, a.Text)
+	}
+	slog.WarnContext(ctx, "critical checklist items failed, machine is put into maintenance", op,
+		slog.String("machine_id", machine.Id),
+		slog.Int("user_id", user.Id),
+		slog.Any("failed", texts),
+	)
+	return nil, errs.ErrChecklistFailed.WithMessage("critical checklist items failed: " + strings.Join(texts, "; "))
+}
+
+// fillChecklist matches answers with items, every item should be answered exactly once
+func fillChecklist(items []entities.ChecklistItem, answers []checklistAnswerRequest) (*entities.ChecklistSubmission, error) {
+	byId := make(map[int]checklistAnswerRequest, len(answers))
+	for _, a := range answers {
+		if _, ok := byId[a.ItemId]; ok {
+			return nil, errs.ErrInvalidRequest.WithMessage(fmt.Sprintf("item %d is answered several times", a.ItemId))
+		}
+		byId[a.ItemId] = a
+	}
+
+	submission := &entities.ChecklistSubmission{Passed: true, SubmittedAt: time.Now(), Answers: make([]entities.ChecklistAnswer, 0, len(items))}
+	missing := make([]string, 0)
+	for _, item := range items {
+		a, ok := byId[item.Id]
+		if !ok {
+			missing = append(missing, strconv.Itoa(item.Id))
+			continue
+		}
+		delete(byId, item.Id)
+
+		submission.Answers = append(submission.Answers, entities.ChecklistAnswer{
+			ItemId:   item.Id,
+			Text:     item.Text,
+			Critical: item.Critical,
+			Ok:       a.Ok,
+			Comment:  a.Comment,
+		})
+		if item.Critical && !a.Ok {
+			submission.Passed = false
+		}
+	}
+
+	for itemId := range byId {
+		return nil, errs.ErrUnknownChecklistItem.WithMessage(fmt.Sprintf("item %d is not in checklist of the machine type", itemId))
+	}
+	if len(missing) != 0 {
+		return nil, errs.ErrChecklistRequired.WithMessage("answers to checklist items are required: " + strings.Join(missing, ", "))
+	}
+	return submission, nil
+}
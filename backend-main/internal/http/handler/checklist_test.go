@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+func TestFailedCriticalItemPutsMachineIntoMaintenance(t *testing.T) {
+	app := newTestApp(t, nil)
+	d := newDevice(t)
+	app.machine("M1", d)
+	app.machine("M2", d)
+	admin := app.token("100")
+
+	forklift := decode[entities.MachineType](t, app.do("POST", "/api/v2/machine-types", admin,
+		machineTypeRequest{Name: "Forklift"}), http.StatusCreated)
+	for _, id := range []string{"M1", "M2"} {
+		if w := app.do("PUT", "/api/v2/machines/"+id+"/type", admin, machineTypeIdRequest{TypeId: forklift.Id}); w.Code != http.StatusOK {
+			t.Fatalf("set type of %s: status %d, want 200: %s", id, w.Code, w.Body.String())
+		}
+	}
+	items := decode[[]entities.ChecklistItem](t, app.do("PUT", fmt.Sprintf("/api/v2/machine-types/%d/checklist", forklift.Id), admin,
+		replaceChecklistRequest{Items: []checklistItemRequest{{Text: "Brakes", Critical: true}, {Text: "Horn"}}}), http.StatusOK)
+	brakes, horn := items[0].Id, items[1].Id
+	app.certify("200", forklift.Id)
+	app.certify("201", forklift.Id)
+
+	w := app.do("POST", "/api/v2/machines/M1/unlock", app.token("200"), nil)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "checklist_required" {
+		t.Fatalf("unlock without answers: status %d, want 400 checklist_required: %s", w.Code, w.Body.String())
+	}
+
+	// failed item which is not critical is recorded, unlock goes on
+	decode[entities.Session](t, app.do("POST", "/api/v2/machines/M1/unlock", app.token("200"), unlockMachineV2Request{
+		Checklist: []checklistAnswerRequest{{ItemId: brakes, Ok: true}, {ItemId: horn, Ok: false, Comment: "quiet"}},
+	}), http.StatusCreated)
+
+	worker2 := app.token("201")
+	w = app.do("POST", "/api/v2/machines/M2/unlock", worker2, unlockMachineV2Request{
+		Checklist: []checklistAnswerRequest{{ItemId: brakes, Ok: false, Comment: "do not hold"}, {ItemId: horn, Ok: true}},
+	})
+	if w.Code != http.StatusConflict || errorCode(t, w) != "checklist_failed" {
+		t.Fatalf("unlock with failed brakes: status %d, want 409 checklist_failed: %s", w.Code, w.Body.String())
+	}
+
+	machine, err := app.svc.GetMachineByID(context.Background(), "M2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !machine.Maintenance || machine.State != entities.MachineFree {
+		t.Errorf("machine %+v, want free machine in maintenance", machine)
+	}
+
+	w = app.do("POST", "/api/v2/machines/M2/unlock", worker2, unlockMachineV2Request{
+		Checklist: []checklistAnswerRequest{{ItemId: brakes, Ok: true}, {ItemId: horn, Ok: true}},
+	})
+	if w.Code != http.StatusConflict || errorCode(t, w) != "machine_in_maintenance" {
+		t.Errorf("unlock in maintenance: status %d, want 409 machine_in_maintenance: %s", w.Code, w.Body.String())
+	}
+	if n := d.requests.Load(); n != 1 {
+		t.Errorf("device got %d requests, want only unlock of M1", n)
+	}
+}
+
+// certify gives user with the phone number certification of machine type valid for a year
+func (a *testApp) certify(phoneNumber string, typeId int) {
+	a.t.Helper()
+
+	user, err := a.svc.GetUserByPhoneNumber(context.Background(), phoneNumber)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	now := time.Now()
+	decode[entities.Certification](a.t, a.do("POST", "/api/v2/certifications", a.token("100"), createCertificationRequest{
+		UserId: user.Id, TypeId: typeId, IssuedAt: now.Add(-time.Hour), ExpiresAt: now.AddDate(1, 0, 0),
+	}), http.StatusCreated)
+}
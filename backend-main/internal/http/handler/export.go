@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/export"
+	"github.com/pkg/errors"
+)
+
+// ExportSessions regenerates export of finished sessions from database.
+// Range and format are taken from `from`, `to` and `format` query parameters.
+func (h *Handler) ExportSessions(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.ExportSessions")
+
+	rng, err := parseReportRange(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	formatQuery := r.URL.Query().Get("format")
+	if formatQuery == "" {
+		formatQuery = string(export.FormatCSV)
+	}
+	format, err := export.ParseFormat(formatQuery)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// the first page is loaded before headers are sent, so most errors are still answered with json
+	filter := entities.SessionFilter{
+		ListParams: entities.ListParams{Limit: entities.MaxPageLimit},
+		State:      ptr(entities.SessionFinished),
+		SiteId:     rng.SiteId,
+		From:       rng.From,
+		To:         rng.To,
+	}
+	page, err := h.service.ListSessions(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list sessions", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	// export of long range can be streamed longer than app.write_timeout
+	if err = http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(r.Context(), "failed to reset write deadline", op, slog.String("error", err.Error()))
+	}
+
+	filename := fmt.Sprintf("sessions_%s_%s.%s", rng.From.Format(time.DateOnly), rng.To.Format(time.DateOnly), format)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	if err = h.writeSessions(r.Context(), w, format, filter, page); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write sessions export", op, slog.String("error", err.Error()))
+	}
+}
+
+// writeSessions writes sessions of page and of all next pages of filter
+func (h *Handler) writeSessions(ctx context.Context, w io.Writer, format export.Format, filter entities.SessionFilter, page *entities.Page[entities.Session]) error {
+	e := export.NewEncoder(w, format)
+	if err := e.WriteHeader(); err != nil {
+		return err
+	}
+
+	workers := make(map[int]entities.User)
+	for {
+		for _, session := range page.Items {
+			worker, ok := workers[session.WorkerId]
+			if !ok {
+				user, err := h.service.GetUserByID(ctx, session.WorkerId)
+				if err != nil {
+					return errors.Wrap(err, "get worker of session")
+				}
+				worker = *user
+				workers[worker.Id] = worker
+			}
+
+			if err := e.Encode(export.NewRecord(session, worker)); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return e.Flush()
+		}
+
+		filter.Cursor = page.NextCursor
+		next, err := h.service.ListSessions(ctx, filter)
+		if err != nil {
+			return errors.Wrap(err, "list next sessions")
+		}
+		page = next
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,293 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
+)
+
+func (h *Handler) ListGeofences(w http.ResponseWriter, r *http.Request) {
+	siteId, err := listSite(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	geofences, err := h.service.ListGeofences(r.Context(), siteId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list geofences", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, geofences)
+}
+
+func (h *Handler) GetGeofence(w http.ResponseWriter, r *http.Request) {
+	geofence, err := h.pathGeofence(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, geofence)
+}
+
+func (h *Handler) CreateGeofence(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.CreateGeofence")
+
+	geofence, siteId, err := parseGeofence(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if geofence.SiteId, err = h.targetSite(r.Context(), siteId); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	created, err := h.service.InsertGeofence(r.Context(), geofence)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "insert geofence", op, slog.String("name", geofence.Name), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "geofence is created", op, slog.Int("geofence_id", created.Id), slog.Int("site_id", created.SiteId))
+	respondJSON(w, r, http.StatusCreated, created)
+}
+
+// UpdateGeofence replaces name, kind and bssids of the geofence, it stays at its site
+func (h *Handler) UpdateGeofence(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.UpdateGeofence")
+
+	stored, err := h.pathGeofence(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	geofence, _, err := parseGeofence(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	geofence.Id, geofence.SiteId = stored.Id, stored.SiteId
+
+	updated, err := h.service.UpdateGeofence(r.Context(), geofence)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update geofence", op, slog.Int("geofence_id", stored.Id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "geofence is updated", op, slog.Int("geofence_id", stored.Id))
+	respondJSON(w, r, http.StatusOK, updated)
+}
+
+// DeleteGeofence removes the geofence, its alerts are kept
+func (h *Handler) DeleteGeofence(w http.ResponseWriter, r *http.Request) {
+	geofence, err := h.pathGeofence(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err = h.service.DeleteGeofence(r.Context(), geofence.Id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "geofence is deleted", slog.Int("geofence_id", geofence.Id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListGeofenceAlerts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.GeofenceAlertSorts)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	filter := entities.GeofenceAlertFilter{ListParams: params, MachineId: q.Get("machine_id")}
+	if filter.SiteId, err = listSite(r); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if filter.Open, err = queryBool(q, "open"); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	sessionId, err := queryInt(q, "session_id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if sessionId != nil {
+		filter.SessionId = *sessionId
+	}
+
+	page, err := h.service.ListGeofenceAlerts(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list geofence alerts", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+// checkGeofences checks location of machine in use against geofences of its site. Alert is raised when machine leaves
+// permitted area and resolved when it returns, session is paused with the alert if `geofence.pause_on_violation` is set.
+// Reports of the machine should be acquired by caller, unresolved alert of the machine is returned.
+func (h *Handler) checkGeofences(ctx context.Context, machine *entities.Machine, session *entities.Session,
+	geofences []entities.Geofence, sighting *entities.Sighting) (*entities.GeofenceAlert, error) {
+	op := slog.String("op", "handler.checkGeofences")
+	idAttr := slog.String("machine_id", machine.Id)
+
+	// alerts of finished sessions are not relevant anymore, paused machine keeps its alert until it is resumed
+	if machine.State == entities.MachineFree {
+		return nil, h.resolveGeofenceAlerts(ctx, machine.Id)
+	}
+	if machine.State != entities.MachineInUse {
+		return h.openGeofenceAlert(ctx, machine.Id)
+	}
+
+	kind, geofence := entities.CheckGeofences(geofences, sighting.Bssid, sighting.ParkingId != 0)
+	if kind == "" {
+		return nil, h.resolveGeofenceAlerts(ctx, machine.Id)
+	}
+
+	alert := entities.GeofenceAlert{MachineId: machine.Id, SiteId: machine.SiteId, Kind: kind, Bssid: sighting.Bssid, CreatedAt: sighting.SeenAt}
+	if geofence != nil {
+		alert.GeofenceId = geofence.Id
+	}
+	if session != nil {
+		alert.SessionId, alert.WorkerId = session.Id, session.WorkerId
+	}
+
+	if h.cfg.Geofence.PauseOnViolation && session != nil {
+		if paused, err := h.pauseOutOfArea(ctx, machine.Id); err != nil {
+			// alert is raised anyway, machine keeps working
+			slog.ErrorContext(ctx, "pause session out of permitted area", op, idAttr, slog.String("error", err.Error()))
+		} else {
+			machine.State = entities.MachineStop
+			alert.Paused, alert.SessionId, alert.WorkerId = true, paused.Id, paused.WorkerId
+		}
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	// machine keeps one alert until it returns to permitted area, alert engine sends unresolved ones by
+	// `alerts.rules.geofence_violation`
+	created, err := h.service.InsertGeofenceAlert(ctx, alert)
+	if errors.Is(err, errs.ErrAlreadyExists) {
+		return h.openGeofenceAlert(ctx, machine.Id)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "insert geofence alert", op, idAttr, slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	slog.WarnContext(ctx, "machine left permitted area", op, idAttr,
+		slog.String("bssid", created.Bssid),
+		slog.Int("alert_id", created.Id),
+		slog.String("kind", created.Kind),
+		slog.Int("geofence_id", created.GeofenceId),
+		slog.Int("session_id", created.SessionId),
+		slog.Bool("paused", created.Paused),
+	)
+	return created, nil
+}
+
+// pauseOutOfArea pauses session with the machine like stop command. Command in progress is not waited for,
+// it changes state of the machine anyway and the next report pauses it if it is still out of permitted area.
+func (h *Handler) pauseOutOfArea(ctx context.Context, machineId string) (*entities.Session, error) {
+	release, err := h.acquireMachine(ctx, machineId)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// state could be changed by command after the report has read it
+	machine, err := h.service.GetMachineByID(ctx, machineId)
+	if err != nil {
+		return nil, errors.Wrap(err, "get machine")
+	}
+	if machine.State != entities.MachineInUse {
+		return nil, errs.ErrMachineNotInUse
+	}
+
+	sessions, err := h.service.GetActiveSessionsByMachineID(ctx, machineId)
+	if err != nil {
+		return nil, errors.Wrap(err, "get active sessions by machine")
+	}
+	if len(sessions) == 0 {
+		return nil, errs.ErrNoActiveSession
+	}
+	return h.pauseSession(ctx, machine, &sessions[0])
+}
+
+func (h *Handler) resolveGeofenceAlerts(ctx context.Context, machineId string) error {
+	resolved, err := h.service.ResolveGeofenceAlerts(ctx, machineId, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "resolve geofence alerts", slog.String("machine_id", machineId), slog.String("error", err.Error()))
+		return err
+	}
+	for _, a := range resolved {
+		slog.InfoContext(ctx, "geofence alert is resolved", slog.String("machine_id", machineId), slog.Int("alert_id", a.Id))
+	}
+	return nil
+}
+
+// openGeofenceAlert returns unresolved alert of the machine, nil if there is none
+func (h *Handler) openGeofenceAlert(ctx context.Context, machineId string) (*entities.GeofenceAlert, error) {
+	open := true
+	page, err := h.service.ListGeofenceAlerts(ctx, entities.GeofenceAlertFilter{
+		ListParams: entities.ListParams{Limit: 1}, MachineId: machineId, Open: &open,
+	})
+	if err != nil || len(page.Items) == 0 {
+		return nil, err
+	}
+	return &page.Items[0], nil
+}
+
+// pathGeofence returns geofence from `id` path parameter, geofences of other sites are not found
+func (h *Handler) pathGeofence(r *http.Request) (*entities.Geofence, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	geofence, err := h.service.GetGeofence(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if !inScope(r.Context(), geofence.SiteId) {
+		return nil, errs.ErrGeofenceNotFound
+	}
+	return geofence, nil
+}
+
+// parseGeofence parses and validates geofence from request body, requested site is returned separately
+func parseGeofence(r *http.Request) (entities.Geofence, int, error) {
+	var data geofenceRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		return entities.Geofence{}, 0, errs.ErrInvalidRequest.Wrap(err)
+	}
+
+	geofence := entities.Geofence{Name: data.Name, Kind: data.Kind, Bssids: make([]string, 0, len(data.Bssids))}
+	for _, b := range data.Bssids {
+		geofence.Bssids = append(geofence.Bssids, entities.NormalizeBssid(b))
+	}
+	if err := geofence.Validate(); err != nil {
+		return entities.Geofence{}, 0, errs.ErrInvalidGeofence.WithMessage(err.Error())
+	}
+	return geofence, data.SiteId, nil
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/config"
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+)
+
+const forbiddenBssid = "aa:bb:cc:dd:ee:01"
+
+func TestGeofenceViolationPausesSession(t *testing.T) {
+	app := newTestApp(t, func(cfg *config.Config) { cfg.Geofence.PauseOnViolation = true })
+	ctx := context.Background()
+
+	app.machine("M1", newDevice(t))
+	app.machine("M2", newDevice(t))
+	if _, err := app.svc.InsertGeofence(ctx, entities.Geofence{Name: "dock", Kind: entities.GeofenceForbidden,
+		Bssids: []string{forbiddenBssid}, SiteId: entities.DefaultSiteId}); err != nil {
+		t.Fatal(err)
+	}
+
+	session := decode[entities.Session](t, app.do("POST", "/api/v2/machines/M1/unlock", app.token("200"), nil), http.StatusCreated)
+	report := map[string]any{"bssid": forbiddenBssid}
+
+	// reports without token of the machine are not trusted
+	for _, token := range []string{"", middlewares.DeviceToken(app.cfg.MC.DeviceSecret, "M2"), "M1"} {
+		w := app.do("POST", "/api/v2/machines/M1/bssid", "", report, middlewares.DeviceTokenHeader, token)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want 401: %s", token, w.Code, w.Body.String())
+		}
+	}
+	if machine, err := app.svc.GetMachineByID(ctx, "M1"); err != nil || machine.State != entities.MachineInUse {
+		t.Fatalf("machine after unauthenticated reports: %+v, %v", machine, err)
+	}
+
+	token := decode[deviceTokenResponse](t, app.do("GET", "/api/v2/machines/M1/device-token", app.token("100"), nil), http.StatusOK).DeviceToken
+	resp := decode[bssidReportResponse](t, app.do("POST", "/api/v2/machines/M1/bssid", "", report, middlewares.DeviceTokenHeader, token), http.StatusOK)
+	if resp.Alert == nil || !resp.Alert.Paused || resp.Alert.SessionId != session.Id {
+		t.Fatalf("alert %+v, want paused alert of session %d", resp.Alert, session.Id)
+	}
+	if resp.CurrentState != entities.MachineStop {
+		t.Errorf("state %d, want %d", resp.CurrentState, entities.MachineStop)
+	}
+
+	paused, err := app.svc.GetPausedSessionsByMachineID(ctx, "M1")
+	if err != nil || len(paused) != 1 || paused[0].Id != session.Id {
+		t.Errorf("paused sessions %+v, %v, want session %d", paused, err, session.Id)
+	}
+}
+
+func TestBssidCaseDoesNotMatter(t *testing.T) {
+	app := newTestApp(t, nil)
+	ctx := context.Background()
+	admin := app.token("100")
+
+	app.machine("M1", newDevice(t))
+	w := app.do("POST", "/api/v2/geofences", admin, map[string]any{"name": "hall", "kind": entities.GeofenceAllowed,
+		"bssids": []string{"AA:BB:CC:DD:EE:10"}})
+	if geofence := decode[entities.Geofence](t, w, http.StatusCreated); geofence.Bssids[0] != "aa:bb:cc:dd:ee:10" {
+		t.Errorf("bssid of geofence is stored as %q", geofence.Bssids[0])
+	}
+	w = app.do("POST", "/api/v2/parkings", admin, map[string]any{"name": "P2", "mac_addr": "AA:BB:CC:DD:EE:20", "state": 1})
+	parking := decode[entities.Parking](t, w, http.StatusCreated)
+
+	decode[entities.Session](t, app.do("POST", "/api/v2/machines/M1/unlock", app.token("200"), nil), http.StatusCreated)
+	token := middlewares.DeviceToken(app.cfg.MC.DeviceSecret, "M1")
+
+	// geofence and parking are recognised in reports in other case, so neither is a violation
+	for _, bssid := range []string{"AA:bb:CC:dd:EE:10", "aa:bb:cc:dd:ee:20", "Aa:Bb:Cc:Dd:Ee:20"} {
+		w = app.do("POST", "/api/v2/machines/M1/bssid", "", map[string]any{"bssid": bssid}, middlewares.DeviceTokenHeader, token)
+		if resp := decode[bssidReportResponse](t, w, http.StatusOK); resp.Alert != nil {
+			t.Errorf("report of %s raised alert %+v", bssid, resp.Alert)
+		}
+	}
+
+	last, err := app.svc.LastSightings(ctx, []string{"M1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := last["M1"]; s.Bssid != "aa:bb:cc:dd:ee:20" || s.ParkingId != parking.Id {
+		t.Errorf("last sighting %+v, want normalized bssid at parking %d", s, parking.Id)
+	}
+}
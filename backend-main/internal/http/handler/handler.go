@@ -2,75 +2,95 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/ecol-master/sharing-wh-machines/internal/alerts"
 	"github.com/ecol-master/sharing-wh-machines/internal/config"
-	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/export"
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
 	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/openapi"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/blob"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/ratelimit"
 	"github.com/ecol-master/sharing-wh-machines/internal/service"
-	"github.com/jmoiron/sqlx"
 )
 
 type Handler struct {
 	service *service.Service
 	cfg     *config.Config
 	qrKey   string
-}
+	spec    *openapi.Document
 
-func New(db *sqlx.DB, cfg *config.Config) *Handler {
-	return &Handler{
-		service: service.New(db),
-		cfg:     cfg,
-		qrKey:   newQrKey(),
-	}
-}
-
-func (h *Handler) MakeHTTPHandler() http.Handler {
-	mux := http.NewServeMux()
+	// sessionLog gets every finished session
+	sessionLog *export.FileWriter
 
-	// api methods for web-application
-	mux.Handle("GET /get_all_users", h.makeAdminHandler(h.GetAllUsers))
-	mux.Handle("GET /get_user", h.makeAdminHandler(h.GetUserByID))
+	// health checks dependencies and background workers for /healthz and /readyz
+	health *health.Checker
 
-	mux.Handle("GET /get_parking_machines", h.makeAdminHandler(h.GetMachinesByParkingName))
-	mux.Handle("GET /get_all_machines", h.makeAdminHandler(h.GetAllMachines))
-	mux.Handle("GET /get_machine", h.makeAdminHandler(h.GetMachineByID))
+	// photos stores images attached to incidents
+	photos blob.Store
 
-	mux.Handle("GET /get_all_sessions", h.makeAdminHandler(h.GetAllSessions))
-	mux.Handle("GET /get_session", h.makeAdminHandler(h.GetSessionByID))
+	// alerts raises alerts about failed commands and geofence violations by rules from config
+	alerts *alerts.Engine
 
-	// New handlers for parkings
-	mux.Handle("GET /get_all_parkings", h.makeAdminHandler(h.GetAllParkings))
-	mux.Handle("GET /get_parking", h.makeAdminHandler(h.GetParkingById))
-	mux.Handle("POST /register_parking", h.makeAdminHandler(h.RegisterParking))
-	mux.Handle("PUT /update_parking_state", h.makeAdminHandler(h.UpdateParkingState))
-	mux.Handle("PUT /update_parking_capacity", h.makeAdminHandler(h.UpdateParkingCapacity))
-	mux.Handle("PUT /add_machine", h.makeAdminHandler(h.ManualyMoveParkingMachine))
-
-	// auth
-	mux.HandleFunc("POST /login", h.Login)
-
-	// handlers to work with qr
-	mux.Handle("GET /get_qr_key", h.makeWorkerHandler(h.GetQrKey))
-	mux.Handle("POST /finish_session", h.makeWorkerHandler(h.FinishSession))
+	// login attempts per client ip and per phone number
+	ipLimiter    *ratelimit.Limiter
+	phoneLimiter *ratelimit.Limiter
+}
 
-	// Lock, Unlock, Pause handler
-	mux.Handle("POST /unlock_machine", h.makeWorkerHandler(h.UnlockMachine))
-	mux.Handle("POST /lock_machine", h.makeWorkerHandler(h.LockMachine))
-	mux.Handle("POST /stop_machine", h.makeWorkerHandler(h.StopMachine))
-	mux.Handle("POST /unstop_machine", h.makeWorkerHandler(h.UnstopMachine))
+func New(svc *service.Service, cfg *config.Config, sessionLog *export.FileWriter, checker *health.Checker, photos blob.Store,
+	alertEngine *alerts.Engine) *Handler {
+	h := &Handler{
+		service:    svc,
+		cfg:        cfg,
+		qrKey:      newQrKey(),
+		sessionLog: sessionLog,
+		health:     checker,
+		photos:     photos,
+		alerts:     alertEngine,
+
+		ipLimiter:    ratelimit.New(cfg.Login.IPLimit, time.Minute),
+		phoneLimiter: ratelimit.New(cfg.Login.PhoneLimit, time.Minute),
+	}
+	h.spec = newSpec(h.apiRoutes())
+	return h
+}
 
-	// handler to register (or make active after failed) arduino in system
-	mux.Handle("POST /register_machine", http.HandlerFunc(h.RegisterMachine))
+func (h *Handler) MakeHTTPHandler() http.Handler {
+	mux := h.routes()
 
-	// logging all request with LoggingMiddleware
-	return middlewares.CorsEnableMiddleware(middlewares.LoggingMiddleware(mux))
+	// logging all request with LoggingMiddleware, request id is added to all logs of the request
+	return middlewares.CorsEnableMiddleware(middlewares.RequestIDMiddleware(middlewares.LoggingMiddleware(mux)))
 }
 
-// function making handler from RoleBasedAccess middleware with entities.Admin role
-func (h *Handler) makeAdminHandler(handleFunc func(w http.ResponseWriter, r *http.Request)) http.Handler {
-	return middlewares.RoleBasedAccess(h.cfg.Secret, entities.Admin, http.HandlerFunc(handleFunc))
+// routes registers every route of apiRoutes with middlewares described by its op
+func (h *Handler) routes() *router {
+	mux := newRouter()
+	for pattern, o := range h.apiRoutes() {
+		mux.Handle(pattern, h.wrap(o))
+	}
+	return mux
 }
 
-func (h *Handler) makeWorkerHandler(handleFunc func(w http.ResponseWriter, r *http.Request)) http.Handler {
-	return middlewares.RoleBasedAccess(h.cfg.Secret, entities.Worker, http.HandlerFunc(handleFunc))
+// wrap adds middlewares described by op to handler of the route.
+// Body is validated after auth, so unauthorized requests get 401 and 403 instead of schema errors
+func (h *Handler) wrap(o op) http.Handler {
+	var handler http.Handler = o.handle
+	if o.idempotent {
+		// outcome of command sent to machine is replayed for repeated Idempotency-Key
+		handler = middlewares.Idempotent(h.service, h.cfg.Idempotency.TTL, handler)
+	}
+	if schema, ok := o.operation().BodySchema(); ok {
+		handler = middlewares.ValidateBody(schema, handler)
+	}
+
+	if o.device {
+		// called only by controller with token of the machine from path
+		return middlewares.RequireDevice(h.cfg.MC.DeviceSecret, handler)
+	}
+	if o.permission != "" {
+		// called only by users whose role has the permission
+		return middlewares.RequirePermission(h.cfg.Secret, h.service, o.permission, handler)
+	}
+	return handler
 }
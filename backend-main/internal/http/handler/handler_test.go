@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/alerts"
+	"github.com/ecol-master/sharing-wh-machines/internal/config"
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/export"
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/blob"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/notify"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+)
+
+// testUsers are seeded into every test app, tokens are issued by phone number
+var testUsers = []entities.User{
+	{Name: "Admin", PhoneNumber: "100", JobPosition: entities.Admin, Password: "admin"},
+	{Name: "Worker", PhoneNumber: "200", JobPosition: entities.Worker, Password: "worker"},
+	{Name: "Worker 2", PhoneNumber: "201", JobPosition: entities.Worker, Password: "worker"},
+	{Name: "Site Admin", PhoneNumber: "300", JobPosition: entities.SiteAdmin, Password: "site-admin"},
+	{Name: "Keeper", PhoneNumber: "400", JobPosition: "keeper", Password: "keeper"}, // role is created by tests
+}
+
+type testApp struct {
+	t        *testing.T
+	svc      *service.Service
+	cfg      *config.Config
+	handler  *Handler
+	server   http.Handler
+	photoDir string
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		TokenTTL:    time.Hour,
+		Secret:      "test-secret",
+		MC:          config.MicrocontrollerConfig{RequestTimeout: time.Second, DeviceSecret: "test-device-secret"},
+		Idempotency: config.IdempotencyConfig{TTL: time.Hour},
+		Login:       config.LoginConfig{IPLimit: 1000, PhoneLimit: 1000, MaxFailures: 5, FailureWindow: time.Minute, Lockout: time.Minute},
+		Incidents:   config.IncidentsConfig{MaxPhotoMB: 1},
+		Alerts:      config.AlertsConfig{CheckInterval: time.Minute, SendTimeout: time.Second, QueueSize: 100},
+	}
+}
+
+// newTestApp builds handler with in-memory storage, cfg is changed by configure before the handler is created
+func newTestApp(t *testing.T, configure func(cfg *config.Config)) *testApp {
+	t.Helper()
+
+	cfg := testConfig()
+	if configure != nil {
+		configure(cfg)
+	}
+
+	svc, err := service.NewInMemory(context.Background(), testUsers)
+	if err != nil {
+		t.Fatalf("create in-memory service: %v", err)
+	}
+
+	photoDir := t.TempDir()
+	photos, err := blob.NewDisk(photoDir)
+	if err != nil {
+		t.Fatalf("create photo store: %v", err)
+	}
+
+	sessionLog, err := export.NewFileWriter(t.TempDir(), []export.Format{export.FormatCSV})
+	if err != nil {
+		t.Fatalf("create session log: %v", err)
+	}
+	t.Cleanup(func() { sessionLog.Close() })
+
+	engine, err := alerts.New(cfg.Alerts, svc, map[string]notify.Sink{})
+	if err != nil {
+		t.Fatalf("create alert engine: %v", err)
+	}
+
+	h := New(svc, cfg, sessionLog, health.New(), photos, engine)
+	return &testApp{t: t, svc: svc, cfg: cfg, handler: h, server: h.MakeHTTPHandler(), photoDir: photoDir}
+}
+
+// token issues token of seeded user with the phone number
+func (a *testApp) token(phoneNumber string) string {
+	a.t.Helper()
+
+	user, err := a.svc.GetUserByPhoneNumber(context.Background(), phoneNumber)
+	if err != nil {
+		a.t.Fatalf("get user %s: %v", phoneNumber, err)
+	}
+	token, err := a.svc.GenerateToken(*user, a.cfg.Secret, a.cfg.TokenTTL)
+	if err != nil {
+		a.t.Fatalf("generate token: %v", err)
+	}
+	return token
+}
+
+// do sends request with json body, []byte body is sent as is, nil body is sent empty
+func (a *testApp) do(method, path, token string, body any, header ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+
+	var reader io.Reader = http.NoBody
+	if raw, ok := body.([]byte); ok {
+		reader = bytes.NewReader(raw)
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(method, path, reader)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	a.server.ServeHTTP(w, r)
+	return w
+}
+
+// device is stand-in of machine controller which accepts every state.
+// Requests wait for hold to be closed if it is set.
+type device struct {
+	*httptest.Server
+	requests atomic.Int32
+	hold     chan struct{}
+}
+
+func newDevice(t *testing.T) *device {
+	t.Helper()
+
+	d := &device{}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.requests.Add(1)
+		if d.hold != nil {
+			<-d.hold
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(d.Close)
+	return d
+}
+
+// machine registers free machine with controller at the device
+func (a *testApp) machine(id string, d *device) *entities.Machine {
+	a.t.Helper()
+
+	machine, err := a.svc.InsertMachine(context.Background(), id, d.Listener.Addr().String())
+	if err != nil {
+		a.t.Fatalf("insert machine %s: %v", id, err)
+	}
+	return machine
+}
+
+// decode checks status of response and decodes its body into v
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+
+	var v T
+	if w.Code != status {
+		t.Fatalf("status %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+// errorCode returns code of error response
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error %s: %v", w.Body.String(), err)
+	}
+	return body.Code
+}
+
+func TestSpecDescribesRoutes(t *testing.T) {
+	app := newTestApp(t, nil)
+
+	w := app.do("GET", "/openapi.json", "", nil)
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+
+	patterns := app.handler.routes().patterns
+	for _, pattern := range patterns {
+		method, path, _ := strings.Cut(pattern, " ")
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %s is not described in spec", pattern)
+		}
+	}
+
+	described := 0
+	for _, item := range spec.Paths {
+		described += len(item)
+	}
+	if described != len(patterns) {
+		t.Errorf("spec describes %d routes, %d are registered", described, len(patterns))
+	}
+}
+
+func TestBodyIsValidatedAfterAuth(t *testing.T) {
+	app := newTestApp(t, nil)
+	invalid := map[string]any{"name": 1}
+
+	if w := app.do("POST", "/api/v2/parkings", "", invalid); w.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status %d, want 401: %s", w.Code, w.Body.String())
+	}
+	if w := app.do("POST", "/api/v2/parkings", app.token("200"), invalid); w.Code != http.StatusForbidden {
+		t.Errorf("without permission: status %d, want 403: %s", w.Code, w.Body.String())
+	}
+	if w := app.do("POST", "/api/v2/parkings", app.token("100"), invalid); w.Code != http.StatusBadRequest {
+		t.Errorf("with permission: status %d, want 400: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
+)
+
+// Healthz responds with 503 if some background worker is stuck, the app should be restarted then
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	respondHealth(w, r, h.health.Live())
+}
+
+// Readyz responds with 503 while db is unreachable, its schema is outdated or some background worker is stuck
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	respondHealth(w, r, h.health.Ready(r.Context()))
+}
+
+func respondHealth(w http.ResponseWriter, r *http.Request, report health.Report) {
+	if !report.OK() {
+		slog.WarnContext(r.Context(), "health check failed", slog.String("path", r.URL.Path), slog.Any("checks", report.Checks))
+		respondJSON(w, r, http.StatusServiceUnavailable, report)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+)
+
+func TestUnlockWithIdempotencyKeyIsReplayed(t *testing.T) {
+	app := newTestApp(t, nil)
+	d := newDevice(t)
+	app.machine("M1", d)
+	token := app.token("200")
+
+	first := app.do("POST", "/api/v2/machines/M1/unlock", token, nil, middlewares.IdempotencyKeyHeader, "key-1")
+	session := decode[entities.Session](t, first, http.StatusCreated)
+
+	second := app.do("POST", "/api/v2/machines/M1/unlock", token, nil, middlewares.IdempotencyKeyHeader, "key-1")
+	replayed := decode[entities.Session](t, second, http.StatusCreated)
+	if second.Header().Get(middlewares.IdempotentReplayHeader) != "true" {
+		t.Errorf("repeated request is not marked as replayed")
+	}
+	if replayed.Id != session.Id {
+		t.Errorf("replayed session %d, want %d", replayed.Id, session.Id)
+	}
+	if n := d.requests.Load(); n != 1 {
+		t.Errorf("device got %d requests, want 1", n)
+	}
+
+	sessions, err := app.svc.GetActiveSessionsByMachineID(context.Background(), "M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("%d active sessions, want 1", len(sessions))
+	}
+}
+
+func TestIdempotencyKeyOfOtherRequestIsRejected(t *testing.T) {
+	app := newTestApp(t, nil)
+	d := newDevice(t)
+	app.machine("M1", d)
+	app.machine("M2", d)
+	token := app.token("200")
+
+	w := app.do("POST", "/api/v2/machines/M1/unlock", token, nil, middlewares.IdempotencyKeyHeader, "key-1")
+	decode[entities.Session](t, w, http.StatusCreated)
+
+	w = app.do("POST", "/api/v2/machines/M2/unlock", token, nil, middlewares.IdempotencyKeyHeader, "key-1")
+	if code := errorCode(t, w); code != "idempotency_key_reused" {
+		t.Errorf("status %d, code %q, want idempotency_key_reused", w.Code, code)
+	}
+
+	// key belongs to the user, other users may use the same one
+	w = app.do("POST", "/api/v2/machines/M2/unlock", app.token("201"), nil, middlewares.IdempotencyKeyHeader, "key-1")
+	decode[entities.Session](t, w, http.StatusCreated)
+}
+
+func TestBusyMachineIsNotReplayed(t *testing.T) {
+	app := newTestApp(t, nil)
+	d := newDevice(t)
+	app.machine("M1", d)
+	token := app.token("200")
+
+	release, err := app.svc.AcquireMachine(context.Background(), "M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := app.do("POST", "/api/v2/machines/M1/unlock", token, nil, middlewares.IdempotencyKeyHeader, "key-1")
+	if w.Code != http.StatusConflict || errorCode(t, w) != "machine_busy" {
+		t.Fatalf("status %d, want 409 machine_busy: %s", w.Code, w.Body.String())
+	}
+	release()
+
+	// the retry with the same key runs the command
+	w = app.do("POST", "/api/v2/machines/M1/unlock", token, nil, middlewares.IdempotencyKeyHeader, "key-1")
+	decode[entities.Session](t, w, http.StatusCreated)
+	if w.Header().Get(middlewares.IdempotentReplayHeader) != "" {
+		t.Errorf("retry after busy machine is replayed")
+	}
+	if n := d.requests.Load(); n != 1 {
+		t.Errorf("device got %d requests, want 1", n)
+	}
+}
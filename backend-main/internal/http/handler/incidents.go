@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/blob"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// photoExtensions are supported types of incident photos with extensions of stored files
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+func photoContentTypes() []string {
+	types := make([]string, 0, len(photoExtensions))
+	for ct := range photoExtensions {
+		types = append(types, ct)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// incidentTransitions lists statuses incident can be moved to from the status
+var incidentTransitions = map[entities.IncidentStatus][]entities.IncidentStatus{
+	entities.IncidentOpen:         {entities.IncidentAcknowledged, entities.IncidentResolved, entities.IncidentRejected},
+	entities.IncidentAcknowledged: {entities.IncidentResolved, entities.IncidentRejected},
+}
+
+// ReportIncident creates incident with the machine, it is linked to unfinished session of the reporter with the machine.
+// Critical incident puts the machine into maintenance.
+func (h *Handler) ReportIncident(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.ReportIncident")
+
+	var data reportIncidentRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	userId, err := userIdFromContext(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user_id from context", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err = h.getMachine(r.Context(), data.MachineId); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	sessionId, err := h.reporterSession(r.Context(), int(userId), data.MachineId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get session of reporter", op, slog.String("machine_id", data.MachineId), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	// the machine may be dangerous, so the rest is done even if the request is cancelled
+	ctx := context.WithoutCancel(r.Context())
+
+	incident, err := h.service.InsertIncident(ctx, entities.Incident{
+		MachineId:   data.MachineId,
+		SessionId:   sessionId,
+		ReporterId:  int(userId),
+		Category:    data.Category,
+		Severity:    data.Severity,
+		Description: data.Description,
+		Status:      entities.IncidentOpen,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "insert incident", op, slog.String("machine_id", data.MachineId), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(ctx, "incident is reported", op,
+		slog.Int("incident_id", incident.Id),
+		slog.String("machine_id", incident.MachineId),
+		slog.Int("session_id", incident.SessionId),
+		slog.String("category", incident.Category),
+		slog.String("severity", incident.Severity),
+	)
+
+	if incident.Severity == entities.SeverityCritical {
+		if err = h.maintainAfterIncident(ctx, incident); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+	respondJSON(w, r, http.StatusCreated, newIncidentResponse(*incident))
+}
+
+// reporterSession returns id of active or paused session of the user with the machine, 0 if there is none
+func (h *Handler) reporterSession(ctx context.Context, userId int, machineId string) (int, error) {
+	sessions, err := h.service.GetActiveSessionsByMachineAndUser(ctx, machineId, userId)
+	if err != nil {
+		return 0, errors.Wrap(err, "get active sessions")
+	}
+	if len(sessions) == 0 {
+		if sessions, err = h.service.GetPausedSessionsByMachineAndUser(ctx, machineId, userId); err != nil {
+			return 0, errors.Wrap(err, "get paused sessions")
+		}
+	}
+
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+	return sessions[0].Id, nil
+}
+
+// maintainAfterIncident puts machine of critical incident into maintenance,
+// session in progress is not interrupted, but the machine can not be unlocked again
+func (h *Handler) maintainAfterIncident(ctx context.Context, incident *entities.Incident) error {
+	if _, err := h.service.UpdateMachineMaintenance(ctx, incident.MachineId, true); err != nil {
+		slog.ErrorContext(ctx, "put machine into maintenance", slog.Int("incident_id", incident.Id),
+			slog.String("machine_id", incident.MachineId), slog.String("error", err.Error()))
+		return err
+	}
+
+	slog.WarnContext(ctx, "machine is put into maintenance after critical incident",
+		slog.Int("incident_id", incident.Id), slog.String("machine_id", incident.MachineId))
+	return nil
+}
+
+func (h *Handler) ListIncidents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.IncidentSorts)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	filter := entities.IncidentFilter{
+		ListParams: params,
+		MachineId:  q.Get("machine_id"),
+		Status:     q.Get("status"),
+		Severity:   q.Get("severity"),
+		Category:   q.Get("category"),
+	}
+	if filter.SiteId, err = listSite(r); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	reporterId, err := queryInt(q, "reporter_id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if reporterId != nil {
+		filter.ReporterId = *reporterId
+	}
+
+	page, err := h.service.ListIncidents(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list incidents", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	resp := entities.Page[incidentResponse]{Items: make([]incidentResponse, 0, len(page.Items)), NextCursor: page.NextCursor}
+	for _, incident := range page.Items {
+		resp.Items = append(resp.Items, newIncidentResponse(incident))
+	}
+	respondJSON(w, r, http.StatusOK, resp)
+}
+
+func (h *Handler) GetIncident(w http.ResponseWriter, r *http.Request) {
+	incident, err := h.pathIncident(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, newIncidentResponse(*incident))
+}
+
+// TriageIncident changes status, severity and resolution of incident.
+// Closed incidents can not be changed, raising severity to critical puts the machine into maintenance.
+func (h *Handler) TriageIncident(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.TriageIncident")
+
+	var data triageIncidentRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	userId, err := userIdFromContext(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user_id from context", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	incident, err := h.pathIncident(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if incident.Closed() {
+		respondError(w, r, errs.ErrIncidentClosed)
+		return
+	}
+
+	wasCritical := incident.Severity == entities.SeverityCritical
+	now := time.Now()
+
+	if data.Status != nil && *data.Status != incident.Status {
+		if !slices.Contains(incidentTransitions[incident.Status], *data.Status) {
+			respondError(w, r, errs.ErrInvalidIncidentStatus.WithMessage(
+				fmt.Sprintf("incident can not be moved from %s to %s", incident.Status, *data.Status)))
+			return
+		}
+		incident.Status = *data.Status
+		if incident.Closed() {
+			incident.ClosedAt = &now
+		}
+	}
+	if data.Severity != nil {
+		incident.Severity = *data.Severity
+	}
+	if data.Resolution != nil {
+		incident.Resolution = *data.Resolution
+	}
+	incident.TriagedBy, incident.UpdatedAt = int(userId), now
+
+	incident, err = h.service.UpdateIncident(r.Context(), *incident)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update incident", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "incident is triaged", op,
+		slog.Int("incident_id", incident.Id),
+		slog.String("status", incident.Status),
+		slog.String("severity", incident.Severity),
+		slog.Int("triaged_by", incident.TriagedBy),
+	)
+
+	if !wasCritical && incident.Severity == entities.SeverityCritical && !incident.Closed() {
+		if err = h.maintainAfterIncident(context.WithoutCancel(r.Context()), incident); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+	respondJSON(w, r, http.StatusOK, newIncidentResponse(*incident))
+}
+
+// UploadIncidentPhoto stores image from request body as photo of incident, previous photo is replaced.
+// Only reporter of the incident and users with incidents.manage permission can upload it.
+func (h *Handler) UploadIncidentPhoto(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.UploadIncidentPhoto")
+
+	userId, err := userIdFromContext(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user_id from context", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	incident, err := h.pathIncident(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if role, ok := middlewares.RoleFromContext(r.Context()); incident.ReporterId != int(userId) && !(ok && role.Has(entities.PermIncidentsManage)) {
+		respondError(w, r, errs.ErrAccessDenied)
+		return
+	}
+
+	maxSize := int64(h.cfg.Incidents.MaxPhotoMB) << 20
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+	if int64(len(data)) > maxSize {
+		respondError(w, r, errs.ErrPhotoTooLarge.WithMessage(fmt.Sprintf("photo should be at most %d MB", h.cfg.Incidents.MaxPhotoMB)))
+		return
+	}
+
+	// type is taken from content, header of the client is not trusted
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	ext, ok := photoExtensions[contentType]
+	if !ok {
+		respondError(w, r, errs.ErrUnsupportedPhoto)
+		return
+	}
+
+	ctx := context.WithoutCancel(r.Context())
+	key := fmt.Sprintf("incidents/%d/%d%s", incident.Id, time.Now().UnixNano(), ext)
+	if err = h.photos.Put(ctx, key, contentType, data); err != nil {
+		slog.ErrorContext(ctx, "store incident photo", op, slog.Int("incident_id", incident.Id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	updated, previous, err := h.service.SetIncidentPhoto(ctx, incident.Id, key, contentType)
+	if err != nil {
+		slog.ErrorContext(ctx, "set incident photo", op, slog.Int("incident_id", incident.Id), slog.String("error", err.Error()))
+		h.deletePhoto(ctx, key)
+		respondError(w, r, err)
+		return
+	}
+	if previous != "" {
+		h.deletePhoto(ctx, previous)
+	}
+
+	slog.InfoContext(ctx, "incident photo is uploaded", op, slog.Int("incident_id", updated.Id), slog.Int("size", len(data)))
+	respondJSON(w, r, http.StatusOK, newIncidentResponse(*updated))
+}
+
+// deletePhoto removes photo which is not referenced by incident anymore, failure only leaves orphan object in storage
+func (h *Handler) deletePhoto(ctx context.Context, key string) {
+	if err := h.photos.Delete(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "delete incident photo", slog.String("key", key), slog.String("error", err.Error()))
+	}
+}
+
+func (h *Handler) GetIncidentPhoto(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.GetIncidentPhoto")
+
+	incident, err := h.pathIncident(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if incident.PhotoKey == "" {
+		respondError(w, r, errs.ErrPhotoNotFound)
+		return
+	}
+
+	data, err := h.photos.Get(r.Context(), incident.PhotoKey)
+	if errors.Is(err, blob.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "photo of incident is missing in storage", op, slog.Int("incident_id", incident.Id),
+			slog.String("key", incident.PhotoKey))
+		respondError(w, r, errs.ErrPhotoNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get incident photo", op, slog.Int("incident_id", incident.Id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", incident.PhotoContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(data); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write photo", op, slog.Int("incident_id", incident.Id), slog.String("error", err.Error()))
+	}
+}
+
+func (h *Handler) pathIncident(r *http.Request) (*entities.Incident, error) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	incident, err := h.service.GetIncident(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.checkMachineScope(r.Context(), incident.MachineId); errors.Is(err, errs.ErrMachineNotFound) {
+		return nil, errs.ErrIncidentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return incident, nil
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplacedIncidentPhotoIsDeleted(t *testing.T) {
+	app := newTestApp(t, nil)
+	app.machine("M1", newDevice(t))
+	token := app.token("200")
+
+	incident := decode[incidentResponse](t, app.do("POST", "/api/v2/incidents", token, map[string]any{
+		"machine_id": "M1", "category": "damage", "severity": "low",
+	}), http.StatusCreated)
+
+	png := []byte("\x89PNG\r\n\x1a\n photo")
+	path := fmt.Sprintf("/api/v2/incidents/%d/photo", incident.Id)
+	decode[incidentResponse](t, app.do("PUT", path, token, png), http.StatusOK)
+	decode[incidentResponse](t, app.do("PUT", path, token, png), http.StatusOK)
+
+	files, err := os.ReadDir(filepath.Join(app.photoDir, "incidents", fmt.Sprint(incident.Id)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d photos are stored, want 1", len(files))
+	}
+
+	w := app.do("GET", path, app.token("100"), nil)
+	if w.Code != http.StatusOK || w.Body.String() != string(png) {
+		t.Errorf("get photo: status %d: %q", w.Code, w.Body.String())
+	}
+}
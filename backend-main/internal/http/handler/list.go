@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/pkg/errors"
+)
+
+// nextCursorHeader is used by v1 list routes, they respond with plain json array
+const nextCursorHeader = "X-Next-Cursor"
+
+func (h *Handler) listUsers(r *http.Request) (*entities.Page[entities.User], error) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.UserSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := entities.UserFilter{ListParams: params, JobPosition: q.Get("job_position")}
+	if filter.SiteId, err = listSite(r); err != nil {
+		return nil, err
+	}
+	if filter.JobPosition != "" {
+		if _, err = h.service.GetRole(r.Context(), filter.JobPosition); errors.Is(err, errs.ErrRoleNotFound) {
+			return nil, errs.ErrInvalidRequest.WithMessage("job_position should be name of existing role")
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return h.service.ListUsers(r.Context(), filter)
+}
+
+func (h *Handler) listMachines(r *http.Request) (*entities.Page[entities.Machine], error) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.MachineSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := entities.MachineFilter{ListParams: params}
+	if filter.SiteId, err = listSite(r); err != nil {
+		return nil, err
+	}
+	if filter.State, err = queryState(q, entities.MachineFree, entities.MachineInUse); err != nil {
+		return nil, err
+	}
+	if filter.ParkingId, err = queryInt(q, "parking_id"); err != nil {
+		return nil, err
+	}
+	if filter.TypeId, err = queryInt(q, "type_id"); err != nil {
+		return nil, err
+	}
+
+	page, err := h.service.ListMachines(r.Context(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.withLastLocations(r.Context(), page.Items); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (h *Handler) listParkings(r *http.Request) (*entities.Page[entities.Parking], error) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.ParkingSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := entities.ParkingFilter{ListParams: params}
+	if filter.SiteId, err = listSite(r); err != nil {
+		return nil, err
+	}
+	state, err := queryState(q, int(entities.ParkingInactive), int(entities.ParkingActive))
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		parkingState := entities.ParkingState(*state)
+		filter.State = &parkingState
+	}
+	return h.service.ListParkings(r.Context(), filter)
+}
+
+func (h *Handler) listSessions(r *http.Request) (*entities.Page[entities.Session], error) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.SessionSorts)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := entities.SessionFilter{ListParams: params, MachineId: q.Get("machine_id")}
+	if filter.SiteId, err = listSite(r); err != nil {
+		return nil, err
+	}
+	if filter.State, err = queryState(q, entities.SessionActive, entities.SessionFinished); err != nil {
+		return nil, err
+	}
+
+	for name, dst := range map[string]*int{"worker_id": &filter.WorkerId, "parking_id": &filter.ParkingId} {
+		value, err := queryInt(q, name)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			*dst = *value
+		}
+	}
+
+	if filter.From, err = queryTime(q, "from"); err != nil {
+		return nil, err
+	}
+	if filter.To, err = queryTime(q, "to"); err != nil {
+		return nil, err
+	}
+	return h.service.ListSessions(r.Context(), filter)
+}
+
+// respondItems responds with items of page for v1 routes, cursor of the next page is sent in header
+func respondItems[T any](w http.ResponseWriter, r *http.Request, page *entities.Page[T]) {
+	if page.NextCursor != "" {
+		w.Header().Set(nextCursorHeader, page.NextCursor)
+	}
+	respondJSON(w, r, http.StatusOK, page.Items)
+}
+
+// parseListParams reads `limit`, `cursor` and `sort` query parameters.
+// Sort field should be one of sorts, `-` before field means descending order.
+func parseListParams(q url.Values, sorts []string) (entities.ListParams, error) {
+	params := entities.ListParams{Cursor: q.Get("cursor")}
+
+	if limit := q.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > entities.MaxPageLimit {
+			return params, errs.ErrInvalidRequest.WithMessage("limit should be integer from 1 to " + strconv.Itoa(entities.MaxPageLimit))
+		}
+		params.Limit = value
+	}
+
+	params.Sort, params.Desc = strings.CutPrefix(q.Get("sort"), "-")
+	if params.Sort != "" && !slices.Contains(sorts, params.Sort) {
+		return params, errs.ErrInvalidRequest.WithMessage("sort should be one of " + strings.Join(sorts, ", "))
+	}
+	return params, nil
+}
+
+// queryState reads `state` query parameter, it should be in [minState, maxState]
+func queryState(q url.Values, minState, maxState int) (*int, error) {
+	state, err := queryInt(q, "state")
+	if err != nil {
+		return nil, err
+	}
+	if state != nil && (*state < minState || *state > maxState) {
+		return nil, errs.ErrInvalidRequest.WithMessage("state should be from " + strconv.Itoa(minState) + " to " + strconv.Itoa(maxState))
+	}
+	return state, nil
+}
+
+// queryInt reads optional integer query parameter, nil means that parameter is missing
+func queryInt(q url.Values, name string) (*int, error) {
+	if !q.Has(name) {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(q.Get(name))
+	if err != nil {
+		return nil, errs.ErrInvalidRequest.WithMessage(name + " should be integer")
+	}
+	return &value, nil
+}
+
+// queryBool reads optional boolean query parameter, nil means that parameter is missing
+func queryBool(q url.Values, name string) (*bool, error) {
+	if !q.Has(name) {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(q.Get(name))
+	if err != nil {
+		return nil, errs.ErrInvalidRequest.WithMessage(name + " should be true or false")
+	}
+	return &value, nil
+}
+
+// queryTime reads optional time query parameter in RFC 3339 or YYYY-MM-DD format
+func queryTime(q url.Values, name string) (time.Time, error) {
+	value := q.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errs.ErrInvalidRequest.WithMessage(name + " should be date YYYY-MM-DD or RFC 3339 time")
+}
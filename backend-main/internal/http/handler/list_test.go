@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+func TestListIsPagedWithCursor(t *testing.T) {
+	app := newTestApp(t, nil)
+	token := app.token("100")
+
+	first := decode[entities.Page[entities.User]](t, app.do("GET", "/api/v2/users?job_position=worker&sort=-name&limit=1", token, nil), http.StatusOK)
+	if len(first.Items) != 1 || first.Items[0].Name != "Worker 2" || first.NextCursor == "" {
+		t.Fatalf("first page %+v, want Worker 2 with cursor", first)
+	}
+
+	second := decode[entities.Page[entities.User]](t,
+		app.do("GET", "/api/v2/users?job_position=worker&sort=-name&limit=1&cursor="+first.NextCursor, token, nil), http.StatusOK)
+	if len(second.Items) != 1 || second.Items[0].Name != "Worker" || second.NextCursor != "" {
+		t.Errorf("last page %+v, want only Worker without cursor", second)
+	}
+
+	// v1 lists respond with plain array and send cursor in header
+	w := app.do("GET", "/get_all_users?limit=1", token, nil)
+	if users := decode[[]entities.User](t, w, http.StatusOK); len(users) != 1 || w.Header().Get(nextCursorHeader) == "" {
+		t.Errorf("v1 page %+v with cursor %q, want one user and cursor", users, w.Header().Get(nextCursorHeader))
+	}
+}
+
+func TestInvalidListParamsAreRejected(t *testing.T) {
+	app := newTestApp(t, nil)
+	token := app.token("100")
+
+	for _, query := range []string{
+		"cursor=not-a-cursor",
+		"sort=password",
+		"limit=0",
+		"limit=1001",
+		"job_position=boss",
+	} {
+		w := app.do("GET", "/api/v2/users?"+query, token, nil)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_request" {
+			t.Errorf("%s: status %d, want 400 invalid_request: %s", query, w.Code, w.Body.String())
+		}
+	}
+
+	w := app.do("GET", "/api/v2/sessions?from=yesterday", token, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("sessions from=yesterday: status %d, want 400: %s", w.Code, w.Body.String())
+	}
+}
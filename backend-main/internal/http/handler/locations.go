@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// ReportMachineBssidV2 is called by controller periodically with access point it is connected to
+func (h *Handler) ReportMachineBssidV2(w http.ResponseWriter, r *http.Request) {
+	var data bssidReportRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	machine, alert, err := h.reportMachineLocation(r.Context(), r.PathValue("id"), entities.NormalizeBssid(data.Bssid), data.Rssi)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, bssidReportResponse{CurrentState: machine.State, Alert: alert})
+}
+
+// GetMachineTrailV2 responds with sightings of the machine in chronological order by default
+func (h *Handler) GetMachineTrailV2(w http.ResponseWriter, r *http.Request) {
+	machine, err := h.getMachine(r.Context(), r.PathValue("id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	h.respondTrail(w, r, entities.SightingFilter{MachineId: machine.Id})
+}
+
+// GetSessionTrailV2 responds with sightings reported while the session was active or paused
+func (h *Handler) GetSessionTrailV2(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	session, err := h.getSession(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	h.respondTrail(w, r, entities.SightingFilter{MachineId: session.MachineId, SessionId: session.Id})
+}
+
+func (h *Handler) respondTrail(w http.ResponseWriter, r *http.Request, filter entities.SightingFilter) {
+	q := r.URL.Query()
+
+	var err error
+	if filter.ListParams, err = parseListParams(q, entities.SightingSorts); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if filter.From, err = queryTime(q, "from"); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if filter.To, err = queryTime(q, "to"); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	page, err := h.service.ListSightings(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list sightings", slog.String("machine_id", filter.MachineId), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+// reportMachineLocation stores sighting of the access point and checks geofences of machine in use.
+// Unresolved geofence alert of the machine is returned.
+func (h *Handler) reportMachineLocation(ctx context.Context, machineId, bssid string, rssi int) (*entities.Machine, *entities.GeofenceAlert, error) {
+	op := slog.String("op", "handler.reportMachineLocation")
+	idAttr := slog.String("machine_id", machineId)
+
+	release, err := h.service.AcquireMachineReport(ctx, machineId)
+	if err != nil {
+		slog.WarnContext(ctx, "acquire machine report", op, idAttr, slog.String("error", err.Error()))
+		return nil, nil, err
+	}
+	defer release()
+
+	machine, err := h.service.GetMachineByID(ctx, machineId)
+	if err != nil {
+		slog.ErrorContext(ctx, "get machine by id", op, idAttr, slog.String("error", err.Error()))
+		return nil, nil, err
+	}
+
+	geofences, err := h.service.ListGeofences(ctx, machine.SiteId)
+	if err != nil {
+		slog.ErrorContext(ctx, "list geofences", op, idAttr, slog.String("error", err.Error()))
+		return nil, nil, err
+	}
+
+	session, err := h.machineSession(ctx, machine.Id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get sessions by machine", op, idAttr, slog.String("error", err.Error()))
+		return nil, nil, err
+	}
+
+	sighting := entities.Sighting{MachineId: machine.Id, Bssid: bssid, Rssi: rssi, SeenAt: time.Now()}
+	if sighting.ParkingId, err = h.parkingAt(ctx, machine.SiteId, bssid); err != nil {
+		slog.ErrorContext(ctx, "get parking by mac address", op, slog.String("bssid", bssid), slog.String("error", err.Error()))
+		return nil, nil, err
+	}
+	if geofence := entities.LocateGeofence(geofences, bssid); geofence != nil {
+		sighting.GeofenceId = geofence.Id
+	}
+	if session != nil {
+		sighting.SessionId = session.Id
+	}
+
+	if _, err = h.service.InsertSighting(ctx, sighting); err != nil {
+		slog.ErrorContext(ctx, "insert sighting", op, idAttr, slog.String("error", err.Error()))
+		return nil, nil, err
+	}
+
+	alert, err := h.checkGeofences(ctx, machine, session, geofences, &sighting)
+	if err != nil {
+		return nil, nil, err
+	}
+	return machine, alert, nil
+}
+
+// parkingAt returns parking of the site with the access point, 0 if there is none
+func (h *Handler) parkingAt(ctx context.Context, siteId int, bssid string) (int, error) {
+	parking, err := h.service.GetParkingByMacAddr(ctx, bssid)
+	if errors.Is(err, errs.ErrParkingNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if parking.SiteId != siteId {
+		return 0, nil
+	}
+	return parking.Id, nil
+}
+
+// machineSession returns active or paused session with the machine, nil if there is none
+func (h *Handler) machineSession(ctx context.Context, machineId string) (*entities.Session, error) {
+	sessions, err := h.service.GetActiveSessionsByMachineID(ctx, machineId)
+	if err != nil {
+		return nil, errors.Wrap(err, "get active sessions")
+	}
+	if len(sessions) == 0 {
+		if sessions, err = h.service.GetPausedSessionsByMachineID(ctx, machineId); err != nil {
+			return nil, errors.Wrap(err, "get paused sessions")
+		}
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	return &sessions[0], nil
+}
+
+// withLastLocations sets the latest sighting of every machine in place
+func (h *Handler) withLastLocations(ctx context.Context, machines []entities.Machine) error {
+	ids := make([]string, 0, len(machines))
+	for _, m := range machines {
+		ids = append(ids, m.Id)
+	}
+
+	last, err := h.service.LastSightings(ctx, ids)
+	if err != nil {
+		return errors.Wrap(err, "get last sightings")
+	}
+	for i := range machines {
+		if s, ok := last[machines[i].Id]; ok {
+			machines[i].LastLocation = &s
+		}
+	}
+	return nil
+}
+
+// withLastLocation sets the latest sighting of the machine
+func (h *Handler) withLastLocation(ctx context.Context, machine *entities.Machine) error {
+	machines := []entities.Machine{*machine}
+	if err := h.withLastLocations(ctx, machines); err != nil {
+		return err
+	}
+	machine.LastLocation = machines[0].LastLocation
+	return nil
+}
@@ -1,275 +1,190 @@
 package handler
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
-	"github.com/ecol-master/sharing-wh-machines/internal/libs/csv"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/export"
 	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/ecol-master/sharing-wh-machines/internal/workorders"
 )
 
 func (h *Handler) LockMachine(w http.ResponseWriter, r *http.Request) {
 	op := slog.String("op", "handler.LockMachine")
 	// TODO: распарсить данные для работы
-	var data struct {
-		MachineId string `json:"machine_id"`
-	}
+	var data machineIdRequest
 
 	err := utils.ParseRequestData(r.Body, &data)
 	if err != nil {
-		slog.Error("failed parse request data", op, slog.String("error", err.Error()))
-		if err = utils.RespondWith400(w, "failed parse request body"); err != nil {
-			slog.Error("failed respond with 400", slog.String("error", err.Error()))
-		}
+		slog.ErrorContext(r.Context(), "failed parse request data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
 		return
 	}
 
-	machine, err := h.service.GetMachineByID(data.MachineId)
+	userId, err := userIdFromContext(r)
 	if err != nil {
-		slog.Error("get machine by id", op, slog.String("machine_id", data.MachineId),
-			slog.String("error", err.Error()))
+		slog.ErrorContext(r.Context(), "get user_id from context", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
 
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed to get machine by id",
-				slog.String("data_machine_id", data.MachineId),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+	if _, err = h.lockMachine(r.Context(), userId, data.MachineId); err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	// Получаем mac адрес от машинки
-	currentMac, err := getMachineCurrentMacAddr(machine, h.cfg.MC.RequestTimeout)
-	if err != nil {
-		slog.Error("failed getMachineCurrentMacAddr", op, slog.String("error", err.Error()))
+	payload := msgResponse{Msg: "successfullly lock machine"}
 
-		if err = utils.RespondWith400(w, "can't get machine mac addr at the moment"); err != nil {
-			slog.Error("failed to respond with 400 on getMachineCurrentMacAddr",
-				slog.Any("machine", machine),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	if err = utils.SuccessRespondWith200(w, payload); err != nil {
+		slog.ErrorContext(r.Context(), "failed to respond with 200 on lock machine",
+			slog.String("machine_id", data.MachineId),
+			slog.Int64("user_id", userId),
+			slog.String("path", r.URL.Path),
+			slog.String("method", r.Method),
+			slog.String("error", err.Error()),
+		)
 	}
 
-	// Проверяем, что парковка с таким мак-адресом существует
-	parking, err := h.service.GetParkingByMacAddr(currentMac)
-	if err != nil {
-		slog.Error("failed GetParkingByMacAddr", op, slog.String("error", err.Error()))
+}
 
-		if err = utils.RespondWith400(w, "can't get parking by macaddr. Parking not exists"); err != nil {
-			slog.Error("failed to respond with 400 on GetParkingByMacAddr",
-				slog.Any("machine", machine),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+// lockMachine finishes session with the machine at the parking it is located now
+func (h *Handler) lockMachine(ctx context.Context, userId int64, machineId string) (*entities.Session, error) {
+	op := slog.String("op", "handler.lockMachine")
+
+	release, err := h.acquireMachine(ctx, machineId)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	// Проверяем, что парковка может принять ещё одну машинку
-	if int(parking.Capacity) <= parking.Machines && parking.Capacity != 0 {
-		if err = utils.RespondWith400(w, "error while adding machine to parking. Parking machines is more or equals than capacity"); err != nil {
-			slog.Error("failed to respond 400 on failed adding machine to parking",
-				slog.Int("parking_id", parking.Id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	machine, err := h.getMachine(ctx, machineId)
+	if err != nil {
+		slog.ErrorContext(ctx, "get machine by id", op, slog.String("machine_id", machineId),
+			slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	// Проверяем, что парковка активна
-	if parking.State == entities.ParkingInactive {
-		if err = utils.RespondWith400(w, "error while adding machine to parking. Parking is inactive for now"); err != nil {
-			slog.Error("failed to respond 400 on failed adding machine to parking",
-				slog.Int("parking_id", parking.Id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	// Получаем mac адрес от машинки
+	currentMac, err := getMachineCurrentMacAddr(ctx, machine, h.cfg.MC.RequestTimeout)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed getMachineCurrentMacAddr", op, slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	if machine.State != entities.MachineInUse {
-		if err = utils.RespondWith400(w, "machine is not in use at the moment"); err != nil {
-			if err = utils.RespondWith500(w); err != nil {
-				slog.Error("failed to respond with 500 during machine is not in use",
-					slog.String("machine_id", data.MachineId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-		}
-		return
+	// Проверяем, что парковка с таким мак-адресом существует
+	parking, err := h.service.GetParkingByMacAddr(ctx, currentMac)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed GetParkingByMacAddr", op, slog.String("mac_addr", currentMac),
+			slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	userId, ok := r.Context().Value("user_id").(int64)
-	if !ok {
-		slog.Error("get `user_id` from r.Context", op, slog.Any("context", r.Context()))
+	// Проверяем, что парковка активна и может принять ещё одну машинку
+	if err = canParkMachine(parking); err != nil {
+		return nil, err
+	}
+	if err = checkParkingSite(machine, parking); err != nil {
+		return nil, err
+	}
 
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed to get 'use_id' value from r.Context",
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	if machine.State != entities.MachineInUse {
+		return nil, errs.ErrMachineNotInUse
 	}
 
-	user, err := h.service.GetUserByID(int(userId))
+	user, err := h.service.GetUserByID(ctx, int(userId))
 	if err != nil {
-		slog.Error("get user by id", op, slog.Int64("user_id", userId), slog.String("error", err.Error()))
-
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed to get user by id",
-				slog.Int64("user_id", userId),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+		slog.ErrorContext(ctx, "get user by id", op, slog.Int64("user_id", userId), slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	session, err := canLockMachine(h.service, user, machine)
+	session, err := canLockMachine(ctx, h.service, user, machine)
 	if err != nil {
-		slog.Error("tryLockMachine", op, slog.Int("user_id", user.Id),
+		slog.ErrorContext(ctx, "tryLockMachine", op, slog.Int("user_id", user.Id),
 			slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		return nil, err
+	}
 
-		if err = utils.RespondWith400(w, "user can not lock machine"); err != nil {
-			slog.Error("failed to respond 500 on failed to try lock machine",
-				slog.Int64("user_id", userId),
-				slog.String("machine_id", machine.Id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	if err = checkParkingZone(ctx, h.service, user, parking); err != nil {
+		slog.ErrorContext(ctx, "check parking of user", op, slog.Int("user_id", user.Id),
+			slog.Int("parking_id", parking.Id), slog.String("error", err.Error()))
+		return nil, err
 	}
 
+	return h.parkMachine(ctx, user, machine, session, parking)
+}
+
+// parkMachine frees the machine, finishes its session and moves machine to the parking.
+// All checks should be done before call.
+func (h *Handler) parkMachine(ctx context.Context, user *entities.User, machine *entities.Machine, session *entities.Session, parking *entities.Parking) (*entities.Session, error) {
+	op := slog.String("op", "handler.parkMachine")
+
 	// TODO: обновить данные в базе данных у машины
 	machine.State = entities.MachineFree
-	_, err = h.service.UpdateMachineState(machine.Id, machine.State)
+	_, err := h.service.UpdateMachineState(ctx, machine.Id, machine.State)
 	if err != nil {
-		slog.Error("failed to update machine state LockMachine",
+		slog.ErrorContext(ctx, "failed to update machine state", op,
 			slog.Any("machine", machine),
 			slog.Int("new_state", machine.State),
 			slog.String("error", err.Error()),
 		)
-
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed update machine state LockMachine",
-				slog.String("machine_id", machine.Id),
-				slog.Int("new_state", machine.State),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+		return nil, err
 	}
 
 	// TODO: Отправить данные на машину, для обработки
-	if err := sendMachineCurrentState(machine, h.cfg.MC.RequestTimeout); err != nil {
-		slog.Error("send machine new state", slog.String("machine_id", machine.Id),
+	if err := h.sendMachineState(ctx, machine); err != nil {
+		slog.ErrorContext(ctx, "send machine new state", op, slog.String("machine_id", machine.Id),
 			slog.Int("new_state", machine.State), slog.String("error", err.Error()))
-		return
+		return nil, err
 	}
 
+	// machine has got new state, so the rest is saved even if the request is cancelled
+	ctx = context.WithoutCancel(ctx)
+
 	// TODO: завершить сессию
-	session, err = h.service.FinishSession(session.Id)
+	session, err = h.service.FinishSession(ctx, session.Id, parking.Id)
 	if err != nil {
-		// TODO:
-		slog.Error("failed to update session state UnlockMachine",
+		slog.ErrorContext(ctx, "failed to update session state", op,
 			slog.Any("machine", machine),
-			slog.Int("new_state", machine.State),
+			slog.Int("new_state", entities.SessionFinished),
 			slog.String("error", err.Error()),
 		)
-
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed session machine state UnlockMachine",
-				slog.Int("session_id", session.Id),
-				slog.Int("new_state", entities.SessionFinished),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-
-		return
+		return nil, err
 	}
 
-	// TODO: ответить, что добавление сессии прошло успешно
-	slog.Info("session was successfully stopped", slog.Int("session_id", session.Id))
+	slog.InfoContext(ctx, "session was successfully stopped", slog.Int("session_id", session.Id))
 
-	// log to csv file information about ending of the session
-	csv.Write(csv.CsvData{
-		UserId:          userId,
-		UserName:        user.Name,
-		SessionStart:    session.DatetimeStart,
-		SessionDuration: session.DatetimeFinish.Sub(session.DatetimeStart),
-	})
+	// session is already finished, so failed export is only logged, it can be regenerated from db
+	if err := h.sessionLog.Write(export.NewRecord(*session, *user)); err != nil {
+		slog.ErrorContext(ctx, "failed to export finished session", op, slog.Int("session_id", session.Id),
+			slog.String("error", err.Error()))
+	}
 
 	// Если всё хорошо - добавляем машинку на парковку
-	_, err = h.service.UpdateParkingMachines(parking.Machines+1, parking.Id)
+	_, err = h.service.UpdateParkingMachines(ctx, parking.Machines+1, parking.Id)
 	if err != nil {
-		slog.Error("parking with such id doesn't exists", slog.Int("parking_id", parking.Id))
-		if err = utils.RespondWith400(w, "parking with such id doesn't exists"); err != nil {
-			if err = utils.RespondWith500(w); err != nil {
-				slog.Error("failed to respond with 500 during parking with such id doesn't exists",
-					slog.Int("parking_id", machine.ParkingId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-		}
-		return
+		slog.ErrorContext(ctx, "update parking machines", op, slog.Int("parking_id", parking.Id),
+			slog.String("error", err.Error()))
+		return nil, err
 	}
 
 	// И обновляем id парковки у машинки
-	_, err = h.service.UpdateMachineParkingId(machine.Id, parking.Id)
+	_, err = h.service.UpdateMachineParkingId(ctx, machine.Id, parking.Id)
 	if err != nil {
-		slog.Error("failed to update machine parkingId lockMachine",
+		slog.ErrorContext(ctx, "failed to update machine parkingId", op,
 			slog.Any("machine", machine),
 			slog.String("error", err.Error()),
 		)
-
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed update machine parkingId lockMachine",
-				slog.String("machine_id", machine.Id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+		return nil, err
 	}
 
-	payload := struct {
-		Msg string `json:"msg"`
-	}{Msg: "successfullly lock machine"}
-
-	if err = utils.SuccessRespondWith200(w, payload); err != nil {
-		slog.Error("failed to respond with 200 on lock machine",
-			slog.String("machine_id", machine.Id),
-			slog.Int("user_id", user.Id),
-			slog.String("path", r.URL.Path),
-			slog.String("method", r.Method),
-			slog.String("error", err.Error()),
-		)
+	// operating hours are grown by the session, failed scheduling is repeated by workorders.Monitor
+	if _, _, err = workorders.Schedule(ctx, h.service, machine); err != nil {
+		slog.ErrorContext(ctx, "schedule service of machine", op, slog.String("machine_id", machine.Id),
+			slog.String("error", err.Error()))
 	}
 
+	return session, nil
 }
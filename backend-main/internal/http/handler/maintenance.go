@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+)
+
+func (h *Handler) SetMachineMaintenanceV2(w http.ResponseWriter, r *http.Request) {
+	var data maintenanceRequest
+
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	machine, err := h.setMachineMaintenance(r.Context(), r.PathValue("id"), data.Maintenance)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, machine)
+}
+
+// setMachineMaintenance puts free machine into maintenance or returns it to service
+func (h *Handler) setMachineMaintenance(ctx context.Context, machineId string, maintenance bool) (*entities.Machine, error) {
+	op := slog.String("op", "handler.setMachineMaintenance")
+
+	release, err := h.acquireMachine(ctx, machineId)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	machine, err := h.getMachine(ctx, machineId)
+	if err != nil {
+		slog.ErrorContext(ctx, "get machine by id", op, slog.String("machine_id", machineId), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	if maintenance && machine.State != entities.MachineFree {
+		return nil, errs.ErrMachineNotFree
+	}
+
+	machine, err = h.service.UpdateMachineMaintenance(ctx, machineId, maintenance)
+	if err != nil {
+		slog.ErrorContext(ctx, "update machine maintenance", op, slog.String("machine_id", machineId), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "machine maintenance is changed", op, slog.String("machine_id", machineId), slog.Bool("maintenance", maintenance))
+	return machine, nil
+}
@@ -1,42 +1,31 @@
 package handler
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
 	"github.com/ecol-master/sharing-wh-machines/internal/utils"
 )
 
 func (h *Handler) GetAllParkings(w http.ResponseWriter, r *http.Request) {
-	parkings, err := h.service.GetAllParkings()
+	page, err := h.listParkings(r)
 
 	if err != nil {
-		slog.Error(
+		slog.ErrorContext(r.Context(),
 			"failed get all parkings",
 			slog.String("path", r.URL.Path),
 			slog.String("method", r.Method),
 			slog.String("error", err.Error()),
 		)
-
-		if err := utils.RespondWith500(w); err != nil {
-			slog.Error("failed respond with error", slog.Int("status", 500))
-		}
+		respondError(w, r, err)
 		return
 	}
 
-	if err := utils.RespondWithJSON(w, 200, parkings); err != nil {
-		slog.Error("failed to respond with json with parkings",
-			slog.String("path", r.URL.Path),
-			slog.String("method", r.Method),
-			slog.String("error", err.Error()),
-		)
-
-		if err := utils.RespondWith500(w); err != nil {
-			slog.Error("failed respond with error", slog.Int("status", 500))
-		}
-	}
+	respondItems(w, r, page)
 }
 
 func (h *Handler) GetParkingById(w http.ResponseWriter, r *http.Request) {
@@ -44,139 +33,116 @@ func (h *Handler) GetParkingById(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(parkingId)
 	if err != nil {
-		slog.Error("`parking_id` query is not integer")
-		if err := utils.RespondWith400(w, "parking_id should be integer"); err != nil {
-			slog.Error(
-				"failed respond 400",
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+		slog.ErrorContext(r.Context(), "`parking_id` query is not integer")
+		respondError(w, r, errs.ErrInvalidRequest.WithMessage("parking_id should be integer"))
 		return
 	}
 
-	parking, err := h.service.GetParkingById(id)
+	parking, err := h.getParking(r.Context(), id)
 	if err != nil {
-		slog.Error("parking not found", slog.Int("parking_id", id))
-		if err := utils.RespondWith400(w, "parking not found"); err != nil {
-			slog.Error(
-				"failed respond 400",
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+		slog.ErrorContext(r.Context(), "get parking by id", slog.Int("parking_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
 		return
 	}
 
 	if err := utils.RespondWithJSON(w, 200, parking); err != nil {
-		slog.Error("failed to respond with json with parking",
+		slog.ErrorContext(r.Context(), "failed to respond with json with parking",
 			slog.Int("parking_id", id),
+			slog.String("path", r.URL.Path),
+			slog.String("method", r.Method),
 			slog.String("error", err.Error()),
 		)
-		if err := utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond with 500 during get parking by id",
-				slog.Int("parking_id", id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
 	}
 }
 
 func (h *Handler) RegisterParking(w http.ResponseWriter, r *http.Request) {
 	op := slog.String("op", "handler.RegisterParking")
 
-	data := entities.Parking{}
+	data := createParkingRequest{}
 	if err := utils.ParseRequestData(r.Body, &data); err != nil {
-		slog.Error("parse req data", op, slog.String("error", err.Error()))
-		if err := utils.RespondWith400(w, "failed to parse request data"); err != nil {
-			slog.Error("failed to respond with 400", op, slog.String("error", err.Error()))
-		}
+		slog.ErrorContext(r.Context(), "parse req data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
 		return
 	}
 
 	name := slog.String("parkingName", data.Name)
-	mac := slog.String("machineId", data.MacAddr)
-	cap := slog.Int("machineId", int(data.Capacity))
+	mac := slog.String("macAddr", data.MacAddr)
+	cap := slog.Int("capacity", int(data.Capacity))
 
-	parking, err := h.service.InsertParking(data.Name, data.MacAddr, data.Capacity, data.State)
+	siteId, err := h.targetSite(r.Context(), data.SiteId)
 	if err != nil {
-		slog.Error("failed to create new in parking. Maybe, parking with this name already exists", name, mac, cap, slog.String("error", err.Error()))
-		if err = utils.RespondWith400(w, "failed to create new in parking. Maybe, parking with this name already exists"); err != nil {
-			slog.Error("failed to respond with 400", op, slog.String("error", err.Error()))
-		}
+		respondError(w, r, err)
+		return
+	}
+
+	parking, err := h.service.InsertParking(r.Context(), data.Name, entities.NormalizeBssid(data.MacAddr), data.Capacity, data.State, siteId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to create new in parking. Maybe, parking with this name already exists", name, mac, cap, slog.String("error", err.Error()))
+		respondError(w, r, err)
 		return
 	}
 
 	if err = utils.SuccessRespondWith200(w, parking); err != nil {
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond Success(200) with paylod on RegisterMachine",
-				slog.Any("payload", parking), name, mac, cap,
-				slog.String("method", r.Method),
-				slog.String("path", r.URL.Path),
-				slog.String("error", err.Error()),
-			)
-		}
+		slog.ErrorContext(r.Context(), "failed to respond Success(200) with paylod on RegisterParking",
+			slog.Any("payload", parking), name, mac, cap,
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("error", err.Error()),
+		)
 	}
 }
 
 func (h *Handler) UpdateParkingState(w http.ResponseWriter, r *http.Request) {
 	op := slog.String("op", "handler.UpdateParking")
 
-	data := struct {
-		ParkingId int `json:"id"`
-		NewState  int `json:"state"`
-	}{}
+	data := updateParkingStateRequest{}
 
 	err := utils.ParseRequestData(r.Body, &data)
 	if err != nil {
-		slog.Error("failed parse request data", op, slog.String("error", err.Error()))
-		if err = utils.RespondWith400(w, "failed parse request body"); err != nil {
-			slog.Error("failed respond with 400", slog.String("error", err.Error()))
-		}
+		slog.ErrorContext(r.Context(), "failed parse request data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
 		return
 	}
 
-	if data.NewState > 1 || data.NewState < 0 {
-		if err = utils.RespondWith400(w, "error while updating parking state. Invalid parking state. Use 0 or 1"); err != nil {
-			slog.Error("failed to respond 400 on failed update parking state",
-				slog.Int("parking_id", data.ParkingId),
-				slog.Int("new_state", int(data.NewState)),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+	parking, err := h.updateParkingState(r.Context(), data.ParkingId, data.NewState)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	parking, err := h.service.UpdateParkingState(entities.ParkingState(data.NewState), data.ParkingId)
-	if err != nil {
-		slog.Error("failed to update parking state",
-			slog.Any("parking", data),
+	if err = utils.SuccessRespondWith200(w, parking); err != nil {
+		slog.ErrorContext(r.Context(), "failed to respond with 200 on update parking state",
+			slog.Int("parking_id", data.ParkingId),
 			slog.Int("new_state", data.NewState),
+			slog.String("path", r.URL.Path),
+			slog.String("method", r.Method),
 			slog.String("error", err.Error()),
 		)
+	}
+}
 
-		if err = utils.RespondWith400(w, "error while updating parking state. Parking not exists or missing field id"); err != nil {
-			slog.Error("failed to respond 400 on failed update parking state",
-				slog.Int("parking_id", data.ParkingId),
-				slog.Int("new_state", data.NewState),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+func (h *Handler) UpdateParkingCapacity(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.UpdateParkingCapacity")
+
+	data := updateParkingCapacityRequest{}
+
+	err := utils.ParseRequestData(r.Body, &data)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed parse request data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	parking, err := h.updateParkingCapacity(r.Context(), data.ParkingId, data.NewCapacity)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
 	if err = utils.SuccessRespondWith200(w, parking); err != nil {
-		slog.Error("failed to respond with 200 on lock machine",
+		slog.ErrorContext(r.Context(), "failed to respond with 200 on update parking capacity",
+			slog.Any("parking", parking),
 			slog.Int("parking_id", data.ParkingId),
-			slog.Int("new_state", data.NewState),
 			slog.String("path", r.URL.Path),
 			slog.String("method", r.Method),
 			slog.String("error", err.Error()),
@@ -184,207 +150,146 @@ func (h *Handler) UpdateParkingState(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) UpdateParkingCapacity(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ManualyMoveParkingMachine(w http.ResponseWriter, r *http.Request) {
 	op := slog.String("op", "handler.ManualyAddParkingMachine")
 
-	data := struct {
-		ParkingId   int               `json:"id"`
-		NewCapacity entities.Capacity `json:"capacity"`
-	}{}
+	data := moveMachineRequest{}
 
 	err := utils.ParseRequestData(r.Body, &data)
 	if err != nil {
-		slog.Error("failed parse request data", op, slog.String("error", err.Error()))
-		if err = utils.RespondWith400(w, "failed parse request body"); err != nil {
-			slog.Error("failed respond with 400", slog.String("error", err.Error()))
-		}
+		slog.ErrorContext(r.Context(), "failed parse request data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
 		return
 	}
 
-	parking, err := h.service.GetParkingById(data.ParkingId)
+	machine, err := h.moveMachineToParking(r.Context(), data.MachineId, data.ParkingId)
 	if err != nil {
-		slog.Error("failed to update parking capacity", op, slog.String("error", err.Error()))
-		if err = utils.RespondWith400(w, "failed to update parking capacity. Parking with this id not exists or missing field id"); err != nil {
-			slog.Error("failed respond with 400", slog.String("error", err.Error()))
-		}
-		return
-	}
-
-	// Проверяем, что новая вместительность парковки больше, чем кол-во машинок, находящихся на данный момент там
-	if int(data.NewCapacity) <= parking.Machines && int(data.NewCapacity) != 0 {
-		if err = utils.RespondWith400(w, "failed to update parking capacity. Capacity is more than machines that now at the parking"); err != nil {
-			slog.Error("failed respond with 400", slog.String("error", err.Error()))
-		}
+		respondError(w, r, err)
 		return
 	}
 
-	parking, err = h.service.UpdateParkingCapacity(data.NewCapacity, data.ParkingId)
-	if err != nil {
-		slog.Error("failed to update parking capacity",
+	if err = utils.SuccessRespondWith200(w, machine); err != nil {
+		slog.ErrorContext(r.Context(), "failed to respond with 200 on adding machine to parking",
+			slog.String("machine_id", data.MachineId),
 			slog.Int("parking_id", data.ParkingId),
+			slog.String("path", r.URL.Path),
+			slog.String("method", r.Method),
 			slog.String("error", err.Error()),
 		)
+	}
+}
 
-		if err = utils.RespondWith400(w, "error while updating parking capacity. Parking not exists or missing field id"); err != nil {
-			slog.Error("error while updating parking capacity",
-				slog.Any("parking", data),
-				slog.Int("new_machines", parking.Machines+1),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+func (h *Handler) updateParkingState(ctx context.Context, parkingId int, newState int) (*entities.Parking, error) {
+	if newState > 1 || newState < 0 {
+		return nil, errs.ErrInvalidParkingState
 	}
 
-	if err = utils.SuccessRespondWith200(w, parking); err != nil {
-		slog.Error("failed to respond with 200 on update machine parking id",
-			slog.Any("parking", parking),
-			slog.Int("parking_id", data.ParkingId),
-			slog.String("path", r.URL.Path),
-			slog.String("method", r.Method),
+	if _, err := h.getParking(ctx, parkingId); err != nil {
+		return nil, err
+	}
+
+	parking, err := h.service.UpdateParkingState(ctx, entities.ParkingState(newState), parkingId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update parking state",
+			slog.Int("parking_id", parkingId),
+			slog.Int("new_state", newState),
 			slog.String("error", err.Error()),
 		)
+		return nil, err
 	}
+	return parking, nil
 }
 
-func (h *Handler) ManualyMoveParkingMachine(w http.ResponseWriter, r *http.Request) {
-	op := slog.String("op", "handler.ManualyAddParkingMachine")
+func (h *Handler) updateParkingCapacity(ctx context.Context, parkingId int, newCapacity entities.Capacity) (*entities.Parking, error) {
+	op := slog.String("op", "handler.updateParkingCapacity")
 
-	data := struct {
-		MachineId string `json:"machine_id"`
-		ParkingId int    `json:"parking_id"`
-	}{}
+	parking, err := h.getParking(ctx, parkingId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update parking capacity", op, slog.String("error", err.Error()))
+		return nil, err
+	}
 
-	err := utils.ParseRequestData(r.Body, &data)
+	// Проверяем, что новая вместительность парковки больше, чем кол-во машинок, находящихся на данный момент там
+	if int(newCapacity) <= parking.Machines && int(newCapacity) != 0 {
+		return nil, errs.ErrInvalidParkingCapacity
+	}
+
+	parking, err = h.service.UpdateParkingCapacity(ctx, newCapacity, parkingId)
 	if err != nil {
-		slog.Error("failed parse request data", op, slog.String("error", err.Error()))
-		if err = utils.RespondWith400(w, "failed parse request body"); err != nil {
-			slog.Error("failed respond with 400", slog.String("error", err.Error()))
-		}
-		return
+		slog.ErrorContext(ctx, "failed to update parking capacity", op,
+			slog.Int("parking_id", parkingId),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+	return parking, nil
+}
+
+// moveMachineToParking manually moves free machine to the parking.
+// Machine is only removed from its current parking if parkingId is 0.
+func (h *Handler) moveMachineToParking(ctx context.Context, machineId string, parkingId int) (*entities.Machine, error) {
+	release, err := h.acquireMachine(ctx, machineId)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
 	// Получаем машинку из базы
-	machine, err := h.service.GetMachineByID(data.MachineId)
+	machine, err := h.getMachine(ctx, machineId)
 	if err != nil {
-		slog.Error("failed to get parking by id",
-			slog.Int("parkingId", data.ParkingId),
+		slog.ErrorContext(ctx, "failed to get machine by id",
+			slog.String("machine_id", machineId),
 			slog.String("error", err.Error()),
 		)
-
-		if err = utils.RespondWith400(w, "error while adding machine to parking. Machine not exists or missing field machine_id"); err != nil {
-			slog.Error("failed to respond 400 on failed adding machine to parking",
-				slog.Int("parking_id", data.ParkingId),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+		return nil, err
 	}
 
 	// Проверяем, что она свободна
 	if machine.State != entities.MachineFree {
-		if err = utils.RespondWith400(w, "error while adding machine to parking. Machine for now in use"); err != nil {
-			slog.Error("failed to respond 400 on failed adding machine to parking",
-				slog.Int("parking_id", data.ParkingId),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+		return nil, errs.ErrMachineNotFree
 	}
 
 	// Если двигаем на другую парковку
-	if data.ParkingId != 0 {
+	if parkingId != 0 {
 
 		// Пробуем достать её из базы
-		parking, err := h.service.GetParkingById(data.ParkingId)
+		parking, err := h.getParking(ctx, parkingId)
 		if err != nil {
-			slog.Error("failed to get parking by id",
-				slog.Int("parkingId", data.ParkingId),
+			slog.ErrorContext(ctx, "failed to get parking by id",
+				slog.Int("parkingId", parkingId),
 				slog.String("error", err.Error()),
 			)
-
-			if err = utils.RespondWith400(w, "error while adding machine to parking. Parking not exists or missing field parking_id"); err != nil {
-				slog.Error("failed to respond 400 on failed adding machine to parking",
-					slog.Int("parking_id", data.ParkingId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-			return
+			return nil, err
 		}
 
-		// Если достали, то проверяем, может ли она вместить ещё одну машинку
-		if int(parking.Capacity) <= parking.Machines && parking.Capacity != 0 {
-			if err = utils.RespondWith400(w, "error while adding machine to parking. Parking machines is more or equals than capacity"); err != nil {
-				slog.Error("failed to respond 400 on failed adding machine to parking",
-					slog.Int("parking_id", data.ParkingId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-			return
+		// Если достали, то проверяем, может ли она вместить ещё одну машинку и активна ли она
+		if err = canParkMachine(parking); err != nil {
+			return nil, err
 		}
-
-		// Если может - проверяем, активна ли она
-		if parking.State == entities.ParkingInactive {
-			if err = utils.RespondWith400(w, "error while adding machine to parking. Parking is Inactive"); err != nil {
-				slog.Error("failed to respond 400 on failed adding machine to parking",
-					slog.Int("parking_id", data.ParkingId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-			return
+		if err = checkParkingSite(machine, parking); err != nil {
+			return nil, err
 		}
 
 		// Если всё гуд - тогда добавляем её на парковку
-		_, err = h.service.UpdateParkingMachines(parking.Machines+1, parking.Id)
+		_, err = h.service.UpdateParkingMachines(ctx, parking.Machines+1, parking.Id)
 		if err != nil {
-			slog.Error("failed to adding machine to parking",
-				slog.Any("parking", data),
+			slog.ErrorContext(ctx, "failed to adding machine to parking",
+				slog.Int("parking_id", parkingId),
 				slog.Int("new_machines", parking.Machines+1),
 				slog.String("error", err.Error()),
 			)
-
-			if err = utils.RespondWith400(w, "error while adding machine to parking"); err != nil {
-				slog.Error("error while adding machine to parking",
-					slog.Any("parking", data),
-					slog.Int("new_machines", parking.Machines+1),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-			return
+			return nil, err
 		}
 
 		// И, наконец, обновляем id парковки у самой машинки
-		_, err = h.service.UpdateMachineParkingId(data.MachineId, data.ParkingId)
+		_, err = h.service.UpdateMachineParkingId(ctx, machineId, parkingId)
 		if err != nil {
-			slog.Error("failed to move machine to parking",
+			slog.ErrorContext(ctx, "failed to move machine to parking",
 				slog.Int("form parking_id", machine.ParkingId),
-				slog.Int("to parking_id", data.ParkingId),
+				slog.Int("to parking_id", parkingId),
 				slog.String("error", err.Error()),
 			)
-
-			if err = utils.RespondWith400(w, "error while moving machine to parking"); err != nil {
-				slog.Error("error while adding machine to parking",
-					slog.Int("form parking_id", machine.ParkingId),
-					slog.Int("to parking_id", data.ParkingId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-			return
+			return nil, err
 		}
 	}
 
@@ -392,53 +297,37 @@ func (h *Handler) ManualyMoveParkingMachine(w http.ResponseWriter, r *http.Reque
 	if machine.ParkingId != 0 {
 
 		// Пробуем досать парковку из базы
-		parking, err := h.service.GetParkingById(machine.ParkingId)
+		parking, err := h.service.GetParkingById(ctx, machine.ParkingId)
 		if err != nil {
-			slog.Error("failed to get parking by id",
-				slog.Int("parkingId", data.ParkingId),
+			slog.ErrorContext(ctx, "failed to get parking by id",
+				slog.Int("parkingId", machine.ParkingId),
 				slog.String("error", err.Error()),
 			)
-
-			if err = utils.RespondWith400(w, "error while adding machine to parking. Parking not exists or missing field parking_id"); err != nil {
-				slog.Error("failed to respond 400 on failed adding machine to parking",
-					slog.Int("parking_id", data.ParkingId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-			return
+			return nil, err
 		}
 
 		// Убираем её с парковки
-		_, err = h.service.UpdateParkingMachines(parking.Machines-1, machine.ParkingId)
+		_, err = h.service.UpdateParkingMachines(ctx, parking.Machines-1, machine.ParkingId)
 		if err != nil {
-			slog.Error("failed to remove machine from parking",
-				slog.Any("parking", data),
-				slog.Int("new_machines", parking.Machines+1),
+			slog.ErrorContext(ctx, "failed to remove machine from parking",
+				slog.Int("parking_id", machine.ParkingId),
+				slog.Int("new_machines", parking.Machines-1),
 				slog.String("error", err.Error()),
 			)
+			return nil, err
+		}
 
-			if err = utils.RespondWith400(w, "error while removing machine from parking"); err != nil {
-				slog.Error("error while adding machine to parking",
-					slog.Any("parking", data),
-					slog.Int("new_machines", parking.Machines+1),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
+		// Если машинку не переносим на другую парковку - просто убираем её с текущей
+		if parkingId == 0 {
+			if _, err = h.service.UpdateMachineParkingId(ctx, machineId, 0); err != nil {
+				slog.ErrorContext(ctx, "failed to remove machine from parking",
+					slog.Int("parking_id", machine.ParkingId),
 					slog.String("error", err.Error()),
 				)
+				return nil, err
 			}
-			return
 		}
 	}
 
-	if err = utils.SuccessRespondWith200(w, machine); err != nil {
-		slog.Error("failed to respond with 200 on adding machine to parking",
-			slog.String("machine_id", data.MachineId),
-			slog.Int("parking_id", data.ParkingId),
-			slog.String("path", r.URL.Path),
-			slog.String("method", r.Method),
-			slog.String("error", err.Error()),
-		)
-	}
+	return h.service.GetMachineByID(ctx, machineId)
 }
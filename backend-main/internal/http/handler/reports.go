@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/libs/xlsx"
+	"github.com/pkg/errors"
+)
+
+// defaultReportPeriod is used when `from` is not set
+const defaultReportPeriod = 30 * 24 * time.Hour
+
+// Formats of report, json is default
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatXLSX = "xlsx"
+)
+
+type reportResponse[T any] struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Items []T       `json:"items"`
+}
+
+// MachinesReport responds with utilisation and idle time of every machine
+func (h *Handler) MachinesReport(w http.ResponseWriter, r *http.Request) {
+	respondReport(w, r, "machines", h.service.MachineUsage)
+}
+
+func (h *Handler) WorkersReport(w http.ResponseWriter, r *http.Request) {
+	respondReport(w, r, "workers", h.service.WorkerHours)
+}
+
+func (h *Handler) ParkingsReport(w http.ResponseWriter, r *http.Request) {
+	respondReport(w, r, "parkings", h.service.ParkingTurnover)
+}
+
+// PeakHoursReport responds with heatmap of week, hours are in `tz` time zone
+func (h *Handler) PeakHoursReport(w http.ResponseWriter, r *http.Request) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		tz = h.cfg.Reports.Timezone
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.WithMessage("unknown time zone: "+tz))
+		return
+	}
+
+	respondReport(w, r, "peak-hours", func(ctx context.Context, rng entities.ReportRange) ([]entities.PeakHour, error) {
+		return h.service.PeakHours(ctx, rng, loc)
+	})
+}
+
+// ExpiringCertificationsReport lists certifications which expire within range, the next 30 days by default
+func (h *Handler) ExpiringCertificationsReport(w http.ResponseWriter, r *http.Request) {
+	rng, err := parseUpcomingRange(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	writeReport(w, r, "expiring-certifications", rng, h.service.ExpiringCertifications)
+}
+
+// respondReport builds report for range from query and writes it in requested format
+func respondReport[T any](w http.ResponseWriter, r *http.Request, name string, build func(ctx context.Context, rng entities.ReportRange) ([]T, error)) {
+	rng, err := parseReportRange(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	writeReport(w, r, name, rng, build)
+}
+
+// writeReport builds report for range and writes it in requested format.
+// csv and xlsx are sent as attachment named after report and range.
+func writeReport[T any](w http.ResponseWriter, r *http.Request, name string, rng entities.ReportRange, build func(ctx context.Context, rng entities.ReportRange) ([]T, error)) {
+	op := slog.String("op", "handler.writeReport")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatJSON
+	}
+	if format != formatJSON && format != formatCSV && format != formatXLSX {
+		respondError(w, r, errs.ErrInvalidRequest.WithMessage("format should be one of json, csv, xlsx"))
+		return
+	}
+
+	items, err := build(r.Context(), rng)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "build report", op, slog.String("report", name), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	if format == formatJSON {
+		respondJSON(w, r, http.StatusOK, reportResponse[T]{From: rng.From, To: rng.To, Items: items})
+		return
+	}
+
+	var (
+		buf         bytes.Buffer
+		contentType string
+		rows        = reportTable(items)
+	)
+	switch format {
+	case formatCSV:
+		contentType = "text/csv; charset=utf-8"
+		err = writeCSV(&buf, rows)
+	case formatXLSX:
+		contentType = xlsx.ContentType
+		err = xlsx.Write(&buf, name, rows)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "write report", op, slog.String("report", name), slog.String("format", format), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%s_%s.%s", name, rng.From.Format(time.DateOnly), rng.To.Format(time.DateOnly), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write report", op, slog.String("report", name), slog.String("error", err.Error()))
+	}
+}
+
+// parseReportRange reads `from` and `to` query parameters, by default report is built for the last 30 days.
+// Site of report is chosen like site of lists.
+func parseReportRange(r *http.Request) (entities.ReportRange, error) {
+	q := r.URL.Query()
+
+	from, err := queryTime(q, "from")
+	if err != nil {
+		return entities.ReportRange{}, err
+	}
+	to, err := queryTime(q, "to")
+	if err != nil {
+		return entities.ReportRange{}, err
+	}
+
+	if to.IsZero() {
+		// timestamps are stored in seconds, next second includes sessions of current one
+		to = time.Now().Truncate(time.Second).Add(time.Second)
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultReportPeriod)
+	}
+	if !from.Before(to) {
+		return entities.ReportRange{}, errs.ErrInvalidRequest.WithMessage("from should be before to")
+	}
+
+	siteId, err := listSite(r)
+	if err != nil {
+		return entities.ReportRange{}, err
+	}
+	return entities.ReportRange{From: from, To: to, SiteId: siteId}, nil
+}
+
+// parseUpcomingRange reads `from` and `to` query parameters of reports about the future,
+// by default range is the next 30 days. Site of report is chosen like site of lists.
+func parseUpcomingRange(r *http.Request) (entities.ReportRange, error) {
+	q := r.URL.Query()
+
+	from, err := queryTime(q, "from")
+	if err != nil {
+		return entities.ReportRange{}, err
+	}
+	to, err := queryTime(q, "to")
+	if err != nil {
+		return entities.ReportRange{}, err
+	}
+
+	if from.IsZero() {
+		from = time.Now().Truncate(time.Second)
+	}
+	if to.IsZero() {
+		to = from.Add(defaultReportPeriod)
+	}
+	if !from.Before(to) {
+		return entities.ReportRange{}, errs.ErrInvalidRequest.WithMessage("from should be before to")
+	}
+
+	siteId, err := listSite(r)
+	if err != nil {
+		return entities.ReportRange{}, err
+	}
+	return entities.ReportRange{From: from, To: to, SiteId: siteId}, nil
+}
+
+// reportTable converts items to rows of table, the first row is header with json names of fields
+func reportTable[T any](items []T) [][]any {
+	t := reflect.TypeFor[T]()
+
+	header := make([]any, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		header = append(header, name)
+	}
+
+	rows := [][]any{header}
+	for _, item := range items {
+		v := reflect.ValueOf(item)
+
+		row := make([]any, 0, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			row = append(row, cellValue(v.Field(i)))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// cellValue returns number or string value of table cell, time is formatted as RFC 3339
+func cellValue(v reflect.Value) any {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case int:
+		return value
+	case int64:
+		return value
+	case float64:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func writeCSV(buf *bytes.Buffer, rows [][]any) error {
+	cw := csv.NewWriter(buf)
+	for _, row := range rows {
+		record := make([]string, 0, len(row))
+		for _, cell := range row {
+			record = append(record, fmt.Sprint(cell))
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.Wrap(err, "write csv record")
+		}
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "flush csv")
+}
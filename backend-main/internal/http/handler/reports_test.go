@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+func TestWorkersReportFormats(t *testing.T) {
+	app := newTestApp(t, nil)
+	token := app.token("100")
+	ctx := context.Background()
+
+	worker, err := app.svc.GetUserByPhoneNumber(ctx, "200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = app.svc.InsertMachine(ctx, "M1", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = app.svc.InsertSession(ctx, worker.Id, "M1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	today := time.Now().UTC()
+	from, to := today.AddDate(0, 0, -1).Format(time.DateOnly), today.AddDate(0, 0, 1).Format(time.DateOnly)
+	path := "/api/v2/reports/workers?from=" + from + "&to=" + to
+
+	report := decode[reportResponse[entities.WorkerHours]](t, app.do("GET", path, token, nil), http.StatusOK)
+	hours := map[string]entities.WorkerHours{}
+	for _, item := range report.Items {
+		hours[item.Name] = item
+	}
+	if hours["Worker"].Sessions != 1 || hours["Worker"].Machines != 1 || hours["Worker 2"].Sessions != 0 {
+		t.Errorf("report %+v, want one session of Worker", report.Items)
+	}
+
+	w := app.do("GET", path+"&format=csv", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("csv: status %d: %s", w.Code, w.Body.String())
+	}
+	if want := `attachment; filename="workers_` + from + "_" + to + `.csv"`; w.Header().Get("Content-Disposition") != want {
+		t.Errorf("csv disposition %q, want %q", w.Header().Get("Content-Disposition"), want)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != len(report.Items)+1 || strings.Join(rows[0], ",") != "worker_id,name,sessions,machines,hours" {
+		t.Errorf("csv %v, want header and %d rows", rows, len(report.Items))
+	}
+
+	w = app.do("GET", path+"&format=xlsx", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("xlsx: status %d: %s", w.Code, w.Body.String())
+	}
+	book, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("open xlsx: %v", err)
+	}
+	sheet, err := book.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatalf("open sheet: %v", err)
+	}
+	defer sheet.Close()
+	if data, _ := io.ReadAll(sheet); !bytes.Contains(data, []byte("Worker 2")) {
+		t.Errorf("sheet has no Worker 2 row: %s", data)
+	}
+}
+
+func TestInvalidReportRequestsAreRejected(t *testing.T) {
+	app := newTestApp(t, nil)
+	token := app.token("100")
+
+	for _, path := range []string{
+		"/api/v2/reports/machines?format=pdf",
+		"/api/v2/reports/machines?from=2024-02-01&to=2024-01-01",
+		"/api/v2/reports/parkings?to=tomorrow",
+		"/api/v2/reports/peak-hours?tz=Mars/Olympus",
+	} {
+		w := app.do("GET", path, token, nil)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_request" {
+			t.Errorf("%s: status %d, want 400 invalid_request: %s", path, w.Code, w.Body.String())
+		}
+	}
+
+	if w := app.do("GET", "/api/v2/reports/machines", app.token("200"), nil); w.Code != http.StatusForbidden {
+		t.Errorf("worker got status %d, want 403", w.Code)
+	}
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+// Request and response bodies of the api.
+// Tags besides `json` describe schema in openapi spec, see openapi.SchemaOf
+
+type machineIdRequest struct {
+	MachineId string `json:"machine_id" required:"true" minLength:"1"`
+}
+
+type unlockMachineRequest struct {
+	MachineId string                   `json:"machine_id" required:"true" minLength:"1"`
+	Checklist []checklistAnswerRequest `json:"checklist" description:"answers to checklist of the machine type, if it has one"`
+}
+
+type loginRequest struct {
+	PhoneNumber string `json:"phone_number" required:"true"`
+	Password    string `json:"password" required:"true"`
+}
+
+type registerMachineRequest struct {
+	MachineId string `json:"machine_id" required:"true" minLength:"1"`
+	IPAddr    string `json:"ip_addr" required:"true" minLength:"1"`
+}
+
+type finishSessionRequest struct {
+	Key         string `json:"key" required:"true"`
+	ParkingName string `json:"parking_name" required:"true"`
+}
+
+type createParkingRequest struct {
+	Name     string                `json:"name" required:"true" minLength:"1"`
+	MacAddr  string                `json:"mac_addr" required:"true" minLength:"1"`
+	Capacity entities.Capacity     `json:"capacity" minimum:"0" description:"0 is unlimited capacity"`
+	State    entities.ParkingState `json:"state" enum:"0,1"`
+	SiteId   int                   `json:"site_id" minimum:"0" description:"chosen by users with sites.manage, site of the user by default"`
+}
+
+type updateParkingStateRequest struct {
+	ParkingId int `json:"id" required:"true"`
+	NewState  int `json:"state" required:"true" enum:"0,1"`
+}
+
+type updateParkingCapacityRequest struct {
+	ParkingId   int               `json:"id" required:"true"`
+	NewCapacity entities.Capacity `json:"capacity" required:"true" minimum:"0"`
+}
+
+type moveMachineRequest struct {
+	MachineId string `json:"machine_id" required:"true" minLength:"1"`
+	ParkingId int    `json:"parking_id" required:"true" minimum:"0" description:"0 removes machine from parking"`
+}
+
+type registerMachineV2Request struct {
+	IPAddr string `json:"ip_addr" required:"true" minLength:"1"`
+}
+
+type moveMachineV2Request struct {
+	ParkingId int `json:"parking_id" required:"true" minimum:"0" description:"0 removes machine from parking"`
+}
+
+type updateParkingV2Request struct {
+	State    *int               `json:"state" enum:"0,1"`
+	Capacity *entities.Capacity `json:"capacity" minimum:"0"`
+}
+
+type saveRoleRequest struct {
+	Description string                `json:"description"`
+	Permissions []entities.Permission `json:"permissions" required:"true" description:"see GET /api/v2/permissions"`
+}
+
+type maintenanceRequest struct {
+	Maintenance bool `json:"maintenance" required:"true" description:"false returns machine to service"`
+}
+
+type machineTypeRequest struct {
+	Name        string `json:"name" required:"true" minLength:"1"`
+	Description string `json:"description"`
+}
+
+type siteRequest struct {
+	Name string `json:"name" required:"true" minLength:"1"`
+}
+
+type setSiteRequest struct {
+	SiteId int `json:"site_id" required:"true" minimum:"1"`
+}
+
+type userParkingsRequest struct {
+	ParkingIds []int `json:"parking_ids" required:"true" description:"parkings where user unlocks and returns machines, empty list allows any parking"`
+}
+
+type shiftRequest struct {
+	Name     string `json:"name" required:"true" minLength:"1"`
+	Start    string `json:"start" required:"true" description:"HH:MM in time zone of the shift"`
+	End      string `json:"end" required:"true" description:"HH:MM, shift ends next day if it is not after start"`
+	Days     []int  `json:"days" required:"true" description:"weekdays when shift starts, 0 is Sunday"`
+	Timezone string `json:"timezone" description:"IANA time zone, reports.timezone from config by default"`
+}
+
+type userShiftsRequest struct {
+	ShiftIds []int `json:"shift_ids" required:"true" description:"shifts when user unlocks machines, empty list allows any time"`
+}
+
+type serviceIntervalRequest struct {
+	Name       string `json:"name" required:"true" minLength:"1"`
+	EveryHours int    `json:"every_hours" required:"true" minimum:"1" description:"service is due every these operating hours"`
+	GraceHours int    `json:"grace_hours" minimum:"0" description:"machine can be used these hours after service is due"`
+}
+
+type closeWorkOrderRequest struct {
+	Notes string `json:"notes"`
+}
+
+type machineTypeIdRequest struct {
+	TypeId int `json:"type_id" required:"true" minimum:"0" description:"0 means that machine needs no certification"`
+}
+
+type createCertificationRequest struct {
+	UserId    int       `json:"user_id" required:"true"`
+	TypeId    int       `json:"machine_type_id" required:"true"`
+	Number    string    `json:"number"`
+	IssuedAt  time.Time `json:"issued_at" required:"true"`
+	ExpiresAt time.Time `json:"expires_at" required:"true"`
+}
+
+type updateCertificationRequest struct {
+	Number    string    `json:"number"`
+	IssuedAt  time.Time `json:"issued_at" required:"true"`
+	ExpiresAt time.Time `json:"expires_at" required:"true"`
+}
+
+type checklistItemRequest struct {
+	Text     string `json:"text" required:"true" minLength:"1"`
+	Critical bool   `json:"critical" description:"failed critical item refuses unlock and puts machine into maintenance"`
+}
+
+type replaceChecklistRequest struct {
+	Items []checklistItemRequest `json:"items" required:"true" description:"replace all items, empty list removes checklist"`
+}
+
+type checklistAnswerRequest struct {
+	ItemId  int    `json:"item_id" required:"true"`
+	Ok      bool   `json:"ok" required:"true"`
+	Comment string `json:"comment"`
+}
+
+type unlockMachineV2Request struct {
+	Checklist []checklistAnswerRequest `json:"checklist" description:"answers to checklist of the machine type, see GET /api/v2/machines/{id}/checklist"`
+}
+
+type reportIncidentRequest struct {
+	MachineId   string `json:"machine_id" required:"true" minLength:"1"`
+	Category    string `json:"category" required:"true" enum:"damage,malfunction,accident,near_miss,other"`
+	Severity    string `json:"severity" required:"true" enum:"low,medium,high,critical" description:"critical puts machine into maintenance"`
+	Description string `json:"description"`
+}
+
+type geofenceRequest struct {
+	Name   string   `json:"name" required:"true" minLength:"1"`
+	Kind   string   `json:"kind" required:"true" enum:"allowed,forbidden" description:"machine in use should stay within allowed geofences of its site, if there are any, and out of forbidden ones"`
+	Bssids []string `json:"bssids" required:"true" description:"BSSIDs of access points in the area"`
+	SiteId int      `json:"site_id" minimum:"0" description:"chosen by users with sites.manage on creation, site of the user by default"`
+}
+
+type bssidReportRequest struct {
+	Bssid string `json:"bssid" required:"true" minLength:"1" description:"access point the controller is connected to"`
+	Rssi  int    `json:"rssi" description:"signal strength in dBm, 0 if unknown"`
+}
+
+// triageIncidentRequest changes only present fields
+type triageIncidentRequest struct {
+	Status     *string `json:"status" enum:"open,acknowledged,resolved,rejected" description:"resolved and rejected incidents can not be changed"`
+	Severity   *string `json:"severity" enum:"low,medium,high,critical"`
+	Resolution *string `json:"resolution"`
+}
+
+type sessionIdResponse struct {
+	SessionId int `json:"sessionId"`
+}
+
+type msgResponse struct {
+	Msg string `json:"msg"`
+}
+
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+type currentStateResponse struct {
+	CurrentState int `json:"current_state"`
+}
+
+type qrKeyResponse struct {
+	QrKey   string `json:"qr_key"`
+	LocalIp string `json:"local_ip"`
+}
+
+// lockoutResponse is failed logins with phone number, Locked is true while logins are rejected
+type lockoutResponse struct {
+	entities.LoginFailures
+	Locked bool `json:"locked"`
+}
+
+// deviceTokenResponse is token of controller, it is sent in X-Device-Token header
+type deviceTokenResponse struct {
+	MachineId   string `json:"machine_id"`
+	DeviceToken string `json:"device_token"`
+}
+
+// bssidReportResponse is current state of machine and unresolved geofence alert of it, if there is one
+type bssidReportResponse struct {
+	CurrentState int                     `json:"current_state"`
+	Alert        *entities.GeofenceAlert `json:"alert"`
+}
+
+type incidentResponse struct {
+	entities.Incident
+	HasPhoto bool `json:"has_photo"`
+}
+
+func newIncidentResponse(incident entities.Incident) incidentResponse {
+	return incidentResponse{Incident: incident, HasPhoto: incident.PhotoKey != ""}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+)
+
+var roleNameRegexp = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// permissionResponse describes one of entities.Permissions
+type permissionResponse struct {
+	Name        entities.Permission `json:"name"`
+	Description string              `json:"description"`
+}
+
+func (h *Handler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	resp := make([]permissionResponse, 0, len(entities.Permissions))
+	for _, permission := range entities.AllPermissions() {
+		resp = append(resp, permissionResponse{Name: permission, Description: entities.Permissions[permission]})
+	}
+	respondJSON(w, r, http.StatusOK, resp)
+}
+
+func (h *Handler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.ListRoles(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list roles", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, roles)
+}
+
+func (h *Handler) GetRole(w http.ResponseWriter, r *http.Request) {
+	role, err := h.service.GetRole(r.Context(), r.PathValue("name"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, role)
+}
+
+// SaveRole creates role or replaces its description and permissions, admin role can not be changed.
+// Only permissions held by the user are granted, so nobody raises own permissions.
+func (h *Handler) SaveRole(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.SaveRole")
+
+	name := r.PathValue("name")
+	if err := checkEditableRole(name); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data saveRoleRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	for _, permission := range data.Permissions {
+		if _, ok := entities.Permissions[permission]; !ok {
+			respondError(w, r, errs.ErrUnknownPermission.WithMessage("unknown permission "+permission))
+			return
+		}
+	}
+	if err := checkGrantable(r.Context(), data.Permissions); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	slices.Sort(data.Permissions)
+
+	role, err := h.service.SaveRole(r.Context(), entities.Role{
+		Name:        name,
+		Description: data.Description,
+		Permissions: slices.Compact(data.Permissions),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "save role", op, slog.String("role", name), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "role is saved", op, slog.String("role", name), slog.Any("permissions", role.Permissions))
+	respondJSON(w, r, http.StatusOK, role)
+}
+
+// DeleteRole removes role which is not assigned to users
+func (h *Handler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := checkEditableRole(name); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := h.service.DeleteRole(r.Context(), name); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "role is deleted", slog.String("role", name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkGrantable returns errs.ErrAccessDenied if role of the user has not some of permissions
+func checkGrantable(ctx context.Context, permissions []entities.Permission) error {
+	role, ok := middlewares.RoleFromContext(ctx)
+	if !ok {
+		return errs.ErrAccessDenied
+	}
+	for _, permission := range permissions {
+		if !role.Has(permission) {
+			return errs.ErrAccessDenied.WithMessage("permission " + permission + " can not be granted without holding it")
+		}
+	}
+	return nil
+}
+
+func checkEditableRole(name string) error {
+	if name == entities.Admin {
+		return errs.ErrRoleReadOnly
+	}
+	if !roleNameRegexp.MatchString(name) {
+		return errs.ErrInvalidRoleName
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+func TestPermissionsOfRoleAreCheckedOnEveryRequest(t *testing.T) {
+	app := newTestApp(t, nil)
+	admin, worker := app.token("100"), app.token("200")
+
+	if w := app.do("GET", "/api/v2/users", worker, nil); w.Code != http.StatusForbidden || errorCode(t, w) != "access_denied" {
+		t.Fatalf("worker lists users: status %d, want 403 access_denied: %s", w.Code, w.Body.String())
+	}
+
+	role := decode[entities.Role](t, app.do("PUT", "/api/v2/roles/worker", admin, saveRoleRequest{
+		Description: "operates machines and sees colleagues",
+		Permissions: []entities.Permission{entities.PermUsersRead, entities.PermSessionsUse, entities.PermUsersRead},
+	}), http.StatusOK)
+	if len(role.Permissions) != 2 {
+		t.Errorf("permissions %v, want duplicates removed", role.Permissions)
+	}
+
+	// token issued before the change gets new permissions of the role
+	if w := app.do("GET", "/api/v2/users", worker, nil); w.Code != http.StatusOK {
+		t.Errorf("worker lists users after role change: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if w := app.do("GET", "/api/v2/reports/machines", worker, nil); w.Code != http.StatusForbidden {
+		t.Errorf("worker views reports: status %d, want 403", w.Code)
+	}
+}
+
+func TestInvalidRoleChangesAreRejected(t *testing.T) {
+	app := newTestApp(t, nil)
+	admin := app.token("100")
+
+	for _, tc := range []struct {
+		path, token string
+		body        saveRoleRequest
+		status      int
+		code        string
+	}{
+		{"/api/v2/roles/admin", admin, saveRoleRequest{Permissions: []string{entities.PermUsersRead}}, http.StatusForbidden, "role_read_only"},
+		{"/api/v2/roles/keeper", admin, saveRoleRequest{Permissions: []string{"keys.keep"}}, http.StatusBadRequest, "unknown_permission"},
+		{"/api/v2/roles/Keeper", admin, saveRoleRequest{Permissions: []string{entities.PermUsersRead}}, http.StatusBadRequest, "invalid_role_name"},
+		{"/api/v2/roles/keeper", app.token("200"), saveRoleRequest{Permissions: []string{entities.PermUsersRead}}, http.StatusForbidden, "access_denied"},
+	} {
+		w := app.do("PUT", tc.path, tc.token, tc.body)
+		if w.Code != tc.status || errorCode(t, w) != tc.code {
+			t.Errorf("PUT %s: status %d, want %d %s: %s", tc.path, w.Code, tc.status, tc.code, w.Body.String())
+		}
+	}
+
+	if w := app.do("DELETE", "/api/v2/roles/worker", admin, nil); w.Code != http.StatusConflict || errorCode(t, w) != "role_in_use" {
+		t.Errorf("delete role of users: status %d, want 409 role_in_use: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRolesAreManagedWithSitesManage(t *testing.T) {
+	app := newTestApp(t, nil)
+	body := map[string]any{"description": "keeper", "permissions": []string{"sites.manage", "users.read"}}
+
+	if w := app.do("PUT", "/api/v2/roles/keeper", app.token("300"), body); w.Code != http.StatusForbidden {
+		t.Fatalf("site admin: status %d, want 403: %s", w.Code, w.Body.String())
+	}
+	if w := app.do("PUT", "/api/v2/roles/keeper", app.token("100"), body); w.Code != http.StatusOK {
+		t.Fatalf("admin: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	token := app.token("400")
+
+	raised := map[string]any{"description": "keeper", "permissions": []string{"sites.manage", "users.read", "users.manage"}}
+	w := app.do("PUT", "/api/v2/roles/keeper", token, raised)
+	if w.Code != http.StatusForbidden || errorCode(t, w) != "access_denied" {
+		t.Errorf("grant not held permission: status %d, want 403 access_denied: %s", w.Code, w.Body.String())
+	}
+	if w = app.do("PUT", "/api/v2/roles/keeper", token, body); w.Code != http.StatusOK {
+		t.Errorf("grant held permissions: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+)
+
+// router is http.ServeMux which remembers registered patterns.
+// Duration of requests is recorded for every pattern
+type router struct {
+	mux      *http.ServeMux
+	patterns []string
+}
+
+func newRouter() *router {
+	return &router{mux: http.NewServeMux()}
+}
+
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.mux.Handle(pattern, middlewares.MetricsMiddleware(pattern, handler))
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
+)
+
+func (h *Handler) ListShifts(w http.ResponseWriter, r *http.Request) {
+	shifts, err := h.service.ListShifts(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list shifts", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, shifts)
+}
+
+func (h *Handler) GetShift(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	shift, err := h.service.GetShift(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, shift)
+}
+
+func (h *Handler) CreateShift(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.CreateShift")
+
+	shift, err := h.parseShift(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	created, err := h.service.InsertShift(r.Context(), shift)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "insert shift", op, slog.String("name", shift.Name), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "shift is created", op, slog.Int("shift_id", created.Id))
+	respondJSON(w, r, http.StatusCreated, created)
+}
+
+// UpdateShift replaces the shift, users assigned to it keep the assignment
+func (h *Handler) UpdateShift(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.UpdateShift")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	shift, err := h.parseShift(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	shift.Id = id
+
+	updated, err := h.service.UpdateShift(r.Context(), shift)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update shift", op, slog.Int("shift_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "shift is updated", op, slog.Int("shift_id", id))
+	respondJSON(w, r, http.StatusOK, updated)
+}
+
+// DeleteShift removes the shift from its users, users without other shifts are no longer restricted
+func (h *Handler) DeleteShift(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err = h.service.DeleteShift(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "shift is deleted", slog.Int("shift_id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserShifts responds with shifts of the user, empty list means the user is not restricted by time
+func (h *Handler) GetUserShifts(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err = h.getUser(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	shifts, err := h.service.ListUserShifts(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get shifts of user", slog.Int("user_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, shifts)
+}
+
+// ReplaceUserShifts replaces shifts of the user, empty list removes restriction
+func (h *Handler) ReplaceUserShifts(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.ReplaceUserShifts")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data userShiftsRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	if _, err = h.getUser(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	for _, shiftId := range data.ShiftIds {
+		if _, err = h.service.GetShift(r.Context(), shiftId); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	if err = h.service.ReplaceUserShifts(r.Context(), id, data.ShiftIds); err != nil {
+		slog.ErrorContext(r.Context(), "replace shifts of user", op, slog.Int("user_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	shifts, err := h.service.ListUserShifts(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get shifts of user", op, slog.Int("user_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "shifts of user are replaced", op, slog.Int("user_id", id), slog.Any("shift_ids", data.ShiftIds))
+	respondJSON(w, r, http.StatusOK, shifts)
+}
+
+func (h *Handler) ShiftsReport(w http.ResponseWriter, r *http.Request) {
+	respondReport(w, r, "shifts", h.service.ShiftUsage)
+}
+
+// parseShift parses and validates shift from request body, time zone is `reports.timezone` from config by default
+func (h *Handler) parseShift(r *http.Request) (entities.Shift, error) {
+	var data shiftRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		return entities.Shift{}, errs.ErrInvalidRequest.Wrap(err)
+	}
+
+	shift := entities.Shift{Name: data.Name, Start: data.Start, End: data.End, Days: data.Days, Timezone: data.Timezone}
+	if shift.Timezone == "" {
+		shift.Timezone = h.cfg.Reports.Timezone
+	}
+	if err := shift.Validate(); err != nil {
+		return entities.Shift{}, errs.ErrInvalidShift.WithMessage(err.Error())
+	}
+	return shift, nil
+}
+
+// checkShift returns errs.ErrOutsideShift if user has shifts and now is out of all of them
+func checkShift(ctx context.Context, svc *service.Service, user *entities.User, now time.Time) error {
+	shifts, err := svc.ListUserShifts(ctx, user.Id)
+	if err != nil {
+		return errors.Wrap(err, "get shifts of user")
+	}
+	if len(shifts) == 0 {
+		return nil
+	}
+
+	for _, s := range shifts {
+		if s.Contains(now) {
+			return nil
+		}
+	}
+	return errs.ErrOutsideShift
+}
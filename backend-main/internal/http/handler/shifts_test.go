@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+func TestUnlockIsRestrictedToShiftsOfUser(t *testing.T) {
+	app := newTestApp(t, nil)
+	d := newDevice(t)
+	app.machine("M1", d)
+	admin, worker := app.token("100"), app.token("200")
+
+	user, err := app.svc.GetUserByPhoneNumber(context.Background(), "200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	everyDay := []int{0, 1, 2, 3, 4, 5, 6}
+	later := decode[entities.Shift](t, app.do("POST", "/api/v2/shifts", admin, shiftRequest{
+		Name: "Later", Start: now.Add(2 * time.Hour).Format("15:04"), End: now.Add(3 * time.Hour).Format("15:04"), Days: everyDay, Timezone: "UTC",
+	}), http.StatusCreated)
+	current := decode[entities.Shift](t, app.do("POST", "/api/v2/shifts", admin, shiftRequest{
+		Name: "Current", Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04"), Days: everyDay, Timezone: "UTC",
+	}), http.StatusCreated)
+
+	path := fmt.Sprintf("/api/v2/users/%d/shifts", user.Id)
+	decode[[]entities.Shift](t, app.do("PUT", path, admin, userShiftsRequest{ShiftIds: []int{later.Id}}), http.StatusOK)
+
+	w := app.do("POST", "/api/v2/machines/M1/unlock", worker, nil)
+	if w.Code != http.StatusForbidden || errorCode(t, w) != "outside_shift" {
+		t.Fatalf("unlock out of shift: status %d, want 403 outside_shift: %s", w.Code, w.Body.String())
+	}
+	if n := d.requests.Load(); n != 0 {
+		t.Errorf("device got %d requests, want none", n)
+	}
+
+	decode[[]entities.Shift](t, app.do("PUT", path, admin, userShiftsRequest{ShiftIds: []int{later.Id, current.Id}}), http.StatusOK)
+	decode[entities.Session](t, app.do("POST", "/api/v2/machines/M1/unlock", worker, nil), http.StatusCreated)
+}
+
+func TestInvalidShiftIsRejected(t *testing.T) {
+	app := newTestApp(t, nil)
+
+	w := app.do("POST", "/api/v2/shifts", app.token("100"), shiftRequest{Name: "Night", Start: "22:00", End: "06:00", Days: []int{5}, Timezone: "Mars/Olympus"})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "invalid_shift" {
+		t.Errorf("status %d, want 400 invalid_shift: %s", w.Code, w.Body.String())
+	}
+}
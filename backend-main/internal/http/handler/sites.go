@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
+)
+
+func (h *Handler) ListSites(w http.ResponseWriter, r *http.Request) {
+	sites, err := h.service.ListSites(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list sites", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, sites)
+}
+
+func (h *Handler) GetSite(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	site, err := h.service.GetSite(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, site)
+}
+
+func (h *Handler) CreateSite(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.CreateSite")
+
+	var data siteRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	site, err := h.service.InsertSite(r.Context(), data.Name)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "insert site", op, slog.String("name", data.Name), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "site is created", op, slog.Int("site_id", site.Id))
+	respondJSON(w, r, http.StatusCreated, site)
+}
+
+func (h *Handler) UpdateSite(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.UpdateSite")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data siteRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	site, err := h.service.UpdateSite(r.Context(), entities.Site{Id: id, Name: data.Name})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update site", op, slog.Int("site_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "site is renamed", op, slog.Int("site_id", id))
+	respondJSON(w, r, http.StatusOK, site)
+}
+
+// SetUserSiteV2 moves user to another site, parkings allowed to the user are removed as they are at the old site.
+// Token of the user keeps the old site until the user logs in again.
+func (h *Handler) SetUserSiteV2(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.SetUserSiteV2")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data setSiteRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	if _, err = h.service.GetSite(r.Context(), data.SiteId); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	user, err := h.service.UpdateUserSiteId(r.Context(), id, data.SiteId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update site of user", op, slog.Int("user_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	if err = h.service.ReplaceUserParkings(r.Context(), id, nil); err != nil {
+		slog.ErrorContext(r.Context(), "remove parkings of user", op, slog.Int("user_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "user is moved to site", op, slog.Int("user_id", id), slog.Int("site_id", data.SiteId))
+	respondJSON(w, r, http.StatusOK, user)
+}
+
+// SetMachineSiteV2 moves free machine which is not at parking to another site
+func (h *Handler) SetMachineSiteV2(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.SetMachineSiteV2")
+
+	var data setSiteRequest
+	if err := utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	if _, err := h.service.GetSite(r.Context(), data.SiteId); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	machineId := r.PathValue("id")
+	release, err := h.acquireMachine(r.Context(), machineId)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer release()
+
+	machine, err := h.service.GetMachineByID(r.Context(), machineId)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if machine.State != entities.MachineFree {
+		respondError(w, r, errs.ErrMachineNotFree)
+		return
+	}
+	if machine.ParkingId != 0 && machine.SiteId != data.SiteId {
+		respondError(w, r, errs.ErrSiteMismatch.WithMessage("remove machine from parking before moving it to another site"))
+		return
+	}
+
+	machine, err = h.service.UpdateMachineSiteId(r.Context(), machineId, data.SiteId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update site of machine", op, slog.String("machine_id", machineId), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "machine is moved to site", op, slog.String("machine_id", machineId), slog.Int("site_id", data.SiteId))
+	respondJSON(w, r, http.StatusOK, machine)
+}
+
+// siteScope returns site which the user works with, 0 means all sites for users with sites.manage.
+// Requests without authorized user are not scoped.
+func siteScope(ctx context.Context) int {
+	if role, ok := middlewares.RoleFromContext(ctx); ok && role.Has(entities.PermSitesManage) {
+		return 0
+	}
+	siteId, _ := middlewares.SiteFromContext(ctx)
+	return siteId
+}
+
+// inScope reports if objects of the site are visible to the user
+func inScope(ctx context.Context, siteId int) bool {
+	scope := siteScope(ctx)
+	return scope == 0 || scope == siteId
+}
+
+// listSite returns site to filter lists by, users with sites.manage choose it with `site_id` query parameter
+func listSite(r *http.Request) (int, error) {
+	if scope := siteScope(r.Context()); scope != 0 {
+		return scope, nil
+	}
+
+	siteId, err := queryInt(r.URL.Query(), "site_id")
+	if err != nil || siteId == nil {
+		return 0, err
+	}
+	return *siteId, nil
+}
+
+// targetSite returns site for new object: requested one for users with sites.manage, otherwise site of the user
+func (h *Handler) targetSite(ctx context.Context, requested int) (int, error) {
+	if requested == 0 {
+		if siteId, ok := middlewares.SiteFromContext(ctx); ok {
+			return siteId, nil
+		}
+		return entities.DefaultSiteId, nil
+	}
+
+	if !inScope(ctx, requested) {
+		return 0, errs.ErrSiteNotFound
+	}
+	if _, err := h.service.GetSite(ctx, requested); err != nil {
+		return 0, err
+	}
+	return requested, nil
+}
+
+// getUser returns user if it is visible to the user of request, users of other sites are not found
+func (h *Handler) getUser(ctx context.Context, userId int) (*entities.User, error) {
+	user, err := h.service.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !inScope(ctx, user.SiteId) {
+		return nil, errs.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// getMachine returns machine if it is visible to the user of request, machines of other sites are not found
+func (h *Handler) getMachine(ctx context.Context, machineId string) (*entities.Machine, error) {
+	machine, err := h.service.GetMachineByID(ctx, machineId)
+	if err != nil {
+		return nil, err
+	}
+	if !inScope(ctx, machine.SiteId) {
+		return nil, errs.ErrMachineNotFound
+	}
+	return machine, nil
+}
+
+// checkMachineScope returns errs.ErrMachineNotFound if machine is at another site than the user of request.
+// Users with sites.manage see objects of deleted machines too.
+func (h *Handler) checkMachineScope(ctx context.Context, machineId string) error {
+	if siteScope(ctx) == 0 {
+		return nil
+	}
+	_, err := h.getMachine(ctx, machineId)
+	return err
+}
+
+// getParking returns parking if it is visible to the user of request, parkings of other sites are not found
+func (h *Handler) getParking(ctx context.Context, parkingId int) (*entities.Parking, error) {
+	parking, err := h.service.GetParkingById(ctx, parkingId)
+	if err != nil {
+		return nil, err
+	}
+	if !inScope(ctx, parking.SiteId) {
+		return nil, errs.ErrParkingNotFound
+	}
+	return parking, nil
+}
+
+// getSession returns session if its machine is visible to the user of request
+func (h *Handler) getSession(ctx context.Context, sessionId int) (*entities.Session, error) {
+	session, err := h.service.GetSessionByID(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = h.getMachine(ctx, session.MachineId); errors.Is(err, errs.ErrMachineNotFound) {
+		return nil, errs.ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// checkParkingSite returns errs.ErrSiteMismatch if machine can not be parked at parking of another site
+func checkParkingSite(machine *entities.Machine, parking *entities.Parking) error {
+	if machine.SiteId != parking.SiteId {
+		return errs.ErrSiteMismatch.WithMessage("parking is at another site than machine")
+	}
+	return nil
+}
+
+// getCertification returns certification if its user is visible to the user of request
+func (h *Handler) getCertification(ctx context.Context, certificationId int) (*entities.Certification, error) {
+	certification, err := h.service.GetCertification(ctx, certificationId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = h.getUser(ctx, certification.UserId); errors.Is(err, errs.ErrUserNotFound) {
+		return nil, errs.ErrCertificationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return certification, nil
+}
+
+// getWorkOrder returns work order if its machine is visible to the user of request
+func (h *Handler) getWorkOrder(ctx context.Context, orderId int) (*entities.WorkOrder, error) {
+	order, err := h.service.GetWorkOrder(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.checkMachineScope(ctx, order.MachineId); errors.Is(err, errs.ErrMachineNotFound) {
+		return nil, errs.ErrWorkOrderNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// getChecklistSubmission returns submission if its machine is visible to the user of request
+func (h *Handler) getChecklistSubmission(ctx context.Context, submissionId int) (*entities.ChecklistSubmission, error) {
+	submission, err := h.service.GetChecklistSubmission(ctx, submissionId)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.checkMachineScope(ctx, submission.MachineId); errors.Is(err, errs.ErrMachineNotFound) {
+		return nil, errs.ErrChecklistSubmissionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
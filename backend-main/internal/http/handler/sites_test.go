@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+// siteFixture has one machine, incident, work order and certification at the default site
+// and the same at the second site, where the site admin (phone 300) works
+type siteFixture struct {
+	app *testApp
+
+	incidents      [2]int
+	workOrders     [2]int
+	certifications [2]int
+}
+
+func newSiteFixture(t *testing.T) *siteFixture {
+	t.Helper()
+
+	ctx := context.Background()
+	f := &siteFixture{app: newTestApp(t, nil)}
+	svc := f.app.svc
+
+	site, err := svc.InsertSite(ctx, "north")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := svc.GetUserByPhoneNumber(ctx, "300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.UpdateUserSiteId(ctx, admin.Id, site.Id); err != nil {
+		t.Fatal(err)
+	}
+	worker, err := svc.GetUserByPhoneNumber(ctx, "200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	machineType, err := svc.InsertMachineType(ctx, "forklift", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i, machineId := range []string{"M1", "M2"} {
+		f.app.machine(machineId, newDevice(t))
+		userId := worker.Id
+		if i == 1 {
+			userId = admin.Id
+			if _, err = svc.UpdateMachineSiteId(ctx, machineId, site.Id); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		incident, err := svc.InsertIncident(ctx, entities.Incident{MachineId: machineId, ReporterId: userId,
+			Category: entities.IncidentDamage, Severity: entities.SeverityLow, Status: entities.IncidentOpen, CreatedAt: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		order, err := svc.InsertWorkOrder(ctx, entities.WorkOrder{MachineId: machineId, IntervalId: i + 1, Name: "oil",
+			Status: entities.WorkOrderOpen, CreatedAt: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		certification, err := svc.InsertCertification(ctx, entities.Certification{UserId: userId, TypeId: machineType.Id,
+			Number: machineId, IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(24 * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.incidents[i], f.workOrders[i], f.certifications[i] = incident.Id, order.Id, certification.Id
+	}
+	return f
+}
+
+func TestSiteAdminSeesOnlyItsSite(t *testing.T) {
+	f := newSiteFixture(t)
+	token := f.app.token("300")
+
+	incidents := decode[entities.Page[incidentResponse]](t, f.app.do("GET", "/api/v2/incidents", token, nil), http.StatusOK)
+	if len(incidents.Items) != 1 || incidents.Items[0].Id != f.incidents[1] {
+		t.Errorf("incidents %+v, want only %d", incidents.Items, f.incidents[1])
+	}
+	orders := decode[entities.Page[entities.WorkOrder]](t, f.app.do("GET", "/api/v2/work-orders", token, nil), http.StatusOK)
+	if len(orders.Items) != 1 || orders.Items[0].Id != f.workOrders[1] {
+		t.Errorf("work orders %+v, want only %d", orders.Items, f.workOrders[1])
+	}
+	certifications := decode[[]entities.Certification](t, f.app.do("GET", "/api/v2/certifications", token, nil), http.StatusOK)
+	if len(certifications) != 1 || certifications[0].Id != f.certifications[1] {
+		t.Errorf("certifications %+v, want only %d", certifications, f.certifications[1])
+	}
+
+	// site_id of other site is ignored for users without sites.manage
+	usage := decode[reportResponse[entities.MachineUsage]](t, f.app.do("GET", "/api/v2/reports/machines?site_id=1", token, nil), http.StatusOK)
+	if len(usage.Items) != 1 || usage.Items[0].MachineId != "M2" {
+		t.Errorf("machines report %+v, want only M2", usage.Items)
+	}
+	expiring := decode[reportResponse[entities.ExpiringCertification]](t,
+		f.app.do("GET", "/api/v2/reports/expiring-certifications", token, nil), http.StatusOK)
+	if len(expiring.Items) != 1 || expiring.Items[0].CertificationId != f.certifications[1] {
+		t.Errorf("expiring certifications %+v, want only %d", expiring.Items, f.certifications[1])
+	}
+
+	for path, code := range map[string]string{
+		fmt.Sprintf("/api/v2/incidents/%d", f.incidents[0]):           "incident_not_found",
+		fmt.Sprintf("/api/v2/work-orders/%d", f.workOrders[0]):        "work_order_not_found",
+		fmt.Sprintf("/api/v2/certifications/%d", f.certifications[0]): "certification_not_found",
+	} {
+		w := f.app.do("GET", path, token, nil)
+		if w.Code != http.StatusNotFound || errorCode(t, w) != code {
+			t.Errorf("GET %s: status %d, want 404 %s: %s", path, w.Code, code, w.Body.String())
+		}
+	}
+	for _, path := range []string{
+		fmt.Sprintf("/api/v2/incidents/%d", f.incidents[1]),
+		fmt.Sprintf("/api/v2/work-orders/%d", f.workOrders[1]),
+		fmt.Sprintf("/api/v2/certifications/%d", f.certifications[1]),
+	} {
+		if w := f.app.do("GET", path, token, nil); w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want 200: %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSitesManageChoosesSite(t *testing.T) {
+	f := newSiteFixture(t)
+	token := f.app.token("100")
+
+	incidents := decode[entities.Page[incidentResponse]](t, f.app.do("GET", "/api/v2/incidents", token, nil), http.StatusOK)
+	if len(incidents.Items) != 2 {
+		t.Errorf("%d incidents of all sites, want 2", len(incidents.Items))
+	}
+
+	usage := decode[reportResponse[entities.MachineUsage]](t, f.app.do("GET", "/api/v2/reports/machines?site_id=1", token, nil), http.StatusOK)
+	if len(usage.Items) != 1 || usage.Items[0].MachineId != "M1" {
+		t.Errorf("machines report of site 1 %+v, want only M1", usage.Items)
+	}
+
+	if w := f.app.do("GET", fmt.Sprintf("/api/v2/incidents/%d", f.incidents[1]), token, nil); w.Code != http.StatusOK {
+		t.Errorf("get incident of other site: status %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
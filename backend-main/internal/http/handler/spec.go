@@ -0,0 +1,382 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/export"
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/openapi"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// op describes one route of the api
+type op struct {
+	// handle serves the route, routes wraps it with middlewares described by other fields
+	handle http.HandlerFunc
+
+	tag     string
+	summary string
+	// permission required to call the route, public routes have none
+	permission entities.Permission
+	body       any
+	query      []openapi.Parameter
+	code       int
+	resp       *openapi.Schema
+
+	// idempotent commands accept Idempotency-Key header
+	idempotent bool
+	// throttled routes answer 429 with Retry-After header
+	throttled bool
+	// device routes are called by machine controller with its token instead of user token
+	device bool
+
+	// content types of raw file sent in request body or in response instead of json
+	upload   []string
+	download []string
+}
+
+func (o op) operation() *openapi.Operation {
+	errorSchema := openapi.SchemaOf(utils.ErrorResponse{})
+
+	operation := &openapi.Operation{
+		Summary:    o.summary,
+		Tags:       []string{o.tag},
+		Parameters: o.query,
+		Responses: map[string]openapi.Response{
+			strconv.Itoa(o.code): openapi.JSONResponse(http.StatusText(o.code), o.resp),
+			"default":            openapi.JSONResponse("Error", errorSchema),
+		},
+	}
+
+	if o.body != nil {
+		schema := openapi.SchemaOf(o.body)
+		operation.RequestBody = openapi.JSONBody(schema)
+		// body without required fields may be omitted, see middlewares.ValidateBody
+		operation.RequestBody.Required = len(schema.Required) > 0
+		operation.Responses["400"] = openapi.JSONResponse("Request body does not match schema", errorSchema)
+	}
+
+	if len(o.upload) > 0 {
+		operation.RequestBody = openapi.BinaryBody(o.upload...)
+	}
+	if len(o.download) > 0 {
+		operation.Responses[strconv.Itoa(o.code)] = openapi.BinaryResponse(http.StatusText(o.code), o.download...)
+	}
+
+	if o.idempotent {
+		operation.Parameters = append(operation.Parameters, openapi.Parameter{
+			Name: middlewares.IdempotencyKeyHeader, In: "header",
+			Description: "outcome of the first request with the key is replayed for repeated ones",
+			Schema:      &openapi.Schema{Type: openapi.TypeString},
+		})
+		operation.Responses["409"] = openapi.JSONResponse("Request with the key is in progress or other conflict", errorSchema)
+	}
+
+	if o.throttled {
+		operation.Responses["429"] = openapi.JSONResponse("Too many attempts, retry after Retry-After seconds", errorSchema)
+	}
+
+	if o.device {
+		operation.Description = "Called by controller of the machine, see `GET /api/v2/machines/{id}/device-token`."
+		operation.Parameters = append(operation.Parameters, openapi.Parameter{
+			Name: middlewares.DeviceTokenHeader, In: "header", Required: true,
+			Description: "token of the machine controller",
+			Schema:      &openapi.Schema{Type: openapi.TypeString},
+		})
+		operation.Responses["401"] = openapi.JSONResponse("Missing token or token of another machine", errorSchema)
+	}
+
+	if o.permission != "" {
+		operation.Description = "Requires `" + o.permission + "` permission."
+		operation.Security = []openapi.SecurityRequirement{{openapi.BearerAuth: {}}}
+		operation.Responses["401"] = openapi.JSONResponse("Missing, invalid or expired token", errorSchema)
+		operation.Responses["403"] = openapi.JSONResponse("User have no access to this resource", errorSchema)
+	}
+	return operation
+}
+
+func queryParam(name, typ string, required bool) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Required: required, Schema: &openapi.Schema{Type: typ}}
+}
+
+func enumParam(name string, values []string) openapi.Parameter {
+	enum := make([]any, 0, len(values))
+	for _, v := range values {
+		enum = append(enum, v)
+	}
+	return openapi.Parameter{Name: name, In: "query", Schema: &openapi.Schema{Type: openapi.TypeString, Enum: enum}}
+}
+
+// listQuery returns query parameters of list route: pagination, sort and filters
+func listQuery(sorts []string, filters ...openapi.Parameter) []openapi.Parameter {
+	sortEnum := make([]any, 0, 2*len(sorts))
+	for _, field := range sorts {
+		sortEnum = append(sortEnum, field, "-"+field)
+	}
+
+	minLimit, maxLimit := 1.0, float64(entities.MaxPageLimit)
+	params := []openapi.Parameter{
+		{Name: "limit", In: "query", Description: "page size, default " + strconv.Itoa(entities.DefaultPageLimit),
+			Schema: &openapi.Schema{Type: openapi.TypeInteger, Minimum: &minLimit, Maximum: &maxLimit}},
+		{Name: "cursor", In: "query", Description: "next_cursor of previous page (X-Next-Cursor header in v1)",
+			Schema: &openapi.Schema{Type: openapi.TypeString}},
+		{Name: "sort", In: "query", Description: "sort field, `-` prefix means descending order",
+			Schema: &openapi.Schema{Type: openapi.TypeString, Enum: sortEnum}},
+	}
+	return append(params, filters...)
+}
+
+// siteQuery is site filter of lists and reports,
+// users without sites.manage always get objects of their own site
+var siteQuery = openapi.Parameter{Name: "site_id", In: "query", Description: "site filter for users with sites.manage",
+	Schema: &openapi.Schema{Type: openapi.TypeInteger}}
+
+// reportQuery returns query parameters of report route
+func reportQuery(extra ...openapi.Parameter) []openapi.Parameter {
+	params := []openapi.Parameter{
+		{Name: "from", In: "query", Description: "range start, YYYY-MM-DD or RFC 3339, 30 days before `to` by default",
+			Schema: &openapi.Schema{Type: openapi.TypeString}},
+		{Name: "to", In: "query", Description: "range end (exclusive), YYYY-MM-DD or RFC 3339, now by default",
+			Schema: &openapi.Schema{Type: openapi.TypeString}},
+		{Name: "format", In: "query", Description: "csv and xlsx are sent as attachment",
+			Schema: &openapi.Schema{Type: openapi.TypeString, Enum: []any{formatJSON, formatCSV, formatXLSX}}},
+		siteQuery,
+	}
+	return append(params, extra...)
+}
+
+// apiRoutes describes every route of the api with its handler.
+// routes registers the table and newSpec serves it as openapi spec, so they can not diverge
+func (h *Handler) apiRoutes() map[string]op {
+	var (
+		user     = openapi.SchemaOf(entities.User{})
+		users    = openapi.ArrayOf(entities.User{})
+		machine  = openapi.SchemaOf(entities.Machine{})
+		machines = openapi.ArrayOf(entities.Machine{})
+		parking  = openapi.SchemaOf(entities.Parking{})
+		parkings = openapi.ArrayOf(entities.Parking{})
+		session  = openapi.SchemaOf(entities.Session{})
+		sessions = openapi.ArrayOf(entities.Session{})
+
+		machineType   = openapi.SchemaOf(entities.MachineType{})
+		certification = openapi.SchemaOf(entities.Certification{})
+		checklist     = openapi.ArrayOf(entities.ChecklistItem{})
+		incident      = openapi.SchemaOf(incidentResponse{})
+		interval      = openapi.SchemaOf(entities.ServiceInterval{})
+		workOrder     = openapi.SchemaOf(entities.WorkOrder{})
+		shift         = openapi.SchemaOf(entities.Shift{})
+		site          = openapi.SchemaOf(entities.Site{})
+		geofence      = openapi.SchemaOf(entities.Geofence{})
+		alert         = openapi.SchemaOf(entities.Alert{})
+		shifts        = openapi.ArrayOf(entities.Shift{})
+
+		userPage    = openapi.SchemaOf(entities.Page[entities.User]{})
+		machinePage = openapi.SchemaOf(entities.Page[entities.Machine]{})
+		parkingPage = openapi.SchemaOf(entities.Page[entities.Parking]{})
+		sessionPage = openapi.SchemaOf(entities.Page[entities.Session]{})
+		trailPage   = openapi.SchemaOf(entities.Page[entities.Sighting]{})
+
+		userQuery    = listQuery(entities.UserSorts, queryParam("job_position", openapi.TypeString, false), siteQuery)
+		machineQuery = listQuery(entities.MachineSorts, queryParam("state", openapi.TypeInteger, false),
+			queryParam("parking_id", openapi.TypeInteger, false), queryParam("type_id", openapi.TypeInteger, false), siteQuery)
+		parkingQuery = listQuery(entities.ParkingSorts, queryParam("state", openapi.TypeInteger, false), siteQuery)
+		sessionQuery = listQuery(entities.SessionSorts, queryParam("state", openapi.TypeInteger, false), siteQuery,
+			queryParam("worker_id", openapi.TypeInteger, false), queryParam("machine_id", openapi.TypeString, false),
+			queryParam("parking_id", openapi.TypeInteger, false),
+			openapi.Parameter{Name: "from", In: "query", Description: "sessions started at or after, YYYY-MM-DD or RFC 3339",
+				Schema: &openapi.Schema{Type: openapi.TypeString}},
+			openapi.Parameter{Name: "to", In: "query", Description: "sessions started before, YYYY-MM-DD or RFC 3339",
+				Schema: &openapi.Schema{Type: openapi.TypeString}})
+		trailQuery = listQuery(entities.SightingSorts,
+			openapi.Parameter{Name: "from", In: "query", Description: "sightings at or after, YYYY-MM-DD or RFC 3339",
+				Schema: &openapi.Schema{Type: openapi.TypeString}},
+			openapi.Parameter{Name: "to", In: "query", Description: "sightings before, YYYY-MM-DD or RFC 3339",
+				Schema: &openapi.Schema{Type: openapi.TypeString}})
+	)
+
+	return map[string]op{
+		"GET /openapi.json": {handle: h.GetOpenAPI, tag: "meta", summary: "OpenAPI specification", code: 200, resp: &openapi.Schema{Type: openapi.TypeObject}},
+		"GET /metrics":      {handle: promhttp.Handler().ServeHTTP, tag: "meta", summary: "Prometheus metrics in text format", code: 200, resp: &openapi.Schema{Type: openapi.TypeString}},
+		"GET /healthz":      {handle: h.Healthz, tag: "meta", summary: "Liveness, background workers are running", code: 200, resp: openapi.SchemaOf(health.Report{})},
+		"GET /readyz":       {handle: h.Readyz, tag: "meta", summary: "Readiness, dependencies are reachable and background workers are running", code: 200, resp: openapi.SchemaOf(health.Report{})},
+
+		// v1
+		"GET /get_all_users":           {handle: h.GetAllUsers, tag: "v1", summary: "List users", permission: entities.PermUsersRead, query: userQuery, code: 200, resp: users},
+		"GET /get_user":                {handle: h.GetUserByID, tag: "v1", summary: "Get user", permission: entities.PermUsersRead, query: []openapi.Parameter{queryParam("user_id", openapi.TypeInteger, true)}, code: 200, resp: user},
+		"GET /get_parking_machines":    {handle: h.GetMachinesByParkingName, tag: "v1", summary: "List machines at parking", permission: entities.PermMachinesRead, query: []openapi.Parameter{queryParam("name", openapi.TypeString, true)}, code: 200, resp: machines},
+		"GET /get_all_machines":        {handle: h.GetAllMachines, tag: "v1", summary: "List machines", permission: entities.PermMachinesRead, query: machineQuery, code: 200, resp: machines},
+		"GET /get_machine":             {handle: h.GetMachineByID, tag: "v1", summary: "Get machine", permission: entities.PermMachinesRead, query: []openapi.Parameter{queryParam("machine_id", openapi.TypeString, true)}, code: 200, resp: machine},
+		"GET /get_all_sessions":        {handle: h.GetAllSessions, tag: "v1", summary: "List sessions", permission: entities.PermSessionsRead, query: sessionQuery, code: 200, resp: sessions},
+		"GET /get_session":             {handle: h.GetSessionByID, tag: "v1", summary: "Get session", permission: entities.PermSessionsRead, query: []openapi.Parameter{queryParam("session_id", openapi.TypeInteger, true)}, code: 200, resp: session},
+		"GET /get_all_parkings":        {handle: h.GetAllParkings, tag: "v1", summary: "List parkings", permission: entities.PermParkingsRead, query: parkingQuery, code: 200, resp: parkings},
+		"GET /get_parking":             {handle: h.GetParkingById, tag: "v1", summary: "Get parking", permission: entities.PermParkingsRead, query: []openapi.Parameter{queryParam("parking_id", openapi.TypeInteger, true)}, code: 200, resp: parking},
+		"POST /register_parking":       {handle: h.RegisterParking, tag: "v1", summary: "Create parking", permission: entities.PermParkingsManage, body: createParkingRequest{}, code: 200, resp: parking},
+		"PUT /update_parking_state":    {handle: h.UpdateParkingState, tag: "v1", summary: "Update parking state", permission: entities.PermParkingsManage, body: updateParkingStateRequest{}, code: 200, resp: parking},
+		"PUT /update_parking_capacity": {handle: h.UpdateParkingCapacity, tag: "v1", summary: "Update parking capacity", permission: entities.PermParkingsManage, body: updateParkingCapacityRequest{}, code: 200, resp: parking},
+		"PUT /add_machine":             {handle: h.ManualyMoveParkingMachine, tag: "v1", summary: "Move free machine to parking", permission: entities.PermMachinesManage, body: moveMachineRequest{}, code: 200, resp: machine},
+		"POST /login":                  {handle: h.Login, tag: "v1", summary: "Get JWT token", body: loginRequest{}, code: 200, resp: openapi.SchemaOf(tokenResponse{}), throttled: true},
+		"GET /get_qr_key":              {handle: h.GetQrKey, tag: "v1", summary: "Get current qr key", permission: entities.PermSessionsUse, code: 200, resp: openapi.SchemaOf(qrKeyResponse{})},
+		"POST /finish_session":         {handle: h.FinishSession, tag: "v1", summary: "Finish sessions with qr-code", permission: entities.PermSessionsUse, body: finishSessionRequest{}, code: 200, resp: openapi.SchemaOf(msgResponse{}), idempotent: true},
+		"POST /unlock_machine":         {handle: h.UnlockMachine, tag: "v1", summary: "Start session", permission: entities.PermSessionsUse, body: unlockMachineRequest{}, code: 200, resp: openapi.SchemaOf(sessionIdResponse{}), idempotent: true},
+		"POST /lock_machine":           {handle: h.LockMachine, tag: "v1", summary: "Finish session at current parking", permission: entities.PermSessionsUse, body: machineIdRequest{}, code: 200, resp: openapi.SchemaOf(msgResponse{}), idempotent: true},
+		"POST /stop_machine":           {handle: h.StopMachine, tag: "v1", summary: "Pause session", permission: entities.PermSessionsUse, body: machineIdRequest{}, code: 200, resp: openapi.SchemaOf(sessionIdResponse{}), idempotent: true},
+		"POST /unstop_machine":         {handle: h.UnstopMachine, tag: "v1", summary: "Resume session", permission: entities.PermSessionsUse, body: machineIdRequest{}, code: 200, resp: openapi.SchemaOf(sessionIdResponse{}), idempotent: true},
+		"POST /register_machine":       {handle: h.RegisterMachine, tag: "v1", summary: "Register microcontroller", body: registerMachineRequest{}, code: 200, resp: openapi.SchemaOf(currentStateResponse{})},
+
+		// v2
+		"POST /api/v2/auth/login":              {handle: h.LoginV2, tag: "auth", summary: "Get JWT token", body: loginRequest{}, code: 200, resp: openapi.SchemaOf(tokenResponse{}), throttled: true},
+		"GET /api/v2/auth/lockouts":            {handle: h.ListLockouts, tag: "auth", summary: "List phone numbers with recent failed logins", permission: entities.PermUsersManage, query: []openapi.Parameter{siteQuery}, code: 200, resp: openapi.ArrayOf(lockoutResponse{})},
+		"DELETE /api/v2/auth/lockouts/{phone}": {handle: h.DeleteLockout, tag: "auth", summary: "Unlock phone number", permission: entities.PermUsersManage, code: 204},
+		"GET /api/v2/permissions":              {handle: h.ListPermissions, tag: "users", summary: "List known permissions", permission: entities.PermUsersRead, code: 200, resp: openapi.ArrayOf(permissionResponse{})},
+		"GET /api/v2/sites":                    {handle: h.ListSites, tag: "users", summary: "List sites", permission: entities.PermSitesManage, code: 200, resp: openapi.ArrayOf(entities.Site{})},
+		"POST /api/v2/sites":                   {handle: h.CreateSite, tag: "users", summary: "Create site", permission: entities.PermSitesManage, body: siteRequest{}, code: 201, resp: site},
+		"GET /api/v2/sites/{id}":               {handle: h.GetSite, tag: "users", summary: "Get site", permission: entities.PermSitesManage, code: 200, resp: site},
+		"PUT /api/v2/sites/{id}":               {handle: h.UpdateSite, tag: "users", summary: "Rename site", permission: entities.PermSitesManage, body: siteRequest{}, code: 200, resp: site},
+		"PUT /api/v2/users/{id}/site":          {handle: h.SetUserSiteV2, tag: "users", summary: "Move user to site, allowed parkings of user are removed", permission: entities.PermSitesManage, body: setSiteRequest{}, code: 200, resp: user},
+		"GET /api/v2/roles":                    {handle: h.ListRoles, tag: "users", summary: "List roles with permissions", permission: entities.PermUsersRead, code: 200, resp: openapi.ArrayOf(entities.Role{})},
+		"GET /api/v2/roles/{name}":             {handle: h.GetRole, tag: "users", summary: "Get role", permission: entities.PermUsersRead, code: 200, resp: openapi.SchemaOf(entities.Role{})},
+		// roles are shared by all sites, so they are managed by users who are not limited to one site
+		"PUT /api/v2/roles/{name}":                          {handle: h.SaveRole, tag: "users", summary: "Create role or replace its permissions, admin role is read-only, only permissions held by the user are granted", permission: entities.PermSitesManage, body: saveRoleRequest{}, code: 200, resp: openapi.SchemaOf(entities.Role{})},
+		"DELETE /api/v2/roles/{name}":                       {handle: h.DeleteRole, tag: "users", summary: "Delete role which is not assigned to users", permission: entities.PermSitesManage, code: 204},
+		"GET /api/v2/certifications":                        {handle: h.ListCertifications, tag: "users", summary: "List certifications", permission: entities.PermUsersRead, query: []openapi.Parameter{queryParam("user_id", openapi.TypeInteger, false), queryParam("type_id", openapi.TypeInteger, false), siteQuery}, code: 200, resp: openapi.ArrayOf(entities.Certification{})},
+		"POST /api/v2/certifications":                       {handle: h.CreateCertification, tag: "users", summary: "Certify user to operate machine type", permission: entities.PermUsersManage, body: createCertificationRequest{}, code: 201, resp: certification},
+		"GET /api/v2/certifications/{id}":                   {handle: h.GetCertification, tag: "users", summary: "Get certification", permission: entities.PermUsersRead, code: 200, resp: certification},
+		"PUT /api/v2/certifications/{id}":                   {handle: h.UpdateCertification, tag: "users", summary: "Replace number and dates of certification", permission: entities.PermUsersManage, body: updateCertificationRequest{}, code: 200, resp: certification},
+		"DELETE /api/v2/certifications/{id}":                {handle: h.DeleteCertification, tag: "users", summary: "Delete certification", permission: entities.PermUsersManage, code: 204},
+		"GET /api/v2/users":                                 {handle: h.ListUsersV2, tag: "users", summary: "List users", permission: entities.PermUsersRead, query: userQuery, code: 200, resp: userPage},
+		"GET /api/v2/users/{id}":                            {handle: h.GetUserV2, tag: "users", summary: "Get user", permission: entities.PermUsersRead, code: 200, resp: user},
+		"GET /api/v2/users/{id}/parkings":                   {handle: h.GetUserParkings, tag: "users", summary: "Parkings where user unlocks and returns machines, empty if any parking is allowed", permission: entities.PermUsersRead, code: 200, resp: parkings},
+		"PUT /api/v2/users/{id}/parkings":                   {handle: h.ReplaceUserParkings, tag: "users", summary: "Replace parkings allowed to user", permission: entities.PermUsersManage, body: userParkingsRequest{}, code: 200, resp: parkings},
+		"GET /api/v2/users/{id}/shifts":                     {handle: h.GetUserShifts, tag: "users", summary: "Shifts when user unlocks machines, empty if user is not restricted by time", permission: entities.PermUsersRead, code: 200, resp: shifts},
+		"PUT /api/v2/users/{id}/shifts":                     {handle: h.ReplaceUserShifts, tag: "users", summary: "Replace shifts of user", permission: entities.PermUsersManage, body: userShiftsRequest{}, code: 200, resp: shifts},
+		"GET /api/v2/shifts":                                {handle: h.ListShifts, tag: "users", summary: "List shifts", permission: entities.PermUsersRead, code: 200, resp: shifts},
+		"POST /api/v2/shifts":                               {handle: h.CreateShift, tag: "users", summary: "Create shift", permission: entities.PermUsersManage, body: shiftRequest{}, code: 201, resp: shift},
+		"GET /api/v2/shifts/{id}":                           {handle: h.GetShift, tag: "users", summary: "Get shift", permission: entities.PermUsersRead, code: 200, resp: shift},
+		"PUT /api/v2/shifts/{id}":                           {handle: h.UpdateShift, tag: "users", summary: "Replace shift", permission: entities.PermUsersManage, body: shiftRequest{}, code: 200, resp: shift},
+		"DELETE /api/v2/shifts/{id}":                        {handle: h.DeleteShift, tag: "users", summary: "Delete shift and remove it from users", permission: entities.PermUsersManage, code: 204},
+		"GET /api/v2/machine-types":                         {handle: h.ListMachineTypes, tag: "machines", summary: "List machine types", permission: entities.PermMachinesRead, code: 200, resp: openapi.ArrayOf(entities.MachineType{})},
+		"POST /api/v2/machine-types":                        {handle: h.CreateMachineType, tag: "machines", summary: "Create machine type", permission: entities.PermMachinesManage, body: machineTypeRequest{}, code: 201, resp: machineType},
+		"GET /api/v2/machine-types/{id}":                    {handle: h.GetMachineType, tag: "machines", summary: "Get machine type", permission: entities.PermMachinesRead, code: 200, resp: machineType},
+		"PUT /api/v2/machine-types/{id}":                    {handle: h.UpdateMachineType, tag: "machines", summary: "Rename machine type", permission: entities.PermMachinesManage, body: machineTypeRequest{}, code: 200, resp: machineType},
+		"DELETE /api/v2/machine-types/{id}":                 {handle: h.DeleteMachineType, tag: "machines", summary: "Delete machine type without machines and certifications", permission: entities.PermMachinesManage, code: 204},
+		"GET /api/v2/machine-types/{id}/checklist":          {handle: h.GetChecklist, tag: "machines", summary: "Get pre-use checklist of machine type", permission: entities.PermMachinesRead, code: 200, resp: checklist},
+		"PUT /api/v2/machine-types/{id}/checklist":          {handle: h.ReplaceChecklist, tag: "machines", summary: "Replace pre-use checklist of machine type", permission: entities.PermMachinesManage, body: replaceChecklistRequest{}, code: 200, resp: checklist},
+		"GET /api/v2/machine-types/{id}/service-intervals":  {handle: h.ListServiceIntervals, tag: "machines", summary: "List service intervals of machine type", permission: entities.PermMachinesRead, code: 200, resp: openapi.ArrayOf(entities.ServiceInterval{})},
+		"POST /api/v2/machine-types/{id}/service-intervals": {handle: h.CreateServiceInterval, tag: "machines", summary: "Create service interval of machine type", permission: entities.PermMachinesManage, body: serviceIntervalRequest{}, code: 201, resp: interval},
+		"PUT /api/v2/service-intervals/{id}":                {handle: h.UpdateServiceInterval, tag: "machines", summary: "Replace name and hours of service interval", permission: entities.PermMachinesManage, body: serviceIntervalRequest{}, code: 200, resp: interval},
+		"DELETE /api/v2/service-intervals/{id}":             {handle: h.DeleteServiceInterval, tag: "machines", summary: "Delete service interval, its work orders are kept", permission: entities.PermMachinesManage, code: 204},
+		"GET /api/v2/machines":                              {handle: h.ListMachinesV2, tag: "machines", summary: "List machines", permission: entities.PermMachinesRead, query: machineQuery, code: 200, resp: machinePage},
+		"GET /api/v2/machines/{id}":                         {handle: h.GetMachineV2, tag: "machines", summary: "Get machine", permission: entities.PermMachinesRead, code: 200, resp: machine},
+		"PUT /api/v2/machines/{id}":                         {handle: h.RegisterMachineV2, tag: "machines", summary: "Register microcontroller", body: registerMachineV2Request{}, code: 200, resp: machine},
+		"POST /api/v2/machines/{id}/bssid":                  {handle: h.ReportMachineBssidV2, tag: "machines", summary: "Report access point of controller, sighting is stored and alert is raised if machine in use left permitted area", body: bssidReportRequest{}, device: true, code: 200, resp: openapi.SchemaOf(bssidReportResponse{})},
+		"GET /api/v2/machines/{id}/device-token":            {handle: h.GetMachineDeviceTokenV2, tag: "machines", summary: "Get token which controller sends with reports of the machine", permission: entities.PermMachinesManage, code: 200, resp: openapi.SchemaOf(deviceTokenResponse{})},
+		"PUT /api/v2/machines/{id}/parking":                 {handle: h.MoveMachineV2, tag: "machines", summary: "Move free machine to parking", permission: entities.PermMachinesManage, body: moveMachineV2Request{}, code: 200, resp: machine},
+		"PUT /api/v2/machines/{id}/site":                    {handle: h.SetMachineSiteV2, tag: "machines", summary: "Move free machine which is not at parking to site", permission: entities.PermSitesManage, body: setSiteRequest{}, code: 200, resp: machine},
+		"PUT /api/v2/machines/{id}/type":                    {handle: h.SetMachineTypeV2, tag: "machines", summary: "Set type of machine, unlock requires certification of the type", permission: entities.PermMachinesManage, body: machineTypeIdRequest{}, code: 200, resp: machine},
+		"GET /api/v2/machines/{id}/checklist":               {handle: h.GetMachineChecklistV2, tag: "machines", summary: "Checklist to answer before unlock, empty if machine needs none", permission: entities.PermSessionsUse, code: 200, resp: checklist},
+		"PUT /api/v2/machines/{id}/maintenance":             {handle: h.SetMachineMaintenanceV2, tag: "machines", summary: "Put free machine into maintenance or return it to service", permission: entities.PermMachinesMaintenance, body: maintenanceRequest{}, code: 200, resp: machine},
+		"GET /api/v2/machines/{id}/trail":                   {handle: h.GetMachineTrailV2, tag: "machines", summary: "Sightings of access points reported by controller of machine", permission: entities.PermMachinesRead, query: trailQuery, code: 200, resp: trailPage},
+		"GET /api/v2/machines/{id}/service":                 {handle: h.GetMachineServiceV2, tag: "machines", summary: "Operating hours of machine and next services, due work orders are created", permission: entities.PermMachinesRead, code: 200, resp: openapi.SchemaOf(entities.MachineService{})},
+		"POST /api/v2/machines/{id}/unlock":                 {handle: h.UnlockMachineV2, tag: "machines", summary: "Start session, checklist of the machine type should be answered", permission: entities.PermSessionsUse, body: unlockMachineV2Request{}, code: 201, resp: session, idempotent: true},
+		"POST /api/v2/machines/{id}/lock":                   {handle: h.LockMachineV2, tag: "machines", summary: "Finish session at current parking", permission: entities.PermSessionsUse, code: 200, resp: session, idempotent: true},
+		"POST /api/v2/machines/{id}/stop":                   {handle: h.StopMachineV2, tag: "machines", summary: "Pause session", permission: entities.PermSessionsUse, code: 200, resp: session, idempotent: true},
+		"POST /api/v2/machines/{id}/unstop":                 {handle: h.UnstopMachineV2, tag: "machines", summary: "Resume session", permission: entities.PermSessionsUse, code: 200, resp: session, idempotent: true},
+		"GET /api/v2/parkings":                              {handle: h.ListParkingsV2, tag: "parkings", summary: "List parkings", permission: entities.PermParkingsRead, query: parkingQuery, code: 200, resp: parkingPage},
+		"POST /api/v2/parkings":                             {handle: h.CreateParkingV2, tag: "parkings", summary: "Create parking", permission: entities.PermParkingsManage, body: createParkingRequest{}, code: 201, resp: parking},
+		"GET /api/v2/parkings/{id}":                         {handle: h.GetParkingV2, tag: "parkings", summary: "Get parking", permission: entities.PermParkingsRead, code: 200, resp: parking},
+		"PATCH /api/v2/parkings/{id}":                       {handle: h.UpdateParkingV2, tag: "parkings", summary: "Update parking state and capacity", permission: entities.PermParkingsManage, body: updateParkingV2Request{}, code: 200, resp: parking},
+		"GET /api/v2/parkings/{id}/machines":                {handle: h.GetParkingMachinesV2, tag: "parkings", summary: "List machines at parking", permission: entities.PermMachinesRead, code: 200, resp: machines},
+		"GET /api/v2/geofences":                             {handle: h.ListGeofences, tag: "parkings", summary: "List allowed and forbidden areas", permission: entities.PermParkingsRead, query: []openapi.Parameter{siteQuery}, code: 200, resp: openapi.ArrayOf(entities.Geofence{})},
+		"POST /api/v2/geofences":                            {handle: h.CreateGeofence, tag: "parkings", summary: "Create geofence", permission: entities.PermParkingsManage, body: geofenceRequest{}, code: 201, resp: geofence},
+		"GET /api/v2/geofences/{id}":                        {handle: h.GetGeofence, tag: "parkings", summary: "Get geofence", permission: entities.PermParkingsRead, code: 200, resp: geofence},
+		"PUT /api/v2/geofences/{id}":                        {handle: h.UpdateGeofence, tag: "parkings", summary: "Replace name, kind and bssids of geofence", permission: entities.PermParkingsManage, body: geofenceRequest{}, code: 200, resp: geofence},
+		"DELETE /api/v2/geofences/{id}":                     {handle: h.DeleteGeofence, tag: "parkings", summary: "Delete geofence, its alerts are kept", permission: entities.PermParkingsManage, code: 204},
+		"GET /api/v2/geofence-alerts": {handle: h.ListGeofenceAlerts, tag: "sessions", summary: "List alerts raised when machines in use left permitted area", permission: entities.PermSessionsRead,
+			query: listQuery(entities.GeofenceAlertSorts, queryParam("machine_id", openapi.TypeString, false),
+				queryParam("session_id", openapi.TypeInteger, false), queryParam("open", openapi.TypeBoolean, false), siteQuery),
+			code: 200, resp: openapi.SchemaOf(entities.Page[entities.GeofenceAlert]{})},
+		"GET /api/v2/sessions":            {handle: h.ListSessionsV2, tag: "sessions", summary: "List sessions", permission: entities.PermSessionsRead, query: sessionQuery, code: 200, resp: sessionPage},
+		"GET /api/v2/sessions/{id}":       {handle: h.GetSessionV2, tag: "sessions", summary: "Get session", permission: entities.PermSessionsRead, code: 200, resp: session},
+		"GET /api/v2/sessions/{id}/trail": {handle: h.GetSessionTrailV2, tag: "sessions", summary: "Sightings of access points reported during session", permission: entities.PermSessionsRead, query: trailQuery, code: 200, resp: trailPage},
+		"POST /api/v2/sessions/finish":    {handle: h.FinishSessionsV2, tag: "sessions", summary: "Finish sessions with qr-code", permission: entities.PermSessionsUse, body: finishSessionRequest{}, code: 200, resp: sessions, idempotent: true},
+		"GET /api/v2/checklist-submissions": {handle: h.ListChecklistSubmissions, tag: "sessions", summary: "List checklists filled before unlock", permission: entities.PermSessionsRead,
+			query: listQuery(entities.ChecklistSubmissionSorts, queryParam("machine_id", openapi.TypeString, false),
+				queryParam("user_id", openapi.TypeInteger, false), queryParam("session_id", openapi.TypeInteger, false), siteQuery),
+			code: 200, resp: openapi.SchemaOf(entities.Page[entities.ChecklistSubmission]{})},
+		"GET /api/v2/checklist-submissions/{id}": {handle: h.GetChecklistSubmission, tag: "sessions", summary: "Get checklist filled before unlock", permission: entities.PermSessionsRead, code: 200, resp: openapi.SchemaOf(entities.ChecklistSubmission{})},
+		"GET /api/v2/incidents": {handle: h.ListIncidents, tag: "incidents", summary: "List incidents", permission: entities.PermIncidentsRead,
+			query: listQuery(entities.IncidentSorts, queryParam("machine_id", openapi.TypeString, false), queryParam("reporter_id", openapi.TypeInteger, false),
+				enumParam("status", entities.IncidentStatuses), enumParam("severity", entities.IncidentSeverities), enumParam("category", entities.IncidentCategories), siteQuery),
+			code: 200, resp: openapi.SchemaOf(entities.Page[incidentResponse]{})},
+		"POST /api/v2/incidents":           {handle: h.ReportIncident, tag: "incidents", summary: "Report incident with machine, critical one puts machine into maintenance", permission: entities.PermSessionsUse, body: reportIncidentRequest{}, code: 201, resp: incident},
+		"GET /api/v2/incidents/{id}":       {handle: h.GetIncident, tag: "incidents", summary: "Get incident", permission: entities.PermIncidentsRead, code: 200, resp: incident},
+		"PATCH /api/v2/incidents/{id}":     {handle: h.TriageIncident, tag: "incidents", summary: "Triage incident: change status, severity and resolution", permission: entities.PermIncidentsManage, body: triageIncidentRequest{}, code: 200, resp: incident},
+		"PUT /api/v2/incidents/{id}/photo": {handle: h.UploadIncidentPhoto, tag: "incidents", summary: "Upload photo of incident, allowed to reporter and to incidents.manage", permission: entities.PermSessionsUse, upload: photoContentTypes(), code: 200, resp: incident},
+		"GET /api/v2/incidents/{id}/photo": {handle: h.GetIncidentPhoto, tag: "incidents", summary: "Download photo of incident", permission: entities.PermIncidentsRead, download: photoContentTypes(), code: 200},
+		"GET /api/v2/alerts": {handle: h.ListAlerts, tag: "alerts", summary: "List alerts about offline devices, stuck sessions, full parkings, failed commands and geofence violations", permission: entities.PermAlertsRead,
+			query: listQuery(entities.AlertSorts, enumParam("type", entities.AlertTypes), queryParam("key", openapi.TypeString, false),
+				enumParam("status", entities.AlertStatuses), queryParam("open", openapi.TypeBoolean, false), siteQuery),
+			code: 200, resp: openapi.SchemaOf(entities.Page[entities.Alert]{})},
+		"GET /api/v2/alerts/{id}":      {handle: h.GetAlert, tag: "alerts", summary: "Get alert", permission: entities.PermAlertsRead, code: 200, resp: alert},
+		"POST /api/v2/alerts/{id}/ack": {handle: h.AcknowledgeAlert, tag: "alerts", summary: "Acknowledge alert, it is not sent again until it is resolved", permission: entities.PermAlertsManage, code: 200, resp: alert},
+		"GET /api/v2/work-orders": {handle: h.ListWorkOrders, tag: "machines", summary: "List maintenance work orders", permission: entities.PermMachinesRead,
+			query: listQuery(entities.WorkOrderSorts, queryParam("machine_id", openapi.TypeString, false),
+				enumParam("status", []string{entities.WorkOrderOpen, entities.WorkOrderClosed}), siteQuery),
+			code: 200, resp: openapi.SchemaOf(entities.Page[entities.WorkOrder]{})},
+		"GET /api/v2/work-orders/{id}":        {handle: h.GetWorkOrder, tag: "machines", summary: "Get work order", permission: entities.PermMachinesRead, code: 200, resp: workOrder},
+		"POST /api/v2/work-orders/{id}/close": {handle: h.CloseWorkOrder, tag: "machines", summary: "Close work order at current operating hours of machine", permission: entities.PermMachinesMaintenance, body: closeWorkOrderRequest{}, code: 200, resp: workOrder},
+		"GET /api/v2/qr-key":                  {handle: h.GetQrKey, tag: "sessions", summary: "Get current qr key", permission: entities.PermSessionsUse, code: 200, resp: openapi.SchemaOf(qrKeyResponse{})},
+
+		"GET /api/v2/reports/machines": {handle: h.MachinesReport, tag: "reports", summary: "Utilisation and idle time of machines", permission: entities.PermReportsRead,
+			query: reportQuery(), code: 200, resp: openapi.SchemaOf(reportResponse[entities.MachineUsage]{})},
+		"GET /api/v2/reports/workers": {handle: h.WorkersReport, tag: "reports", summary: "Hours worked with machines by workers", permission: entities.PermReportsRead,
+			query: reportQuery(), code: 200, resp: openapi.SchemaOf(reportResponse[entities.WorkerHours]{})},
+		"GET /api/v2/reports/parkings": {handle: h.ParkingsReport, tag: "reports", summary: "Turnover of machines at parkings", permission: entities.PermReportsRead,
+			query: reportQuery(), code: 200, resp: openapi.SchemaOf(reportResponse[entities.ParkingTurnover]{})},
+		"GET /api/v2/reports/peak-hours": {handle: h.PeakHoursReport, tag: "reports", summary: "Heatmap of machines usage by weekday and hour", permission: entities.PermReportsRead,
+			query: reportQuery(openapi.Parameter{Name: "tz", In: "query", Description: "IANA time zone, `reports.timezone` from config by default",
+				Schema: &openapi.Schema{Type: openapi.TypeString}}),
+			code: 200, resp: openapi.SchemaOf(reportResponse[entities.PeakHour]{})},
+		"GET /api/v2/reports/shifts": {handle: h.ShiftsReport, tag: "reports", summary: "Sessions and hours by shift in which sessions started", permission: entities.PermReportsRead,
+			query: reportQuery(), code: 200, resp: openapi.SchemaOf(reportResponse[entities.ShiftUsage]{})},
+		"GET /api/v2/reports/expiring-certifications": {handle: h.ExpiringCertificationsReport, tag: "reports", summary: "Certifications which expire within range", permission: entities.PermReportsRead,
+			query: []openapi.Parameter{
+				{Name: "from", In: "query", Description: "range start, YYYY-MM-DD or RFC 3339, now by default",
+					Schema: &openapi.Schema{Type: openapi.TypeString}},
+				{Name: "to", In: "query", Description: "range end (exclusive), YYYY-MM-DD or RFC 3339, 30 days after `from` by default",
+					Schema: &openapi.Schema{Type: openapi.TypeString}},
+				{Name: "format", In: "query", Description: "csv and xlsx are sent as attachment",
+					Schema: &openapi.Schema{Type: openapi.TypeString, Enum: []any{formatJSON, formatCSV, formatXLSX}}},
+				siteQuery,
+			},
+			code: 200, resp: openapi.SchemaOf(reportResponse[entities.ExpiringCertification]{})},
+
+		"GET /api/v2/exports/sessions": {handle: h.ExportSessions, tag: "reports", summary: "Download finished sessions started in range", permission: entities.PermReportsRead,
+			query: []openapi.Parameter{
+				{Name: "from", In: "query", Description: "range start, YYYY-MM-DD or RFC 3339, 30 days before `to` by default",
+					Schema: &openapi.Schema{Type: openapi.TypeString}},
+				{Name: "to", In: "query", Description: "range end (exclusive), YYYY-MM-DD or RFC 3339, now by default",
+					Schema: &openapi.Schema{Type: openapi.TypeString}},
+				{Name: "format", In: "query", Description: "csv (RFC 4180 with header) or json lines",
+					Schema: &openapi.Schema{Type: openapi.TypeString, Enum: []any{string(export.FormatCSV), string(export.FormatJSONL)}}},
+				siteQuery,
+			},
+			code: 200, resp: &openapi.Schema{Type: openapi.TypeString, Format: "binary"}},
+	}
+}
+
+func newSpec(routes map[string]op) *openapi.Document {
+	doc := openapi.New("Sharing Warehouse Machines API", "2.0.0")
+	for pattern, o := range routes {
+		doc.Add(pattern, o.operation())
+	}
+	return doc
+}
+
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, r, http.StatusOK, h.spec)
+}
@@ -1,177 +1,119 @@
 package handler
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
 	"github.com/ecol-master/sharing-wh-machines/internal/utils"
 )
 
 func (h *Handler) StopMachine(w http.ResponseWriter, r *http.Request) {
 	op := slog.String("op", "handler.StopMachine")
 
-	var respData struct {
-		MachineId string `json:"machine_id"`
-	}
+	var respData machineIdRequest
 
 	if err := utils.ParseRequestData(r.Body, &respData); err != nil {
-		slog.Error("failed parse request data", op, slog.String("error", err.Error()))
-
-		if err := utils.RespondWith500(w); err != nil {
-			slog.Error("respond with 500 during failed parse request body",
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+		slog.ErrorContext(r.Context(), "failed parse request data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
 		return
 	}
 
-	machine, err := h.service.GetMachineByID(respData.MachineId)
+	userId, err := userIdFromContext(r)
 	if err != nil {
-		slog.Error("machine with such id doesn't exists", slog.String("machine_id", respData.MachineId))
-		if err = utils.RespondWith400(w, "machine with such id doesn't exists"); err != nil {
-			if err = utils.RespondWith500(w); err != nil {
-				slog.Error("failed to respond with 500 during machine with such id doesn't exists",
-					slog.String("machine_id", respData.MachineId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-		}
+		slog.ErrorContext(r.Context(), "get user_id from context", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
 		return
 	}
 
-	if machine.State != entities.MachineInUse {
-		if err = utils.RespondWith400(w, "machine is not in use at the moment"); err != nil {
-			if err = utils.RespondWith500(w); err != nil {
-				slog.Error("failed to respond with 500 during machine is not in use",
-					slog.String("machine_id", respData.MachineId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-		}
+	session, err := h.stopMachine(r.Context(), userId, respData.MachineId)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	userId, ok := r.Context().Value("user_id").(int64)
-	if !ok {
-		slog.Error("failed to get user_id from r.Context", op, slog.Bool("ok", ok))
-
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond with 500 on failed get user_id from request context",
-				slog.String("machine_id", respData.MachineId),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	payload := sessionIdResponse{SessionId: session.Id}
+
+	if err = utils.SuccessRespondWith200(w, payload); err != nil {
+		slog.ErrorContext(r.Context(), "failed to respond with json (session_id)", op,
+			slog.Any("payload", payload),
+			slog.String("error", err.Error()),
+		)
 	}
+}
 
-	user, err := h.service.GetUserByID(int(userId))
+// stopMachine pauses active session of the user with the machine
+func (h *Handler) stopMachine(ctx context.Context, userId int64, machineId string) (*entities.Session, error) {
+	op := slog.String("op", "handler.stopMachine")
+
+	release, err := h.acquireMachine(ctx, machineId)
 	if err != nil {
-		slog.Error("failed get user by id", slog.Int("user_id", int(userId)), slog.String("error", err.Error()))
-
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond with 500 on failed get user by id",
-				slog.String("machine_id", respData.MachineId),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+		return nil, err
 	}
+	defer release()
 
-	session, err := canStopMachine(h.service, user, machine)
+	machine, err := h.getMachine(ctx, machineId)
 	if err != nil {
-		slog.Error("try stop machine", op, slog.Int("user_id", int(userId)),
-			slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		slog.ErrorContext(ctx, "get machine by id", op, slog.String("machine_id", machineId),
+			slog.String("error", err.Error()))
+		return nil, err
+	}
 
-		if err = utils.RespondWith400(w, "user can not stop machine"); err != nil {
-			slog.Error("failed to respond 400 on user can not stop machine",
-				slog.Int("user_id", user.Id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	if machine.State != entities.MachineInUse {
+		return nil, errs.ErrMachineNotInUse
+	}
+
+	user, err := h.service.GetUserByID(ctx, int(userId))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed get user by id", op, slog.Int("user_id", int(userId)), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	session, err := canStopMachine(ctx, h.service, user, machine)
+	if err != nil {
+		slog.ErrorContext(ctx, "try stop machine", op, slog.Int("user_id", int(userId)),
+			slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		return nil, err
 	}
 
+	return h.pauseSession(ctx, machine, session)
+}
+
+// pauseSession stops machine in use and pauses its session, the machine should be acquired by caller
+func (h *Handler) pauseSession(ctx context.Context, machine *entities.Machine, session *entities.Session) (*entities.Session, error) {
+	op := slog.String("op", "handler.pauseSession")
+
 	machine.State = entities.MachineStop
-	if err = sendMachineCurrentState(machine, h.cfg.MC.RequestTimeout); err != nil {
-		slog.Error("failed sendMachineCurrentState", op, slog.String("error", err.Error()))
-
-		if err = utils.RespondWith400(w, "machine can not be used at the current moment"); err != nil {
-			slog.Error("failed to respond with 400 on machine is not active",
-				slog.Any("machine", machine),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	if err := h.sendMachineState(ctx, machine); err != nil {
+		slog.ErrorContext(ctx, "failed sendMachineCurrentState", op, slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	_, err = h.service.UpdateMachineState(machine.Id, machine.State)
+	// machine has got new state, so the rest is saved even if the request is cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	_, err := h.service.UpdateMachineState(ctx, machine.Id, machine.State)
 	if err != nil {
 		// TODO: подумать, что должно произойти, если не удалось обновить машину
-		slog.Error("failed to update machine state", op,
+		slog.ErrorContext(ctx, "failed to update machine state", op,
 			slog.Any("machine", machine),
 			slog.Int("new_state", machine.State),
 			slog.String("error", err.Error()),
 		)
-
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed update machine state", op,
-				slog.String("machine_id", machine.Id),
-				slog.Int("new_state", machine.State),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+		return nil, err
 	}
 
-	_, err = h.service.UpdateSessionState(session.Id, entities.SessionPause)
+	paused, err := h.service.UpdateSessionState(ctx, session.Id, entities.SessionPause)
 	if err != nil {
-		slog.Error("failed to insert new session",
-			slog.Int("user_id", int(userId)),
+		slog.ErrorContext(ctx, "failed to update session state", op,
+			slog.Int("user_id", session.WorkerId),
 			slog.String("machine_id", machine.Id),
 			slog.String("error", err.Error()),
 		)
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed respond with json (session_id)",
-				slog.Int("user_id", user.Id),
-				slog.String("machine_id", machine.Id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
-	}
-
-	payload := struct {
-		SessionId int `json:"sessionId"`
-	}{SessionId: session.Id}
-
-	if err = utils.SuccessRespondWith200(w, payload); err != nil {
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed respond with json (session_id)",
-				slog.Any("payload", payload),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+		return nil, err
 	}
 
+	return paused, nil
 }
@@ -1,228 +1,158 @@
 package handler
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
 	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
 )
 
 func (h *Handler) UnlockMachine(w http.ResponseWriter, r *http.Request) {
 	op := slog.String("op", "handler.UnlockMachine")
 
-	var respData struct {
-		MachineId string `json:"machine_id"`
-	}
+	var respData unlockMachineRequest
 
 	err := utils.ParseRequestData(r.Body, &respData)
 	if err != nil {
-		slog.Error("failed parse request data", op, slog.String("error", err.Error()))
-
-		if err := utils.RespondWith500(w); err != nil {
-			slog.Error("respond with 500 during failed parse request body",
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+		slog.ErrorContext(r.Context(), "failed parse request data", op, slog.String("error", err.Error()))
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
 		return
 	}
 
-	machine, err := h.service.GetMachineByID(respData.MachineId)
+	userId, err := userIdFromContext(r)
 	if err != nil {
-		slog.Error("machine with such id doesn't exists", slog.String("machine_id", respData.MachineId))
-		if err = utils.RespondWith400(w, "machine with such id doesn't exists"); err != nil {
-			if err = utils.RespondWith500(w); err != nil {
-				slog.Error("failed to respond with 500 during machine with such id doesn't exists",
-					slog.String("machine_id", respData.MachineId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-		}
+		slog.ErrorContext(r.Context(), "get user_id from context", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
 		return
 	}
-	if machine.State != entities.MachineFree {
-		if err = utils.RespondWith400(w, "machine is not free at the moment"); err != nil {
-			if err = utils.RespondWith500(w); err != nil {
-				slog.Error("failed to respond with 500 during machine is not free",
-					slog.String("machine_id", respData.MachineId),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-		}
+
+	session, err := h.unlockMachine(r.Context(), userId, respData.MachineId, respData.Checklist)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	userId, ok := r.Context().Value("user_id").(int64)
-	if !ok {
-		slog.Error("failed to get user_id from r.Context", op, slog.Bool("ok", ok))
+	payload := sessionIdResponse{SessionId: session.Id}
 
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond with 500 on failed get user_id from request context",
-				slog.String("machine_id", respData.MachineId),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
+	if err = utils.SuccessRespondWith200(w, payload); err != nil {
+		slog.ErrorContext(r.Context(), "failed to respond with json (session_id)", op,
+			slog.Any("payload", payload),
+			slog.String("error", err.Error()),
+		)
+	}
+}
 
-		return
+// unlockMachine starts new session of the user with the machine,
+// answers are required if type of the machine has checklist
+func (h *Handler) unlockMachine(ctx context.Context, userId int64, machineId string, answers []checklistAnswerRequest) (*entities.Session, error) {
+	op := slog.String("op", "handler.unlockMachine")
+
+	release, err := h.acquireMachine(ctx, machineId)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	user, err := h.service.GetUserByID(int(userId))
+	machine, err := h.getMachine(ctx, machineId)
 	if err != nil {
-		slog.Error("failed get user by id", slog.Int("user_id", int(userId)), slog.String("error", err.Error()))
+		slog.ErrorContext(ctx, "get machine by id", op, slog.String("machine_id", machineId),
+			slog.String("error", err.Error()))
+		return nil, err
+	}
+	if machine.State != entities.MachineFree {
+		return nil, errs.ErrMachineNotFree
+	}
 
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond with 500 on failed get user by id",
-				slog.String("machine_id", respData.MachineId),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	user, err := h.service.GetUserByID(ctx, int(userId))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed get user by id", op, slog.Int("user_id", int(userId)), slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	if err = canUnlockMachine(h.service, user, machine); err != nil {
-		slog.Error("try unlock machine", op, slog.Int("user_id", int(userId)),
+	if err = canUnlockMachine(ctx, h.service, user, machine); err != nil {
+		slog.ErrorContext(ctx, "try unlock machine", op, slog.Int("user_id", int(userId)),
 			slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		return nil, err
+	}
 
-		if err = utils.RespondWith400(w, "user can not unlock machine"); err != nil {
-			slog.Error("failed to respond 400 on failed get active sessions by user_id",
-				slog.Int("user_id", user.Id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	if err = h.checkUnlockZone(ctx, user, machine); err != nil {
+		slog.ErrorContext(ctx, "check parking of machine", op, slog.Int("user_id", user.Id),
+			slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	machine.State = entities.MachineInUse
-	if err = sendMachineCurrentState(machine, h.cfg.MC.RequestTimeout); err != nil {
-		slog.Error("failed sendMachineCurrentState", op, slog.String("error", err.Error()))
+	submission, err := h.checkChecklist(ctx, user, machine, answers)
+	if err != nil {
+		return nil, err
+	}
 
-		if err = utils.RespondWith400(w, "machine can not be used at the current moment"); err != nil {
-			slog.Error("failed to respond with 400 on machine is not active",
-				slog.Any("machine", machine),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+	machine.State = entities.MachineInUse
+	if err = h.sendMachineState(ctx, machine); err != nil {
+		slog.ErrorContext(ctx, "failed sendMachineCurrentState", op, slog.String("error", err.Error()))
+		return nil, err
 	}
 
-	_, err = h.service.UpdateMachineState(machine.Id, machine.State)
+	// machine has got new state, so the rest is saved even if the request is cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	_, err = h.service.UpdateMachineState(ctx, machine.Id, machine.State)
 	if err != nil {
 		// TODO: подумать, что должно произойти, если не удалось обновить машину
-		slog.Error("failed to update machine state UnlockMachine",
+		slog.ErrorContext(ctx, "failed to update machine state UnlockMachine",
 			slog.Any("machine", machine),
 			slog.Int("new_state", machine.State),
 			slog.String("error", err.Error()),
 		)
-
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed update machine state UnlockMachine",
-				slog.String("machine_id", machine.Id),
-				slog.Int("new_state", machine.State),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-		return
+		return nil, err
 	}
 
-	session, err := h.service.InsertSession(user.Id, machine.Id)
+	session, err := h.service.InsertSession(ctx, user.Id, machine.Id, machine.ParkingId)
 	if err != nil {
-		slog.Error("failed to insert new session",
+		slog.ErrorContext(ctx, "failed to insert new session",
 			slog.Int("user_id", int(userId)),
 			slog.String("machine_id", machine.Id),
 			slog.String("error", err.Error()),
 		)
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed respond with json (session_id)",
-				slog.Int("user_id", user.Id),
-				slog.String("machine_id", machine.Id),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
+		return nil, err
+	}
+
+	if submission != nil {
+		submission.SessionId = session.Id
+		if _, err = h.service.InsertChecklistSubmission(ctx, *submission); err != nil {
+			slog.ErrorContext(ctx, "insert checklist submission", op, slog.Int("session_id", session.Id),
+				slog.String("error", err.Error()))
+			return nil, err
 		}
-		return
 	}
 
 	if machine.ParkingId != 0 {
-		parking, err := h.service.GetParkingById(machine.ParkingId)
+		parking, err := h.service.GetParkingById(ctx, machine.ParkingId)
 		if err != nil {
-			slog.Error("parking with such id doesn't exists", slog.Int("parking_id", machine.ParkingId))
-			if err = utils.RespondWith400(w, "parking with such id doesn't exists"); err != nil {
-				if err = utils.RespondWith500(w); err != nil {
-					slog.Error("failed to respond with 500 during parking with such id doesn't exists",
-						slog.Int("parking_id", machine.ParkingId),
-						slog.String("path", r.URL.Path),
-						slog.String("method", r.Method),
-						slog.String("error", err.Error()),
-					)
-				}
-			}
-			return
+			slog.ErrorContext(ctx, "get parking by id", op, slog.Int("parking_id", machine.ParkingId),
+				slog.String("error", err.Error()))
+			return nil, errors.Wrap(err, "get machine's parking")
 		}
 
-		_, err = h.service.UpdateParkingMachines(parking.Machines-1, parking.Id)
+		_, err = h.service.UpdateParkingMachines(ctx, parking.Machines-1, parking.Id)
 		if err != nil {
-			slog.Error("parking with such id doesn't exists", slog.Int("parking_id", parking.Id))
-			if err = utils.RespondWith400(w, "parking with such id doesn't exists"); err != nil {
-				if err = utils.RespondWith500(w); err != nil {
-					slog.Error("failed to respond with 500 during parking with such id doesn't exists",
-						slog.Int("parking_id", machine.ParkingId),
-						slog.String("path", r.URL.Path),
-						slog.String("method", r.Method),
-						slog.String("error", err.Error()),
-					)
-				}
-			}
-			return
+			slog.ErrorContext(ctx, "update parking machines", op, slog.Int("parking_id", parking.Id),
+				slog.String("error", err.Error()))
+			return nil, err
 		}
 
-		_, err = h.service.UpdateMachineParkingId(machine.Id, 0)
+		_, err = h.service.UpdateMachineParkingId(ctx, machine.Id, 0)
 		if err != nil {
-			slog.Error("failed to update machine parkingId UnlockMachine",
+			slog.ErrorContext(ctx, "failed to update machine parkingId UnlockMachine",
 				slog.Any("machine", machine),
 				slog.String("error", err.Error()),
 			)
-
-			if err = utils.RespondWith500(w); err != nil {
-				slog.Error("failed to respond 500 on failed update machine parkingId UnlockMachine",
-					slog.String("machine_id", machine.Id),
-					slog.String("path", r.URL.Path),
-					slog.String("method", r.Method),
-					slog.String("error", err.Error()),
-				)
-			}
-			return
+			return nil, err
 		}
 	}
 
-	payload := struct {
-		SessionId int `json:"sessionId"`
-	}{SessionId: session.Id}
-
-	if err = utils.SuccessRespondWith200(w, payload); err != nil {
-		if err = utils.RespondWith500(w); err != nil {
-			slog.Error("failed to respond 500 on failed respond with json (session_id)",
-				slog.Any("payload", payload),
-				slog.String("path", r.URL.Path),
-				slog.String("method", r.Method),
-				slog.String("error", err.Error()),
-			)
-		}
-	}
+	return session, nil
 }
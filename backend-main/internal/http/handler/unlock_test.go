@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentUnlock(t *testing.T) {
+	const n = 20
+
+	app := newTestApp(t, nil)
+	d := newDevice(t)
+	d.hold = make(chan struct{})
+	release := sync.OnceFunc(func() { close(d.hold) })
+	defer release()
+	app.machine("M1", d)
+	token := app.token("200")
+
+	responses := make(chan *httptest.ResponseRecorder, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			responses <- app.do("POST", "/api/v2/machines/M1/unlock", token, nil)
+		}()
+	}
+
+	// device holds the unlock which has acquired the machine until all others are answered
+	for i := 0; i < n-1; i++ {
+		w := <-responses
+		if code := errorCode(t, w); w.Code != http.StatusConflict || code != "machine_busy" {
+			t.Errorf("status %d, code %q, want 409 machine_busy", w.Code, code)
+		}
+	}
+	release()
+
+	if w := <-responses; w.Code != http.StatusCreated {
+		t.Errorf("status %d, want 201: %s", w.Code, w.Body.String())
+	}
+
+	sessions, err := app.svc.GetActiveSessionsByMachineID(context.Background(), "M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("%d active sessions, want 1", len(sessions))
+	}
+	if got := d.requests.Load(); got != 1 {
+		t.Errorf("device got %d requests, want 1", got)
+	}
+}
+
+func TestUnlockIsNotBlockedByLocationReport(t *testing.T) {
+	app := newTestApp(t, nil)
+	app.machine("M1", newDevice(t))
+
+	release, err := app.svc.AcquireMachineReport(context.Background(), "M1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if w := app.do("POST", "/api/v2/machines/M1/unlock", app.token("200"), nil); w.Code != http.StatusCreated {
+		t.Errorf("status %d, want 201: %s", w.Code, w.Body.String())
+	}
+}
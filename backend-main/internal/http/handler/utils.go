@@ -6,20 +6,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/middlewares"
+	"github.com/ecol-master/sharing-wh-machines/internal/metrics"
 	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
 	"github.com/pkg/errors"
 )
 
-func getMachineCurrentMacAddr(machine *entities.Machine, timeout time.Duration) (macAddr string, err error) {
+// clients of microcontrollers, duration and failures of their requests are exposed in metrics
+var (
+	macAddrClient = &http.Client{Transport: metrics.DeviceTransport("get_mac_addr", http.DefaultTransport)}
+	stateClient   = &http.Client{Transport: metrics.DeviceTransport("send_state", http.DefaultTransport)}
+)
+
+func getMachineCurrentMacAddr(ctx context.Context, machine *entities.Machine, timeout time.Duration) (macAddr string, err error) {
 	address := fmt.Sprintf("http://%s/%s/get_mac_addr", machine.IPAddr, machine.Id)
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
@@ -27,14 +38,19 @@ func getMachineCurrentMacAddr(machine *entities.Machine, timeout time.Duration)
 		return "", errors.Wrap(err, "create new request")
 	}
 
-	resp, err := (&http.Client{}).Do(req)
+	resp, err := macAddrClient.Do(req)
 	if err != nil {
-		return "", errors.Wrap(err, "do request")
+		return "", errs.ErrMachineUnreachable.Wrap(errors.Wrap(err, "do request"))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errs.ErrMachineUnreachable.Wrap(errors.Errorf("get mac addr: status %d", resp.StatusCode))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return "", errs.ErrMachineUnreachable.Wrap(errors.Wrap(err, "read body"))
 	}
 
 	data := struct {
@@ -42,23 +58,19 @@ func getMachineCurrentMacAddr(machine *entities.Machine, timeout time.Duration)
 	}{}
 
 	if err := json.Unmarshal(body, &data); err != nil {
-		return "", err
+		return "", errs.ErrMachineUnreachable.Wrap(errors.Wrap(err, "unmarshal body"))
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", errors.New("failed send to arduino current status")
-	}
-
-	return data.MacAddr, nil
+	return entities.NormalizeBssid(data.MacAddr), nil
 }
 
-func sendMachineCurrentState(machine *entities.Machine, timeout time.Duration) error {
+func sendMachineCurrentState(ctx context.Context, machine *entities.Machine, timeout time.Duration) error {
 	payload := []byte(fmt.Sprintf(`{"current_state": %d}`, machine.State))
 	reader := bytes.NewReader(payload)
 
 	address := fmt.Sprintf("http://%s/%s", machine.IPAddr, machine.Id)
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, reader)
@@ -66,147 +78,212 @@ func sendMachineCurrentState(machine *entities.Machine, timeout time.Duration) e
 		return errors.Wrap(err, "create new request")
 	}
 
-	resp, err := (&http.Client{}).Do(req)
+	resp, err := stateClient.Do(req)
 	if err != nil {
-		return errors.Wrap(err, "do request")
+		return errs.ErrMachineUnreachable.Wrap(errors.Wrap(err, "do request"))
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("failed send to arduino current status")
+		return errs.ErrMachineUnreachable.Wrap(errors.Errorf("failed send to arduino current status: status %d", resp.StatusCode))
 	}
 
 	return nil
 }
 
-func canUnlockMachine(svc *service.Service, user *entities.User, _ *entities.Machine) error {
-	switch user.JobPosition {
-	case entities.Worker:
-		unfinishedSessions, err := svc.GetUnfinishedSessionsByUserId(user.Id)
-		if err != nil {
-			return errors.Wrap(err, "get active sessions by userId")
-		}
+// forcesSessions reports if role of the request allows commands with sessions of other users
+func forcesSessions(ctx context.Context) bool {
+	role, ok := middlewares.RoleFromContext(ctx)
+	return ok && role.Has(entities.PermSessionsForce)
+}
 
-		if len(unfinishedSessions) != 0 {
-			msg := fmt.Sprintf("user have active sessions, cnt=%d", len(unfinishedSessions))
-			return errors.Wrap(err, msg)
-		}
-		return nil
+// canUnlockMachine checks that user has no unfinished sessions, users who force sessions can use several machines
+func canUnlockMachine(ctx context.Context, svc *service.Service, user *entities.User, machine *entities.Machine) error {
+	if machine.Maintenance {
+		return errs.ErrMachineInMaintenance
+	}
 
-	case entities.Admin:
+	// certification is required from everyone who operates the machine
+	if err := checkCertification(ctx, svc, user, machine); err != nil {
+		return err
+	}
+
+	// users with shifts work only within them, users without shifts are not restricted
+	if err := checkShift(ctx, svc, user, time.Now()); err != nil {
+		return err
+	}
+
+	// work orders which are due are created here too, so overdue machine is never unlocked
+	if err := checkService(ctx, svc, machine); err != nil {
+		return err
+	}
+
+	if forcesSessions(ctx) {
 		return nil
+	}
+
+	unfinishedSessions, err := svc.GetUnfinishedSessionsByUserId(ctx, user.Id)
+	if err != nil {
+		return errors.Wrap(err, "get active sessions by userId")
+	}
 
-	default:
-		return errors.New("user has uknown job position")
+	if len(unfinishedSessions) != 0 {
+		msg := fmt.Sprintf("user have active sessions, cnt=%d", len(unfinishedSessions))
+		return errors.Wrap(errs.ErrUnfinishedSession, msg)
 	}
+	return nil
 }
 
-func canUnstopMachine(svc *service.Service, user *entities.User, machine *entities.Machine) (*entities.Session, error) {
-	if user.JobPosition == entities.Worker {
-		pausedSessions, err := svc.GetPausedSessionsByMachineAndUser(machine.Id, user.Id)
+func canUnstopMachine(ctx context.Context, svc *service.Service, user *entities.User, machine *entities.Machine) (*entities.Session, error) {
+	if !forcesSessions(ctx) {
+		pausedSessions, err := svc.GetPausedSessionsByMachineAndUser(ctx, machine.Id, user.Id)
 		if err != nil {
 			return nil, errors.Wrap(err, "get paused sessions by machine and user")
 		}
 
 		if len(pausedSessions) == 0 {
-			return nil, errors.New("there is no paused session")
+			return nil, errs.ErrNoPausedSession
 		}
 
 		if len(pausedSessions) > 1 {
-			return nil, errors.New("there are several paused sessions with machine and user")
+			return nil, errors.Wrap(errs.ErrSeveralSessions, "there are several paused sessions with machine and user")
 		}
 		return &pausedSessions[0], nil
 	}
 
-	if user.JobPosition == entities.Admin {
-		pausedSessions, err := svc.GetPausedSessionsByMachineID(machine.Id)
-		if err != nil {
-			return nil, errors.Wrap(err, "get paused sessions by machine.Id")
-		}
-
-		if len(pausedSessions) == 0 {
-			return nil, errors.New("there is no paused sessions with machine.Id")
-		}
+	pausedSessions, err := svc.GetPausedSessionsByMachineID(ctx, machine.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "get paused sessions by machine.Id")
+	}
 
-		if len(pausedSessions) > 1 {
-			return nil, errors.New("there are several paused sessions with machine.Id")
-		}
-		return &pausedSessions[0], nil
+	if len(pausedSessions) == 0 {
+		return nil, errs.ErrNoPausedSession
 	}
 
-	return nil, errors.New("user has unknown job position")
+	if len(pausedSessions) > 1 {
+		return nil, errors.Wrap(errs.ErrSeveralSessions, "there are several paused sessions with machine.Id")
+	}
+	return &pausedSessions[0], nil
 }
 
-func canLockMachine(svc *service.Service, user *entities.User, machine *entities.Machine) (*entities.Session, error) {
-	if user.JobPosition == entities.Worker {
-		sessions, err := svc.GetActiveSessionsByMachineAndUser(machine.Id, user.Id)
+func canLockMachine(ctx context.Context, svc *service.Service, user *entities.User, machine *entities.Machine) (*entities.Session, error) {
+	if !forcesSessions(ctx) {
+		sessions, err := svc.GetActiveSessionsByMachineAndUser(ctx, machine.Id, user.Id)
 		if err != nil {
 			return nil, errors.Wrap(err, "get sessions by machine.Id and user.Id")
 		}
 
 		if len(sessions) == 0 {
-			return nil, errors.New("user has no active sessions with that machine")
+			return nil, errs.ErrNoActiveSession.WithMessage("user has no active sessions with that machine")
 		}
 
 		if len(sessions) > 1 {
-			return nil, errors.New("user has several active sessions with machine")
+			return nil, errors.Wrap(errs.ErrSeveralSessions, "user has several active sessions with machine")
 		}
 
 		return &sessions[0], nil
 	}
 
-	if user.JobPosition == entities.Admin {
-		activeSessions, err := svc.GetActiveSessionsByMachineID(machine.Id)
-		if err != nil {
-			return nil, errors.Wrap(err, "get active sessions by machine.Id")
-		}
-		if len(activeSessions) == 0 {
-			return nil, errors.New("there is no active sessions with machine")
-		}
-		if len(activeSessions) > 1 {
-			return nil, errors.New("there several active sessions with machine")
-		}
-
-		return &activeSessions[0], nil
+	activeSessions, err := svc.GetActiveSessionsByMachineID(ctx, machine.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "get active sessions by machine.Id")
+	}
+	if len(activeSessions) == 0 {
+		return nil, errs.ErrNoActiveSession
+	}
+	if len(activeSessions) > 1 {
+		return nil, errors.Wrap(errs.ErrSeveralSessions, "there several active sessions with machine")
 	}
 
-	return nil, errors.New("user has uknown job position")
-
+	return &activeSessions[0], nil
 }
 
-func canStopMachine(svc *service.Service, user *entities.User, machine *entities.Machine) (*entities.Session, error) {
-	if user.JobPosition == entities.Worker {
-		sessions, err := svc.GetActiveSessionsByMachineAndUser(machine.Id, user.Id)
+func canStopMachine(ctx context.Context, svc *service.Service, user *entities.User, machine *entities.Machine) (*entities.Session, error) {
+	if !forcesSessions(ctx) {
+		sessions, err := svc.GetActiveSessionsByMachineAndUser(ctx, machine.Id, user.Id)
 		if err != nil {
 			return nil, errors.Wrap(err, "get sessions by machine and user")
 		}
 
 		if len(sessions) == 0 {
-			return nil, errors.Wrap(err, "there is no active sessions")
+			return nil, errs.ErrNoActiveSession
 		}
 
 		if len(sessions) > 1 {
-			return nil, errors.Wrap(err, "there are several sessions with machine and user")
+			return nil, errors.Wrap(errs.ErrSeveralSessions, "there are several sessions with machine and user")
 		}
 		return &sessions[0], nil
 	}
 
-	if user.JobPosition == entities.Admin {
-		sessions, err := svc.GetActiveSessionsByMachineID(machine.Id)
-		if err != nil {
-			return nil, errors.Wrap(err, "get sessions by machine")
-		}
+	sessions, err := svc.GetActiveSessionsByMachineID(ctx, machine.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "get sessions by machine")
+	}
 
-		if len(sessions) == 0 {
-			return nil, errors.Wrap(err, "there is no sessions with machine")
-		}
+	if len(sessions) == 0 {
+		return nil, errs.ErrNoActiveSession
+	}
 
-		if len(sessions) > 1 {
-			return nil, errors.Wrap(err, "machine has several active sessions")
-		}
-		return &sessions[0], nil
+	if len(sessions) > 1 {
+		return nil, errors.Wrap(errs.ErrSeveralSessions, "machine has several active sessions")
 	}
+	return &sessions[0], nil
+}
+
+// acquireMachine serialises commands with the machine, so concurrent requests can not both pass
+// checks of its state. Returned function releases the machine and should be deferred.
+func (h *Handler) acquireMachine(ctx context.Context, machineId string) (func(), error) {
+	release, err := h.service.AcquireMachine(ctx, machineId)
+	if err != nil {
+		slog.WarnContext(ctx, "acquire machine", slog.String("op", "handler.acquireMachine"),
+			slog.String("machine_id", machineId), slog.String("error", err.Error()))
+		return nil, err
+	}
+	return release, nil
+}
+
+// canParkMachine checks that parking is active and can take one more machine
+func canParkMachine(parking *entities.Parking) error {
+	if int(parking.Capacity) <= parking.Machines && parking.Capacity != entities.UnlimitedCapacity {
+		return errs.ErrParkingFull
+	}
+
+	if parking.State == entities.ParkingInactive {
+		return errs.ErrParkingInactive
+	}
+	return nil
+}
+
+// userIdFromContext returns id of the user set by middlewares.RequirePermission
+func userIdFromContext(r *http.Request) (int64, error) {
+	userId, ok := r.Context().Value("user_id").(int64)
+	if !ok {
+		return 0, errors.New("failed to get user_id from r.Context")
+	}
+	return userId, nil
+}
 
-	return nil, errors.New("unknown user's job")
+// respondJSON writes payload with the code and logs failed writes
+func respondJSON(w http.ResponseWriter, r *http.Request, code int, payload interface{}) {
+	if err := utils.RespondWithJSON(w, code, payload); err != nil {
+		slog.ErrorContext(r.Context(), "failed to respond with json",
+			slog.String("path", r.URL.Path),
+			slog.String("method", r.Method),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// respondError writes err with status code of its kind, see utils.RespondWithAppError
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	if respondErr := utils.RespondWithAppError(w, err); respondErr != nil {
+		slog.ErrorContext(r.Context(), "failed to respond with error",
+			slog.String("path", r.URL.Path),
+			slog.String("method", r.Method),
+			slog.String("respond_error", respondErr.Error()),
+			slog.String("error", err.Error()),
+		)
+	}
 }
 
 func newQrKey() string {
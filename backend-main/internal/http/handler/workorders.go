@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/ecol-master/sharing-wh-machines/internal/workorders"
+)
+
+func (h *Handler) ListServiceIntervals(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err = h.service.GetMachineTypeById(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	intervals, err := h.service.ListServiceIntervals(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list service intervals", slog.Int("type_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, intervals)
+}
+
+func (h *Handler) CreateServiceInterval(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.CreateServiceInterval")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data serviceIntervalRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	if _, err = h.service.GetMachineTypeById(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	interval, err := h.service.InsertServiceInterval(r.Context(), entities.ServiceInterval{
+		TypeId:     id,
+		Name:       data.Name,
+		EveryHours: data.EveryHours,
+		GraceHours: data.GraceHours,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "insert service interval", op, slog.Int("type_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "service interval is created", op, slog.Int("interval_id", interval.Id), slog.Int("type_id", id))
+	respondJSON(w, r, http.StatusCreated, interval)
+}
+
+// UpdateServiceInterval replaces name and hours of interval, open work orders keep their due hours
+func (h *Handler) UpdateServiceInterval(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.UpdateServiceInterval")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data serviceIntervalRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	interval, err := h.service.UpdateServiceInterval(r.Context(), entities.ServiceInterval{
+		Id:         id,
+		Name:       data.Name,
+		EveryHours: data.EveryHours,
+		GraceHours: data.GraceHours,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update service interval", op, slog.Int("interval_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "service interval is updated", op, slog.Int("interval_id", id))
+	respondJSON(w, r, http.StatusOK, interval)
+}
+
+func (h *Handler) DeleteServiceInterval(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err = h.service.DeleteServiceInterval(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "service interval is deleted", slog.Int("interval_id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMachineServiceV2 responds with operating hours of the machine and next services by intervals of its type
+func (h *Handler) GetMachineServiceV2(w http.ResponseWriter, r *http.Request) {
+	machine, err := h.getMachine(r.Context(), r.PathValue("id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// orders are not created here, see workorders.Monitor
+	machineService, _, err := workorders.Plan(r.Context(), h.service, machine)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "plan service of machine", slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, machineService)
+}
+
+func (h *Handler) ListWorkOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	params, err := parseListParams(q, entities.WorkOrderSorts)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	siteId, err := listSite(r)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	page, err := h.service.ListWorkOrders(r.Context(), entities.WorkOrderFilter{
+		ListParams: params,
+		MachineId:  q.Get("machine_id"),
+		Status:     q.Get("status"),
+		SiteId:     siteId,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list work orders", slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, page)
+}
+
+func (h *Handler) GetWorkOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	order, err := h.getWorkOrder(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, order)
+}
+
+// CloseWorkOrder marks service as done at current operating hours of the machine,
+// next service by the interval is due after its every_hours from now
+func (h *Handler) CloseWorkOrder(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.CloseWorkOrder")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data closeWorkOrderRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	userId, err := userIdFromContext(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user_id from context", op, slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	order, err := h.getWorkOrder(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if order.Status != entities.WorkOrderOpen {
+		respondError(w, r, errs.ErrWorkOrderClosed)
+		return
+	}
+
+	now := time.Now()
+	seconds, err := h.service.OperatingSeconds(r.Context(), order.MachineId, now)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get operating time of machine", op, slog.String("machine_id", order.MachineId),
+			slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	order.Notes, order.ClosedAt, order.ClosedBy = data.Notes, &now, int(userId)
+	order.ClosedAtHours = entities.OperatingHours(seconds)
+
+	order, err = h.service.CloseWorkOrder(r.Context(), *order)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "close work order", op, slog.Int("work_order_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "work order is closed", op,
+		slog.Int("work_order_id", order.Id),
+		slog.String("machine_id", order.MachineId),
+		slog.Float64("hours", order.ClosedAtHours),
+		slog.Int("closed_by", order.ClosedBy),
+	)
+	respondJSON(w, r, http.StatusOK, order)
+}
+
+// checkService creates work orders which are due and returns errs.ErrMaintenanceOverdue
+// if machine has open work order after its overdue hours
+func checkService(ctx context.Context, svc *service.Service, machine *entities.Machine) error {
+	machineService, open, err := workorders.Schedule(ctx, svc, machine)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range open {
+		if order.Overdue(machineService.OperatingHours) {
+			return errs.ErrMaintenanceOverdue.WithMessage(fmt.Sprintf("%s of machine is overdue, close work order %d first", order.Name, order.Id))
+		}
+	}
+	return nil
+}
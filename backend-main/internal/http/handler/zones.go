@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// GetUserParkings responds with parkings allowed to the user, empty list means any parking
+func (h *Handler) GetUserParkings(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if _, err = h.getUser(r.Context(), id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	parkings, err := h.userParkings(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get parkings of user", slog.Int("user_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+	respondJSON(w, r, http.StatusOK, parkings)
+}
+
+// ReplaceUserParkings replaces parkings allowed to the user, empty list allows any parking
+func (h *Handler) ReplaceUserParkings(w http.ResponseWriter, r *http.Request) {
+	op := slog.String("op", "handler.ReplaceUserParkings")
+
+	id, err := pathInt(r, "id")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var data userParkingsRequest
+	if err = utils.ParseRequestData(r.Body, &data); err != nil {
+		respondError(w, r, errs.ErrInvalidRequest.Wrap(err))
+		return
+	}
+
+	user, err := h.getUser(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	for _, parkingId := range data.ParkingIds {
+		parking, err := h.getParking(r.Context(), parkingId)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		if parking.SiteId != user.SiteId {
+			respondError(w, r, errs.ErrSiteMismatch.WithMessage("parking is at another site than user"))
+			return
+		}
+	}
+
+	if err = h.service.ReplaceUserParkings(r.Context(), id, data.ParkingIds); err != nil {
+		slog.ErrorContext(r.Context(), "replace parkings of user", op, slog.Int("user_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	parkings, err := h.userParkings(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get parkings of user", op, slog.Int("user_id", id), slog.String("error", err.Error()))
+		respondError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "parkings of user are replaced", op, slog.Int("user_id", id), slog.Any("parking_ids", data.ParkingIds))
+	respondJSON(w, r, http.StatusOK, parkings)
+}
+
+func (h *Handler) userParkings(ctx context.Context, userId int) ([]entities.Parking, error) {
+	ids, err := h.service.ListUserParkingIds(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	parkings := make([]entities.Parking, 0, len(ids))
+	for _, id := range ids {
+		parking, err := h.service.GetParkingById(ctx, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get parking %d", id)
+		}
+		parkings = append(parkings, *parking)
+	}
+	return parkings, nil
+}
+
+// checkUnlockZone returns errs.ErrParkingNotAllowed if user is restricted to parkings and the machine is not at one of them.
+// Machine without parking is located by bssid of its router, it is not allowed if it is outside of parkings.
+func (h *Handler) checkUnlockZone(ctx context.Context, user *entities.User, machine *entities.Machine) error {
+	allowed, err := h.service.ListUserParkingIds(ctx, user.Id)
+	if err != nil {
+		return errors.Wrap(err, "get parkings of user")
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	parkingId := machine.ParkingId
+	if parkingId == 0 {
+		currentMac, err := getMachineCurrentMacAddr(ctx, machine, h.cfg.MC.RequestTimeout)
+		if err != nil {
+			return err
+		}
+
+		parking, err := h.service.GetParkingByMacAddr(ctx, currentMac)
+		if errors.Is(err, errs.ErrParkingNotFound) {
+			return errs.ErrParkingNotAllowed.WithMessage("machine is outside of parkings allowed to user")
+		}
+		if err != nil {
+			return errors.Wrap(err, "get parking by mac addr")
+		}
+		parkingId = parking.Id
+	}
+
+	if !slices.Contains(allowed, parkingId) {
+		return errs.ErrParkingNotAllowed
+	}
+	return nil
+}
+
+// checkParkingZone returns errs.ErrParkingNotAllowed if user is restricted to parkings and the parking is not one of them
+func checkParkingZone(ctx context.Context, svc *service.Service, user *entities.User, parking *entities.Parking) error {
+	allowed, err := svc.ListUserParkingIds(ctx, user.Id)
+	if err != nil {
+		return errors.Wrap(err, "get parkings of user")
+	}
+
+	if len(allowed) != 0 && !slices.Contains(allowed, parking.Id) {
+		return errs.ErrParkingNotAllowed
+	}
+	return nil
+}
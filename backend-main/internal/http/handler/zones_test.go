@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+func TestUnlockIsRestrictedToParkingsOfUser(t *testing.T) {
+	app := newTestApp(t, nil)
+	d := newDevice(t)
+	app.machine("M1", d)
+	app.machine("M2", d)
+	admin, worker := app.token("100"), app.token("200")
+
+	user, err := app.svc.GetUserByPhoneNumber(context.Background(), "200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parkings []entities.Parking
+	for _, req := range []createParkingRequest{
+		{Name: "North", MacAddr: "aa:aa:aa:aa:aa:01", State: entities.ParkingActive},
+		{Name: "South", MacAddr: "aa:aa:aa:aa:aa:02", State: entities.ParkingActive},
+	} {
+		parkings = append(parkings, decode[entities.Parking](t, app.do("POST", "/api/v2/parkings", admin, req), http.StatusCreated))
+	}
+	for i, id := range []string{"M1", "M2"} {
+		if _, err = app.svc.UpdateMachineParkingId(context.Background(), id, parkings[i].Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	allowed := decode[[]entities.Parking](t, app.do("PUT", fmt.Sprintf("/api/v2/users/%d/parkings", user.Id), admin,
+		userParkingsRequest{ParkingIds: []int{parkings[0].Id}}), http.StatusOK)
+	if len(allowed) != 1 || allowed[0].Id != parkings[0].Id {
+		t.Fatalf("parkings of user %+v, want only %s", allowed, parkings[0].Name)
+	}
+
+	w := app.do("POST", "/api/v2/machines/M2/unlock", worker, nil)
+	if w.Code != http.StatusForbidden || errorCode(t, w) != "parking_not_allowed" {
+		t.Fatalf("unlock at other parking: status %d, want 403 parking_not_allowed: %s", w.Code, w.Body.String())
+	}
+	if n := d.requests.Load(); n != 0 {
+		t.Errorf("device got %d requests, want none", n)
+	}
+
+	decode[entities.Session](t, app.do("POST", "/api/v2/machines/M1/unlock", worker, nil), http.StatusCreated)
+}
+
+func TestUserParkingsMustExist(t *testing.T) {
+	app := newTestApp(t, nil)
+
+	w := app.do("PUT", "/api/v2/users/2/parkings", app.token("100"), userParkingsRequest{ParkingIds: []int{42}})
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "parking_not_found" {
+		t.Errorf("status %d, want 404 parking_not_found: %s", w.Code, w.Body.String())
+	}
+}
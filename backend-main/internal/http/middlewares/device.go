@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+)
+
+// DeviceTokenHeader carries token of machine controller, see DeviceToken
+const DeviceTokenHeader = "X-Device-Token"
+
+// DeviceToken returns token of controller of the machine: hex HMAC-SHA256 of machine id with device secret.
+// Token of one machine is not accepted for another one.
+func DeviceToken(secret, machineId string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(machineId))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RequireDevice passes requests of controller with token of the machine from `id` path value
+func RequireDevice(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := slog.String("op", "middlewares.RequireDevice")
+		machineId := r.PathValue("id")
+
+		err := errs.ErrMissingToken
+		if token := r.Header.Get(DeviceTokenHeader); token != "" {
+			if hmac.Equal([]byte(token), []byte(DeviceToken(secret, machineId))) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			err = errs.ErrInvalidDeviceToken
+		}
+
+		slog.WarnContext(r.Context(), "request of device is not authenticated", op, slog.String("machine_id", machineId),
+			slog.String("path", r.URL.Path), slog.String("error", err.Error()))
+		if err := utils.RespondWithAppError(w, err); err != nil {
+			slog.ErrorContext(r.Context(), "failed respond with 401: device token", op, slog.String("error", err.Error()))
+		}
+	})
+}
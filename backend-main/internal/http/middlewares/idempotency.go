@@ -0,0 +1,152 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+)
+
+const (
+	IdempotencyKeyHeader   = "Idempotency-Key"
+	IdempotentReplayHeader = "Idempotent-Replayed"
+)
+
+// maxIdempotencyKeyLen is length of idempotency_keys.key column
+const maxIdempotencyKeyLen = 128
+
+// idempotencyPendingTTL is time after which command in progress is considered abandoned,
+// e.g. the app was killed before it finished. It is longer than any command can last.
+const idempotencyPendingTTL = time.Minute
+
+type IdempotencyStore interface {
+	ReserveKey(ctx context.Context, userId int, key, requestHash string, expired, abandoned time.Time) (*entities.IdempotencyRecord, bool, error)
+	CompleteKey(ctx context.Context, userId int, key string, status int, contentType string, body []byte) error
+	ReleaseKey(ctx context.Context, userId int, key string) error
+}
+
+// recordingWriter keeps copy of response body to store it
+type recordingWriter struct {
+	statusWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.statusWriter.Write(b)
+}
+
+// Idempotent stores outcome of command sent with Idempotency-Key header and replays it
+// for repeated requests of the same user with the same key within ttl.
+// Requests without the header are passed as is. It should be wrapped with RequirePermission.
+// Responses with 5xx status and transient conflicts are not stored, so failed command can be retried with the same key.
+func Idempotent(store IdempotencyStore, ttl time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := slog.String("op", "middlewares.Idempotent")
+
+		key := r.Header.Get(IdempotencyKeyHeader)
+		userId, ok := r.Context().Value("user_id").(int64)
+		if key == "" || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			respondAppError(w, r, op, errs.ErrInvalidRequest.WithMessage("Idempotency-Key is longer than 128 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			respondAppError(w, r, op, errs.ErrInvalidRequest.Wrap(err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := requestHash(r, body)
+		now := time.Now()
+		record, reserved, err := store.ReserveKey(r.Context(), int(userId), key, hash, now.Add(-ttl), now.Add(-idempotencyPendingTTL))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "reserve idempotency key", op, slog.String("error", err.Error()))
+			respondAppError(w, r, op, err)
+			return
+		}
+
+		if !reserved {
+			replay(w, r, op, record, hash)
+			return
+		}
+
+		rec := &recordingWriter{statusWriter: statusWriter{ResponseWriter: w}}
+		next.ServeHTTP(rec, r)
+
+		// outcome is saved even if client has gone, it is what the retry should get
+		ctx := context.WithoutCancel(r.Context())
+		if rec.code() >= http.StatusInternalServerError || transient(rec) {
+			err = store.ReleaseKey(ctx, int(userId), key)
+		} else {
+			err = store.CompleteKey(ctx, int(userId), key, rec.code(), rec.Header().Get("Content-Type"), rec.body.Bytes())
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "save idempotency key outcome", op, slog.String("key", key), slog.String("error", err.Error()))
+		}
+	})
+}
+
+// transient reports if command was rejected because of other command in progress,
+// the retry should run it instead of getting the rejection again
+func transient(rec *recordingWriter) bool {
+	if rec.code() != http.StatusConflict {
+		return false
+	}
+	var resp utils.ErrorResponse
+	if err := json.Unmarshal(rec.body.Bytes(), &resp); err != nil {
+		return false
+	}
+	return resp.Code == errs.ErrMachineBusy.Code || resp.Code == errs.ErrIdempotencyInProgress.Code
+}
+
+// replay writes stored outcome if the key was used for the same request
+func replay(w http.ResponseWriter, r *http.Request, op slog.Attr, record *entities.IdempotencyRecord, hash string) {
+	if record.RequestHash != hash {
+		respondAppError(w, r, op, errs.ErrIdempotencyKeyReused)
+		return
+	}
+	if !record.Completed() {
+		respondAppError(w, r, op, errs.ErrIdempotencyInProgress)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "replay idempotent request", op, slog.String("key", record.Key), slog.Int("status", record.Status))
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(IdempotentReplayHeader, "true")
+	w.WriteHeader(record.Status)
+	if _, err := w.Write(record.Body); err != nil {
+		slog.ErrorContext(r.Context(), "failed to replay response", op, slog.String("error", err.Error()))
+	}
+}
+
+// requestHash identifies request by route and body, key sent with other request is rejected
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func respondAppError(w http.ResponseWriter, r *http.Request, op slog.Attr, err error) {
+	if err = utils.RespondWithAppError(w, err); err != nil {
+		slog.ErrorContext(r.Context(), "failed respond with error", op, slog.String("error", err.Error()))
+	}
+}
@@ -6,15 +6,50 @@ import (
 	"time"
 )
 
+// statusWriter remembers status code written by handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// code returns written status, handler which wrote nothing responds with 200
+func (w *statusWriter) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap lets http.ResponseController reach original writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(sw, r)
 
-		slog.Info("handle request",
+		slog.InfoContext(r.Context(), "handle request",
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.code()),
 			slog.Int64("duration_ns", int64(time.Since(start))),
 		)
 	})
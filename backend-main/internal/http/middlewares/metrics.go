@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/metrics"
+)
+
+// MetricsMiddleware records duration of requests handled by the route with pattern like "GET /api/v2/users/{id}".
+// Pattern is used as label instead of path, so ids in path do not create new series.
+func MetricsMiddleware(pattern string, next http.Handler) http.Handler {
+	method, route, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, route = "ANY", pattern
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		metrics.ObserveRequest(method, route, sw.code(), time.Since(start))
+	})
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/logger"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen limits id taken from client, longer ids are replaced with generated one
+const maxRequestIDLen = 128
+
+// RequestIDMiddleware puts id of the request into context, so it is logged with every
+// slog.*Context call, and returns it in X-Request-ID header.
+// Id from the request header is kept to trace requests through proxies.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestId) {
+			requestId = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestId)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), requestId)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
 	"github.com/ecol-master/sharing-wh-machines/internal/libs/jwt"
 	"github.com/ecol-master/sharing-wh-machines/internal/utils"
 )
@@ -31,65 +32,105 @@ func validateAuthHeader(authToken []string) (string, error) {
 	return tokenData[1], nil
 }
 
-// role - the minimal role level which will have access to resource
-func RoleBasedAccess(secret string, requiredJob entities.UserJob, next http.Handler) http.Handler {
+type (
+	roleKey struct{}
+	siteKey struct{}
+)
+
+// RoleStore gives roles with their permissions by name
+type RoleStore interface {
+	GetRole(ctx context.Context, name string) (*entities.Role, error)
+}
+
+// RoleFromContext returns role of the user authorized by RequirePermission
+func RoleFromContext(ctx context.Context) (*entities.Role, bool) {
+	role, ok := ctx.Value(roleKey{}).(*entities.Role)
+	return role, ok
+}
+
+// SiteFromContext returns site of the user from token, it is added by RequirePermission
+func SiteFromContext(ctx context.Context) (int, bool) {
+	siteId, ok := ctx.Value(siteKey{}).(int)
+	return siteId, ok
+}
+
+// RequirePermission passes requests of users whose role has the permission.
+// Role is read on every request, so changed permissions apply to already issued tokens.
+// User id, role and site are added to context of the request.
+func RequirePermission(secret string, roles RoleStore, permission entities.Permission, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		op := slog.String("op", "middlewares.RoleBasedAccess")
+		op := slog.String("op", "middlewares.RequirePermission")
 
 		token, err := validateAuthHeader(r.Header["Authorization"])
 		if err != nil {
-			slog.Info("validate token error", op, slog.Any("auth header", r.Header["Authorization"]),
+			slog.InfoContext(r.Context(), "validate token error", op, slog.Any("auth header", r.Header["Authorization"]),
 				slog.String("error", err.Error()))
 
-			if err = utils.RespondWith400(w, err.Error()); err != nil {
-				slog.Error("failed respond with 400: validateAuthHeader", op, slog.String("error", err.Error()))
+			if err = utils.RespondWithAppError(w, errs.ErrMissingToken.Wrap(err)); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with 401: validateAuthHeader", op, slog.String("error", err.Error()))
 			}
 			return
 		}
 
 		claims, err := extractClaims(token, secret)
 		if err != nil {
-			slog.Error("failed extract token claims: token is invalid", op, slog.String("token", token), slog.String("error", err.Error()))
-			if err := utils.RespondWith400(w, err.Error()); err != nil {
-				slog.Error("failed respond with 400: parse claims", op, slog.String("error", err.Error()))
+			slog.ErrorContext(r.Context(), "failed extract token claims: token is invalid", op, slog.String("token", token), slog.String("error", err.Error()))
+			if err := utils.RespondWithAppError(w, errs.ErrInvalidToken.Wrap(err)); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with 401: parse claims", op, slog.String("error", err.Error()))
 			}
 			return
 		}
 
 		jwtData, err := jwt.GetDataFromClaims(claims)
 		if err != nil {
-			slog.Error("failed parse jwt data", slog.String("error", err.Error()))
+			slog.ErrorContext(r.Context(), "failed parse jwt data", slog.String("error", err.Error()))
 
-			if err = utils.RespondWith400(w, "wrong jwt token data"); err != nil {
-				slog.Error("failed respond with 400: parse claims", op, slog.String("error", err.Error()))
+			if err = utils.RespondWithAppError(w, errs.ErrInvalidToken.WithMessage("wrong jwt token data")); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with 401: parse claims", op, slog.String("error", err.Error()))
 			}
 			return
 		}
 
 		if isJWTExpire(jwtData.Exp) {
-			if err := utils.RespondWith401(w, "token is expired"); err != nil {
-				slog.Error("failed respond with 400: parse claims", op, slog.String("error", err.Error()))
+			if err := utils.RespondWithAppError(w, errs.ErrTokenExpired); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with 401: token is expired", op, slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		role, err := roles.GetRole(r.Context(), jwtData.JobPosition)
+		if errors.Is(err, errs.ErrRoleNotFound) {
+			err = errs.ErrUnknownJobPosition.Wrap(err)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get role of user", op, slog.String("user_job", jwtData.JobPosition),
+				slog.String("error", err.Error()))
+
+			if err := utils.RespondWithAppError(w, err); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with error: get role", op, slog.String("error", err.Error()))
 			}
 			return
 		}
 
-		if !hasUserPermission(jwtData.JobPosition, requiredJob) {
-			slog.Info("user has no permission to data", slog.String("path", r.URL.Path),
-				slog.String("user_job", jwtData.JobPosition))
+		if !role.Has(permission) {
+			slog.InfoContext(r.Context(), "user has no permission to data", slog.String("path", r.URL.Path),
+				slog.String("user_job", jwtData.JobPosition), slog.String("permission", permission))
 
-			if err := utils.RespondWith400(w, "user have no access to this resource"); err != nil {
-				slog.Error("failed respond with 400: parse claims", op, slog.String("error", err.Error()))
+			if err := utils.RespondWithAppError(w, errs.ErrAccessDenied); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with 403: no access", op, slog.String("error", err.Error()))
 			}
 			return
 		}
 
 		// user has access to data
-		slog.Info("handle request with auth",
+		slog.InfoContext(r.Context(), "handle request with auth",
 			slog.String("path", r.URL.Path),
 			slog.String("method", r.Method),
 		)
 
-		ctx := context.WithValue(context.Background(), "user_id", jwtData.UserId)
+		ctx := context.WithValue(r.Context(), "user_id", jwtData.UserId)
+		ctx = context.WithValue(ctx, roleKey{}, role)
+		ctx = context.WithValue(ctx, siteKey{}, jwtData.SiteId)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
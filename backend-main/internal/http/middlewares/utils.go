@@ -3,7 +3,6 @@ package middlewares
 import (
 	"errors"
 
-	"github.com/ecol-master/sharing-wh-machines/internal/entities"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -23,14 +22,3 @@ func extractClaims(tokenStr, secret string) (jwt.MapClaims, error) {
 	}
 	return nil, errors.New("token is invalid")
 }
-
-func hasUserPermission(userJob, requiredJob entities.UserJob) bool {
-	switch requiredJob {
-	case entities.Admin:
-		return userJob == entities.Admin
-
-	case entities.Worker:
-		return userJob == entities.Worker || userJob == entities.Admin
-	}
-	return false
-}
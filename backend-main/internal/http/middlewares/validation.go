@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/http/openapi"
+	"github.com/ecol-master/sharing-wh-machines/internal/utils"
+)
+
+// maxBodySize limits size of request body which is read for validation
+const maxBodySize = 1 << 20
+
+// ValidateBody rejects requests which json body does not match the schema.
+// Body is restored after validation, so next handler can read it again.
+func ValidateBody(schema *openapi.Schema, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := slog.String("op", "middlewares.ValidateBody")
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			slog.InfoContext(r.Context(), "read request body", op, slog.String("path", r.URL.Path), slog.String("error", err.Error()))
+			if err = utils.RespondWithAppError(w, errs.ErrInvalidRequest.Wrap(err)); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with 400: read body", op, slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		// empty body is validated as empty object, so bodies without required fields may be omitted
+		if len(bytes.TrimSpace(body)) == 0 {
+			body = []byte("{}")
+		}
+
+		var value any
+		if err = json.Unmarshal(body, &value); err != nil {
+			if err = utils.RespondWithAppError(w, errs.ErrInvalidRequest.WithMessage("request body is not valid json")); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with 400: unmarshal body", op, slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		if err = schema.Validate(value); err != nil {
+			slog.InfoContext(r.Context(), "request body does not match schema", op, slog.String("path", r.URL.Path), slog.String("error", err.Error()))
+			if err = utils.RespondWithAppError(w, errs.ErrInvalidRequest.WithMessage("request body does not match schema: "+err.Error())); err != nil {
+				slog.ErrorContext(r.Context(), "failed respond with 400: validate body", op, slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Document is a minimal OpenAPI 3 document, only fields used by the app are declared
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps lowercase http method to operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []SecurityRequirement `json:"security"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type SecurityRequirement map[string][]string
+
+// BearerAuth is the name of jwt security scheme
+const BearerAuth = "bearerAuth"
+
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+}
+
+// Add registers operation for ServeMux pattern like "GET /machines/{id}".
+// Path parameters are added to operation automatically.
+func (d *Document) Add(pattern string, op *Operation) {
+	method, path, err := splitPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range pathParams(path) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: TypeString},
+		})
+	}
+
+	if op.Security == nil {
+		op.Security = []SecurityRequirement{}
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// BodySchema returns json schema of operation's request body if it has one
+func (op *Operation) BodySchema() (*Schema, bool) {
+	if op.RequestBody == nil {
+		return nil, false
+	}
+
+	media, ok := op.RequestBody.Content[ContentTypeJSON]
+	if !ok || media.Schema == nil {
+		return nil, false
+	}
+	return media.Schema, true
+}
+
+const ContentTypeJSON = "application/json"
+
+// JSONBody makes required json request body with the schema
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{ContentTypeJSON: {Schema: schema}},
+	}
+}
+
+// JSONResponse makes response with json content, schema can be nil for empty body
+func JSONResponse(description string, schema *Schema) Response {
+	if schema == nil {
+		return Response{Description: description}
+	}
+	return Response{
+		Description: description,
+		Content:     map[string]MediaType{ContentTypeJSON: {Schema: schema}},
+	}
+}
+
+// BinaryBody makes required request body with raw file of one of content types, it is not validated against schema
+func BinaryBody(contentTypes ...string) *RequestBody {
+	return &RequestBody{Required: true, Content: binaryContent(contentTypes)}
+}
+
+// BinaryResponse makes response with raw file of one of content types
+func BinaryResponse(description string, contentTypes ...string) Response {
+	return Response{Description: description, Content: binaryContent(contentTypes)}
+}
+
+func binaryContent(contentTypes []string) map[string]MediaType {
+	content := make(map[string]MediaType, len(contentTypes))
+	for _, ct := range contentTypes {
+		content[ct] = MediaType{Schema: &Schema{Type: TypeString, Format: "binary"}}
+	}
+	return content
+}
+
+func splitPattern(pattern string) (method, path string, err error) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || method == "" || !strings.HasPrefix(path, "/") {
+		return "", "", fmt.Errorf("openapi: pattern %q should be in form \"METHOD /path\"", pattern)
+	}
+	return method, path, nil
+}
+
+func pathParams(path string) []string {
+	params := make([]string, 0)
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, strings.TrimSuffix(strings.Trim(segment, "{}"), "..."))
+		}
+	}
+	return params
+}
@@ -0,0 +1,149 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// SchemaOf builds schema from go value using `json` tags.
+// Additional struct tags are used for request validation:
+//   - `required:"true"` - field should be present in body
+//   - `enum:"0,1"` - allowed values of the field
+//   - `minimum:"0"` - minimal value of number field
+//   - `minLength:"1"` - minimal length of string field
+func SchemaOf(v any) *Schema {
+	return schemaOfType(reflect.TypeOf(v))
+}
+
+// ArrayOf builds schema of json array with items of v
+func ArrayOf(v any) *Schema {
+	return &Schema{Type: TypeArray, Items: SchemaOf(v)}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaOfType(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		s := schemaOfType(t.Elem())
+		s.Nullable = true
+		return s
+	}
+
+	if t == timeType {
+		return &Schema{Type: TypeString, Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return schemaOfStruct(t)
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: TypeArray, Items: schemaOfType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: TypeObject, AdditionalProperties: schemaOfType(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: TypeString}
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: TypeInteger}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TypeNumber}
+	default:
+		return &Schema{}
+	}
+}
+
+func schemaOfStruct(t reflect.Type) *Schema {
+	s := &Schema{Type: TypeObject, Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		// fields of embedded struct are encoded inline, like encoding/json does
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			embedded := schemaOfStruct(field.Type)
+			for name, prop := range embedded.Properties {
+				s.Properties[name] = prop
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := schemaOfType(field.Type)
+		applyTags(prop, field.Tag)
+		s.Properties[name] = prop
+
+		if field.Tag.Get("required") == "true" {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+func applyTags(s *Schema, tag reflect.StructTag) {
+	if enum := tag.Get("enum"); enum != "" {
+		for _, value := range strings.Split(enum, ",") {
+			if s.Type == TypeInteger {
+				if n, err := strconv.Atoi(value); err == nil {
+					s.Enum = append(s.Enum, n)
+					continue
+				}
+			}
+			s.Enum = append(s.Enum, value)
+		}
+	}
+
+	if minimum := tag.Get("minimum"); minimum != "" {
+		if n, err := strconv.ParseFloat(minimum, 64); err == nil {
+			s.Minimum = &n
+		}
+	}
+
+	if minLength := tag.Get("minLength"); minLength != "" {
+		if n, err := strconv.Atoi(minLength); err == nil {
+			s.MinLength = &n
+		}
+	}
+
+	if description := tag.Get("description"); description != "" {
+		s.Description = description
+	}
+}
@@ -0,0 +1,106 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// Validate checks value decoded from json into `any` against the schema.
+// Only keywords produced by SchemaOf are supported.
+func (s *Schema) Validate(value any) error {
+	return s.validate("body", value)
+}
+
+func (s *Schema) validate(path string, value any) error {
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: should not be null", path)
+	}
+
+	switch s.Type {
+	case TypeObject:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: should be object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s: is required", path, name)
+			}
+		}
+		for name, v := range obj {
+			if prop, ok := s.Properties[name]; ok {
+				if err := prop.validate(path+"."+name, v); err != nil {
+					return err
+				}
+			} else if s.AdditionalProperties != nil {
+				if err := s.AdditionalProperties.validate(path+"."+name, v); err != nil {
+					return err
+				}
+			}
+		}
+
+	case TypeArray:
+		arr, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: should be array", path)
+		}
+		if s.Items != nil {
+			for i, v := range arr {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), v); err != nil {
+					return err
+				}
+			}
+		}
+
+	case TypeString:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: should be string", path)
+		}
+		if s.MinLength != nil && utf8.RuneCountInString(str) < *s.MinLength {
+			return fmt.Errorf("%s: should be at least %d characters", path, *s.MinLength)
+		}
+
+	case TypeInteger, TypeNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s: should be %s", path, s.Type)
+		}
+		if s.Type == TypeInteger && n != math.Trunc(n) {
+			return fmt.Errorf("%s: should be integer", path)
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return fmt.Errorf("%s: should be >= %v", path, *s.Minimum)
+		}
+
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: should be boolean", path)
+		}
+	}
+
+	if len(s.Enum) != 0 && !inEnum(s.Enum, value) {
+		return fmt.Errorf("%s: should be one of %v", path, s.Enum)
+	}
+	return nil
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		switch e := e.(type) {
+		case int:
+			if n, ok := value.(float64); ok && n == float64(e) {
+				return true
+			}
+		default:
+			if e == value {
+				return true
+			}
+		}
+	}
+	return false
+}
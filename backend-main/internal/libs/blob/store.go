@@ -0,0 +1,18 @@
+// Package blob stores files like photos of incidents on local disk or in S3-compatible storage.
+// Keys are slash-separated paths, content type is kept by caller.
+package blob
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by Get when there is no object with the key
+var ErrNotFound = errors.New("blob not found")
+
+type Store interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes object, missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
@@ -18,6 +18,7 @@ type jwtData struct {
 	UserId      int64
 	PhoneNumber string
 	JobPosition entities.UserJob
+	SiteId      int
 	Exp         int64
 }
 
@@ -29,6 +30,7 @@ func (s *serviceJWT) GenerateToken(user entities.User, secret string, tokenTTL t
 	claims["id"] = user.Id
 	claims["phone_number"] = user.PhoneNumber
 	claims["job_position"] = user.JobPosition
+	claims["site_id"] = user.SiteId
 	claims["exp"] = time.Now().Add(tokenTTL).Unix()
 
 	tokenString, err := token.SignedString([]byte(secret))
@@ -64,6 +66,16 @@ func GetDataFromClaims(claims map[string]interface{}) (jwtData, error) {
 	}
 	data.JobPosition = job
 
+	// tokens issued before sites belong to users of the default site
+	data.SiteId = entities.DefaultSiteId
+	if siteId, ok := claims["site_id"]; ok {
+		id, ok := siteId.(float64)
+		if !ok {
+			return data, errors.New("failed to parse `site_id` from claims")
+		}
+		data.SiteId = int(id)
+	}
+
 	exp, ok := claims["exp"].(float64)
 	if !ok {
 		return data, errors.New("failed to parse `exp` from claims")
@@ -0,0 +1,24 @@
+// Package notify sends messages to people through sinks: stdout, webhook, e-mail and Telegram bot.
+package notify
+
+import "context"
+
+// Message is sent to every sink as is, sinks choose fields they can deliver
+type Message struct {
+	Subject string `json:"subject"` // one line, e-mail subject
+	Text    string `json:"text"`
+	Data    any    `json:"data,omitempty"` // object the message is about, sent by webhook and stdout
+}
+
+// Sink delivers messages, Send returns when the message is accepted by the receiver or ctx is done
+type Sink interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Names of sinks which are used in config
+const (
+	SinkStdout   = "stdout"
+	SinkWebhook  = "webhook"
+	SinkSMTP     = "smtp"
+	SinkTelegram = "telegram"
+)
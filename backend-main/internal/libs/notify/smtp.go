@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SMTPConfig describes mail server and recipients of messages. STARTTLS is used if server supports it,
+// credentials are sent only over TLS or to localhost.
+type SMTPConfig struct {
+	Addr     string   `yaml:"addr"` // host:port
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password" env:"SMTP_PASSWORD"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+type SMTP struct {
+	cfg  SMTPConfig
+	host string
+}
+
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid smtp addr %q", cfg.Addr)
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("smtp from and to are required")
+	}
+	return &SMTP{cfg: cfg, host: host}, nil
+}
+
+// Send sends plain text e-mail with subject and text of the message to all recipients
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return errors.Wrap(err, "dial smtp")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return errors.Wrap(err, "set deadline")
+		}
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return errors.Wrap(err, "smtp hello")
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return errors.Wrap(err, "smtp starttls")
+		}
+	}
+	if s.cfg.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.host)); err != nil {
+			return errors.Wrap(err, "smtp auth")
+		}
+	}
+
+	if err = c.Mail(s.cfg.From); err != nil {
+		return errors.Wrap(err, "smtp mail")
+	}
+	for _, to := range s.cfg.To {
+		if err = c.Rcpt(to); err != nil {
+			return errors.Wrapf(err, "smtp rcpt %s", to)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "smtp data")
+	}
+	if _, err = w.Write(s.mail(msg)); err != nil {
+		return errors.Wrap(err, "write mail")
+	}
+	if err = w.Close(); err != nil {
+		return errors.Wrap(err, "smtp data")
+	}
+	return errors.Wrap(c.Quit(), "smtp quit")
+}
+
+func (s *SMTP) mail(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.ReplaceAll(msg.Subject, "\n", " "))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
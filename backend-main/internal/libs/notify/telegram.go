@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TelegramConfig describes bot which sends messages to chat. APIURL is changed only for local stand-in of Bot API.
+type TelegramConfig struct {
+	APIURL string `yaml:"api_url" env-default:"https://api.telegram.org"`
+	Token  string `yaml:"token" env:"TELEGRAM_BOT_TOKEN"`
+	ChatId string `yaml:"chat_id"` // id of chat or @channel_name
+}
+
+type Telegram struct {
+	cfg    TelegramConfig
+	client *http.Client
+}
+
+func NewTelegram(cfg TelegramConfig, client *http.Client) (*Telegram, error) {
+	if u, err := url.Parse(cfg.APIURL); err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid telegram api url %q", cfg.APIURL)
+	}
+	if cfg.Token == "" || cfg.ChatId == "" {
+		return nil, errors.New("telegram token and chat_id are required")
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+	return &Telegram{cfg: cfg, client: client}, nil
+}
+
+// Send sends subject and text of the message with sendMessage method of Bot API
+func (s *Telegram) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": s.cfg.ChatId,
+		"text":    msg.Subject + "\n" + msg.Text,
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal message")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.APIURL+"/bot"+s.cfg.Token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// url of the request contains token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return errors.Wrap(err, "send telegram message")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError("telegram", resp)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return errors.Wrap(err, "decode telegram response")
+	}
+	if !result.Ok {
+		return errors.Errorf("telegram: %s", result.Description)
+	}
+	return nil
+}
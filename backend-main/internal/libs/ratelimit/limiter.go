@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket per key. Each key can make limit requests at once
+// and then gets limit requests back evenly over interval.
+// Limiter with limit <= 0 allows everything.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     float64
+	interval  time.Duration
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+func New(limit int, interval time.Duration) *Limiter {
+	return &Limiter{
+		limit:     float64(limit),
+		interval:  interval,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes request of the key into account. If the key is over the limit,
+// false and time after which the next request is allowed are returned.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.limit, at: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.at = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.limit * float64(l.interval))
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + l.limit*float64(now.Sub(b.at))/float64(l.interval)
+	return min(tokens, l.limit)
+}
+
+// sweep removes full buckets once per interval, they are the same as missing ones
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.interval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.limit {
+			delete(l.buckets, key)
+		}
+	}
+}
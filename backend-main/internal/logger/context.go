@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+type requestIdKey struct{}
+
+// WithRequestID returns context which records logged with it get request_id attribute
+func WithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestID returns id of the request set by WithRequestID or empty string
+func RequestID(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// contextHandler adds values of context passed to slog.InfoContext and others to the record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestId := RequestID(ctx); requestId != "" {
+		r.AddAttrs(slog.String("request_id", requestId))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
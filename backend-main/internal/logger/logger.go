@@ -1,19 +1,33 @@
 package logger
 
 import (
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 
+	"github.com/ecol-master/sharing-wh-machines/internal/config"
 	"github.com/pkg/errors"
 )
 
-func Setup(filepath string) {
-	file, err := os.OpenFile(filepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// Setup makes json logger writing to stdout and to rotated file in cfg.OutDir the default one.
+// Returned closer closes the log file, it should be called before exit.
+func Setup(cfg config.LogConfig) (io.Closer, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, errors.Wrapf(err, "log level %q", cfg.Level)
+	}
+
+	if err := os.MkdirAll(cfg.OutDir, 0750); err != nil {
+		return nil, errors.Wrap(err, "create logs folder")
+	}
+
+	file, err := NewRotatingFile(filepath.Join(cfg.OutDir, cfg.Dev), int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
 	if err != nil {
-		panic(errors.Wrap(err, "open dev logs file"))
+		return nil, errors.Wrap(err, "open dev logs file")
 	}
-	defer file.Close()
 
-	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
-	slog.SetDefault(log)
+	handler := slog.NewJSONHandler(io.MultiWriter(os.Stdout, file), &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return file, nil
 }
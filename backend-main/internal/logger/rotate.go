@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// RotatingFile is a file writer which renames the file to path.1 when it grows over maxSize.
+// Older backups are shifted to path.2 ... path.<maxBackups>, the oldest one is removed.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens file at path for appending, maxSize <= 0 disables rotation
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the file. Failed rotation does not stop logging: it is reported to stderr,
+// p is written to the current file and rotation is retried after another maxSize bytes.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to rotate log file %s: %v\n", f.path, err)
+			f.size = 0
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "open log file")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "stat log file")
+	}
+
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the file to backup and opens new one at path.
+// Current file is closed only after the new one is opened, so it is kept on any failure.
+func (f *RotatingFile) rotate() error {
+	if f.maxBackups > 0 {
+		for i := f.maxBackups - 1; i > 0; i-- {
+			err := os.Rename(f.backup(i), f.backup(i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return errors.Wrap(err, "shift log backup")
+			}
+		}
+		// path is missing if previous rotation failed to open it, current file is already backup then
+		if err := os.Rename(f.path, f.backup(1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "rename log file")
+		}
+	} else if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "remove log file")
+	}
+
+	current := f.file
+	if err := f.open(); err != nil {
+		return err
+	}
+	if err := current.Close(); err != nil {
+		return errors.Wrap(err, "close rotated log file")
+	}
+	return nil
+}
+
+func (f *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first-\n", "second\n", "third-\n"} {
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatalf("write %q: %v", line, err)
+		}
+	}
+
+	if got := readFile(t, path); got != "third-\n" {
+		t.Errorf("log file %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "second\n" {
+		t.Errorf("backup 1 %q", got)
+	}
+	if got := readFile(t, path+".2"); got != "first-\n" {
+		t.Errorf("backup 2 %q", got)
+	}
+}
+
+func TestRotatingFileKeepsWritingWhenRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// log file can not be renamed to backup which is not empty directory
+	if err = os.MkdirAll(filepath.Join(path+".1", "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"first-\n", "second\n"} {
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatalf("write %q: %v", line, err)
+		}
+	}
+	if got := readFile(t, path); got != "first-\nsecond\n" {
+		t.Errorf("log file after failed rotation %q", got)
+	}
+
+	// rotation is retried once the backup can be written
+	if err = os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("third-\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := readFile(t, path); got != "third-\n" {
+		t.Errorf("log file after rotation %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "first-\nsecond\n" {
+		t.Errorf("backup %q", got)
+	}
+}
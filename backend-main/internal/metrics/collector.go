@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type stateSource interface {
+	SessionsByState(ctx context.Context) (map[entities.SessionState]int, error)
+	MachinesByState(ctx context.Context) (map[entities.MachineState]int, error)
+	ListParkings(ctx context.Context, filter entities.ParkingFilter) (*entities.Page[entities.Parking], error)
+}
+
+var (
+	sessionStates = map[entities.SessionState]string{
+		entities.SessionActive: "active",
+		entities.SessionPause:  "paused",
+	}
+	machineStates = map[entities.MachineState]string{
+		entities.MachineFree:  "free",
+		entities.MachineStop:  "stopped",
+		entities.MachineInUse: "in_use",
+	}
+)
+
+// collectTimeout limits time of reading gauges from storage, it should be less than scrape timeout
+const collectTimeout = 5 * time.Second
+
+// stateCollector reads gauges of machines, sessions and parkings from storage on every scrape
+type stateCollector struct {
+	source stateSource
+
+	sessions        *prometheus.Desc
+	machines        *prometheus.Desc
+	parkingMachines *prometheus.Desc
+	parkingCapacity *prometheus.Desc
+	parkingOccupied *prometheus.Desc
+}
+
+// NewStateCollector creates collector of current state of storage, register it with prometheus.MustRegister
+func NewStateCollector(source stateSource) prometheus.Collector {
+	return &stateCollector{
+		source: source,
+		sessions: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "sessions"),
+			"Unfinished sessions by state.", []string{"state"}, nil),
+		machines: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "machines"),
+			"Machines by state.", []string{"state"}, nil),
+		parkingMachines: prometheus.NewDesc(prometheus.BuildFQName(namespace, "parking", "machines"),
+			"Machines at parking.", []string{"parking"}, nil),
+		parkingCapacity: prometheus.NewDesc(prometheus.BuildFQName(namespace, "parking", "capacity"),
+			"Capacity of parking, 0 is unlimited.", []string{"parking"}, nil),
+		parkingOccupied: prometheus.NewDesc(prometheus.BuildFQName(namespace, "parking", "occupancy_ratio"),
+			"Machines divided by capacity, parkings with unlimited capacity are skipped.", []string{"parking"}, nil),
+	}
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.sessions
+	ch <- c.machines
+	ch <- c.parkingMachines
+	ch <- c.parkingCapacity
+	ch <- c.parkingOccupied
+}
+
+// Collect skips metrics which can not be read, so failed storage does not break scrape of others
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	op := slog.String("op", "metrics.stateCollector.Collect")
+
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	if sessions, err := c.source.SessionsByState(ctx); err != nil {
+		slog.Error("count sessions by state", op, slog.String("error", err.Error()))
+	} else {
+		collectStates(ch, c.sessions, sessionStates, sessions)
+	}
+
+	if machines, err := c.source.MachinesByState(ctx); err != nil {
+		slog.Error("count machines by state", op, slog.String("error", err.Error()))
+	} else {
+		collectStates(ch, c.machines, machineStates, machines)
+	}
+
+	parkings, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Parking], error) {
+		return c.source.ListParkings(ctx, entities.ParkingFilter{ListParams: p})
+	})
+	if err != nil {
+		slog.Error("list parkings", op, slog.String("error", err.Error()))
+		return
+	}
+
+	for _, p := range parkings {
+		ch <- prometheus.MustNewConstMetric(c.parkingMachines, prometheus.GaugeValue, float64(p.Machines), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.parkingCapacity, prometheus.GaugeValue, float64(p.Capacity), p.Name)
+		if p.Capacity != entities.UnlimitedCapacity {
+			ch <- prometheus.MustNewConstMetric(c.parkingOccupied, prometheus.GaugeValue,
+				float64(p.Machines)/float64(p.Capacity), p.Name)
+		}
+	}
+}
+
+// collectStates sends count of every known state, states without items are sent as 0
+func collectStates(ch chan<- prometheus.Metric, desc *prometheus.Desc, names map[int]string, counts map[int]int) {
+	for state, name := range names {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(counts[state]), name)
+	}
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Device failure reasons
+const (
+	reasonTimeout = "timeout"
+	reasonError   = "error"
+	reasonStatus  = "status"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// DeviceTransport records duration and failures of requests to microcontrollers made with next.
+// Responses with status other than 2xx are counted as failures too.
+func DeviceTransport(operation string, next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		deviceDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			deviceFailures.WithLabelValues(operation, reasonTimeout).Inc()
+		case err != nil:
+			deviceFailures.WithLabelValues(operation, reasonError).Inc()
+		case resp.StatusCode < 200 || resp.StatusCode > 299:
+			deviceFailures.WithLabelValues(operation, reasonStatus).Inc()
+		}
+		return resp, err
+	})
+}
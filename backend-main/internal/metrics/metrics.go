@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace is prefix of all metrics of the app
+const namespace = "sharing"
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of http requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	deviceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "device_request_duration_seconds",
+		Help:      "Duration of requests to machine microcontrollers.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation"})
+
+	deviceFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "device_request_failures_total",
+		Help:      "Failed requests to machine microcontrollers by reason: timeout, error or status.",
+	}, []string{"operation", "reason"})
+)
+
+// ObserveRequest records duration of http request handled by route
+func ObserveRequest(method, route string, status int, d time.Duration) {
+	requestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(d.Seconds())
+}
@@ -0,0 +1,150 @@
+package alerts
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Alert
+type memoryRepository struct {
+	mu     sync.RWMutex
+	alerts map[int]entities.Alert
+	lastId int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{alerts: make(map[int]entities.Alert)}
+}
+
+func (r *memoryRepository) RaiseAlert(ctx context.Context, a entities.Alert) (*entities.Alert, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seenAt := time.Unix(a.LastSeenAt.Unix(), 0)
+	for id, stored := range r.alerts {
+		if stored.Type == a.Type && stored.Key == a.Key && stored.ResolvedAt == nil {
+			stored.Count++
+			stored.Message, stored.LastSeenAt = a.Message, seenAt
+			r.alerts[id] = stored
+			return &stored, false, nil
+		}
+	}
+
+	r.lastId++
+	a = entities.Alert{
+		Id:         r.lastId,
+		Type:       a.Type,
+		Key:        a.Key,
+		SiteId:     a.SiteId,
+		Message:    a.Message,
+		Status:     entities.AlertOpen,
+		Count:      1,
+		CreatedAt:  seenAt,
+		LastSeenAt: seenAt,
+	}
+	r.alerts[a.Id] = a
+	return &a, true, nil
+}
+
+func (r *memoryRepository) GetAlert(ctx context.Context, alertId int) (*entities.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.alerts[alertId]
+	if !ok {
+		return nil, errs.ErrAlertNotFound
+	}
+	return &a, nil
+}
+
+func (r *memoryRepository) ListAlerts(ctx context.Context, f entities.AlertFilter) (*entities.Page[entities.Alert], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	alerts := make([]entities.Alert, 0)
+	for _, a := range r.alerts {
+		if (f.Type == "" || a.Type == f.Type) && (f.Key == "" || a.Key == f.Key) && (f.Status == "" || a.Status == f.Status) &&
+			(f.SiteId == 0 || a.SiteId == f.SiteId) && (f.Open == nil || *f.Open == (a.ResolvedAt == nil)) {
+			alerts = append(alerts, a)
+		}
+	}
+	r.mu.RUnlock()
+
+	page, err := listing.Memory(alerts, f.ListParams, func(a entities.Alert) (any, any) {
+		return sortValue(a, f.Sort), a.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) UnresolvedAlerts(ctx context.Context, alertType entities.AlertType) ([]entities.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	alerts := make([]entities.Alert, 0)
+	for _, a := range r.alerts {
+		if a.Type == alertType && a.ResolvedAt == nil {
+			alerts = append(alerts, a)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Id < alerts[j].Id })
+	return alerts, nil
+}
+
+func (r *memoryRepository) SetAlertNotified(ctx context.Context, alertId int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if a, ok := r.alerts[alertId]; ok {
+		notifiedAt := time.Unix(at.Unix(), 0)
+		a.NotifiedAt = &notifiedAt
+		r.alerts[alertId] = a
+	}
+	return nil
+}
+
+func (r *memoryRepository) AcknowledgeAlert(ctx context.Context, alertId, userId int, at time.Time) (*entities.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.alerts[alertId]
+	if !ok {
+		return nil, errs.ErrAlertNotFound
+	}
+	switch a.Status {
+	case entities.AlertResolved:
+		return nil, errs.ErrAlertResolved
+	case entities.AlertAcknowledged:
+		return &a, nil
+	}
+
+	acknowledgedAt := time.Unix(at.Unix(), 0)
+	a.Status, a.AcknowledgedBy, a.AcknowledgedAt = entities.AlertAcknowledged, userId, &acknowledgedAt
+	r.alerts[alertId] = a
+	return &a, nil
+}
+
+func (r *memoryRepository) ResolveAlert(ctx context.Context, alertType entities.AlertType, key string, at time.Time) (*entities.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, a := range r.alerts {
+		if a.Type == alertType && a.Key == key && a.ResolvedAt == nil {
+			resolvedAt := time.Unix(at.Unix(), 0)
+			a.Status, a.ResolvedAt = entities.AlertResolved, &resolvedAt
+			r.alerts[id] = a
+			return &a, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,192 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+var sortColumns = map[string]string{
+	"id":           "id",
+	"created_at":   "created_at",
+	"last_seen_at": "last_seen_at",
+}
+
+const columns = `id, type, key, site_id, message, status, count, created_at, last_seen_at, notified_at, acknowledged_by,
+	acknowledged_at, resolved_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAlert(row scanner, extra ...any) (*entities.Alert, error) {
+	var (
+		a                                      entities.Alert
+		createdAt, lastSeenAt                  int64
+		notifiedAt, acknowledgedAt, resolvedAt sql.NullInt64
+	)
+	dest := []any{&a.Id, &a.Type, &a.Key, &a.SiteId, &a.Message, &a.Status, &a.Count, &createdAt, &lastSeenAt, &notifiedAt,
+		&a.AcknowledgedBy, &acknowledgedAt, &resolvedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	a.CreatedAt, a.LastSeenAt = time.Unix(createdAt, 0), time.Unix(lastSeenAt, 0)
+	a.NotifiedAt, a.AcknowledgedAt, a.ResolvedAt = unixPtr(notifiedAt), unixPtr(acknowledgedAt), unixPtr(resolvedAt)
+	return &a, nil
+}
+
+func unixPtr(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0)
+	return &t
+}
+
+// RaiseAlert relies on unique index of unresolved alerts, xmax of inserted row is 0
+func (r *repository) RaiseAlert(ctx context.Context, a entities.Alert) (*entities.Alert, bool, error) {
+	q := `
+		INSERT INTO alerts (type, key, site_id, message, status, count, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, 1, $6, $6)
+		ON CONFLICT (type, key) WHERE resolved_at IS NULL
+		DO UPDATE SET count = alerts.count + 1, message = EXCLUDED.message, last_seen_at = EXCLUDED.last_seen_at
+		RETURNING ` + columns + `, xmax = 0`
+
+	var created bool
+	raised, err := scanAlert(r.db.QueryRowContext(ctx, q, a.Type, a.Key, a.SiteId, a.Message, entities.AlertOpen,
+		a.LastSeenAt.Unix()), &created)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "raise alert")
+	}
+	return raised, created, nil
+}
+
+func (r *repository) GetAlert(ctx context.Context, alertId int) (*entities.Alert, error) {
+	a, err := scanAlert(r.db.QueryRowContext(ctx, `SELECT `+columns+` FROM alerts WHERE id = $1`, alertId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrAlertNotFound
+	}
+	return a, errors.Wrap(err, "select alert")
+}
+
+func (r *repository) ListAlerts(ctx context.Context, f entities.AlertFilter) (*entities.Page[entities.Alert], error) {
+	var query listing.Query
+
+	if f.Type != "" {
+		query.Where("type = %s", f.Type)
+	}
+	if f.Key != "" {
+		query.Where("key = %s", f.Key)
+	}
+	if f.Status != "" {
+		query.Where("status = %s", f.Status)
+	}
+	if f.SiteId != 0 {
+		query.Where("site_id = %s", f.SiteId)
+	}
+	if f.Open != nil {
+		query.Where("(resolved_at IS NULL) = %s", *f.Open)
+	}
+
+	q, args, limit, err := query.Build(`SELECT `+columns+` FROM alerts`, sortColumns, "id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts, err := r.queryAlerts(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list alerts")
+	}
+
+	page := listing.Page(alerts, limit, func(a entities.Alert) listing.Cursor {
+		return listing.NewCursor(sortValue(a, f.Sort), a.Id)
+	})
+	return &page, nil
+}
+
+func (r *repository) UnresolvedAlerts(ctx context.Context, alertType entities.AlertType) ([]entities.Alert, error) {
+	alerts, err := r.queryAlerts(ctx, `SELECT `+columns+` FROM alerts WHERE type = $1 AND resolved_at IS NULL ORDER BY id`, alertType)
+	return alerts, errors.Wrap(err, "select unresolved alerts")
+}
+
+func (r *repository) SetAlertNotified(ctx context.Context, alertId int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE alerts SET notified_at = $1 WHERE id = $2`, at.Unix(), alertId)
+	return errors.Wrap(err, "set alert notified")
+}
+
+func (r *repository) AcknowledgeAlert(ctx context.Context, alertId, userId int, at time.Time) (*entities.Alert, error) {
+	q := `
+		UPDATE alerts SET status = $1, acknowledged_by = $2, acknowledged_at = $3
+		WHERE id = $4 AND status = $5
+		RETURNING ` + columns
+
+	a, err := scanAlert(r.db.QueryRowContext(ctx, q, entities.AlertAcknowledged, userId, at.Unix(), alertId, entities.AlertOpen))
+	if errors.Is(err, sql.ErrNoRows) {
+		// alert is not found, already acknowledged or resolved
+		stored, err := r.GetAlert(ctx, alertId)
+		if err != nil {
+			return nil, err
+		}
+		if stored.Status == entities.AlertResolved {
+			return nil, errs.ErrAlertResolved
+		}
+		return stored, nil
+	}
+	return a, errors.Wrap(err, "acknowledge alert")
+}
+
+func (r *repository) ResolveAlert(ctx context.Context, alertType entities.AlertType, key string, at time.Time) (*entities.Alert, error) {
+	q := `
+		UPDATE alerts SET status = $1, resolved_at = $2
+		WHERE type = $3 AND key = $4 AND resolved_at IS NULL
+		RETURNING ` + columns
+
+	a, err := scanAlert(r.db.QueryRowContext(ctx, q, entities.AlertResolved, at.Unix(), alertType, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return a, errors.Wrap(err, "resolve alert")
+}
+
+func (r *repository) queryAlerts(ctx context.Context, q string, args ...any) ([]entities.Alert, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make([]entities.Alert, 0)
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan alert")
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, rows.Err()
+}
+
+func sortValue(a entities.Alert, field string) any {
+	switch field {
+	case "created_at":
+		return a.CreatedAt.Unix()
+	case "last_seen_at":
+		return a.LastSeenAt.Unix()
+	}
+	return a.Id
+}
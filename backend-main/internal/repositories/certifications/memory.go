@@ -0,0 +1,148 @@
+package certifications
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/pkg/errors"
+)
+
+type (
+	userGetter interface {
+		GetUserByID(ctx context.Context, userId int) (*entities.User, error)
+	}
+	typeGetter interface {
+		GetMachineTypeById(ctx context.Context, typeId int) (*entities.MachineType, error)
+	}
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Certification,
+// names of users and types in the report are taken from other in-memory repositories
+type memoryRepository struct {
+	mu             sync.RWMutex
+	certifications map[int]entities.Certification
+	lastId         int
+	users          userGetter
+	types          typeGetter
+}
+
+func NewMemoryRepository(users userGetter, types typeGetter) *memoryRepository {
+	return &memoryRepository{certifications: make(map[int]entities.Certification), users: users, types: types}
+}
+
+func (r *memoryRepository) InsertCertification(ctx context.Context, c entities.Certification) (*entities.Certification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastId++
+	c.Id = r.lastId
+	r.certifications[c.Id] = c
+	return &c, nil
+}
+
+func (r *memoryRepository) GetCertification(ctx context.Context, certificationId int) (*entities.Certification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.certifications[certificationId]
+	if !ok {
+		return nil, errs.ErrCertificationNotFound
+	}
+	return &c, nil
+}
+
+func (r *memoryRepository) ListCertifications(ctx context.Context, f entities.CertificationFilter) ([]entities.Certification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]entities.Certification, 0)
+	for _, c := range r.certifications {
+		if (f.UserId == 0 || c.UserId == f.UserId) && (f.TypeId == 0 || c.TypeId == f.TypeId) {
+			list = append(list, c)
+		}
+	}
+
+	if f.SiteId != 0 {
+		atSite := list[:0]
+		for _, c := range list {
+			user, err := r.users.GetUserByID(ctx, c.UserId)
+			if err != nil {
+				return nil, errors.Wrap(err, "get user of certification")
+			}
+			if user.SiteId == f.SiteId {
+				atSite = append(atSite, c)
+			}
+		}
+		list = atSite
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list, nil
+}
+
+func (r *memoryRepository) UpdateCertification(ctx context.Context, c entities.Certification) (*entities.Certification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.certifications[c.Id]
+	if !ok {
+		return nil, errs.ErrCertificationNotFound
+	}
+
+	stored.Number, stored.IssuedAt, stored.ExpiresAt = c.Number, c.IssuedAt, c.ExpiresAt
+	r.certifications[c.Id] = stored
+	return &stored, nil
+}
+
+func (r *memoryRepository) DeleteCertification(ctx context.Context, certificationId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.certifications[certificationId]; !ok {
+		return errs.ErrCertificationNotFound
+	}
+	delete(r.certifications, certificationId)
+	return nil
+}
+
+func (r *memoryRepository) ExpiringCertifications(ctx context.Context, rng entities.ReportRange) ([]entities.ExpiringCertification, error) {
+	list, err := r.ListCertifications(ctx, entities.CertificationFilter{SiteId: rng.SiteId})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := make([]entities.ExpiringCertification, 0)
+	for _, c := range list {
+		if c.ExpiresAt.Before(rng.From) || !c.ExpiresAt.Before(rng.To) {
+			continue
+		}
+
+		user, err := r.users.GetUserByID(ctx, c.UserId)
+		if err != nil {
+			return nil, errors.Wrap(err, "get user of certification")
+		}
+		machineType, err := r.types.GetMachineTypeById(ctx, c.TypeId)
+		if err != nil {
+			return nil, errors.Wrap(err, "get machine type of certification")
+		}
+
+		report = append(report, entities.ExpiringCertification{
+			CertificationId: c.Id,
+			UserId:          c.UserId,
+			UserName:        user.Name,
+			TypeId:          c.TypeId,
+			TypeName:        machineType.Name,
+			Number:          c.Number,
+			ExpiresAt:       c.ExpiresAt,
+			DaysLeft:        entities.DaysUntil(now, c.ExpiresAt),
+		})
+	}
+
+	sort.SliceStable(report, func(i, j int) bool { return report[i].ExpiresAt.Before(report[j].ExpiresAt) })
+	return report, nil
+}
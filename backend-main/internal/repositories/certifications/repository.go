@@ -0,0 +1,142 @@
+package certifications
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+const certificationColumns = `id, user_id, type_id, number, issued_at, expires_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCertification(row scanner) (*entities.Certification, error) {
+	var (
+		c                   entities.Certification
+		issuedAt, expiresAt int64
+	)
+	if err := row.Scan(&c.Id, &c.UserId, &c.TypeId, &c.Number, &issuedAt, &expiresAt); err != nil {
+		return nil, err
+	}
+
+	c.IssuedAt, c.ExpiresAt = time.Unix(issuedAt, 0), time.Unix(expiresAt, 0)
+	return &c, nil
+}
+
+func (r *repository) InsertCertification(ctx context.Context, c entities.Certification) (*entities.Certification, error) {
+	q := `
+		INSERT INTO certifications (user_id, type_id, number, issued_at, expires_at) VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + certificationColumns
+
+	inserted, err := scanCertification(r.db.QueryRowContext(ctx, q, c.UserId, c.TypeId, c.Number, c.IssuedAt.Unix(), c.ExpiresAt.Unix()))
+	return inserted, errors.Wrap(err, "insert certification")
+}
+
+func (r *repository) GetCertification(ctx context.Context, certificationId int) (*entities.Certification, error) {
+	q := `SELECT ` + certificationColumns + ` FROM certifications WHERE id = $1`
+
+	c, err := scanCertification(r.db.QueryRowContext(ctx, q, certificationId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrCertificationNotFound
+	}
+	return c, errors.Wrap(err, "select certification")
+}
+
+func (r *repository) ListCertifications(ctx context.Context, f entities.CertificationFilter) ([]entities.Certification, error) {
+	q := `
+		SELECT ` + certificationColumns + ` FROM certifications
+		WHERE ($1 = 0 OR user_id = $1) AND ($2 = 0 OR type_id = $2)
+			AND ($3 = 0 OR user_id IN (SELECT id FROM users WHERE site_id = $3))
+		ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, q, f.UserId, f.TypeId, f.SiteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "select certifications")
+	}
+	defer rows.Close()
+
+	list := make([]entities.Certification, 0)
+	for rows.Next() {
+		c, err := scanCertification(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan certification")
+		}
+		list = append(list, *c)
+	}
+	return list, errors.Wrap(rows.Err(), "select certifications")
+}
+
+func (r *repository) UpdateCertification(ctx context.Context, c entities.Certification) (*entities.Certification, error) {
+	q := `
+		UPDATE certifications SET number = $1, issued_at = $2, expires_at = $3 WHERE id = $4
+		RETURNING ` + certificationColumns
+
+	updated, err := scanCertification(r.db.QueryRowContext(ctx, q, c.Number, c.IssuedAt.Unix(), c.ExpiresAt.Unix(), c.Id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrCertificationNotFound
+	}
+	return updated, errors.Wrap(err, "update certification")
+}
+
+func (r *repository) DeleteCertification(ctx context.Context, certificationId int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM certifications WHERE id = $1`, certificationId)
+	if err != nil {
+		return errors.Wrap(err, "delete certification")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete certification")
+	}
+	if n == 0 {
+		return errs.ErrCertificationNotFound
+	}
+	return nil
+}
+
+func (r *repository) ExpiringCertifications(ctx context.Context, rng entities.ReportRange) ([]entities.ExpiringCertification, error) {
+	q := `
+		SELECT c.id, c.user_id, u.name, c.type_id, t.name, c.number, c.expires_at
+		FROM certifications c
+		JOIN users u ON u.id = c.user_id
+		JOIN machine_types t ON t.id = c.type_id
+		WHERE c.expires_at >= $1 AND c.expires_at < $2 AND ($3 = 0 OR u.site_id = $3)
+		ORDER BY c.expires_at, c.id`
+
+	rows, err := r.db.QueryContext(ctx, q, rng.From.Unix(), rng.To.Unix(), rng.SiteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "select expiring certifications")
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	list := make([]entities.ExpiringCertification, 0)
+	for rows.Next() {
+		var (
+			c         entities.ExpiringCertification
+			expiresAt int64
+		)
+		if err = rows.Scan(&c.CertificationId, &c.UserId, &c.UserName, &c.TypeId, &c.TypeName, &c.Number, &expiresAt); err != nil {
+			return nil, errors.Wrap(err, "scan expiring certification")
+		}
+		c.ExpiresAt = time.Unix(expiresAt, 0)
+		c.DaysLeft = entities.DaysUntil(now, c.ExpiresAt)
+		list = append(list, c)
+	}
+	return list, errors.Wrap(rows.Err(), "select expiring certifications")
+}
@@ -0,0 +1,112 @@
+package checklists
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Checklist
+type memoryRepository struct {
+	mu               sync.RWMutex
+	items            map[int][]entities.ChecklistItem
+	submissions      map[int]entities.ChecklistSubmission
+	lastItemId       int
+	lastSubmissionId int
+
+	// machines is used to filter submissions by site of machine, like subquery in postgres
+	machines machineGetter
+}
+
+type machineGetter interface {
+	GetMachineByID(ctx context.Context, machineId string) (*entities.Machine, error)
+}
+
+func NewMemoryRepository(machines machineGetter) *memoryRepository {
+	return &memoryRepository{
+		items:       make(map[int][]entities.ChecklistItem),
+		submissions: make(map[int]entities.ChecklistSubmission),
+		machines:    machines,
+	}
+}
+
+func (r *memoryRepository) ListChecklistItems(ctx context.Context, typeId int) ([]entities.ChecklistItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append(make([]entities.ChecklistItem, 0), r.items[typeId]...), nil
+}
+
+func (r *memoryRepository) ReplaceChecklist(ctx context.Context, typeId int, items []entities.ChecklistItem) ([]entities.ChecklistItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := make([]entities.ChecklistItem, 0, len(items))
+	for i, item := range items {
+		r.lastItemId++
+		item.Id, item.TypeId, item.Position = r.lastItemId, typeId, i+1
+		saved = append(saved, item)
+	}
+
+	r.items[typeId] = saved
+	return append(make([]entities.ChecklistItem, 0), saved...), nil
+}
+
+func (r *memoryRepository) InsertChecklistSubmission(ctx context.Context, s entities.ChecklistSubmission) (*entities.ChecklistSubmission, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastSubmissionId++
+	s.Id = r.lastSubmissionId
+	s.SubmittedAt = time.Unix(s.SubmittedAt.Unix(), 0)
+	s.Answers = append(make([]entities.ChecklistAnswer, 0), s.Answers...)
+	sort.SliceStable(s.Answers, func(i, j int) bool { return s.Answers[i].ItemId < s.Answers[j].ItemId })
+
+	r.submissions[s.Id] = s
+	return &s, nil
+}
+
+func (r *memoryRepository) GetChecklistSubmission(ctx context.Context, submissionId int) (*entities.ChecklistSubmission, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.submissions[submissionId]
+	if !ok {
+		return nil, errs.ErrChecklistSubmissionNotFound
+	}
+	return &s, nil
+}
+
+func (r *memoryRepository) ListChecklistSubmissions(ctx context.Context, f entities.ChecklistSubmissionFilter) (*entities.Page[entities.ChecklistSubmission], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	submissions := make([]entities.ChecklistSubmission, 0)
+	for _, s := range r.submissions {
+		if (f.MachineId == "" || s.MachineId == f.MachineId) && (f.UserId == 0 || s.UserId == f.UserId) &&
+			(f.SessionId == 0 || s.SessionId == f.SessionId) && (f.SiteId == 0 || r.machineAtSite(ctx, s.MachineId, f.SiteId)) {
+			submissions = append(submissions, s)
+		}
+	}
+	r.mu.RUnlock()
+
+	page, err := listing.Memory(submissions, f.ListParams, func(s entities.ChecklistSubmission) (any, any) {
+		return sortValue(s, f.Sort), s.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) machineAtSite(ctx context.Context, machineId string, siteId int) bool {
+	machine, err := r.machines.GetMachineByID(ctx, machineId)
+	return err == nil && machine.SiteId == siteId
+}
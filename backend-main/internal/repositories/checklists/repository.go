@@ -0,0 +1,204 @@
+package checklists
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+var sortColumns = map[string]string{
+	"id":           "id",
+	"submitted_at": "submitted_at",
+}
+
+const submissionColumns = `id, machine_id, user_id, session_id, passed, submitted_at`
+
+func (r *repository) ListChecklistItems(ctx context.Context, typeId int) ([]entities.ChecklistItem, error) {
+	q := `SELECT id, type_id, position, text, critical FROM checklist_items WHERE type_id = $1 ORDER BY position, id`
+
+	rows, err := r.db.QueryContext(ctx, q, typeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "select checklist items")
+	}
+	defer rows.Close()
+
+	items := make([]entities.ChecklistItem, 0)
+	for rows.Next() {
+		var item entities.ChecklistItem
+		if err = rows.Scan(&item.Id, &item.TypeId, &item.Position, &item.Text, &item.Critical); err != nil {
+			return nil, errors.Wrap(err, "scan checklist item")
+		}
+		items = append(items, item)
+	}
+	return items, errors.Wrap(rows.Err(), "select checklist items")
+}
+
+func (r *repository) ReplaceChecklist(ctx context.Context, typeId int, items []entities.ChecklistItem) ([]entities.ChecklistItem, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM checklist_items WHERE type_id = $1`, typeId); err != nil {
+		return nil, errors.Wrap(err, "delete checklist items")
+	}
+
+	saved := make([]entities.ChecklistItem, 0, len(items))
+	for i, item := range items {
+		item.TypeId, item.Position = typeId, i+1
+
+		q := `INSERT INTO checklist_items (type_id, position, text, critical) VALUES ($1, $2, $3, $4) RETURNING id`
+		if err = tx.QueryRowContext(ctx, q, item.TypeId, item.Position, item.Text, item.Critical).Scan(&item.Id); err != nil {
+			return nil, errors.Wrap(err, "insert checklist item")
+		}
+		saved = append(saved, item)
+	}
+	return saved, errors.Wrap(tx.Commit(), "commit checklist")
+}
+
+func (r *repository) InsertChecklistSubmission(ctx context.Context, s entities.ChecklistSubmission) (*entities.ChecklistSubmission, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO checklist_submissions (machine_id, user_id, session_id, passed, submitted_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	if err = tx.QueryRowContext(ctx, q, s.MachineId, s.UserId, s.SessionId, s.Passed, s.SubmittedAt.Unix()).Scan(&s.Id); err != nil {
+		return nil, errors.Wrap(err, "insert checklist submission")
+	}
+
+	for _, a := range s.Answers {
+		q = `INSERT INTO checklist_answers (submission_id, item_id, text, critical, ok, comment) VALUES ($1, $2, $3, $4, $5, $6)`
+		if _, err = tx.ExecContext(ctx, q, s.Id, a.ItemId, a.Text, a.Critical, a.Ok, a.Comment); err != nil {
+			return nil, errors.Wrap(err, "insert checklist answer")
+		}
+	}
+
+	s.SubmittedAt = time.Unix(s.SubmittedAt.Unix(), 0)
+	return &s, errors.Wrap(tx.Commit(), "commit checklist submission")
+}
+
+func (r *repository) GetChecklistSubmission(ctx context.Context, submissionId int) (*entities.ChecklistSubmission, error) {
+	submissions, err := r.selectSubmissions(ctx, `SELECT `+submissionColumns+` FROM checklist_submissions WHERE id = $1`, submissionId)
+	if err != nil {
+		return nil, errors.Wrap(err, "select checklist submission")
+	}
+	if len(submissions) == 0 {
+		return nil, errs.ErrChecklistSubmissionNotFound
+	}
+	return &submissions[0], nil
+}
+
+func (r *repository) ListChecklistSubmissions(ctx context.Context, f entities.ChecklistSubmissionFilter) (*entities.Page[entities.ChecklistSubmission], error) {
+	var query listing.Query
+
+	if f.MachineId != "" {
+		query.Where("machine_id = %s", f.MachineId)
+	}
+	if f.UserId != 0 {
+		query.Where("user_id = %s", f.UserId)
+	}
+	if f.SessionId != 0 {
+		query.Where("session_id = %s", f.SessionId)
+	}
+	if f.SiteId != 0 {
+		query.Where("machine_id IN (SELECT id FROM machines WHERE site_id = %s)", f.SiteId)
+	}
+
+	q, args, limit, err := query.Build(`SELECT `+submissionColumns+` FROM checklist_submissions`, sortColumns, "id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	submissions, err := r.selectSubmissions(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list checklist submissions")
+	}
+
+	page := listing.Page(submissions, limit, func(s entities.ChecklistSubmission) listing.Cursor {
+		return listing.NewCursor(sortValue(s, f.Sort), s.Id)
+	})
+	return &page, nil
+}
+
+// selectSubmissions selects submissions with their answers
+func (r *repository) selectSubmissions(ctx context.Context, q string, args ...any) ([]entities.ChecklistSubmission, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	submissions := make([]entities.ChecklistSubmission, 0)
+	for rows.Next() {
+		var (
+			s           entities.ChecklistSubmission
+			submittedAt int64
+		)
+		if err = rows.Scan(&s.Id, &s.MachineId, &s.UserId, &s.SessionId, &s.Passed, &submittedAt); err != nil {
+			return nil, errors.Wrap(err, "scan checklist submission")
+		}
+		s.SubmittedAt = time.Unix(submittedAt, 0)
+		s.Answers = make([]entities.ChecklistAnswer, 0)
+		submissions = append(submissions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(submissions) == 0 {
+		return submissions, nil
+	}
+
+	ids := make([]int64, 0, len(submissions))
+	index := make(map[int]int, len(submissions))
+	for i, s := range submissions {
+		ids = append(ids, int64(s.Id))
+		index[s.Id] = i
+	}
+
+	q = `
+		SELECT submission_id, item_id, text, critical, ok, comment FROM checklist_answers
+		WHERE submission_id = ANY($1) ORDER BY submission_id, item_id`
+	answers, err := r.db.QueryContext(ctx, q, pq.Array(ids))
+	if err != nil {
+		return nil, errors.Wrap(err, "select checklist answers")
+	}
+	defer answers.Close()
+
+	for answers.Next() {
+		var (
+			submissionId int
+			a            entities.ChecklistAnswer
+		)
+		if err = answers.Scan(&submissionId, &a.ItemId, &a.Text, &a.Critical, &a.Ok, &a.Comment); err != nil {
+			return nil, errors.Wrap(err, "scan checklist answer")
+		}
+
+		i := index[submissionId]
+		submissions[i].Answers = append(submissions[i].Answers, a)
+	}
+	return submissions, errors.Wrap(answers.Err(), "select checklist answers")
+}
+
+func sortValue(s entities.ChecklistSubmission, field string) any {
+	if field == "submitted_at" {
+		return s.SubmittedAt.Unix()
+	}
+	return s.Id
+}
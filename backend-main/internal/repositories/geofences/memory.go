@@ -0,0 +1,147 @@
+package geofences
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Geofence
+type memoryRepository struct {
+	mu          sync.RWMutex
+	geofences   map[int]entities.Geofence
+	alerts      map[int]entities.GeofenceAlert
+	lastId      int
+	lastAlertId int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		geofences: make(map[int]entities.Geofence),
+		alerts:    make(map[int]entities.GeofenceAlert),
+	}
+}
+
+func (r *memoryRepository) ListGeofences(ctx context.Context, siteId int) ([]entities.Geofence, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	geofences := make([]entities.Geofence, 0)
+	for _, g := range r.geofences {
+		if siteId == 0 || g.SiteId == siteId {
+			geofences = append(geofences, g)
+		}
+	}
+	sort.Slice(geofences, func(i, j int) bool { return geofences[i].Id < geofences[j].Id })
+	return geofences, nil
+}
+
+func (r *memoryRepository) GetGeofence(ctx context.Context, geofenceId int) (*entities.Geofence, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	g, ok := r.geofences[geofenceId]
+	if !ok {
+		return nil, errs.ErrGeofenceNotFound
+	}
+	return &g, nil
+}
+
+func (r *memoryRepository) InsertGeofence(ctx context.Context, g entities.Geofence) (*entities.Geofence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastId++
+	g.Id = r.lastId
+	g.Bssids = slices.Clone(g.Bssids)
+	r.geofences[g.Id] = g
+	return &g, nil
+}
+
+func (r *memoryRepository) UpdateGeofence(ctx context.Context, g entities.Geofence) (*entities.Geofence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.geofences[g.Id]
+	if !ok {
+		return nil, errs.ErrGeofenceNotFound
+	}
+	stored.Name, stored.Kind, stored.Bssids = g.Name, g.Kind, slices.Clone(g.Bssids)
+	r.geofences[g.Id] = stored
+	return &stored, nil
+}
+
+func (r *memoryRepository) DeleteGeofence(ctx context.Context, geofenceId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.geofences[geofenceId]; !ok {
+		return errs.ErrGeofenceNotFound
+	}
+	delete(r.geofences, geofenceId)
+	return nil
+}
+
+func (r *memoryRepository) InsertGeofenceAlert(ctx context.Context, a entities.GeofenceAlert) (*entities.GeofenceAlert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.alerts {
+		if stored.MachineId == a.MachineId && stored.ResolvedAt == nil {
+			return nil, errs.ErrAlreadyExists
+		}
+	}
+
+	r.lastAlertId++
+	a.Id = r.lastAlertId
+	a.CreatedAt = time.Unix(a.CreatedAt.Unix(), 0)
+	a.ResolvedAt = nil
+	r.alerts[a.Id] = a
+	return &a, nil
+}
+
+func (r *memoryRepository) ListGeofenceAlerts(ctx context.Context, f entities.GeofenceAlertFilter) (*entities.Page[entities.GeofenceAlert], error) {
+	if _, err := listing.Column(alertSortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	alerts := make([]entities.GeofenceAlert, 0)
+	for _, a := range r.alerts {
+		if (f.MachineId == "" || a.MachineId == f.MachineId) && (f.SessionId == 0 || a.SessionId == f.SessionId) &&
+			(f.SiteId == 0 || a.SiteId == f.SiteId) && (f.Open == nil || *f.Open == (a.ResolvedAt == nil)) {
+			alerts = append(alerts, a)
+		}
+	}
+	r.mu.RUnlock()
+
+	page, err := listing.Memory(alerts, f.ListParams, func(a entities.GeofenceAlert) (any, any) {
+		return alertSortValue(a, f.Sort), a.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) ResolveGeofenceAlerts(ctx context.Context, machineId string, at time.Time) ([]entities.GeofenceAlert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resolvedAt := time.Unix(at.Unix(), 0)
+	resolved := make([]entities.GeofenceAlert, 0)
+	for id, a := range r.alerts {
+		if a.MachineId == machineId && a.ResolvedAt == nil {
+			a.ResolvedAt = &resolvedAt
+			r.alerts[id] = a
+			resolved = append(resolved, a)
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,213 @@
+package geofences
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+var alertSortColumns = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+}
+
+const (
+	geofenceColumns = `id, name, kind, bssids, site_id`
+	alertColumns    = `id, machine_id, session_id, worker_id, site_id, geofence_id, kind, bssid, paused, created_at, resolved_at`
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanGeofence(row scanner) (*entities.Geofence, error) {
+	var (
+		g      entities.Geofence
+		bssids pq.StringArray
+	)
+	if err := row.Scan(&g.Id, &g.Name, &g.Kind, &bssids, &g.SiteId); err != nil {
+		return nil, err
+	}
+	g.Bssids = []string(bssids)
+	return &g, nil
+}
+
+func scanAlert(row scanner) (*entities.GeofenceAlert, error) {
+	var (
+		a          entities.GeofenceAlert
+		createdAt  int64
+		resolvedAt sql.NullInt64
+	)
+	err := row.Scan(&a.Id, &a.MachineId, &a.SessionId, &a.WorkerId, &a.SiteId, &a.GeofenceId, &a.Kind, &a.Bssid, &a.Paused,
+		&createdAt, &resolvedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	a.CreatedAt = time.Unix(createdAt, 0)
+	if resolvedAt.Valid {
+		t := time.Unix(resolvedAt.Int64, 0)
+		a.ResolvedAt = &t
+	}
+	return &a, nil
+}
+
+func (r *repository) ListGeofences(ctx context.Context, siteId int) ([]entities.Geofence, error) {
+	q := `SELECT ` + geofenceColumns + ` FROM geofences WHERE $1 = 0 OR site_id = $1 ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, q, siteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "list geofences")
+	}
+	defer rows.Close()
+
+	geofences := make([]entities.Geofence, 0)
+	for rows.Next() {
+		g, err := scanGeofence(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan geofence")
+		}
+		geofences = append(geofences, *g)
+	}
+	return geofences, errors.Wrap(rows.Err(), "list geofences")
+}
+
+func (r *repository) GetGeofence(ctx context.Context, geofenceId int) (*entities.Geofence, error) {
+	g, err := scanGeofence(r.db.QueryRowContext(ctx, `SELECT `+geofenceColumns+` FROM geofences WHERE id = $1`, geofenceId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrGeofenceNotFound
+	}
+	return g, errors.Wrap(err, "select geofence")
+}
+
+func (r *repository) InsertGeofence(ctx context.Context, g entities.Geofence) (*entities.Geofence, error) {
+	q := `INSERT INTO geofences (name, kind, bssids, site_id) VALUES ($1, $2, $3, $4) RETURNING ` + geofenceColumns
+
+	inserted, err := scanGeofence(r.db.QueryRowContext(ctx, q, g.Name, g.Kind, pq.Array(g.Bssids), g.SiteId))
+	return inserted, errors.Wrap(err, "insert geofence")
+}
+
+func (r *repository) UpdateGeofence(ctx context.Context, g entities.Geofence) (*entities.Geofence, error) {
+	q := `UPDATE geofences SET name = $1, kind = $2, bssids = $3 WHERE id = $4 RETURNING ` + geofenceColumns
+
+	updated, err := scanGeofence(r.db.QueryRowContext(ctx, q, g.Name, g.Kind, pq.Array(g.Bssids), g.Id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrGeofenceNotFound
+	}
+	return updated, errors.Wrap(err, "update geofence")
+}
+
+func (r *repository) DeleteGeofence(ctx context.Context, geofenceId int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM geofences WHERE id = $1`, geofenceId)
+	if err != nil {
+		return errors.Wrap(err, "delete geofence")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete geofence")
+	}
+	if n == 0 {
+		return errs.ErrGeofenceNotFound
+	}
+	return nil
+}
+
+// InsertGeofenceAlert relies on unique index of unresolved alerts, errs.ErrAlreadyExists is returned for the second one
+func (r *repository) InsertGeofenceAlert(ctx context.Context, a entities.GeofenceAlert) (*entities.GeofenceAlert, error) {
+	q := `
+		INSERT INTO geofence_alerts (machine_id, session_id, worker_id, site_id, geofence_id, kind, bssid, paused, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + alertColumns
+
+	inserted, err := scanAlert(r.db.QueryRowContext(ctx, q, a.MachineId, a.SessionId, a.WorkerId, a.SiteId, a.GeofenceId,
+		a.Kind, a.Bssid, a.Paused, a.CreatedAt.Unix()))
+	return inserted, errors.Wrap(errs.FromSQL(err, nil), "insert geofence alert")
+}
+
+func (r *repository) ListGeofenceAlerts(ctx context.Context, f entities.GeofenceAlertFilter) (*entities.Page[entities.GeofenceAlert], error) {
+	var query listing.Query
+
+	if f.MachineId != "" {
+		query.Where("machine_id = %s", f.MachineId)
+	}
+	if f.SessionId != 0 {
+		query.Where("session_id = %s", f.SessionId)
+	}
+	if f.SiteId != 0 {
+		query.Where("site_id = %s", f.SiteId)
+	}
+	if f.Open != nil {
+		query.Where("(resolved_at IS NULL) = %s", *f.Open)
+	}
+
+	q, args, limit, err := query.Build(`SELECT `+alertColumns+` FROM geofence_alerts`, alertSortColumns, "id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list geofence alerts")
+	}
+	defer rows.Close()
+
+	alerts := make([]entities.GeofenceAlert, 0)
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan geofence alert")
+		}
+		alerts = append(alerts, *a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "list geofence alerts")
+	}
+
+	page := listing.Page(alerts, limit, func(a entities.GeofenceAlert) listing.Cursor {
+		return listing.NewCursor(alertSortValue(a, f.Sort), a.Id)
+	})
+	return &page, nil
+}
+
+func (r *repository) ResolveGeofenceAlerts(ctx context.Context, machineId string, at time.Time) ([]entities.GeofenceAlert, error) {
+	q := `UPDATE geofence_alerts SET resolved_at = $1 WHERE machine_id = $2 AND resolved_at IS NULL RETURNING ` + alertColumns
+
+	rows, err := r.db.QueryContext(ctx, q, at.Unix(), machineId)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve geofence alerts")
+	}
+	defer rows.Close()
+
+	alerts := make([]entities.GeofenceAlert, 0)
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan geofence alert")
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, errors.Wrap(rows.Err(), "resolve geofence alerts")
+}
+
+func alertSortValue(a entities.GeofenceAlert, field string) any {
+	if field == "created_at" {
+		return a.CreatedAt.Unix()
+	}
+	return a.Id
+}
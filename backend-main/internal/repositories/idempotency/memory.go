@@ -0,0 +1,72 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+type recordKey struct {
+	userId int
+	key    string
+}
+
+// memoryRepository is a thread-safe in-memory implementation of service.Idempotency
+type memoryRepository struct {
+	mu      sync.Mutex
+	records map[recordKey]entities.IdempotencyRecord
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{records: make(map[recordKey]entities.IdempotencyRecord)}
+}
+
+func (r *memoryRepository) ReserveKey(ctx context.Context, userId int, key, requestHash string, expired, abandoned time.Time) (*entities.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, record := range r.records {
+		if record.CreatedAt.Before(expired) || (!record.Completed() && record.CreatedAt.Before(abandoned)) {
+			delete(r.records, k)
+		}
+	}
+
+	k := recordKey{userId: userId, key: key}
+	if record, ok := r.records[k]; ok {
+		return &record, false, nil
+	}
+
+	record := entities.IdempotencyRecord{
+		UserId:      userId,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   time.Unix(time.Now().Unix(), 0),
+	}
+	r.records[k] = record
+	return &record, true, nil
+}
+
+func (r *memoryRepository) CompleteKey(ctx context.Context, userId int, key string, status int, contentType string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := recordKey{userId: userId, key: key}
+	if record, ok := r.records[k]; ok {
+		record.Status, record.ContentType, record.Body = status, contentType, body
+		r.records[k] = record
+	}
+	return nil
+}
+
+func (r *memoryRepository) ReleaseKey(ctx context.Context, userId int, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := recordKey{userId: userId, key: key}
+	if record, ok := r.records[k]; ok && !record.Completed() {
+		delete(r.records, k)
+	}
+	return nil
+}
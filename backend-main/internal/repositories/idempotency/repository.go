@@ -0,0 +1,70 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+func (r *repository) ReserveKey(ctx context.Context, userId int, key, requestHash string, expired, abandoned time.Time) (*entities.IdempotencyRecord, bool, error) {
+	q := `DELETE FROM idempotency_keys WHERE created_at < $1 OR (status = 0 AND created_at < $2)`
+	if _, err := r.db.ExecContext(ctx, q, expired.Unix(), abandoned.Unix()); err != nil {
+		return nil, false, errors.Wrap(err, "delete expired idempotency keys")
+	}
+
+	now := time.Now()
+	q = `
+		INSERT INTO idempotency_keys (user_id, key, request_hash, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO NOTHING`
+	res, err := r.db.ExecContext(ctx, q, userId, key, requestHash, now.Unix())
+	if err != nil {
+		return nil, false, errors.Wrap(err, "insert idempotency key")
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, false, errors.Wrap(err, "insert idempotency key")
+	} else if n == 1 {
+		return &entities.IdempotencyRecord{UserId: userId, Key: key, RequestHash: requestHash, CreatedAt: time.Unix(now.Unix(), 0)}, true, nil
+	}
+
+	var (
+		record    entities.IdempotencyRecord
+		createdAt int64
+	)
+	q = `SELECT request_hash, status, content_type, body, created_at FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+	err = r.db.QueryRowContext(ctx, q, userId, key).Scan(&record.RequestHash, &record.Status, &record.ContentType, &record.Body, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// completed request has just been released, so the key is free again
+		return r.ReserveKey(ctx, userId, key, requestHash, expired, abandoned)
+	}
+	if err != nil {
+		return nil, false, errors.Wrap(err, "select idempotency key")
+	}
+
+	record.UserId, record.Key, record.CreatedAt = userId, key, time.Unix(createdAt, 0)
+	return &record, false, nil
+}
+
+func (r *repository) CompleteKey(ctx context.Context, userId int, key string, status int, contentType string, body []byte) error {
+	q := `UPDATE idempotency_keys SET status = $1, content_type = $2, body = $3 WHERE user_id = $4 AND key = $5`
+	_, err := r.db.ExecContext(ctx, q, status, contentType, body, userId, key)
+	return errors.Wrap(err, "complete idempotency key")
+}
+
+func (r *repository) ReleaseKey(ctx context.Context, userId int, key string) error {
+	q := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND status = 0`
+	_, err := r.db.ExecContext(ctx, q, userId, key)
+	return errors.Wrap(err, "release idempotency key")
+}
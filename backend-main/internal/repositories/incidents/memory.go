@@ -0,0 +1,117 @@
+package incidents
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Incident
+type memoryRepository struct {
+	mu        sync.RWMutex
+	incidents map[int]entities.Incident
+	lastId    int
+
+	// machines is used to filter incidents by site of machine, like subquery in postgres
+	machines machineGetter
+}
+
+type machineGetter interface {
+	GetMachineByID(ctx context.Context, machineId string) (*entities.Machine, error)
+}
+
+func NewMemoryRepository(machines machineGetter) *memoryRepository {
+	return &memoryRepository{incidents: make(map[int]entities.Incident), machines: machines}
+}
+
+func (r *memoryRepository) InsertIncident(ctx context.Context, i entities.Incident) (*entities.Incident, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastId++
+	i.Id = r.lastId
+	i.CreatedAt = time.Unix(i.CreatedAt.Unix(), 0)
+	i.UpdatedAt = i.CreatedAt
+	r.incidents[i.Id] = i
+	return &i, nil
+}
+
+func (r *memoryRepository) GetIncident(ctx context.Context, incidentId int) (*entities.Incident, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.incidents[incidentId]
+	if !ok {
+		return nil, errs.ErrIncidentNotFound
+	}
+	return &i, nil
+}
+
+func (r *memoryRepository) ListIncidents(ctx context.Context, f entities.IncidentFilter) (*entities.Page[entities.Incident], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	incidents := make([]entities.Incident, 0)
+	for _, i := range r.incidents {
+		if (f.MachineId == "" || i.MachineId == f.MachineId) && (f.ReporterId == 0 || i.ReporterId == f.ReporterId) &&
+			(f.Status == "" || i.Status == f.Status) && (f.Severity == "" || i.Severity == f.Severity) &&
+			(f.Category == "" || i.Category == f.Category) && (f.SiteId == 0 || r.machineAtSite(ctx, i.MachineId, f.SiteId)) {
+			incidents = append(incidents, i)
+		}
+	}
+	r.mu.RUnlock()
+
+	page, err := listing.Memory(incidents, f.ListParams, func(i entities.Incident) (any, any) {
+		return sortValue(i, f.Sort), i.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) UpdateIncident(ctx context.Context, i entities.Incident) (*entities.Incident, error) {
+	return r.update(i.Id, func(stored *entities.Incident) {
+		stored.Severity, stored.Status, stored.Resolution, stored.TriagedBy = i.Severity, i.Status, i.Resolution, i.TriagedBy
+		stored.UpdatedAt = time.Unix(i.UpdatedAt.Unix(), 0)
+		stored.ClosedAt = nil
+		if i.ClosedAt != nil {
+			t := time.Unix(i.ClosedAt.Unix(), 0)
+			stored.ClosedAt = &t
+		}
+	})
+}
+
+func (r *memoryRepository) SetIncidentPhoto(ctx context.Context, incidentId int, key, contentType string) (*entities.Incident, string, error) {
+	var previous string
+	updated, err := r.update(incidentId, func(stored *entities.Incident) {
+		previous = stored.PhotoKey
+		stored.PhotoKey, stored.PhotoContentType = key, contentType
+		stored.UpdatedAt = time.Unix(time.Now().Unix(), 0)
+	})
+	return updated, previous, err
+}
+
+func (r *memoryRepository) update(incidentId int, apply func(i *entities.Incident)) (*entities.Incident, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.incidents[incidentId]
+	if !ok {
+		return nil, errs.ErrIncidentNotFound
+	}
+	apply(&i)
+	r.incidents[incidentId] = i
+	return &i, nil
+}
+
+func (r *memoryRepository) machineAtSite(ctx context.Context, machineId string, siteId int) bool {
+	machine, err := r.machines.GetMachineByID(ctx, machineId)
+	return err == nil && machine.SiteId == siteId
+}
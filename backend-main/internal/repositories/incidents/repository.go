@@ -0,0 +1,186 @@
+package incidents
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+var sortColumns = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+const incidentColumns = `id, machine_id, session_id, reporter_id, category, severity, description, status, resolution,
+	triaged_by, photo_key, photo_content_type, created_at, updated_at, closed_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// withExtra scans columns which follow columns of incident into extra
+type withExtra struct {
+	row   scanner
+	extra []any
+}
+
+func (s withExtra) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+func scanIncident(row scanner) (*entities.Incident, error) {
+	var (
+		i                    entities.Incident
+		createdAt, updatedAt int64
+		closedAt             sql.NullInt64
+	)
+	err := row.Scan(&i.Id, &i.MachineId, &i.SessionId, &i.ReporterId, &i.Category, &i.Severity, &i.Description, &i.Status,
+		&i.Resolution, &i.TriagedBy, &i.PhotoKey, &i.PhotoContentType, &createdAt, &updatedAt, &closedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	i.CreatedAt, i.UpdatedAt = time.Unix(createdAt, 0), time.Unix(updatedAt, 0)
+	if closedAt.Valid {
+		t := time.Unix(closedAt.Int64, 0)
+		i.ClosedAt = &t
+	}
+	return &i, nil
+}
+
+func (r *repository) InsertIncident(ctx context.Context, i entities.Incident) (*entities.Incident, error) {
+	q := `
+		INSERT INTO incidents (machine_id, session_id, reporter_id, category, severity, description, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		RETURNING ` + incidentColumns
+
+	inserted, err := scanIncident(r.db.QueryRowContext(ctx, q, i.MachineId, i.SessionId, i.ReporterId, i.Category, i.Severity,
+		i.Description, i.Status, i.CreatedAt.Unix()))
+	return inserted, errors.Wrap(err, "insert incident")
+}
+
+func (r *repository) GetIncident(ctx context.Context, incidentId int) (*entities.Incident, error) {
+	q := `SELECT ` + incidentColumns + ` FROM incidents WHERE id = $1`
+
+	i, err := scanIncident(r.db.QueryRowContext(ctx, q, incidentId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrIncidentNotFound
+	}
+	return i, errors.Wrap(err, "select incident")
+}
+
+func (r *repository) ListIncidents(ctx context.Context, f entities.IncidentFilter) (*entities.Page[entities.Incident], error) {
+	var query listing.Query
+
+	if f.MachineId != "" {
+		query.Where("machine_id = %s", f.MachineId)
+	}
+	if f.ReporterId != 0 {
+		query.Where("reporter_id = %s", f.ReporterId)
+	}
+	if f.Status != "" {
+		query.Where("status = %s", f.Status)
+	}
+	if f.Severity != "" {
+		query.Where("severity = %s", f.Severity)
+	}
+	if f.Category != "" {
+		query.Where("category = %s", f.Category)
+	}
+	if f.SiteId != 0 {
+		query.Where("machine_id IN (SELECT id FROM machines WHERE site_id = %s)", f.SiteId)
+	}
+
+	q, args, limit, err := query.Build(`SELECT `+incidentColumns+` FROM incidents`, sortColumns, "id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list incidents")
+	}
+	defer rows.Close()
+
+	incidents := make([]entities.Incident, 0)
+	for rows.Next() {
+		i, err := scanIncident(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan incident")
+		}
+		incidents = append(incidents, *i)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "list incidents")
+	}
+
+	page := listing.Page(incidents, limit, func(i entities.Incident) listing.Cursor {
+		return listing.NewCursor(sortValue(i, f.Sort), i.Id)
+	})
+	return &page, nil
+}
+
+func (r *repository) UpdateIncident(ctx context.Context, i entities.Incident) (*entities.Incident, error) {
+	var closedAt sql.NullInt64
+	if i.ClosedAt != nil {
+		closedAt = sql.NullInt64{Int64: i.ClosedAt.Unix(), Valid: true}
+	}
+
+	q := `
+		UPDATE incidents SET severity = $1, status = $2, resolution = $3, triaged_by = $4, updated_at = $5, closed_at = $6
+		WHERE id = $7
+		RETURNING ` + incidentColumns
+
+	updated, err := scanIncident(r.db.QueryRowContext(ctx, q, i.Severity, i.Status, i.Resolution, i.TriagedBy,
+		i.UpdatedAt.Unix(), closedAt, i.Id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrIncidentNotFound
+	}
+	return updated, errors.Wrap(err, "update incident")
+}
+
+// SetIncidentPhoto locks the row to read replaced key, so concurrent uploads get different previous keys
+func (r *repository) SetIncidentPhoto(ctx context.Context, incidentId int, key, contentType string) (*entities.Incident, string, error) {
+	q := `
+		WITH previous AS (SELECT id AS previous_id, photo_key AS previous_key FROM incidents WHERE id = $4 FOR UPDATE)
+		UPDATE incidents SET photo_key = $1, photo_content_type = $2, updated_at = $3
+		FROM previous WHERE id = previous_id
+		RETURNING ` + incidentColumns + `, previous_key`
+
+	var previous string
+	row := r.db.QueryRowContext(ctx, q, key, contentType, time.Now().Unix(), incidentId)
+	updated, err := scanIncident(withExtra{row: row, extra: []any{&previous}})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", errs.ErrIncidentNotFound
+	}
+	if err != nil {
+		return nil, "", errors.Wrap(err, "update incident photo")
+	}
+	return updated, previous, nil
+}
+
+func sortValue(i entities.Incident, field string) any {
+	switch field {
+	case "created_at":
+		return i.CreatedAt.Unix()
+	case "updated_at":
+		return i.UpdatedAt.Unix()
+	default:
+		return i.Id
+	}
+}
@@ -0,0 +1,254 @@
+// Package listing implements keyset (cursor) pagination shared by repositories.
+// Rows are ordered by sort column and by id as tie-breaker, cursor stores both
+// values of the last row of page, so next page starts right after it even if
+// new rows were inserted in between.
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+)
+
+// Cursor is position of the last row of page
+type Cursor struct {
+	Value string `json:"v"`
+	Id    string `json:"id"`
+}
+
+// NewCursor makes cursor from sort column value and id of row
+func NewCursor(value, id any) Cursor {
+	return Cursor{Value: fmt.Sprint(value), Id: fmt.Sprint(id)}
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses cursor from query, empty string means first page
+func Decode(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errs.ErrInvalidRequest.WithMessage("invalid cursor")
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, errs.ErrInvalidRequest.WithMessage("invalid cursor")
+	}
+	return &c, nil
+}
+
+// Limit returns page size with defaults applied
+func Limit(limit int) int {
+	if limit <= 0 {
+		return entities.DefaultPageLimit
+	}
+	return min(limit, entities.MaxPageLimit)
+}
+
+// Column returns sql column for sort field, columns maps fields to columns
+func Column(columns map[string]string, field string) (string, error) {
+	if field == "" {
+		field = "id"
+	}
+
+	column, ok := columns[field]
+	if !ok {
+		return "", errs.ErrInvalidRequest.WithMessage("unknown sort field: " + field)
+	}
+	return column, nil
+}
+
+// Keyset returns sql condition which selects rows after cursor and ORDER BY clause.
+// Arguments of condition are numbered from argN. Condition is empty without cursor.
+func Keyset(column, idColumn string, desc bool, cursor *Cursor, argN int) (cond string, args []any, order string) {
+	cmp, dir := ">", "ASC"
+	if desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	order = fmt.Sprintf("ORDER BY %s %s, %s %s", column, dir, idColumn, dir)
+	if cursor == nil {
+		return "", nil, order
+	}
+
+	if column == idColumn {
+		return fmt.Sprintf("%s %s $%d", idColumn, cmp, argN), []any{cursor.Id}, order
+	}
+	cond = fmt.Sprintf("(%s, %s) %s ($%d, $%d)", column, idColumn, cmp, argN, argN+1)
+	return cond, []any{cursor.Value, cursor.Id}, order
+}
+
+// Query collects filter conditions of sql query and their arguments
+type Query struct {
+	conds []string
+	args  []any
+}
+
+// Where adds condition, %s in cond is replaced with placeholder of value
+func (q *Query) Where(cond string, value any) {
+	q.args = append(q.args, value)
+	q.conds = append(q.conds, fmt.Sprintf(cond, fmt.Sprintf("$%d", len(q.args))))
+}
+
+// Build returns sql query selecting one page of rows with LIMIT limit+1 and its arguments.
+// selectFrom is the query without WHERE clause, sort field is mapped to column with columns.
+func (q *Query) Build(selectFrom string, columns map[string]string, idColumn string, params entities.ListParams) (sql string, args []any, limit int, err error) {
+	column, err := Column(columns, params.Sort)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	cursor, err := Decode(params.Cursor)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	conds, args := q.conds, q.args
+	keyset, keysetArgs, order := Keyset(column, idColumn, params.Desc, cursor, len(args)+1)
+	if keyset != "" {
+		conds = append(conds, keyset)
+		args = append(args, keysetArgs...)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	limit = Limit(params.Limit)
+	return fmt.Sprintf("%s %s %s LIMIT %d", selectFrom, where, order, limit+1), args, limit, nil
+}
+
+// Page makes page from rows selected with LIMIT limit+1.
+// key returns cursor of row, it is used for the last row of page.
+func Page[T any](rows []T, limit int, key func(T) Cursor) entities.Page[T] {
+	if len(rows) <= limit {
+		return entities.Page[T]{Items: rows}
+	}
+
+	rows = rows[:limit]
+	return entities.Page[T]{Items: rows, NextCursor: key(rows[len(rows)-1]).Encode()}
+}
+
+// Memory sorts items in the same order as Keyset does and returns page after cursor.
+// key returns value of sort field and id of item, they should be integer or string.
+func Memory[T any](items []T, params entities.ListParams, key func(item T) (value, id any)) (entities.Page[T], error) {
+	cursor, err := Decode(params.Cursor)
+	if err != nil {
+		return entities.Page[T]{}, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		vi, idi := key(items[i])
+		vj, idj := key(items[j])
+
+		c := compare(vi, vj)
+		if c == 0 {
+			c = compare(idi, idj)
+		}
+		if params.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	start := 0
+	if cursor != nil {
+		start = len(items)
+		for i, item := range items {
+			value, id := key(item)
+
+			c := compare(value, parseAs(value, cursor.Value))
+			if c == 0 {
+				c = compare(id, parseAs(id, cursor.Id))
+			}
+			if (params.Desc && c < 0) || (!params.Desc && c > 0) {
+				start = i
+				break
+			}
+		}
+	}
+
+	limit := Limit(params.Limit)
+	end := min(start+limit+1, len(items))
+
+	return Page(items[start:end], limit, func(item T) Cursor {
+		return NewCursor(key(item))
+	}), nil
+}
+
+// parseAs converts cursor value to type of item value
+func parseAs(like any, s string) any {
+	switch like.(type) {
+	case int, int64:
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	default:
+		return s
+	}
+}
+
+func compare(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return compareInt(int64(a), toInt(b))
+	case int64:
+		return compareInt(a, toInt(b))
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
+
+func toInt(v any) int64 {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	default:
+		return 0
+	}
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// All collects items of all pages of list
+func All[T any](list func(params entities.ListParams) (*entities.Page[T], error)) ([]T, error) {
+	items := make([]T, 0)
+	params := entities.ListParams{Limit: entities.MaxPageLimit}
+
+	for {
+		page, err := list(params)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+
+		if page.NextCursor == "" {
+			return items, nil
+		}
+		params.Cursor = page.NextCursor
+	}
+}
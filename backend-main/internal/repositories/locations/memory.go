@@ -0,0 +1,92 @@
+package locations
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Location
+type memoryRepository struct {
+	mu        sync.RWMutex
+	sightings []entities.Sighting
+	last      map[string]entities.Sighting
+	lastId    int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{last: make(map[string]entities.Sighting)}
+}
+
+func (r *memoryRepository) InsertSighting(ctx context.Context, s entities.Sighting) (*entities.Sighting, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastId++
+	s.Id = r.lastId
+	s.SeenAt = time.Unix(s.SeenAt.Unix(), 0)
+	r.sightings = append(r.sightings, s)
+	r.last[s.MachineId] = s
+	return &s, nil
+}
+
+func (r *memoryRepository) ListSightings(ctx context.Context, f entities.SightingFilter) (*entities.Page[entities.Sighting], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	sightings := make([]entities.Sighting, 0)
+	for _, s := range r.sightings {
+		if (f.MachineId == "" || s.MachineId == f.MachineId) && (f.SessionId == 0 || s.SessionId == f.SessionId) &&
+			(f.From.IsZero() || s.SeenAt.Unix() >= f.From.Unix()) && (f.To.IsZero() || s.SeenAt.Unix() < f.To.Unix()) {
+			sightings = append(sightings, s)
+		}
+	}
+	r.mu.RUnlock()
+
+	page, err := listing.Memory(sightings, f.ListParams, func(s entities.Sighting) (any, any) {
+		return sortValue(s, f.Sort), s.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) LastSightings(ctx context.Context, machineIds []string) (map[string]entities.Sighting, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	last := make(map[string]entities.Sighting, len(machineIds))
+	for _, id := range machineIds {
+		if s, ok := r.last[id]; ok {
+			last[id] = s
+		}
+	}
+	return last, nil
+}
+
+func (r *memoryRepository) DeleteSightingsBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := make([]entities.Sighting, 0, len(r.sightings))
+	for _, s := range r.sightings {
+		if s.SeenAt.Unix() >= before.Unix() {
+			kept = append(kept, s)
+		}
+	}
+	deleted := int64(len(r.sightings) - len(kept))
+	r.sightings = kept
+
+	for machineId, s := range r.last {
+		if s.SeenAt.Unix() < before.Unix() {
+			delete(r.last, machineId)
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,155 @@
+package locations
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+var sortColumns = map[string]string{
+	"id":      "id",
+	"seen_at": "seen_at",
+}
+
+const columns = `id, machine_id, session_id, bssid, rssi, parking_id, geofence_id, seen_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSighting(row scanner) (*entities.Sighting, error) {
+	var (
+		s      entities.Sighting
+		seenAt int64
+	)
+	if err := row.Scan(&s.Id, &s.MachineId, &s.SessionId, &s.Bssid, &s.Rssi, &s.ParkingId, &s.GeofenceId, &seenAt); err != nil {
+		return nil, err
+	}
+	s.SeenAt = time.Unix(seenAt, 0)
+	return &s, nil
+}
+
+func (r *repository) InsertSighting(ctx context.Context, s entities.Sighting) (*entities.Sighting, error) {
+	q := `
+		INSERT INTO machine_sightings (machine_id, session_id, bssid, rssi, parking_id, geofence_id, seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + columns
+
+	inserted, err := scanSighting(r.db.QueryRowContext(ctx, q, s.MachineId, s.SessionId, s.Bssid, s.Rssi, s.ParkingId,
+		s.GeofenceId, s.SeenAt.Unix()))
+	return inserted, errors.Wrap(err, "insert sighting")
+}
+
+func (r *repository) ListSightings(ctx context.Context, f entities.SightingFilter) (*entities.Page[entities.Sighting], error) {
+	var query listing.Query
+
+	if f.MachineId != "" {
+		query.Where("machine_id = %s", f.MachineId)
+	}
+	if f.SessionId != 0 {
+		query.Where("session_id = %s", f.SessionId)
+	}
+	if !f.From.IsZero() {
+		query.Where("seen_at >= %s", f.From.Unix())
+	}
+	if !f.To.IsZero() {
+		query.Where("seen_at < %s", f.To.Unix())
+	}
+
+	q, args, limit, err := query.Build(`SELECT `+columns+` FROM machine_sightings`, sortColumns, "id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	sightings, err := r.selectSightings(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list sightings")
+	}
+
+	page := listing.Page(sightings, limit, func(s entities.Sighting) listing.Cursor {
+		return listing.NewCursor(sortValue(s, f.Sort), s.Id)
+	})
+	return &page, nil
+}
+
+func (r *repository) LastSightings(ctx context.Context, machineIds []string) (map[string]entities.Sighting, error) {
+	q := `
+		SELECT DISTINCT ON (machine_id) ` + columns + ` FROM machine_sightings
+		WHERE machine_id = ANY($1)
+		ORDER BY machine_id, id DESC`
+
+	sightings, err := r.selectSightings(ctx, q, pq.Array(machineIds))
+	if err != nil {
+		return nil, errors.Wrap(err, "select last sightings")
+	}
+
+	last := make(map[string]entities.Sighting, len(sightings))
+	for _, s := range sightings {
+		last[s.MachineId] = s
+	}
+	return last, nil
+}
+
+// deleteBatch limits rows deleted by one statement, so reports are not blocked by long delete
+const deleteBatch = 10000
+
+func (r *repository) DeleteSightingsBefore(ctx context.Context, before time.Time) (int64, error) {
+	q := `
+		DELETE FROM machine_sightings WHERE id IN (
+			SELECT id FROM machine_sightings WHERE seen_at < $1 LIMIT $2
+		)`
+
+	var deleted int64
+	for {
+		res, err := r.db.ExecContext(ctx, q, before.Unix(), deleteBatch)
+		if err != nil {
+			return deleted, errors.Wrap(err, "delete sightings")
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return deleted, errors.Wrap(err, "delete sightings")
+		}
+		deleted += n
+		if n < deleteBatch {
+			return deleted, nil
+		}
+	}
+}
+
+func (r *repository) selectSightings(ctx context.Context, q string, args ...any) ([]entities.Sighting, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sightings := make([]entities.Sighting, 0)
+	for rows.Next() {
+		s, err := scanSighting(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan sighting")
+		}
+		sightings = append(sightings, *s)
+	}
+	return sightings, rows.Err()
+}
+
+func sortValue(s entities.Sighting, field string) any {
+	if field == "seen_at" {
+		return s.SeenAt.Unix()
+	}
+	return s.Id
+}
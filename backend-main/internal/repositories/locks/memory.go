@@ -0,0 +1,50 @@
+package locks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+)
+
+type lockKey struct {
+	space     int
+	machineId string
+}
+
+// memoryRepository serialises commands with machines within one process
+type memoryRepository struct {
+	mu   sync.Mutex
+	held map[lockKey]bool
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{held: make(map[lockKey]bool)}
+}
+
+func (r *memoryRepository) AcquireMachine(ctx context.Context, machineId string) (func(), error) {
+	return r.acquire(lockKey{space: machineLockSpace, machineId: machineId})
+}
+
+func (r *memoryRepository) AcquireMachineReport(ctx context.Context, machineId string) (func(), error) {
+	return r.acquire(lockKey{space: reportLockSpace, machineId: machineId})
+}
+
+func (r *memoryRepository) acquire(key lockKey) (func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.held[key] {
+		return nil, errs.ErrMachineBusy
+	}
+	r.held[key] = true
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			delete(r.held, key)
+		})
+	}, nil
+}
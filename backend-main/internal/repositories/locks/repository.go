@@ -0,0 +1,70 @@
+package locks
+
+import (
+	"context"
+	"database/sql/driver"
+	"log/slog"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// First keys of advisory locks, the second one is hash of machine id.
+// Reports of location have their own locks, so they do not hold up commands.
+const (
+	machineLockSpace = 1
+	reportLockSpace  = 2
+)
+
+// repository serialises commands with machines using postgres advisory locks,
+// so they are serialised between several instances of the app too
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+func (r *repository) AcquireMachine(ctx context.Context, machineId string) (func(), error) {
+	return r.acquire(ctx, machineLockSpace, machineId)
+}
+
+func (r *repository) AcquireMachineReport(ctx context.Context, machineId string) (func(), error) {
+	return r.acquire(ctx, reportLockSpace, machineId)
+}
+
+// acquire takes session-level advisory lock on a dedicated connection,
+// the connection is returned to the pool when lock is released
+func (r *repository) acquire(ctx context.Context, space int, machineId string) (func(), error) {
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get connection for machine lock")
+	}
+
+	var locked bool
+	q := `SELECT pg_try_advisory_lock($1, hashtext($2))`
+	if err := conn.QueryRowContext(ctx, q, space, machineId).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "try machine lock")
+	}
+	if !locked {
+		conn.Close()
+		return nil, errs.ErrMachineBusy
+	}
+
+	return func() {
+		// lock is released even if the request is cancelled
+		ctx := context.WithoutCancel(ctx)
+		q := `SELECT pg_advisory_unlock($1, hashtext($2))`
+		if _, err := conn.ExecContext(ctx, q, space, machineId); err != nil {
+			slog.ErrorContext(ctx, "release machine lock, connection is dropped", slog.String("op", "locks.acquire"),
+				slog.String("machine_id", machineId), slog.String("error", err.Error()))
+
+			// lock lives as long as the connection, so it should not get back to the pool
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,100 @@
+package logins
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.LoginAttempts
+type memoryRepository struct {
+	mu       sync.Mutex
+	failures map[string]entities.LoginFailures
+
+	// users is used to filter phone numbers by site of user, like subquery in postgres
+	users userGetter
+}
+
+type userGetter interface {
+	GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*entities.User, error)
+}
+
+func NewMemoryRepository(users userGetter) *memoryRepository {
+	return &memoryRepository{failures: make(map[string]entities.LoginFailures), users: users}
+}
+
+func (r *memoryRepository) GetLoginFailures(ctx context.Context, phoneNumber string) (*entities.LoginFailures, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.failures[phoneNumber]
+	if !ok {
+		return nil, errs.ErrLockoutNotFound
+	}
+	return &f, nil
+}
+
+func (r *memoryRepository) ListLoginFailures(ctx context.Context, since time.Time, siteId int) ([]entities.LoginFailures, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	list := make([]entities.LoginFailures, 0)
+	for _, f := range r.failures {
+		if (!f.LastFailureAt.Before(since) || f.Locked(now)) && (siteId == 0 || r.userAtSite(ctx, f.PhoneNumber, siteId)) {
+			list = append(list, f)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastFailureAt.After(list[j].LastFailureAt)
+	})
+	return list, nil
+}
+
+func (r *memoryRepository) RegisterLoginFailure(ctx context.Context, phoneNumber string, since time.Time, maxFailures int, lockout time.Duration) (*entities.LoginFailures, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	for phone, f := range r.failures {
+		if f.LastFailureAt.Before(since) && !f.Locked(now) {
+			delete(r.failures, phone)
+		}
+	}
+
+	f, ok := r.failures[phoneNumber]
+	if !ok || (f.LockedUntil != nil && !f.Locked(now)) {
+		f = entities.LoginFailures{PhoneNumber: phoneNumber}
+	}
+
+	f.Failures++
+	f.LastFailureAt = now
+	if f.LockedUntil == nil && f.Failures >= maxFailures {
+		until := now.Add(lockout)
+		f.LockedUntil = &until
+	}
+
+	r.failures[phoneNumber] = f
+	return &f, nil
+}
+
+func (r *memoryRepository) ResetLoginFailures(ctx context.Context, phoneNumber string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.failures[phoneNumber]; !ok {
+		return errs.ErrLockoutNotFound
+	}
+	delete(r.failures, phoneNumber)
+	return nil
+}
+
+func (r *memoryRepository) userAtSite(ctx context.Context, phoneNumber string, siteId int) bool {
+	user, err := r.users.GetUserByPhoneNumber(ctx, phoneNumber)
+	return err == nil && user.SiteId == siteId
+}
@@ -0,0 +1,130 @@
+package logins
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+const loginFailuresColumns = `phone_number, failures, last_failure_at, locked_until`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLoginFailures(row scanner) (*entities.LoginFailures, error) {
+	var (
+		f             entities.LoginFailures
+		lastFailureAt int64
+		lockedUntil   sql.NullInt64
+	)
+	if err := row.Scan(&f.PhoneNumber, &f.Failures, &lastFailureAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+
+	f.LastFailureAt = time.Unix(lastFailureAt, 0)
+	if lockedUntil.Valid {
+		until := time.Unix(lockedUntil.Int64, 0)
+		f.LockedUntil = &until
+	}
+	return &f, nil
+}
+
+func (r *repository) GetLoginFailures(ctx context.Context, phoneNumber string) (*entities.LoginFailures, error) {
+	q := `SELECT ` + loginFailuresColumns + ` FROM login_failures WHERE phone_number = $1`
+
+	f, err := scanLoginFailures(r.db.QueryRowContext(ctx, q, phoneNumber))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrLockoutNotFound
+	}
+	return f, errors.Wrap(err, "select login failures")
+}
+
+func (r *repository) ListLoginFailures(ctx context.Context, since time.Time, siteId int) ([]entities.LoginFailures, error) {
+	q := `
+		SELECT ` + loginFailuresColumns + ` FROM login_failures
+		WHERE (last_failure_at >= $1 OR locked_until > $2)
+			AND ($3 = 0 OR phone_number IN (SELECT phone_number FROM users WHERE site_id = $3))
+		ORDER BY last_failure_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, q, since.Unix(), time.Now().Unix(), siteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "select login failures")
+	}
+	defer rows.Close()
+
+	list := make([]entities.LoginFailures, 0)
+	for rows.Next() {
+		f, err := scanLoginFailures(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan login failures")
+		}
+		list = append(list, *f)
+	}
+	return list, errors.Wrap(rows.Err(), "select login failures")
+}
+
+func (r *repository) RegisterLoginFailure(ctx context.Context, phoneNumber string, since time.Time, maxFailures int, lockout time.Duration) (*entities.LoginFailures, error) {
+	now := time.Now().Unix()
+
+	q := `DELETE FROM login_failures WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until <= $2)`
+	if _, err := r.db.ExecContext(ctx, q, since.Unix(), now); err != nil {
+		return nil, errors.Wrap(err, "delete stale login failures")
+	}
+
+	// counter starts over after expired lockout
+	q = `
+		INSERT INTO login_failures (phone_number, failures, last_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (phone_number) DO UPDATE SET
+			failures = CASE WHEN login_failures.locked_until <= $2 THEN 1 ELSE login_failures.failures + 1 END,
+			last_failure_at = $2,
+			locked_until = CASE WHEN login_failures.locked_until <= $2 THEN NULL ELSE login_failures.locked_until END
+		RETURNING ` + loginFailuresColumns
+	f, err := scanLoginFailures(r.db.QueryRowContext(ctx, q, phoneNumber, now))
+	if err != nil {
+		return nil, errors.Wrap(err, "upsert login failures")
+	}
+
+	if f.LockedUntil != nil || f.Failures < maxFailures {
+		return f, nil
+	}
+
+	// lockout is not extended by concurrent failures
+	q = `
+		UPDATE login_failures SET locked_until = $1
+		WHERE phone_number = $2 AND locked_until IS NULL
+		RETURNING ` + loginFailuresColumns
+	locked, err := scanLoginFailures(r.db.QueryRowContext(ctx, q, now+int64(lockout/time.Second), phoneNumber))
+	if errors.Is(err, sql.ErrNoRows) {
+		return f, nil
+	}
+	return locked, errors.Wrap(err, "lock login")
+}
+
+func (r *repository) ResetLoginFailures(ctx context.Context, phoneNumber string) error {
+	q := `DELETE FROM login_failures WHERE phone_number = $1`
+	res, err := r.db.ExecContext(ctx, q, phoneNumber)
+	if err != nil {
+		return errors.Wrap(err, "delete login failures")
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.Wrap(err, "delete login failures")
+	} else if n == 0 {
+		return errs.ErrLockoutNotFound
+	}
+	return nil
+}
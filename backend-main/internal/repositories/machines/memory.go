@@ -0,0 +1,123 @@
+package machines
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/pkg/errors"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Machine.
+type memoryRepository struct {
+	mu       sync.RWMutex
+	machines map[string]entities.Machine
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{machines: make(map[string]entities.Machine)}
+}
+
+func (r *memoryRepository) InsertMachine(ctx context.Context, machineId, ipAddr string) (*entities.Machine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.machines[machineId]; ok {
+		return nil, errors.Wrap(errs.ErrAlreadyExists, "insert machine")
+	}
+
+	machine := entities.Machine{Id: machineId, IPAddr: ipAddr, SiteId: entities.DefaultSiteId}
+	r.machines[machineId] = machine
+
+	return &machine, nil
+}
+
+func (r *memoryRepository) GetMachineByID(ctx context.Context, machineId string) (*entities.Machine, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.machines[machineId]
+	if !ok {
+		return nil, errors.Wrap(errs.ErrMachineNotFound, "select machine by id")
+	}
+	return &m, nil
+}
+
+func (r *memoryRepository) ListMachines(ctx context.Context, f entities.MachineFilter) (*entities.Page[entities.Machine], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	machines := r.filter(func(m entities.Machine) bool {
+		return (f.State == nil || m.State == *f.State) && (f.ParkingId == nil || m.ParkingId == *f.ParkingId) &&
+			(f.TypeId == nil || m.TypeId == *f.TypeId) && (f.SiteId == 0 || m.SiteId == f.SiteId)
+	})
+
+	page, err := listing.Memory(machines, f.ListParams, func(m entities.Machine) (any, any) {
+		return sortValue(m, f.Sort), m.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) UpdateMachineIPAddr(ctx context.Context, machineId, ipAddr string) (*entities.Machine, error) {
+	return r.update(machineId, func(m *entities.Machine) { m.IPAddr = ipAddr })
+}
+
+func (r *memoryRepository) UpdateMachineState(ctx context.Context, machineId string, state entities.MachineState) (*entities.Machine, error) {
+	return r.update(machineId, func(m *entities.Machine) { m.State = state })
+}
+
+func (r *memoryRepository) UpdateMachineMaintenance(ctx context.Context, machineId string, maintenance bool) (*entities.Machine, error) {
+	return r.update(machineId, func(m *entities.Machine) { m.Maintenance = maintenance })
+}
+
+func (r *memoryRepository) UpdateMachineTypeId(ctx context.Context, machineId string, typeId int) (*entities.Machine, error) {
+	return r.update(machineId, func(m *entities.Machine) { m.TypeId = typeId })
+}
+
+func (r *memoryRepository) UpdateMachineSiteId(ctx context.Context, machineId string, siteId int) (*entities.Machine, error) {
+	return r.update(machineId, func(m *entities.Machine) { m.SiteId = siteId })
+}
+
+func (r *memoryRepository) UpdateMachineParkingId(ctx context.Context, machineId string, parkingId int) (*entities.Machine, error) {
+	return r.update(machineId, func(m *entities.Machine) { m.ParkingId = parkingId })
+}
+
+func (r *memoryRepository) GetMachinesByParkingId(ctx context.Context, parkingId int) ([]entities.Machine, error) {
+	return r.filter(func(m entities.Machine) bool { return m.ParkingId == parkingId }), nil
+}
+
+func (r *memoryRepository) update(machineId string, apply func(m *entities.Machine)) (*entities.Machine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.machines[machineId]
+	if !ok {
+		return nil, errors.Wrap(errs.ErrMachineNotFound, "update machine")
+	}
+	apply(&m)
+	r.machines[machineId] = m
+
+	return &m, nil
+}
+
+func (r *memoryRepository) filter(match func(m entities.Machine) bool) []entities.Machine {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	machines := make([]entities.Machine, 0)
+	for _, m := range r.machines {
+		if match(m) {
+			machines = append(machines, m)
+		}
+	}
+	sort.Slice(machines, func(i, j int) bool { return machines[i].Id < machines[j].Id })
+
+	return machines
+}
@@ -1,7 +1,10 @@
 package machines
 
 import (
+	"context"
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
@@ -14,90 +17,160 @@ func NewRepository(db *sqlx.DB) *repository {
 	return &repository{db: db}
 }
 
-func (r *repository) InsertMachine(machineId, ipAddr string) (*entities.Machine, error) {
+func (r *repository) InsertMachine(ctx context.Context, machineId, ipAddr string) (*entities.Machine, error) {
 	var newMachine entities.Machine
 
 	q := `INSERT INTO machines (id, ip_addr) VALUES ($1, $2);`
-	if _, err := r.db.Queryx(q, machineId, ipAddr); err != nil {
-		return nil, errors.Wrap(err, "insert machine")
+	if _, err := r.db.ExecContext(ctx, q, machineId, ipAddr); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, nil), "insert machine")
 	}
 
 	q = `SELECT * FROM machines WHERE id = $1;`
-	if err := r.db.Get(&newMachine, q, machineId); err != nil {
+	if err := r.db.GetContext(ctx, &newMachine, q, machineId); err != nil {
 		return nil, errors.Wrap(err, "select inserted machine")
 	}
 
 	return &newMachine, nil
 }
 
-func (r *repository) GetMachineByID(machineId string) (*entities.Machine, error) {
+func (r *repository) GetMachineByID(ctx context.Context, machineId string) (*entities.Machine, error) {
 	var m entities.Machine
 
 	q := `SELECT * FROM machines WHERE id = $1`
 
-	if err := r.db.Get(&m, q, machineId); err != nil {
-		return nil, errors.Wrap(err, "select machine by id")
+	if err := r.db.GetContext(ctx, &m, q, machineId); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineNotFound), "select machine by id")
 	}
 	return &m, nil
 }
 
-func (r *repository) GetAllMachines() ([]entities.Machine, error) {
-	machines := make([]entities.Machine, 0)
+var sortColumns = map[string]string{
+	"id":         "id",
+	"state":      "state",
+	"parking_id": "parking_id",
+}
 
-	q := `SELECT * FROM machines`
-	if err := r.db.Select(&machines, q); err != nil {
-		return nil, errors.Wrap(err, "get all machines")
+func (r *repository) ListMachines(ctx context.Context, f entities.MachineFilter) (*entities.Page[entities.Machine], error) {
+	var query listing.Query
+
+	if f.State != nil {
+		query.Where("state = %s", *f.State)
 	}
-	return machines, nil
+	if f.ParkingId != nil {
+		query.Where("parking_id = %s", *f.ParkingId)
+	}
+	if f.TypeId != nil {
+		query.Where("type_id = %s", *f.TypeId)
+	}
+	if f.SiteId != 0 {
+		query.Where("site_id = %s", f.SiteId)
+	}
+
+	q, args, limit, err := query.Build(`SELECT * FROM machines`, sortColumns, "id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	machines := make([]entities.Machine, 0)
+	if err := r.db.SelectContext(ctx, &machines, q, args...); err != nil {
+		return nil, errors.Wrap(err, "list machines")
+	}
+
+	page := listing.Page(machines, limit, func(m entities.Machine) listing.Cursor {
+		return listing.NewCursor(sortValue(m, f.Sort), m.Id)
+	})
+	return &page, nil
 }
 
-func (r *repository) UpdateMachineIPAddr(machineId, ipAddr string) (*entities.Machine, error) {
+func (r *repository) UpdateMachineIPAddr(ctx context.Context, machineId, ipAddr string) (*entities.Machine, error) {
 	var machine entities.Machine
 
 	q := `
 		UPDATE machines SET ip_addr = $1 WHERE id = $2
 		RETURNING *;
 	`
-	if err := r.db.QueryRowx(q, ipAddr, machineId).StructScan(&machine); err != nil {
-		return nil, errors.Wrap(err, "failed to update machine's ipAddr")
+	if err := r.db.QueryRowxContext(ctx, q, ipAddr, machineId).StructScan(&machine); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineNotFound), "failed to update machine's ipAddr")
 	}
 	return &machine, nil
 }
 
-func (r *repository) UpdateMachineState(machineId string, state entities.MachineState) (*entities.Machine, error) {
+func (r *repository) UpdateMachineState(ctx context.Context, machineId string, state entities.MachineState) (*entities.Machine, error) {
 	var machine entities.Machine
 
 	q := `
 		UPDATE machines SET state = $1 WHERE id = $2
 		RETURNING *;
 	`
-	if err := r.db.QueryRowx(q, state, machineId).StructScan(&machine); err != nil {
-		return nil, errors.Wrap(err, "failed to update machine's ipAddr")
+	if err := r.db.QueryRowxContext(ctx, q, state, machineId).StructScan(&machine); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineNotFound), "failed to update machine's state")
+	}
+	return &machine, nil
+}
+
+func (r *repository) UpdateMachineMaintenance(ctx context.Context, machineId string, maintenance bool) (*entities.Machine, error) {
+	var machine entities.Machine
+
+	q := `UPDATE machines SET maintenance = $1 WHERE id = $2 RETURNING *`
+	if err := r.db.QueryRowxContext(ctx, q, maintenance, machineId).StructScan(&machine); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineNotFound), "update machine maintenance")
+	}
+	return &machine, nil
+}
+
+func (r *repository) UpdateMachineTypeId(ctx context.Context, machineId string, typeId int) (*entities.Machine, error) {
+	var machine entities.Machine
+
+	q := `UPDATE machines SET type_id = $1 WHERE id = $2 RETURNING *`
+	if err := r.db.QueryRowxContext(ctx, q, typeId, machineId).StructScan(&machine); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineNotFound), "update machine type")
+	}
+	return &machine, nil
+}
+
+func (r *repository) UpdateMachineSiteId(ctx context.Context, machineId string, siteId int) (*entities.Machine, error) {
+	var machine entities.Machine
+
+	q := `UPDATE machines SET site_id = $1 WHERE id = $2 RETURNING *`
+	if err := r.db.QueryRowxContext(ctx, q, siteId, machineId).StructScan(&machine); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineNotFound), "update machine site")
 	}
 	return &machine, nil
 }
 
 // New method to update machines parking place (parkingId)
-func (r *repository) UpdateMachineParkingId(machineId string, parkingId int) (*entities.Machine, error) {
+func (r *repository) UpdateMachineParkingId(ctx context.Context, machineId string, parkingId int) (*entities.Machine, error) {
 	var machine entities.Machine
 
 	q := `
 		UPDATE machines SET parking_id = $1 WHERE id = $2
 		RETURNING *;
 	`
-	if err := r.db.QueryRowx(q, parkingId, machineId).StructScan(&machine); err != nil {
-		return nil, errors.Wrap(err, "failed to update machine's parking_id")
+	if err := r.db.QueryRowxContext(ctx, q, parkingId, machineId).StructScan(&machine); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineNotFound), "failed to update machine's parking_id")
 	}
 	return &machine, nil
 }
 
 // New method to select machines for each parking
-func (r *repository) GetMachinesByParkingId(parkingId int) ([]entities.Machine, error) {
+func (r *repository) GetMachinesByParkingId(ctx context.Context, parkingId int) ([]entities.Machine, error) {
 	machines := make([]entities.Machine, 0)
 
 	q := `SELECT * FROM machines WHERE parking_id = $1`
-	if err := r.db.Select(&machines, q, parkingId); err != nil {
+	if err := r.db.SelectContext(ctx, &machines, q, parkingId); err != nil {
 		return nil, errors.Wrap(err, "get all machines")
 	}
 	return machines, nil
 }
+
+// sortValue returns value of machine field used as sort key of list
+func sortValue(m entities.Machine, field string) any {
+	switch field {
+	case "state":
+		return m.State
+	case "parking_id":
+		return m.ParkingId
+	default:
+		return m.Id
+	}
+}
@@ -0,0 +1,113 @@
+package machinetypes
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/pkg/errors"
+)
+
+type machineLister interface {
+	ListMachines(ctx context.Context, filter entities.MachineFilter) (*entities.Page[entities.Machine], error)
+}
+
+// memoryRepository is a thread-safe in-memory implementation of service.MachineType,
+// unique names of types are emulated to match the sql schema
+type memoryRepository struct {
+	mu       sync.RWMutex
+	types    map[int]entities.MachineType
+	lastId   int
+	machines machineLister
+}
+
+func NewMemoryRepository(machines machineLister) *memoryRepository {
+	return &memoryRepository{types: make(map[int]entities.MachineType), machines: machines}
+}
+
+func (r *memoryRepository) InsertMachineType(ctx context.Context, name, description string) (*entities.MachineType, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(name, 0) {
+		return nil, errors.Wrap(errs.ErrAlreadyExists, "insert machine type")
+	}
+
+	r.lastId++
+	t := entities.MachineType{Id: r.lastId, Name: name, Description: description}
+	r.types[t.Id] = t
+	return &t, nil
+}
+
+func (r *memoryRepository) GetMachineTypeById(ctx context.Context, typeId int) (*entities.MachineType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.types[typeId]
+	if !ok {
+		return nil, errs.ErrMachineTypeNotFound
+	}
+	return &t, nil
+}
+
+func (r *memoryRepository) ListMachineTypes(ctx context.Context) ([]entities.MachineType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]entities.MachineType, 0, len(r.types))
+	for _, t := range r.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Id < types[j].Id
+	})
+	return types, nil
+}
+
+func (r *memoryRepository) UpdateMachineType(ctx context.Context, typeId int, name, description string) (*entities.MachineType, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.types[typeId]
+	if !ok {
+		return nil, errs.ErrMachineTypeNotFound
+	}
+	if r.nameTaken(name, typeId) {
+		return nil, errors.Wrap(errs.ErrAlreadyExists, "update machine type")
+	}
+
+	t.Name, t.Description = name, description
+	r.types[typeId] = t
+	return &t, nil
+}
+
+func (r *memoryRepository) DeleteMachineType(ctx context.Context, typeId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.types[typeId]; !ok {
+		return errs.ErrMachineTypeNotFound
+	}
+
+	page, err := r.machines.ListMachines(ctx, entities.MachineFilter{ListParams: entities.ListParams{Limit: 1}, TypeId: &typeId})
+	if err != nil {
+		return errors.Wrap(err, "list machines of type")
+	}
+	if len(page.Items) != 0 {
+		return errs.ErrMachineTypeInUse
+	}
+
+	delete(r.types, typeId)
+	return nil
+}
+
+func (r *memoryRepository) nameTaken(name string, exceptId int) bool {
+	for _, t := range r.types {
+		if t.Name == name && t.Id != exceptId {
+			return true
+		}
+	}
+	return false
+}
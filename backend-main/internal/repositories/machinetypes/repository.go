@@ -0,0 +1,79 @@
+package machinetypes
+
+import (
+	"context"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+func (r *repository) InsertMachineType(ctx context.Context, name, description string) (*entities.MachineType, error) {
+	var t entities.MachineType
+
+	q := `INSERT INTO machine_types (name, description) VALUES ($1, $2) RETURNING id, name, description`
+	if err := r.db.QueryRowxContext(ctx, q, name, description).StructScan(&t); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, nil), "insert machine type")
+	}
+	return &t, nil
+}
+
+func (r *repository) GetMachineTypeById(ctx context.Context, typeId int) (*entities.MachineType, error) {
+	var t entities.MachineType
+
+	q := `SELECT id, name, description FROM machine_types WHERE id = $1`
+	if err := r.db.GetContext(ctx, &t, q, typeId); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineTypeNotFound), "select machine type")
+	}
+	return &t, nil
+}
+
+func (r *repository) ListMachineTypes(ctx context.Context) ([]entities.MachineType, error) {
+	types := make([]entities.MachineType, 0)
+
+	q := `SELECT id, name, description FROM machine_types ORDER BY id`
+	if err := r.db.SelectContext(ctx, &types, q); err != nil {
+		return nil, errors.Wrap(err, "list machine types")
+	}
+	return types, nil
+}
+
+func (r *repository) UpdateMachineType(ctx context.Context, typeId int, name, description string) (*entities.MachineType, error) {
+	var t entities.MachineType
+
+	q := `UPDATE machine_types SET name = $1, description = $2 WHERE id = $3 RETURNING id, name, description`
+	if err := r.db.QueryRowxContext(ctx, q, name, description, typeId).StructScan(&t); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrMachineTypeNotFound), "update machine type")
+	}
+	return &t, nil
+}
+
+func (r *repository) DeleteMachineType(ctx context.Context, typeId int) error {
+	q := `DELETE FROM machine_types WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM machines WHERE type_id = $1)`
+	res, err := r.db.ExecContext(ctx, q, typeId)
+	if err != nil {
+		return errors.Wrap(err, "delete machine type")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete machine type")
+	}
+	if n == 1 {
+		return nil
+	}
+
+	if _, err = r.GetMachineTypeById(ctx, typeId); err != nil {
+		return err
+	}
+	return errs.ErrMachineTypeInUse
+}
@@ -0,0 +1,198 @@
+package maintenance
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Maintenance
+type memoryRepository struct {
+	mu sync.RWMutex
+
+	intervals      map[int]entities.ServiceInterval
+	lastIntervalId int
+
+	orders      map[int]entities.WorkOrder
+	lastOrderId int
+
+	// machines is used to filter orders by site of machine, like subquery in postgres
+	machines machineGetter
+}
+
+type machineGetter interface {
+	GetMachineByID(ctx context.Context, machineId string) (*entities.Machine, error)
+}
+
+func NewMemoryRepository(machines machineGetter) *memoryRepository {
+	return &memoryRepository{
+		intervals: make(map[int]entities.ServiceInterval),
+		orders:    make(map[int]entities.WorkOrder),
+		machines:  machines,
+	}
+}
+
+func (r *memoryRepository) ListServiceIntervals(ctx context.Context, typeId int) ([]entities.ServiceInterval, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	intervals := make([]entities.ServiceInterval, 0)
+	for _, i := range r.intervals {
+		if i.TypeId == typeId {
+			intervals = append(intervals, i)
+		}
+	}
+	sort.Slice(intervals, func(a, b int) bool { return intervals[a].Id < intervals[b].Id })
+	return intervals, nil
+}
+
+func (r *memoryRepository) GetServiceInterval(ctx context.Context, intervalId int) (*entities.ServiceInterval, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.intervals[intervalId]
+	if !ok {
+		return nil, errs.ErrServiceIntervalNotFound
+	}
+	return &i, nil
+}
+
+func (r *memoryRepository) InsertServiceInterval(ctx context.Context, i entities.ServiceInterval) (*entities.ServiceInterval, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastIntervalId++
+	i.Id = r.lastIntervalId
+	r.intervals[i.Id] = i
+	return &i, nil
+}
+
+func (r *memoryRepository) UpdateServiceInterval(ctx context.Context, i entities.ServiceInterval) (*entities.ServiceInterval, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.intervals[i.Id]
+	if !ok {
+		return nil, errs.ErrServiceIntervalNotFound
+	}
+	stored.Name, stored.EveryHours, stored.GraceHours = i.Name, i.EveryHours, i.GraceHours
+	r.intervals[i.Id] = stored
+	return &stored, nil
+}
+
+func (r *memoryRepository) DeleteServiceInterval(ctx context.Context, intervalId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.intervals[intervalId]; !ok {
+		return errs.ErrServiceIntervalNotFound
+	}
+	delete(r.intervals, intervalId)
+
+	// like ON DELETE SET NULL in postgres
+	for id, o := range r.orders {
+		if o.IntervalId == intervalId {
+			o.IntervalId = 0
+			r.orders[id] = o
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) InsertWorkOrder(ctx context.Context, o entities.WorkOrder) (*entities.WorkOrder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.orders {
+		if o.IntervalId != 0 && stored.MachineId == o.MachineId && stored.IntervalId == o.IntervalId &&
+			stored.Status == entities.WorkOrderOpen {
+			return nil, errs.ErrAlreadyExists
+		}
+	}
+
+	r.lastOrderId++
+	o.Id = r.lastOrderId
+	o.Status = entities.WorkOrderOpen
+	o.CreatedAt = time.Unix(o.CreatedAt.Unix(), 0)
+	r.orders[o.Id] = o
+	return &o, nil
+}
+
+func (r *memoryRepository) GetWorkOrder(ctx context.Context, orderId int) (*entities.WorkOrder, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, ok := r.orders[orderId]
+	if !ok {
+		return nil, errs.ErrWorkOrderNotFound
+	}
+	return &o, nil
+}
+
+func (r *memoryRepository) ListWorkOrders(ctx context.Context, f entities.WorkOrderFilter) (*entities.Page[entities.WorkOrder], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	orders := r.filter(func(o entities.WorkOrder) bool {
+		return (f.MachineId == "" || o.MachineId == f.MachineId) && (f.Status == "" || o.Status == f.Status) &&
+			(f.SiteId == 0 || r.machineAtSite(ctx, o.MachineId, f.SiteId))
+	})
+
+	page, err := listing.Memory(orders, f.ListParams, func(o entities.WorkOrder) (any, any) {
+		return sortValue(o, f.Sort), o.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) MachineWorkOrders(ctx context.Context, machineId string) ([]entities.WorkOrder, error) {
+	return r.filter(func(o entities.WorkOrder) bool { return o.MachineId == machineId }), nil
+}
+
+func (r *memoryRepository) CloseWorkOrder(ctx context.Context, o entities.WorkOrder) (*entities.WorkOrder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.orders[o.Id]
+	if !ok {
+		return nil, errs.ErrWorkOrderNotFound
+	}
+	if stored.Status != entities.WorkOrderOpen {
+		return nil, errs.ErrWorkOrderClosed
+	}
+
+	stored.Status, stored.Notes, stored.ClosedBy, stored.ClosedAtHours = entities.WorkOrderClosed, o.Notes, o.ClosedBy, o.ClosedAtHours
+	if o.ClosedAt != nil {
+		t := time.Unix(o.ClosedAt.Unix(), 0)
+		stored.ClosedAt = &t
+	}
+	r.orders[o.Id] = stored
+	return &stored, nil
+}
+
+func (r *memoryRepository) filter(match func(o entities.WorkOrder) bool) []entities.WorkOrder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := make([]entities.WorkOrder, 0)
+	for _, o := range r.orders {
+		if match(o) {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+	return orders
+}
+
+func (r *memoryRepository) machineAtSite(ctx context.Context, machineId string, siteId int) bool {
+	machine, err := r.machines.GetMachineByID(ctx, machineId)
+	return err == nil && machine.SiteId == siteId
+}
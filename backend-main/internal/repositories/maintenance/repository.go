@@ -0,0 +1,221 @@
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+var sortColumns = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+}
+
+const intervalColumns = `id, type_id, name, every_hours, grace_hours`
+
+const orderColumns = `id, machine_id, COALESCE(interval_id, 0), name, due_hours, overdue_hours, status, notes,
+	created_at, closed_at, closed_by, closed_at_hours`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWorkOrder(row scanner) (*entities.WorkOrder, error) {
+	var (
+		o         entities.WorkOrder
+		createdAt int64
+		closedAt  sql.NullInt64
+	)
+	err := row.Scan(&o.Id, &o.MachineId, &o.IntervalId, &o.Name, &o.DueHours, &o.OverdueHours, &o.Status, &o.Notes,
+		&createdAt, &closedAt, &o.ClosedBy, &o.ClosedAtHours)
+	if err != nil {
+		return nil, err
+	}
+
+	o.CreatedAt = time.Unix(createdAt, 0)
+	if closedAt.Valid {
+		t := time.Unix(closedAt.Int64, 0)
+		o.ClosedAt = &t
+	}
+	return &o, nil
+}
+
+func (r *repository) ListServiceIntervals(ctx context.Context, typeId int) ([]entities.ServiceInterval, error) {
+	intervals := make([]entities.ServiceInterval, 0)
+
+	q := `SELECT ` + intervalColumns + ` FROM service_intervals WHERE type_id = $1 ORDER BY id`
+	if err := r.db.SelectContext(ctx, &intervals, q, typeId); err != nil {
+		return nil, errors.Wrap(err, "list service intervals")
+	}
+	return intervals, nil
+}
+
+func (r *repository) GetServiceInterval(ctx context.Context, intervalId int) (*entities.ServiceInterval, error) {
+	var i entities.ServiceInterval
+
+	q := `SELECT ` + intervalColumns + ` FROM service_intervals WHERE id = $1`
+	if err := r.db.GetContext(ctx, &i, q, intervalId); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrServiceIntervalNotFound), "select service interval")
+	}
+	return &i, nil
+}
+
+func (r *repository) InsertServiceInterval(ctx context.Context, i entities.ServiceInterval) (*entities.ServiceInterval, error) {
+	var inserted entities.ServiceInterval
+
+	q := `INSERT INTO service_intervals (type_id, name, every_hours, grace_hours) VALUES ($1, $2, $3, $4)
+		RETURNING ` + intervalColumns
+	if err := r.db.QueryRowxContext(ctx, q, i.TypeId, i.Name, i.EveryHours, i.GraceHours).StructScan(&inserted); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, nil), "insert service interval")
+	}
+	return &inserted, nil
+}
+
+func (r *repository) UpdateServiceInterval(ctx context.Context, i entities.ServiceInterval) (*entities.ServiceInterval, error) {
+	var updated entities.ServiceInterval
+
+	q := `UPDATE service_intervals SET name = $1, every_hours = $2, grace_hours = $3 WHERE id = $4
+		RETURNING ` + intervalColumns
+	if err := r.db.QueryRowxContext(ctx, q, i.Name, i.EveryHours, i.GraceHours, i.Id).StructScan(&updated); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrServiceIntervalNotFound), "update service interval")
+	}
+	return &updated, nil
+}
+
+// DeleteServiceInterval removes interval, foreign key sets interval_id of its work orders to NULL
+func (r *repository) DeleteServiceInterval(ctx context.Context, intervalId int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM service_intervals WHERE id = $1`, intervalId)
+	if err != nil {
+		return errors.Wrap(err, "delete service interval")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete service interval")
+	}
+	if n == 0 {
+		return errs.ErrServiceIntervalNotFound
+	}
+	return nil
+}
+
+func (r *repository) InsertWorkOrder(ctx context.Context, o entities.WorkOrder) (*entities.WorkOrder, error) {
+	var intervalId sql.NullInt64
+	if o.IntervalId != 0 {
+		intervalId = sql.NullInt64{Int64: int64(o.IntervalId), Valid: true}
+	}
+
+	q := `
+		INSERT INTO work_orders (machine_id, interval_id, name, due_hours, overdue_hours, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + orderColumns
+
+	inserted, err := scanWorkOrder(r.db.QueryRowContext(ctx, q, o.MachineId, intervalId, o.Name, o.DueHours, o.OverdueHours,
+		entities.WorkOrderOpen, o.CreatedAt.Unix()))
+	return inserted, errors.Wrap(errs.FromSQL(err, nil), "insert work order")
+}
+
+func (r *repository) GetWorkOrder(ctx context.Context, orderId int) (*entities.WorkOrder, error) {
+	q := `SELECT ` + orderColumns + ` FROM work_orders WHERE id = $1`
+
+	o, err := scanWorkOrder(r.db.QueryRowContext(ctx, q, orderId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrWorkOrderNotFound
+	}
+	return o, errors.Wrap(err, "select work order")
+}
+
+func (r *repository) ListWorkOrders(ctx context.Context, f entities.WorkOrderFilter) (*entities.Page[entities.WorkOrder], error) {
+	var query listing.Query
+
+	if f.MachineId != "" {
+		query.Where("machine_id = %s", f.MachineId)
+	}
+	if f.Status != "" {
+		query.Where("status = %s", f.Status)
+	}
+	if f.SiteId != 0 {
+		query.Where("machine_id IN (SELECT id FROM machines WHERE site_id = %s)", f.SiteId)
+	}
+
+	q, args, limit, err := query.Build(`SELECT `+orderColumns+` FROM work_orders`, sortColumns, "id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := r.selectWorkOrders(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := listing.Page(orders, limit, func(o entities.WorkOrder) listing.Cursor {
+		return listing.NewCursor(sortValue(o, f.Sort), o.Id)
+	})
+	return &page, nil
+}
+
+func (r *repository) MachineWorkOrders(ctx context.Context, machineId string) ([]entities.WorkOrder, error) {
+	q := `SELECT ` + orderColumns + ` FROM work_orders WHERE machine_id = $1 ORDER BY id`
+	return r.selectWorkOrders(ctx, q, machineId)
+}
+
+func (r *repository) CloseWorkOrder(ctx context.Context, o entities.WorkOrder) (*entities.WorkOrder, error) {
+	var closedAt int64
+	if o.ClosedAt != nil {
+		closedAt = o.ClosedAt.Unix()
+	}
+
+	q := `
+		UPDATE work_orders SET status = $1, notes = $2, closed_at = $3, closed_by = $4, closed_at_hours = $5
+		WHERE id = $6 AND status = $7
+		RETURNING ` + orderColumns
+
+	closed, err := scanWorkOrder(r.db.QueryRowContext(ctx, q, entities.WorkOrderClosed, o.Notes, closedAt, o.ClosedBy,
+		o.ClosedAtHours, o.Id, entities.WorkOrderOpen))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err = r.GetWorkOrder(ctx, o.Id); err != nil {
+			return nil, err
+		}
+		return nil, errs.ErrWorkOrderClosed
+	}
+	return closed, errors.Wrap(err, "close work order")
+}
+
+func (r *repository) selectWorkOrders(ctx context.Context, q string, args ...any) ([]entities.WorkOrder, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "select work orders")
+	}
+	defer rows.Close()
+
+	orders := make([]entities.WorkOrder, 0)
+	for rows.Next() {
+		o, err := scanWorkOrder(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan work order")
+		}
+		orders = append(orders, *o)
+	}
+	return orders, errors.Wrap(rows.Err(), "select work orders")
+}
+
+func sortValue(o entities.WorkOrder, field string) any {
+	if field == "created_at" {
+		return o.CreatedAt.Unix()
+	}
+	return o.Id
+}
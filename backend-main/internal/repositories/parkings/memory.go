@@ -0,0 +1,127 @@
+package parkings
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/pkg/errors"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Parking.
+// Unique constraints on name and mac_addr are emulated to match the sql schema.
+type memoryRepository struct {
+	mu       sync.RWMutex
+	parkings map[int]entities.Parking
+	lastId   int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{parkings: make(map[int]entities.Parking)}
+}
+
+func (r *memoryRepository) InsertParking(ctx context.Context, name, mac string, capacity entities.Capacity, state entities.ParkingState, siteId int) (*entities.Parking, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.parkings {
+		if p.Name == name || p.MacAddr == mac {
+			return nil, errors.Wrap(errs.ErrAlreadyExists, "inserting new parking")
+		}
+	}
+
+	r.lastId++
+	parking := entities.Parking{
+		Id:       r.lastId,
+		Name:     name,
+		MacAddr:  mac,
+		Capacity: capacity,
+		State:    state,
+		SiteId:   siteId,
+	}
+	r.parkings[parking.Id] = parking
+
+	return &parking, nil
+}
+
+func (r *memoryRepository) GetParkingById(ctx context.Context, parkingId int) (*entities.Parking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.parkings[parkingId]
+	if !ok {
+		return nil, errors.Wrap(errs.ErrParkingNotFound, "get parking by id")
+	}
+	return &p, nil
+}
+
+func (r *memoryRepository) GetParkingByName(ctx context.Context, name string) (*entities.Parking, error) {
+	return r.find(func(p entities.Parking) bool { return p.Name == name }, "get parking by name")
+}
+
+func (r *memoryRepository) GetParkingByMacAddr(ctx context.Context, macAddr string) (*entities.Parking, error) {
+	return r.find(func(p entities.Parking) bool { return p.MacAddr == macAddr }, "get parking by mac_addr")
+}
+
+func (r *memoryRepository) ListParkings(ctx context.Context, f entities.ParkingFilter) (*entities.Page[entities.Parking], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	parkings := make([]entities.Parking, 0, len(r.parkings))
+	for _, p := range r.parkings {
+		if (f.State == nil || p.State == *f.State) && (f.SiteId == 0 || p.SiteId == f.SiteId) {
+			parkings = append(parkings, p)
+		}
+	}
+	r.mu.RUnlock()
+
+	page, err := listing.Memory(parkings, f.ListParams, func(p entities.Parking) (any, any) {
+		return sortValue(p, f.Sort), p.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) UpdateParkingState(ctx context.Context, state entities.ParkingState, parkingId int) (*entities.Parking, error) {
+	return r.update(parkingId, func(p *entities.Parking) { p.State = state }, "update parking state")
+}
+
+func (r *memoryRepository) UpdateParkingMachines(ctx context.Context, machines int, parkingId int) (*entities.Parking, error) {
+	return r.update(parkingId, func(p *entities.Parking) { p.Machines = machines }, "update parking machines")
+}
+
+func (r *memoryRepository) UpdateParkingCapacity(ctx context.Context, capacity entities.Capacity, parkingId int) (*entities.Parking, error) {
+	return r.update(parkingId, func(p *entities.Parking) { p.Capacity = capacity }, "update parking capacity")
+}
+
+func (r *memoryRepository) find(match func(p entities.Parking) bool, op string) (*entities.Parking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.parkings {
+		if match(p) {
+			return &p, nil
+		}
+	}
+	return nil, errors.Wrap(errs.ErrParkingNotFound, op)
+}
+
+func (r *memoryRepository) update(parkingId int, apply func(p *entities.Parking), op string) (*entities.Parking, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.parkings[parkingId]
+	if !ok {
+		return nil, errors.Wrap(errs.ErrParkingNotFound, op)
+	}
+	apply(&p)
+	r.parkings[parkingId] = p
+
+	return &p, nil
+}
@@ -1,7 +1,10 @@
 package parkings
 
 import (
+	"context"
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
@@ -15,97 +18,131 @@ func NewRepository(db *sqlx.DB) *repository {
 }
 
 // Add new parking
-func (r *repository) InsertParking(name, mac string, capacity entities.Capacity, state entities.ParkingState) (*entities.Parking, error) {
+func (r *repository) InsertParking(ctx context.Context, name, mac string, capacity entities.Capacity, state entities.ParkingState, siteId int) (*entities.Parking, error) {
 	var parking entities.Parking
 
 	q := `
-		INSERT INTO parkings (name, mac_addr, capacity, state)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO parkings (name, mac_addr, capacity, state, site_id)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING *;
 	`
-	if err := r.db.QueryRowx(q, name, mac, capacity, state).StructScan(&parking); err != nil {
-		return nil, errors.Wrap(err, "inserting new parking")
+	if err := r.db.QueryRowxContext(ctx, q, name, mac, capacity, state, siteId).StructScan(&parking); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, nil), "inserting new parking")
 	}
 
 	return &parking, nil
 }
 
 // Get parking via Id
-func (r *repository) GetParkingById(parkingId int) (*entities.Parking, error) {
+func (r *repository) GetParkingById(ctx context.Context, parkingId int) (*entities.Parking, error) {
 	var parking entities.Parking
 
 	q := `SELECT * FROM parkings WHERE id = $1`
-	if err := r.db.Get(&parking, q, parkingId); err != nil {
-		return nil, errors.Wrap(err, "get parking by id")
+	if err := r.db.GetContext(ctx, &parking, q, parkingId); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrParkingNotFound), "get parking by id")
 	}
 	return &parking, nil
 }
 
 // Get parking via Name
-func (r *repository) GetParkingByName(name string) (*entities.Parking, error) {
+func (r *repository) GetParkingByName(ctx context.Context, name string) (*entities.Parking, error) {
 	var parking entities.Parking
 
 	q := `SELECT * FROM parkings WHERE name = $1`
-	if err := r.db.Get(&parking, q, name); err != nil {
-		return nil, errors.Wrap(err, "get parking by id")
+	if err := r.db.GetContext(ctx, &parking, q, name); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrParkingNotFound), "get parking by name")
 	}
 	return &parking, nil
 }
 
 // Get parking via MacAddr
-func (r *repository) GetParkingByMacAddr(macAddr string) (*entities.Parking, error) {
+func (r *repository) GetParkingByMacAddr(ctx context.Context, macAddr string) (*entities.Parking, error) {
 	var parking entities.Parking
 
 	q := `SELECT * FROM parkings WHERE mac_addr = $1`
-	if err := r.db.Get(&parking, q, macAddr); err != nil {
-		return nil, errors.Wrap(err, "get parking by mac_addr")
+	if err := r.db.GetContext(ctx, &parking, q, macAddr); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrParkingNotFound), "get parking by mac_addr")
 	}
 	return &parking, nil
 }
 
-// Get all parkings
-func (r *repository) GetAllParkings() ([]entities.Parking, error) {
-	parkings := make([]entities.Parking, 0)
+var sortColumns = map[string]string{
+	"id":       "id",
+	"name":     "name",
+	"machines": "machines",
+}
 
-	q := `SELECT * FROM parkings`
-	if err := r.db.Select(&parkings, q); err != nil {
-		return nil, errors.Wrap(err, "select all parkings")
+// List parkings page
+func (r *repository) ListParkings(ctx context.Context, f entities.ParkingFilter) (*entities.Page[entities.Parking], error) {
+	var query listing.Query
+
+	if f.State != nil {
+		query.Where("state = %s", *f.State)
+	}
+	if f.SiteId != 0 {
+		query.Where("site_id = %s", f.SiteId)
 	}
-	return parkings, nil
+
+	q, args, limit, err := query.Build(`SELECT * FROM parkings`, sortColumns, "id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	parkings := make([]entities.Parking, 0)
+	if err := r.db.SelectContext(ctx, &parkings, q, args...); err != nil {
+		return nil, errors.Wrap(err, "list parkings")
+	}
+
+	page := listing.Page(parkings, limit, func(p entities.Parking) listing.Cursor {
+		return listing.NewCursor(sortValue(p, f.Sort), p.Id)
+	})
+	return &page, nil
 }
 
 // Update parking state (active, inactive)
-func (r *repository) UpdateParkingState(state entities.ParkingState, parkingId int) (*entities.Parking, error) {
+func (r *repository) UpdateParkingState(ctx context.Context, state entities.ParkingState, parkingId int) (*entities.Parking, error) {
 	var id int
 
 	q := `UPDATE parkings SET state = $1 WHERE id = $2 RETURNING id;`
-	if err := r.db.QueryRowx(q, state, parkingId).Scan(&id); err != nil {
-		return nil, errors.Wrap(err, "update parking state")
+	if err := r.db.QueryRowxContext(ctx, q, state, parkingId).Scan(&id); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrParkingNotFound), "update parking state")
 	}
 
-	return r.GetParkingById(id)
+	return r.GetParkingById(ctx, id)
 }
 
 // Add or remove machines from parking
-func (r *repository) UpdateParkingMachines(machines int, parkingId int) (*entities.Parking, error) {
+func (r *repository) UpdateParkingMachines(ctx context.Context, machines int, parkingId int) (*entities.Parking, error) {
 	var id int
 
 	q := `UPDATE parkings SET machines = $1 WHERE id = $2 RETURNING id;`
-	if err := r.db.QueryRowx(q, machines, parkingId).Scan(&id); err != nil {
-		return nil, errors.Wrap(err, "update parking machines")
+	if err := r.db.QueryRowxContext(ctx, q, machines, parkingId).Scan(&id); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrParkingNotFound), "update parking machines")
 	}
 
-	return r.GetParkingById(id)
+	return r.GetParkingById(ctx, id)
 }
 
 // Update parking capacity
-func (r *repository) UpdateParkingCapacity(capacity entities.Capacity, parkingId int) (*entities.Parking, error) {
+func (r *repository) UpdateParkingCapacity(ctx context.Context, capacity entities.Capacity, parkingId int) (*entities.Parking, error) {
 	var id int
 
 	q := `UPDATE parkings SET capacity = $1 WHERE id = $2 RETURNING id;`
-	if err := r.db.QueryRowx(q, capacity, parkingId).Scan(&id); err != nil {
-		return nil, errors.Wrap(err, "update parking capacity")
+	if err := r.db.QueryRowxContext(ctx, q, capacity, parkingId).Scan(&id); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrParkingNotFound), "update parking capacity")
 	}
 
-	return r.GetParkingById(id)
+	return r.GetParkingById(ctx, id)
+}
+
+// sortValue returns value of parking field used as sort key of list
+func sortValue(p entities.Parking, field string) any {
+	switch field {
+	case "name":
+		return p.Name
+	case "machines":
+		return p.Machines
+	default:
+		return p.Id
+	}
 }
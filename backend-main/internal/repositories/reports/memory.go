@@ -0,0 +1,260 @@
+package reports
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+)
+
+type (
+	userLister interface {
+		ListUsers(ctx context.Context, filter entities.UserFilter) (*entities.Page[entities.User], error)
+	}
+	machineLister interface {
+		ListMachines(ctx context.Context, filter entities.MachineFilter) (*entities.Page[entities.Machine], error)
+	}
+	parkingLister interface {
+		ListParkings(ctx context.Context, filter entities.ParkingFilter) (*entities.Page[entities.Parking], error)
+	}
+	sessionLister interface {
+		ListSessions(ctx context.Context, filter entities.SessionFilter) (*entities.Page[entities.Session], error)
+	}
+	shiftLister interface {
+		ListShifts(ctx context.Context) ([]entities.Shift, error)
+		ListUserShifts(ctx context.Context, userId int) ([]entities.Shift, error)
+	}
+)
+
+// memoryRepository builds the same reports as postgres repository from other in-memory repositories
+type memoryRepository struct {
+	users    userLister
+	machines machineLister
+	parkings parkingLister
+	sessions sessionLister
+	shifts   shiftLister
+}
+
+func NewMemoryRepository(users userLister, machines machineLister, parkings parkingLister, sessions sessionLister,
+	shifts shiftLister) *memoryRepository {
+	return &memoryRepository{users: users, machines: machines, parkings: parkings, sessions: sessions, shifts: shifts}
+}
+
+// clipped is session with start and finish clipped to report range, in unix seconds
+type clipped struct {
+	entities.Session
+	start  int64
+	finish int64
+}
+
+func (r *memoryRepository) MachineUsage(ctx context.Context, rng entities.ReportRange) ([]entities.MachineUsage, error) {
+	now := time.Now()
+	period := rng.Seconds(now)
+	end := rng.From.Unix() + period
+
+	machines, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Machine], error) {
+		return r.machines.ListMachines(ctx, entities.MachineFilter{ListParams: p, SiteId: rng.SiteId})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := r.clippedSessions(ctx, rng, now)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].start < sessions[j].start })
+
+	usage := make([]entities.MachineUsage, 0, len(machines))
+	for _, m := range machines {
+		var (
+			count             int
+			used, longestIdle int64
+			lastUsed          *time.Time
+		)
+
+		prevFinish := rng.From.Unix()
+		for _, s := range sessions {
+			if s.MachineId != m.Id {
+				continue
+			}
+			count++
+			used += s.finish - s.start
+			longestIdle = max(longestIdle, s.start-prevFinish)
+			prevFinish = s.finish
+		}
+		longestIdle = max(longestIdle, end-prevFinish)
+
+		if count > 0 {
+			t := time.Unix(prevFinish, 0)
+			lastUsed = &t
+		}
+		usage = append(usage, newMachineUsage(m.Id, count, used, longestIdle, lastUsed, period))
+	}
+	return usage, nil
+}
+
+func (r *memoryRepository) WorkerHours(ctx context.Context, rng entities.ReportRange) ([]entities.WorkerHours, error) {
+	users, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.User], error) {
+		return r.users.ListUsers(ctx, entities.UserFilter{ListParams: p})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := r.clippedSessions(ctx, rng, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make([]entities.WorkerHours, 0, len(users))
+	for _, u := range users {
+		var seconds int64
+		w := entities.WorkerHours{WorkerId: u.Id, Name: u.Name}
+		machines := make(map[string]bool)
+
+		for _, s := range sessions {
+			if s.WorkerId == u.Id {
+				w.Sessions++
+				seconds += s.finish - s.start
+				machines[s.MachineId] = true
+			}
+		}
+
+		atSite := rng.SiteId == 0 || u.SiteId == rng.SiteId
+		if (u.JobPosition != entities.Worker || !atSite) && w.Sessions == 0 {
+			continue
+		}
+		w.Machines = len(machines)
+		w.Hours = hours(seconds)
+		workers = append(workers, w)
+	}
+	return workers, nil
+}
+
+func (r *memoryRepository) ParkingTurnover(ctx context.Context, rng entities.ReportRange) ([]entities.ParkingTurnover, error) {
+	parkings, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Parking], error) {
+		return r.parkings.ListParkings(ctx, entities.ParkingFilter{ListParams: p, SiteId: rng.SiteId})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// parkings are already limited to the site
+	sessions, err := r.allSessions(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	inRange := func(t time.Time) bool { return !t.Before(rng.From) && t.Before(rng.To) }
+
+	turnover := make([]entities.ParkingTurnover, 0, len(parkings))
+	for _, p := range parkings {
+		t := entities.ParkingTurnover{ParkingId: p.Id, Name: p.Name}
+		for _, s := range sessions {
+			if s.StartParkingId == p.Id && inRange(s.DatetimeStart) {
+				t.Departures++
+			}
+			if s.FinishParkingId == p.Id && s.State == entities.SessionFinished && inRange(s.DatetimeFinish) {
+				t.Arrivals++
+			}
+		}
+		t.Turnover = t.Departures + t.Arrivals
+		turnover = append(turnover, t)
+	}
+	return turnover, nil
+}
+
+func (r *memoryRepository) PeakHours(ctx context.Context, rng entities.ReportRange, loc *time.Location) ([]entities.PeakHour, error) {
+	sessions, err := r.clippedSessions(ctx, rng, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	cells := heatmap(nil)
+	seconds := make([]int64, len(cells))
+
+	for _, s := range sessions {
+		if !s.DatetimeStart.Before(rng.From) {
+			start := s.DatetimeStart.In(loc)
+			cells[int(start.Weekday())*24+start.Hour()].SessionsStarted++
+		}
+
+		// hours are aligned to UTC hours like in postgres repository
+		for h := s.start - s.start%3600; h < s.finish; h += 3600 {
+			t := time.Unix(h, 0).In(loc)
+			seconds[int(t.Weekday())*24+t.Hour()] += min(s.finish, h+3600) - max(s.start, h)
+		}
+	}
+
+	for i := range cells {
+		cells[i].MachineHours = hours(seconds[i])
+	}
+	return cells, nil
+}
+
+func (r *memoryRepository) ShiftUsage(ctx context.Context, rng entities.ReportRange) ([]entities.ShiftUsage, error) {
+	shifts, err := r.shifts.ListShifts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := r.clippedSessions(ctx, rng, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make(map[int][]int)
+	started := make([]startedSession, 0, len(sessions))
+	for _, s := range sessions {
+		if _, ok := assigned[s.WorkerId]; !ok {
+			userShifts, err := r.shifts.ListUserShifts(ctx, s.WorkerId)
+			if err != nil {
+				return nil, err
+			}
+			assigned[s.WorkerId] = make([]int, 0, len(userShifts))
+			for _, shift := range userShifts {
+				assigned[s.WorkerId] = append(assigned[s.WorkerId], shift.Id)
+			}
+		}
+		started = append(started, startedSession{workerId: s.WorkerId, start: s.DatetimeStart, seconds: s.finish - s.start})
+	}
+
+	return shiftUsage(shifts, assigned, started), nil
+}
+
+// allSessions returns sessions of machines at the site, zero siteId means any site
+func (r *memoryRepository) allSessions(ctx context.Context, siteId int) ([]entities.Session, error) {
+	return listing.All(func(p entities.ListParams) (*entities.Page[entities.Session], error) {
+		return r.sessions.ListSessions(ctx, entities.SessionFilter{ListParams: p, SiteId: siteId})
+	})
+}
+
+// clippedSessions returns sessions overlapping report range, unfinished sessions last until now
+func (r *memoryRepository) clippedSessions(ctx context.Context, rng entities.ReportRange, now time.Time) ([]clipped, error) {
+	sessions, err := r.allSessions(ctx, rng.SiteId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]clipped, 0)
+	for _, s := range sessions {
+		finish := s.DatetimeFinish.Unix()
+		if s.State != entities.SessionFinished {
+			finish = now.Unix()
+		}
+
+		c := clipped{
+			Session: s,
+			start:   max(s.DatetimeStart.Unix(), rng.From.Unix()),
+			finish:  min(finish, rng.To.Unix()),
+		}
+		overlaps := s.DatetimeStart.Before(rng.To) && (s.State != entities.SessionFinished || finish > rng.From.Unix())
+		if overlaps && c.finish >= c.start {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+// Package reports aggregates sessions into utilisation reports.
+// Postgres repository aggregates in sql, memory repository does the same in go.
+package reports
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+)
+
+// newMachineUsage calculates utilisation and idle time from time machine was used within period
+func newMachineUsage(machineId string, sessions int, used, longestIdle int64, lastUsed *time.Time, period int64) entities.MachineUsage {
+	usage := entities.MachineUsage{
+		MachineId:          machineId,
+		Sessions:           sessions,
+		UsedSeconds:        used,
+		IdleSeconds:        max(period-used, 0),
+		LongestIdleSeconds: longestIdle,
+		LastUsed:           lastUsed,
+	}
+	if period > 0 {
+		usage.Utilisation = round(100 * float64(used) / float64(period))
+	}
+	return usage
+}
+
+// heatmap returns all 7*24 cells of week, cells missing in aggregated are zero
+func heatmap(aggregated []entities.PeakHour) []entities.PeakHour {
+	cells := make([]entities.PeakHour, 0, 7*24)
+	for weekday := 0; weekday < 7; weekday++ {
+		for hour := 0; hour < 24; hour++ {
+			cells = append(cells, entities.PeakHour{Weekday: weekday, Hour: hour})
+		}
+	}
+
+	for _, c := range aggregated {
+		cell := &cells[c.Weekday*24+c.Hour]
+		cell.SessionsStarted += c.SessionsStarted
+		cell.MachineHours = round(cell.MachineHours + c.MachineHours)
+	}
+	return cells
+}
+
+// startedSession is session clipped to report range, it is attributed to shift by its real start
+type startedSession struct {
+	workerId int
+	start    time.Time
+	seconds  int64
+}
+
+// shiftUsage attributes each session to the first shift of its worker containing start of the session,
+// sessions outside of shifts go to the last row with zero shift id. assigned are shift ids by worker id.
+func shiftUsage(shifts []entities.Shift, assigned map[int][]int, sessions []startedSession) []entities.ShiftUsage {
+	byId := make(map[int]entities.Shift, len(shifts))
+	for _, s := range shifts {
+		byId[s.Id] = s
+	}
+
+	usage := make([]entities.ShiftUsage, 0, len(shifts)+1)
+	for _, s := range shifts {
+		usage = append(usage, entities.ShiftUsage{ShiftId: s.Id, Name: s.Name})
+	}
+	usage = append(usage, entities.ShiftUsage{Name: "outside shifts"})
+
+	seconds := make([]int64, len(usage))
+	workers := make([]map[int]bool, len(usage))
+	for _, session := range sessions {
+		row := len(usage) - 1
+		for _, shiftId := range assigned[session.workerId] {
+			if shift, ok := byId[shiftId]; ok && shift.Contains(session.start) {
+				row = slices.IndexFunc(usage, func(u entities.ShiftUsage) bool { return u.ShiftId == shiftId })
+				break
+			}
+		}
+
+		usage[row].Sessions++
+		seconds[row] += session.seconds
+		if workers[row] == nil {
+			workers[row] = make(map[int]bool)
+		}
+		workers[row][session.workerId] = true
+	}
+
+	for i := range usage {
+		usage[i].Workers = len(workers[i])
+		usage[i].Hours = hours(seconds[i])
+	}
+	return usage
+}
+
+func hours(seconds int64) float64 {
+	return round(float64(seconds) / 3600)
+}
+
+// round rounds value to 2 decimal places
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
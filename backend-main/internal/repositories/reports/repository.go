@@ -0,0 +1,276 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// clippedSessions selects sessions overlapping report range with start and finish
+// clipped to it. Unfinished sessions last until now.
+// Parameters: $1 - range start, $2 - range end, $3 - now, all are unix seconds,
+// $4 - site of machines, 0 means any site.
+const clippedSessions = `
+	WITH clipped AS (
+		SELECT id, machine_id, worker_id, datetime_start,
+			GREATEST(datetime_start, $1::bigint) AS start,
+			LEAST(CASE WHEN state = 2 THEN datetime_finish ELSE $3::bigint END, $2::bigint) AS finish
+		FROM sessions
+		WHERE datetime_start < $2::bigint AND (state != 2 OR datetime_finish > $1::bigint)
+			AND ($4::int = 0 OR machine_id IN (SELECT id FROM machines WHERE site_id = $4::int))
+	),
+	s AS (SELECT * FROM clipped WHERE finish >= start)`
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+func (r *repository) MachineUsage(ctx context.Context, rng entities.ReportRange) ([]entities.MachineUsage, error) {
+	now := time.Now()
+	period := rng.Seconds(now)
+	end := rng.From.Unix() + period
+
+	q := clippedSessions + `,
+	gaps AS (
+		SELECT machine_id, start - COALESCE(LAG(finish) OVER (PARTITION BY machine_id ORDER BY start), $1::bigint) AS gap FROM s
+		UNION ALL
+		SELECT machine_id, $5::bigint - MAX(finish) FROM s GROUP BY machine_id
+	)
+	SELECT m.id,
+		(SELECT COUNT(*) FROM s WHERE s.machine_id = m.id),
+		(SELECT COALESCE(SUM(finish - start), 0) FROM s WHERE s.machine_id = m.id),
+		(SELECT COALESCE(MAX(gap), $5::bigint - $1::bigint) FROM gaps WHERE gaps.machine_id = m.id),
+		(SELECT MAX(finish) FROM s WHERE s.machine_id = m.id)
+	FROM machines m
+	WHERE $4::int = 0 OR m.site_id = $4::int
+	ORDER BY m.id`
+
+	rows, err := r.db.QueryContext(ctx, q, rng.From.Unix(), rng.To.Unix(), now.Unix(), rng.SiteId, end)
+	if err != nil {
+		return nil, errors.Wrap(err, "select machine usage")
+	}
+	defer rows.Close()
+
+	usage := make([]entities.MachineUsage, 0)
+	for rows.Next() {
+		var (
+			machineId   string
+			sessions    int
+			used        int64
+			longestIdle int64
+			lastUsed    sql.NullInt64
+		)
+		if err := rows.Scan(&machineId, &sessions, &used, &longestIdle, &lastUsed); err != nil {
+			return nil, errors.Wrap(err, "scan machine usage")
+		}
+
+		var lastUsedTime *time.Time
+		if lastUsed.Valid {
+			t := time.Unix(lastUsed.Int64, 0)
+			lastUsedTime = &t
+		}
+		usage = append(usage, newMachineUsage(machineId, sessions, used, longestIdle, lastUsedTime, period))
+	}
+
+	return usage, errors.Wrap(rows.Err(), "iterate machine usage")
+}
+
+func (r *repository) WorkerHours(ctx context.Context, rng entities.ReportRange) ([]entities.WorkerHours, error) {
+	q := clippedSessions + `
+	SELECT u.id, u.name, COUNT(s.id), COUNT(DISTINCT s.machine_id), COALESCE(SUM(s.finish - s.start), 0)
+	FROM users u LEFT JOIN s ON s.worker_id = u.id
+	WHERE (u.job_position = 'worker' AND ($4::int = 0 OR u.site_id = $4::int)) OR s.id IS NOT NULL
+	GROUP BY u.id, u.name
+	ORDER BY u.id`
+
+	rows, err := r.db.QueryContext(ctx, q, rng.From.Unix(), rng.To.Unix(), time.Now().Unix(), rng.SiteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "select worker hours")
+	}
+	defer rows.Close()
+
+	workers := make([]entities.WorkerHours, 0)
+	for rows.Next() {
+		var (
+			w       entities.WorkerHours
+			seconds int64
+		)
+		if err := rows.Scan(&w.WorkerId, &w.Name, &w.Sessions, &w.Machines, &seconds); err != nil {
+			return nil, errors.Wrap(err, "scan worker hours")
+		}
+		w.Hours = hours(seconds)
+		workers = append(workers, w)
+	}
+
+	return workers, errors.Wrap(rows.Err(), "iterate worker hours")
+}
+
+func (r *repository) ParkingTurnover(ctx context.Context, rng entities.ReportRange) ([]entities.ParkingTurnover, error) {
+	q := `
+	SELECT p.id, p.name,
+		(SELECT COUNT(*) FROM sessions
+			WHERE start_parking_id = p.id AND datetime_start >= $1 AND datetime_start < $2),
+		(SELECT COUNT(*) FROM sessions
+			WHERE finish_parking_id = p.id AND state = 2 AND datetime_finish >= $1 AND datetime_finish < $2)
+	FROM parkings p
+	WHERE $3::int = 0 OR p.site_id = $3::int
+	ORDER BY p.id`
+
+	rows, err := r.db.QueryContext(ctx, q, rng.From.Unix(), rng.To.Unix(), rng.SiteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "select parking turnover")
+	}
+	defer rows.Close()
+
+	parkings := make([]entities.ParkingTurnover, 0)
+	for rows.Next() {
+		var p entities.ParkingTurnover
+		if err := rows.Scan(&p.ParkingId, &p.Name, &p.Departures, &p.Arrivals); err != nil {
+			return nil, errors.Wrap(err, "scan parking turnover")
+		}
+		p.Turnover = p.Departures + p.Arrivals
+		parkings = append(parkings, p)
+	}
+
+	return parkings, errors.Wrap(rows.Err(), "iterate parking turnover")
+}
+
+// PeakHours splits used time of sessions into hours of week in loc time zone.
+// Hours are aligned to UTC hours, so zones with not whole hour offset are approximated.
+func (r *repository) PeakHours(ctx context.Context, rng entities.ReportRange, loc *time.Location) ([]entities.PeakHour, error) {
+	q := clippedSessions + `,
+	used AS (
+		SELECT EXTRACT(DOW FROM to_timestamp(h) AT TIME ZONE $5)::int AS weekday,
+			EXTRACT(HOUR FROM to_timestamp(h) AT TIME ZONE $5)::int AS hour,
+			SUM(LEAST(s.finish, h + 3600) - GREATEST(s.start, h)) AS seconds
+		FROM s CROSS JOIN LATERAL generate_series(s.start - s.start % 3600, s.finish - 1, 3600) AS h
+		GROUP BY 1, 2
+	),
+	started AS (
+		SELECT EXTRACT(DOW FROM to_timestamp(datetime_start) AT TIME ZONE $5)::int AS weekday,
+			EXTRACT(HOUR FROM to_timestamp(datetime_start) AT TIME ZONE $5)::int AS hour,
+			COUNT(*) AS sessions
+		FROM s WHERE datetime_start >= $1::bigint
+		GROUP BY 1, 2
+	)
+	SELECT COALESCE(used.weekday, started.weekday), COALESCE(used.hour, started.hour),
+		COALESCE(started.sessions, 0), COALESCE(used.seconds, 0)
+	FROM used FULL JOIN started ON used.weekday = started.weekday AND used.hour = started.hour`
+
+	rows, err := r.db.QueryContext(ctx, q, rng.From.Unix(), rng.To.Unix(), time.Now().Unix(), rng.SiteId, loc.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "select peak hours")
+	}
+	defer rows.Close()
+
+	cells := make([]entities.PeakHour, 0)
+	for rows.Next() {
+		var (
+			c       entities.PeakHour
+			seconds int64
+		)
+		if err := rows.Scan(&c.Weekday, &c.Hour, &c.SessionsStarted, &seconds); err != nil {
+			return nil, errors.Wrap(err, "scan peak hours")
+		}
+		c.MachineHours = hours(seconds)
+		cells = append(cells, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate peak hours")
+	}
+
+	return heatmap(cells), nil
+}
+
+// ShiftUsage selects sessions, shifts and assignments, shifts are matched in go
+// because shift windows depend on time zone and may cross midnight
+func (r *repository) ShiftUsage(ctx context.Context, rng entities.ReportRange) ([]entities.ShiftUsage, error) {
+	shifts, err := r.selectShifts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned, err := r.selectAssignedShifts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := clippedSessions + `
+	SELECT worker_id, datetime_start, finish - start FROM s`
+
+	rows, err := r.db.QueryContext(ctx, q, rng.From.Unix(), rng.To.Unix(), time.Now().Unix(), rng.SiteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "select shift sessions")
+	}
+	defer rows.Close()
+
+	sessions := make([]startedSession, 0)
+	for rows.Next() {
+		var (
+			s     startedSession
+			start int64
+		)
+		if err := rows.Scan(&s.workerId, &start, &s.seconds); err != nil {
+			return nil, errors.Wrap(err, "scan shift sessions")
+		}
+		s.start = time.Unix(start, 0)
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate shift sessions")
+	}
+
+	return shiftUsage(shifts, assigned, sessions), nil
+}
+
+func (r *repository) selectShifts(ctx context.Context) ([]entities.Shift, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, start_time, end_time, days, timezone FROM shifts ORDER BY id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "select shifts")
+	}
+	defer rows.Close()
+
+	shifts := make([]entities.Shift, 0)
+	for rows.Next() {
+		var (
+			s    entities.Shift
+			days pq.Int64Array
+		)
+		if err := rows.Scan(&s.Id, &s.Name, &s.Start, &s.End, &days, &s.Timezone); err != nil {
+			return nil, errors.Wrap(err, "scan shift")
+		}
+		for _, d := range days {
+			s.Days = append(s.Days, int(d))
+		}
+		shifts = append(shifts, s)
+	}
+	return shifts, errors.Wrap(rows.Err(), "iterate shifts")
+}
+
+// selectAssignedShifts returns shift ids by user id
+func (r *repository) selectAssignedShifts(ctx context.Context) (map[int][]int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT user_id, shift_id FROM user_shifts ORDER BY user_id, shift_id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "select user shifts")
+	}
+	defer rows.Close()
+
+	assigned := make(map[int][]int)
+	for rows.Next() {
+		var userId, shiftId int
+		if err := rows.Scan(&userId, &shiftId); err != nil {
+			return nil, errors.Wrap(err, "scan user shifts")
+		}
+		assigned[userId] = append(assigned[userId], shiftId)
+	}
+	return assigned, errors.Wrap(rows.Err(), "iterate user shifts")
+}
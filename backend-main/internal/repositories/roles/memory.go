@@ -0,0 +1,122 @@
+package roles
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/pkg/errors"
+)
+
+type userLister interface {
+	ListUsers(ctx context.Context, filter entities.UserFilter) (*entities.Page[entities.User], error)
+}
+
+// memoryRepository is a thread-safe in-memory implementation of service.Role,
+// it starts with the same roles as assets/postgres/init.sql creates
+type memoryRepository struct {
+	mu    sync.RWMutex
+	roles map[string]entities.Role
+	users userLister
+}
+
+func NewMemoryRepository(users userLister) *memoryRepository {
+	r := &memoryRepository{roles: make(map[string]entities.Role), users: users}
+	for _, role := range defaultRoles() {
+		r.roles[role.Name] = role
+	}
+	return r
+}
+
+func defaultRoles() []entities.Role {
+	siteAdmin := slices.DeleteFunc(entities.AllPermissions(), func(p entities.Permission) bool {
+		return p == entities.PermSitesManage
+	})
+
+	return []entities.Role{
+		{Name: entities.Admin, Description: "full access", Permissions: entities.AllPermissions()},
+		{Name: entities.SiteAdmin, Description: "full access within own site", Permissions: siteAdmin},
+		{Name: entities.Worker, Description: "operates machines", Permissions: []entities.Permission{
+			entities.PermSessionsUse,
+		}},
+		{Name: entities.Supervisor, Description: "oversees workers, force-finishes sessions and views reports", Permissions: []entities.Permission{
+			entities.PermAlertsManage, entities.PermAlertsRead, entities.PermIncidentsManage, entities.PermIncidentsRead,
+			entities.PermMachinesRead, entities.PermParkingsRead, entities.PermReportsRead, entities.PermSessionsForce,
+			entities.PermSessionsRead, entities.PermSessionsUse, entities.PermUsersRead,
+		}},
+		{Name: entities.Technician, Description: "services machines", Permissions: []entities.Permission{
+			entities.PermAlertsRead, entities.PermIncidentsManage, entities.PermIncidentsRead, entities.PermMachinesMaintenance,
+			entities.PermMachinesRead, entities.PermParkingsRead, entities.PermSessionsUse,
+		}},
+	}
+}
+
+func (r *memoryRepository) ListRoles(ctx context.Context) ([]entities.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	roles := make([]entities.Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		roles = append(roles, cloneRole(role))
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+	return roles, nil
+}
+
+func (r *memoryRepository) GetRole(ctx context.Context, name string) (*entities.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	role, ok := r.roles[name]
+	if !ok {
+		return nil, errs.ErrRoleNotFound
+	}
+	role = cloneRole(role)
+	return &role, nil
+}
+
+func (r *memoryRepository) SaveRole(ctx context.Context, role entities.Role) (*entities.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	role = cloneRole(role)
+	slices.Sort(role.Permissions)
+	role.Permissions = slices.Compact(role.Permissions)
+	r.roles[role.Name] = role
+
+	role = cloneRole(role)
+	return &role, nil
+}
+
+func (r *memoryRepository) DeleteRole(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.roles[name]; !ok {
+		return errs.ErrRoleNotFound
+	}
+
+	page, err := r.users.ListUsers(ctx, entities.UserFilter{ListParams: entities.ListParams{Limit: 1}, JobPosition: name})
+	if err != nil {
+		return errors.Wrap(err, "list users of role")
+	}
+	if len(page.Items) != 0 {
+		return errs.ErrRoleInUse
+	}
+
+	delete(r.roles, name)
+	return nil
+}
+
+func cloneRole(role entities.Role) entities.Role {
+	role.Permissions = slices.Clone(role.Permissions)
+	if role.Permissions == nil {
+		role.Permissions = []entities.Permission{}
+	}
+	return role
+}
@@ -0,0 +1,113 @@
+package roles
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+const selectRoles = `
+	SELECT r.name, r.description,
+		COALESCE(array_agg(p.permission ORDER BY p.permission) FILTER (WHERE p.permission IS NOT NULL), '{}')
+	FROM roles r LEFT JOIN role_permissions p ON p.role = r.name`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRole(row scanner) (*entities.Role, error) {
+	var role entities.Role
+	if err := row.Scan(&role.Name, &role.Description, pq.Array(&role.Permissions)); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *repository) ListRoles(ctx context.Context) ([]entities.Role, error) {
+	rows, err := r.db.QueryContext(ctx, selectRoles+` GROUP BY r.name ORDER BY r.name`)
+	if err != nil {
+		return nil, errors.Wrap(err, "select roles")
+	}
+	defer rows.Close()
+
+	roles := make([]entities.Role, 0)
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan role")
+		}
+		roles = append(roles, *role)
+	}
+	return roles, errors.Wrap(rows.Err(), "select roles")
+}
+
+func (r *repository) GetRole(ctx context.Context, name string) (*entities.Role, error) {
+	role, err := scanRole(r.db.QueryRowContext(ctx, selectRoles+` WHERE r.name = $1 GROUP BY r.name`, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrRoleNotFound
+	}
+	return role, errors.Wrap(err, "select role")
+}
+
+func (r *repository) SaveRole(ctx context.Context, role entities.Role) (*entities.Role, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	q := `
+		INSERT INTO roles (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description`
+	if _, err = tx.ExecContext(ctx, q, role.Name, role.Description); err != nil {
+		return nil, errors.Wrap(err, "upsert role")
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role = $1`, role.Name); err != nil {
+		return nil, errors.Wrap(err, "delete role permissions")
+	}
+
+	q = `INSERT INTO role_permissions (role, permission) SELECT DISTINCT $1, unnest($2::text[])`
+	if _, err = tx.ExecContext(ctx, q, role.Name, pq.Array(role.Permissions)); err != nil {
+		return nil, errors.Wrap(err, "insert role permissions")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit role")
+	}
+	return r.GetRole(ctx, role.Name)
+}
+
+func (r *repository) DeleteRole(ctx context.Context, name string) error {
+	q := `DELETE FROM roles WHERE name = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE job_position = $1)`
+	res, err := r.db.ExecContext(ctx, q, name)
+	if err != nil {
+		return errors.Wrap(err, "delete role")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete role")
+	}
+	if n == 1 {
+		return nil
+	}
+
+	if _, err = r.GetRole(ctx, name); err != nil {
+		return err
+	}
+	return errs.ErrRoleInUse
+}
@@ -0,0 +1,199 @@
+package sessions
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/pkg/errors"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Session.
+// Timestamps are truncated to seconds to behave like the unix columns in postgres.
+type memoryRepository struct {
+	mu       sync.RWMutex
+	sessions map[int]entities.Session
+	lastId   int
+
+	// machines is used to filter sessions by parking and site of machine, like join in postgres
+	machines machineGetter
+}
+
+type machineGetter interface {
+	GetMachineByID(ctx context.Context, machineId string) (*entities.Machine, error)
+}
+
+func NewMemoryRepository(machines machineGetter) *memoryRepository {
+	return &memoryRepository{sessions: make(map[int]entities.Session), machines: machines}
+}
+
+func (r *memoryRepository) InsertSession(ctx context.Context, userId int, machineId string, parkingId int) (*entities.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timeNow := time.Unix(time.Now().Unix(), 0)
+
+	r.lastId++
+	session := entities.Session{
+		Id:             r.lastId,
+		State:          entities.SessionActive,
+		MachineId:      machineId,
+		WorkerId:       userId,
+		DatetimeStart:  timeNow,
+		DatetimeFinish: timeNow,
+		StartParkingId: parkingId,
+	}
+	r.sessions[session.Id] = session
+
+	return &session, nil
+}
+
+func (r *memoryRepository) GetSessionByID(ctx context.Context, sessionId int) (*entities.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.sessions[sessionId]
+	if !ok {
+		return nil, errors.Wrap(errs.ErrSessionNotFound, "get session and scan values")
+	}
+	return &s, nil
+}
+
+func (r *memoryRepository) ListSessions(ctx context.Context, f entities.SessionFilter) (*entities.Page[entities.Session], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	sessions := r.filter(func(s entities.Session) bool {
+		return (f.State == nil || s.State == *f.State) &&
+			(f.WorkerId == 0 || s.WorkerId == f.WorkerId) &&
+			(f.MachineId == "" || s.MachineId == f.MachineId) &&
+			(f.From.IsZero() || !s.DatetimeStart.Before(f.From)) &&
+			(f.To.IsZero() || s.DatetimeStart.Before(f.To)) &&
+			(f.ParkingId == 0 || r.machineAtParking(ctx, s.MachineId, f.ParkingId)) &&
+			(f.SiteId == 0 || r.machineAtSite(ctx, s.MachineId, f.SiteId))
+	})
+
+	page, err := listing.Memory(sessions, f.ListParams, func(s entities.Session) (any, any) {
+		return sortValue(s, f.Sort), s.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) GetActiveSessionsByMachineID(ctx context.Context, machineId string) ([]entities.Session, error) {
+	return r.filter(func(s entities.Session) bool {
+		return s.MachineId == machineId && s.State == entities.SessionActive
+	}), nil
+}
+
+func (r *memoryRepository) GetPausedSessionsByMachineID(ctx context.Context, machineId string) ([]entities.Session, error) {
+	return r.filter(func(s entities.Session) bool {
+		return s.MachineId == machineId && s.State == entities.SessionPause
+	}), nil
+}
+
+func (r *memoryRepository) GetActiveSessionsByUserID(ctx context.Context, userId int) ([]entities.Session, error) {
+	return r.filter(func(s entities.Session) bool {
+		return s.WorkerId == userId && s.State == entities.SessionActive
+	}), nil
+}
+
+func (r *memoryRepository) GetPauseSessionsByUserID(ctx context.Context, userId int) ([]entities.Session, error) {
+	return r.filter(func(s entities.Session) bool {
+		return s.WorkerId == userId && s.State == entities.SessionPause
+	}), nil
+}
+
+func (r *memoryRepository) GetUnfinishedSessionsByUserId(ctx context.Context, userId int) ([]entities.Session, error) {
+	return r.filter(func(s entities.Session) bool {
+		return s.WorkerId == userId && s.State != entities.SessionFinished
+	}), nil
+}
+
+func (r *memoryRepository) GetActiveSessionsByMachineAndUser(ctx context.Context, machineId string, userId int) ([]entities.Session, error) {
+	return r.filter(func(s entities.Session) bool {
+		return s.MachineId == machineId && s.WorkerId == userId && s.State == entities.SessionActive
+	}), nil
+}
+
+func (r *memoryRepository) GetPausedSessionsByMachineAndUser(ctx context.Context, machineId string, userId int) ([]entities.Session, error) {
+	return r.filter(func(s entities.Session) bool {
+		return s.MachineId == machineId && s.WorkerId == userId && s.State == entities.SessionPause
+	}), nil
+}
+
+func (r *memoryRepository) UpdateSessionState(ctx context.Context, sessionId int, state entities.SessionState) (*entities.Session, error) {
+	return r.update(sessionId, func(s *entities.Session) { s.State = state })
+}
+
+func (r *memoryRepository) PauseSession(ctx context.Context, sessionId int) (*entities.Session, error) {
+	return r.update(sessionId, func(s *entities.Session) { s.State = entities.SessionPause })
+}
+
+func (r *memoryRepository) FinishSession(ctx context.Context, sessionId int, parkingId int) (*entities.Session, error) {
+	timeNow := time.Unix(time.Now().Unix(), 0)
+
+	return r.update(sessionId, func(s *entities.Session) {
+		s.State = entities.SessionFinished
+		s.DatetimeFinish = timeNow
+		s.FinishParkingId = parkingId
+	})
+}
+
+func (r *memoryRepository) OperatingSeconds(ctx context.Context, machineId string, now time.Time) (int64, error) {
+	var seconds int64
+	for _, s := range r.filter(func(s entities.Session) bool { return s.MachineId == machineId }) {
+		finish := now
+		if s.State == entities.SessionFinished {
+			finish = s.DatetimeFinish
+		}
+		seconds += finish.Unix() - s.DatetimeStart.Unix()
+	}
+	return seconds, nil
+}
+
+func (r *memoryRepository) update(sessionId int, apply func(s *entities.Session)) (*entities.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[sessionId]
+	if !ok {
+		return nil, errors.Wrap(errs.ErrSessionNotFound, "update session")
+	}
+	apply(&s)
+	r.sessions[sessionId] = s
+
+	return &s, nil
+}
+
+func (r *memoryRepository) filter(match func(s entities.Session) bool) []entities.Session {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]entities.Session, 0)
+	for _, s := range r.sessions {
+		if match(s) {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Id < sessions[j].Id })
+
+	return sessions
+}
+
+func (r *memoryRepository) machineAtParking(ctx context.Context, machineId string, parkingId int) bool {
+	machine, err := r.machines.GetMachineByID(ctx, machineId)
+	return err == nil && machine.ParkingId == parkingId
+}
+
+func (r *memoryRepository) machineAtSite(ctx context.Context, machineId string, siteId int) bool {
+	machine, err := r.machines.GetMachineByID(ctx, machineId)
+	return err == nil && machine.SiteId == siteId
+}
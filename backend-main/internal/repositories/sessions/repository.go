@@ -1,13 +1,18 @@
 package sessions
 
 import (
+	"context"
 	"time"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
+const columns = `id, state, machine_id, worker_id, datetime_start, datetime_finish, start_parking_id, finish_parking_id`
+
 type repository struct {
 	db *sqlx.DB
 }
@@ -16,144 +21,219 @@ func NewRepository(db *sqlx.DB) *repository {
 	return &repository{db: db}
 }
 
-func (r *repository) InsertSession(userId int, machineId string) (*entities.Session, error) {
+func (r *repository) InsertSession(ctx context.Context, userId int, machineId string, parkingId int) (*entities.Session, error) {
 	var id int
 
 	timeNow := time.Now().Unix()
 
-	q := `INSERT INTO sessions (machine_id, worker_id, datetime_start, datetime_finish) VALUES ($1, $2, $3, $4) RETURNING id;`
+	q := `INSERT INTO sessions (machine_id, worker_id, datetime_start, datetime_finish, start_parking_id) VALUES ($1, $2, $3, $4, $5) RETURNING id;`
 
-	if err := r.db.QueryRowx(q, machineId, userId, timeNow, timeNow).Scan(&id); err != nil {
+	if err := r.db.QueryRowxContext(ctx, q, machineId, userId, timeNow, timeNow, parkingId).Scan(&id); err != nil {
 		return nil, errors.Wrap(err, "insert new session and scan id")
 	}
 
-	return r.GetSessionByID(id)
+	return r.GetSessionByID(ctx, id)
 }
 
-func (r *repository) GetSessionByID(sessionId int) (*entities.Session, error) {
-	var (
-		session        entities.Session
-		timeStartUnix  int64
-		timeFinishUnix int64
-	)
+func (r *repository) GetSessionByID(ctx context.Context, sessionId int) (*entities.Session, error) {
+	q := `SELECT ` + columns + ` FROM sessions WHERE id = $1`
 
-	q := `SELECT id, state, machine_id, worker_id, datetime_start, datetime_finish FROM sessions WHERE id = $1`
-	if err := r.db.QueryRowx(q, sessionId).Scan(&session.Id, &session.State, &session.MachineId, &session.WorkerId, &timeStartUnix, &timeFinishUnix); err != nil {
-		return nil, errors.Wrap(err, "get session and scan values")
+	session, err := scanSession(r.db.QueryRowxContext(ctx, q, sessionId))
+	if err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrSessionNotFound), "get session and scan values")
 	}
+	return session, nil
+}
 
-	session.DatetimeStart = time.Unix(timeStartUnix, 0)
-	session.DatetimeFinish = time.Unix(timeFinishUnix, 0)
-
-	return &session, nil
+var sortColumns = map[string]string{
+	"id":              "s.id",
+	"datetime_start":  "s.datetime_start",
+	"datetime_finish": "s.datetime_finish",
 }
 
-func (r *repository) GetAllSessions() ([]entities.Session, error) {
-	q := `SELECT id, state, machine_id, worker_id, datetime_start, datetime_finish FROM sessions`
+func (r *repository) ListSessions(ctx context.Context, f entities.SessionFilter) (*entities.Page[entities.Session], error) {
+	var query listing.Query
+
+	from := `SELECT s.id, s.state, s.machine_id, s.worker_id, s.datetime_start, s.datetime_finish, s.start_parking_id, s.finish_parking_id FROM sessions s`
+	if f.ParkingId != 0 || f.SiteId != 0 {
+		from += ` JOIN machines m ON m.id = s.machine_id`
+	}
+	if f.ParkingId != 0 {
+		query.Where("m.parking_id = %s", f.ParkingId)
+	}
+	if f.SiteId != 0 {
+		query.Where("m.site_id = %s", f.SiteId)
+	}
+
+	if f.State != nil {
+		query.Where("s.state = %s", *f.State)
+	}
+	if f.WorkerId != 0 {
+		query.Where("s.worker_id = %s", f.WorkerId)
+	}
+	if f.MachineId != "" {
+		query.Where("s.machine_id = %s", f.MachineId)
+	}
+	if !f.From.IsZero() {
+		query.Where("s.datetime_start >= %s", f.From.Unix())
+	}
+	if !f.To.IsZero() {
+		query.Where("s.datetime_start < %s", f.To.Unix())
+	}
+
+	q, args, limit, err := query.Build(from, sortColumns, "s.id", f.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := r.selectSessions(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list sessions")
+	}
 
-	return r.selectSessions(q)
+	page := listing.Page(sessions, limit, func(s entities.Session) listing.Cursor {
+		return listing.NewCursor(sortValue(s, f.Sort), s.Id)
+	})
+	return &page, nil
 }
 
-func (r *repository) GetActiveSessionsByMachineID(machineId string) ([]entities.Session, error) {
-	q := `SELECT id, state, machine_id, worker_id, datetime_start, datetime_finish FROM sessions WHERE machine_id = $1 AND state = 0`
+func (r *repository) GetActiveSessionsByMachineID(ctx context.Context, machineId string) ([]entities.Session, error) {
+	q := `SELECT ` + columns + ` FROM sessions WHERE machine_id = $1 AND state = 0`
 
-	return r.selectSessions(q, machineId)
+	return r.selectSessions(ctx, q, machineId)
 }
 
-func (r *repository) GetPausedSessionsByMachineID(machineId string) ([]entities.Session, error) {
-	q := `SELECT * FROM sessions WHERE machine_id = $1 AND state = $2`
+func (r *repository) GetPausedSessionsByMachineID(ctx context.Context, machineId string) ([]entities.Session, error) {
+	q := `SELECT ` + columns + ` FROM sessions WHERE machine_id = $1 AND state = $2`
 
-	return r.selectSessions(q, machineId, entities.SessionPause)
+	return r.selectSessions(ctx, q, machineId, entities.SessionPause)
 }
 
-func (r *repository) GetActiveSessionsByUserID(userId int) ([]entities.Session, error) {
-	q := `SELECT * FROM sessions WHERE worker_id = $1 AND state = 0`
+func (r *repository) GetActiveSessionsByUserID(ctx context.Context, userId int) ([]entities.Session, error) {
+	q := `SELECT ` + columns + ` FROM sessions WHERE worker_id = $1 AND state = 0`
 
-	return r.selectSessions(q, userId)
+	return r.selectSessions(ctx, q, userId)
 }
 
-func (r *repository) GetPauseSessionsByUserID(userId int) ([]entities.Session, error) {
-	q := `SELECT * FROM sessions WHERE worker_id = $1 AND state = $2`
+func (r *repository) GetPauseSessionsByUserID(ctx context.Context, userId int) ([]entities.Session, error) {
+	q := `SELECT ` + columns + ` FROM sessions WHERE worker_id = $1 AND state = $2`
 
-	return r.selectSessions(q, userId, entities.SessionPause)
+	return r.selectSessions(ctx, q, userId, entities.SessionPause)
 }
 
-func (r *repository) GetUnfinishedSessionsByUserId(userId int) ([]entities.Session, error) {
-	q := `SELECT * FROM sessions WHERE worker_id = $1 AND state != $2`
+func (r *repository) GetUnfinishedSessionsByUserId(ctx context.Context, userId int) ([]entities.Session, error) {
+	q := `SELECT ` + columns + ` FROM sessions WHERE worker_id = $1 AND state != $2`
 
-	return r.selectSessions(q, userId, entities.SessionFinished)
+	return r.selectSessions(ctx, q, userId, entities.SessionFinished)
 }
 
-func (r *repository) GetActiveSessionsByMachineAndUser(machineId string, userId int) ([]entities.Session, error) {
-	q := `SELECT * FROM sessions WHERE machine_id = $1 AND worker_id = $2 AND state = $3;`
+func (r *repository) GetActiveSessionsByMachineAndUser(ctx context.Context, machineId string, userId int) ([]entities.Session, error) {
+	q := `SELECT ` + columns + ` FROM sessions WHERE machine_id = $1 AND worker_id = $2 AND state = $3;`
 
-	return r.selectSessions(q, machineId, userId, entities.SessionActive)
+	return r.selectSessions(ctx, q, machineId, userId, entities.SessionActive)
 }
 
-func (r *repository) GetPausedSessionsByMachineAndUser(machineId string, userId int) ([]entities.Session, error) {
-	q := `SELECT * FROM sessions WHERE machine_id = $1 AND worker_id = $2 AND state = $3;`
+func (r *repository) GetPausedSessionsByMachineAndUser(ctx context.Context, machineId string, userId int) ([]entities.Session, error) {
+	q := `SELECT ` + columns + ` FROM sessions WHERE machine_id = $1 AND worker_id = $2 AND state = $3;`
 
-	return r.selectSessions(q, machineId, userId, entities.SessionPause)
+	return r.selectSessions(ctx, q, machineId, userId, entities.SessionPause)
 }
 
-func (r *repository) UpdateSessionState(sessionId int, state entities.SessionState) (*entities.Session, error) {
+func (r *repository) UpdateSessionState(ctx context.Context, sessionId int, state entities.SessionState) (*entities.Session, error) {
 	var id int
 
 	q := `UPDATE sessions SET state = $1 WHERE id = $2 RETURNING id;`
-	if err := r.db.QueryRowx(q, state, sessionId).Scan(&id); err != nil {
-		return nil, errors.Wrap(err, "update session")
+	if err := r.db.QueryRowxContext(ctx, q, state, sessionId).Scan(&id); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrSessionNotFound), "update session")
 	}
 
-	return r.GetSessionByID(id)
+	return r.GetSessionByID(ctx, id)
 }
 
-func (r *repository) PauseSession(sessionId int) (*entities.Session, error) {
+func (r *repository) PauseSession(ctx context.Context, sessionId int) (*entities.Session, error) {
 	var id int
 
 	q := `UPDATE sessions SET state = $1 WHERE id = $2 RETURNING id;`
-	if err := r.db.QueryRowx(q, entities.SessionPause, sessionId).Scan(&id); err != nil {
-		return nil, errors.Wrap(err, "update session")
+	if err := r.db.QueryRowxContext(ctx, q, entities.SessionPause, sessionId).Scan(&id); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrSessionNotFound), "update session")
 	}
 
-	return r.GetSessionByID(id)
+	return r.GetSessionByID(ctx, id)
 }
 
-func (r *repository) FinishSession(sessionId int) (*entities.Session, error) {
+func (r *repository) FinishSession(ctx context.Context, sessionId int, parkingId int) (*entities.Session, error) {
 	var id int
 	timeNow := time.Now().Unix()
 
-	q := `UPDATE sessions SET state = $1, datetime_finish = $2 WHERE id = $3 RETURNING id;`
-	if err := r.db.QueryRowx(q, entities.SessionFinished, timeNow, sessionId).Scan(&id); err != nil {
-		return nil, errors.Wrap(err, "update session")
+	q := `UPDATE sessions SET state = $1, datetime_finish = $2, finish_parking_id = $3 WHERE id = $4 RETURNING id;`
+	if err := r.db.QueryRowxContext(ctx, q, entities.SessionFinished, timeNow, parkingId, sessionId).Scan(&id); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrSessionNotFound), "update session")
 	}
 
-	return r.GetSessionByID(id)
+	return r.GetSessionByID(ctx, id)
 }
 
-func (r *repository) selectSessions(q string, args ...any) ([]entities.Session, error) {
+func (r *repository) OperatingSeconds(ctx context.Context, machineId string, now time.Time) (int64, error) {
+	q := `SELECT COALESCE(SUM(CASE WHEN state = $2 THEN datetime_finish ELSE $3 END - datetime_start), 0)
+		FROM sessions WHERE machine_id = $1`
+
+	var seconds int64
+	err := r.db.QueryRowContext(ctx, q, machineId, entities.SessionFinished, now.Unix()).Scan(&seconds)
+	return seconds, errors.Wrap(err, "sum operating time of machine")
+}
+
+func (r *repository) selectSessions(ctx context.Context, q string, args ...any) ([]entities.Session, error) {
 	sessions := make([]entities.Session, 0)
 
-	rows, err := r.db.Query(q, args...)
+	rows, err := r.db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "select all sessions")
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var (
-			session        entities.Session
-			timeStartUnix  int64
-			timeFinishUnix int64
-		)
-
-		if err := rows.Scan(&session.Id, &session.State, &session.MachineId, &session.WorkerId, &timeStartUnix, &timeFinishUnix); err != nil {
+		session, err := scanSession(rows)
+		if err != nil {
 			return nil, errors.Wrap(err, "get all sessions and scan values")
 		}
+		sessions = append(sessions, *session)
+	}
 
-		session.DatetimeStart = time.Unix(timeStartUnix, 0)
-		session.DatetimeFinish = time.Unix(timeFinishUnix, 0)
+	return sessions, errors.Wrap(rows.Err(), "iterate sessions")
+}
 
-		sessions = append(sessions, session)
+// sortValue returns value of session field used as sort key of list
+func sortValue(s entities.Session, field string) any {
+	switch field {
+	case "datetime_start":
+		return s.DatetimeStart.Unix()
+	case "datetime_finish":
+		return s.DatetimeFinish.Unix()
+	default:
+		return s.Id
 	}
+}
 
-	return sessions, nil
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSession scans row of `columns`, timestamps are stored as unix seconds
+func scanSession(row scanner) (*entities.Session, error) {
+	var (
+		session        entities.Session
+		timeStartUnix  int64
+		timeFinishUnix int64
+	)
+
+	err := row.Scan(&session.Id, &session.State, &session.MachineId, &session.WorkerId,
+		&timeStartUnix, &timeFinishUnix, &session.StartParkingId, &session.FinishParkingId)
+	if err != nil {
+		return nil, err
+	}
+
+	session.DatetimeStart = time.Unix(timeStartUnix, 0)
+	session.DatetimeFinish = time.Unix(timeFinishUnix, 0)
+
+	return &session, nil
 }
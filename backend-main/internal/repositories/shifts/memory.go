@@ -0,0 +1,125 @@
+package shifts
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Shift
+type memoryRepository struct {
+	mu     sync.RWMutex
+	shifts map[int]entities.Shift
+	lastId int
+
+	// shift ids by user id
+	users map[int][]int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{shifts: make(map[int]entities.Shift), users: make(map[int][]int)}
+}
+
+func (r *memoryRepository) ListShifts(ctx context.Context) ([]entities.Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shifts := make([]entities.Shift, 0, len(r.shifts))
+	for _, s := range r.shifts {
+		shifts = append(shifts, s)
+	}
+	sort.Slice(shifts, func(i, j int) bool { return shifts[i].Id < shifts[j].Id })
+	return shifts, nil
+}
+
+func (r *memoryRepository) GetShift(ctx context.Context, shiftId int) (*entities.Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.shifts[shiftId]
+	if !ok {
+		return nil, errs.ErrShiftNotFound
+	}
+	return &s, nil
+}
+
+func (r *memoryRepository) InsertShift(ctx context.Context, s entities.Shift) (*entities.Shift, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(s.Name, 0) {
+		return nil, errs.ErrAlreadyExists
+	}
+
+	r.lastId++
+	s.Id = r.lastId
+	s.Days = slices.Clone(s.Days)
+	r.shifts[s.Id] = s
+	return &s, nil
+}
+
+func (r *memoryRepository) UpdateShift(ctx context.Context, s entities.Shift) (*entities.Shift, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.shifts[s.Id]; !ok {
+		return nil, errs.ErrShiftNotFound
+	}
+	if r.nameTaken(s.Name, s.Id) {
+		return nil, errs.ErrAlreadyExists
+	}
+
+	s.Days = slices.Clone(s.Days)
+	r.shifts[s.Id] = s
+	return &s, nil
+}
+
+func (r *memoryRepository) DeleteShift(ctx context.Context, shiftId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.shifts[shiftId]; !ok {
+		return errs.ErrShiftNotFound
+	}
+	delete(r.shifts, shiftId)
+
+	// like ON DELETE CASCADE in postgres
+	for userId, ids := range r.users {
+		r.users[userId] = slices.DeleteFunc(ids, func(id int) bool { return id == shiftId })
+	}
+	return nil
+}
+
+func (r *memoryRepository) ListUserShifts(ctx context.Context, userId int) ([]entities.Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shifts := make([]entities.Shift, 0, len(r.users[userId]))
+	for _, id := range r.users[userId] {
+		shifts = append(shifts, r.shifts[id])
+	}
+	return shifts, nil
+}
+
+func (r *memoryRepository) ReplaceUserShifts(ctx context.Context, userId int, shiftIds []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := slices.Clone(shiftIds)
+	slices.Sort(ids)
+	r.users[userId] = slices.Compact(ids)
+	return nil
+}
+
+func (r *memoryRepository) nameTaken(name string, exceptId int) bool {
+	for _, s := range r.shifts {
+		if s.Name == name && s.Id != exceptId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package shifts
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+const columns = `id, name, start_time, end_time, days, timezone`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanShift(row scanner) (*entities.Shift, error) {
+	var (
+		s    entities.Shift
+		days pq.Int64Array
+	)
+	if err := row.Scan(&s.Id, &s.Name, &s.Start, &s.End, &days, &s.Timezone); err != nil {
+		return nil, err
+	}
+
+	s.Days = make([]int, 0, len(days))
+	for _, d := range days {
+		s.Days = append(s.Days, int(d))
+	}
+	return &s, nil
+}
+
+func (r *repository) ListShifts(ctx context.Context) ([]entities.Shift, error) {
+	return r.selectShifts(ctx, `SELECT `+columns+` FROM shifts ORDER BY id`)
+}
+
+func (r *repository) GetShift(ctx context.Context, shiftId int) (*entities.Shift, error) {
+	s, err := scanShift(r.db.QueryRowContext(ctx, `SELECT `+columns+` FROM shifts WHERE id = $1`, shiftId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.ErrShiftNotFound
+	}
+	return s, errors.Wrap(err, "select shift")
+}
+
+func (r *repository) InsertShift(ctx context.Context, s entities.Shift) (*entities.Shift, error) {
+	q := `INSERT INTO shifts (name, start_time, end_time, days, timezone) VALUES ($1, $2, $3, $4, $5) RETURNING ` + columns
+
+	inserted, err := scanShift(r.db.QueryRowContext(ctx, q, s.Name, s.Start, s.End, pq.Array(s.Days), s.Timezone))
+	return inserted, errors.Wrap(errs.FromSQL(err, nil), "insert shift")
+}
+
+func (r *repository) UpdateShift(ctx context.Context, s entities.Shift) (*entities.Shift, error) {
+	q := `UPDATE shifts SET name = $1, start_time = $2, end_time = $3, days = $4, timezone = $5 WHERE id = $6 RETURNING ` + columns
+
+	updated, err := scanShift(r.db.QueryRowContext(ctx, q, s.Name, s.Start, s.End, pq.Array(s.Days), s.Timezone, s.Id))
+	return updated, errors.Wrap(errs.FromSQL(err, errs.ErrShiftNotFound), "update shift")
+}
+
+// DeleteShift removes shift, foreign key removes it from users
+func (r *repository) DeleteShift(ctx context.Context, shiftId int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM shifts WHERE id = $1`, shiftId)
+	if err != nil {
+		return errors.Wrap(err, "delete shift")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete shift")
+	}
+	if n == 0 {
+		return errs.ErrShiftNotFound
+	}
+	return nil
+}
+
+func (r *repository) ListUserShifts(ctx context.Context, userId int) ([]entities.Shift, error) {
+	q := `SELECT ` + columns + ` FROM shifts WHERE id IN (SELECT shift_id FROM user_shifts WHERE user_id = $1) ORDER BY id`
+	return r.selectShifts(ctx, q, userId)
+}
+
+func (r *repository) ReplaceUserShifts(ctx context.Context, userId int, shiftIds []int) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM user_shifts WHERE user_id = $1`, userId); err != nil {
+		return errors.Wrap(err, "delete shifts of user")
+	}
+
+	for _, shiftId := range shiftIds {
+		q := `INSERT INTO user_shifts (user_id, shift_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		if _, err = tx.ExecContext(ctx, q, userId, shiftId); err != nil {
+			return errors.Wrap(err, "insert shift of user")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "commit shifts of user")
+}
+
+func (r *repository) selectShifts(ctx context.Context, q string, args ...any) ([]entities.Shift, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "select shifts")
+	}
+	defer rows.Close()
+
+	shifts := make([]entities.Shift, 0)
+	for rows.Next() {
+		s, err := scanShift(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan shift")
+		}
+		shifts = append(shifts, *s)
+	}
+	return shifts, errors.Wrap(rows.Err(), "select shifts")
+}
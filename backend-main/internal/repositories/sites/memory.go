@@ -0,0 +1,86 @@
+package sites
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Site,
+// it starts with the default site like assets/postgres/init.sql
+type memoryRepository struct {
+	mu     sync.RWMutex
+	sites  map[int]entities.Site
+	lastId int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		sites:  map[int]entities.Site{entities.DefaultSiteId: {Id: entities.DefaultSiteId, Name: "default"}},
+		lastId: entities.DefaultSiteId,
+	}
+}
+
+func (r *memoryRepository) ListSites(ctx context.Context) ([]entities.Site, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sites := make([]entities.Site, 0, len(r.sites))
+	for _, s := range r.sites {
+		sites = append(sites, s)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Id < sites[j].Id })
+	return sites, nil
+}
+
+func (r *memoryRepository) GetSite(ctx context.Context, siteId int) (*entities.Site, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.sites[siteId]
+	if !ok {
+		return nil, errs.ErrSiteNotFound
+	}
+	return &s, nil
+}
+
+func (r *memoryRepository) InsertSite(ctx context.Context, name string) (*entities.Site, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(name, 0) {
+		return nil, errs.ErrAlreadyExists
+	}
+
+	r.lastId++
+	site := entities.Site{Id: r.lastId, Name: name}
+	r.sites[site.Id] = site
+	return &site, nil
+}
+
+func (r *memoryRepository) UpdateSite(ctx context.Context, s entities.Site) (*entities.Site, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sites[s.Id]; !ok {
+		return nil, errs.ErrSiteNotFound
+	}
+	if r.nameTaken(s.Name, s.Id) {
+		return nil, errs.ErrAlreadyExists
+	}
+
+	r.sites[s.Id] = s
+	return &s, nil
+}
+
+func (r *memoryRepository) nameTaken(name string, exceptId int) bool {
+	for _, s := range r.sites {
+		if s.Name == name && s.Id != exceptId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package sites
+
+import (
+	"context"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+func (r *repository) ListSites(ctx context.Context) ([]entities.Site, error) {
+	sites := make([]entities.Site, 0)
+	if err := r.db.SelectContext(ctx, &sites, `SELECT id, name FROM sites ORDER BY id`); err != nil {
+		return nil, errors.Wrap(err, "list sites")
+	}
+	return sites, nil
+}
+
+func (r *repository) GetSite(ctx context.Context, siteId int) (*entities.Site, error) {
+	var site entities.Site
+	if err := r.db.GetContext(ctx, &site, `SELECT id, name FROM sites WHERE id = $1`, siteId); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrSiteNotFound), "get site")
+	}
+	return &site, nil
+}
+
+func (r *repository) InsertSite(ctx context.Context, name string) (*entities.Site, error) {
+	var site entities.Site
+	if err := r.db.GetContext(ctx, &site, `INSERT INTO sites (name) VALUES ($1) RETURNING id, name`, name); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, nil), "insert site")
+	}
+	return &site, nil
+}
+
+func (r *repository) UpdateSite(ctx context.Context, s entities.Site) (*entities.Site, error) {
+	var site entities.Site
+	if err := r.db.GetContext(ctx, &site, `UPDATE sites SET name = $1 WHERE id = $2 RETURNING id, name`, s.Name, s.Id); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrSiteNotFound), "update site")
+	}
+	return &site, nil
+}
@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"context"
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+)
+
+type (
+	machineLister interface {
+		ListMachines(ctx context.Context, filter entities.MachineFilter) (*entities.Page[entities.Machine], error)
+	}
+	sessionLister interface {
+		ListSessions(ctx context.Context, filter entities.SessionFilter) (*entities.Page[entities.Session], error)
+	}
+)
+
+// memoryRepository counts items of other in-memory repositories
+type memoryRepository struct {
+	machines machineLister
+	sessions sessionLister
+}
+
+func NewMemoryRepository(machines machineLister, sessions sessionLister) *memoryRepository {
+	return &memoryRepository{machines: machines, sessions: sessions}
+}
+
+func (r *memoryRepository) SessionsByState(ctx context.Context) (map[entities.SessionState]int, error) {
+	counts := make(map[entities.SessionState]int)
+	for _, state := range []entities.SessionState{entities.SessionActive, entities.SessionPause} {
+		sessions, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Session], error) {
+			return r.sessions.ListSessions(ctx, entities.SessionFilter{ListParams: p, State: &state})
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(sessions) > 0 {
+			counts[state] = len(sessions)
+		}
+	}
+	return counts, nil
+}
+
+func (r *memoryRepository) MachinesByState(ctx context.Context) (map[entities.MachineState]int, error) {
+	machines, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Machine], error) {
+		return r.machines.ListMachines(ctx, entities.MachineFilter{ListParams: p})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[entities.MachineState]int)
+	for _, m := range machines {
+		counts[m.State]++
+	}
+	return counts, nil
+}
@@ -0,0 +1,46 @@
+package stats
+
+import (
+	"context"
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+func (r *repository) SessionsByState(ctx context.Context) (map[entities.SessionState]int, error) {
+	q := `SELECT state, COUNT(*) FROM sessions WHERE state != $1 GROUP BY state`
+	counts, err := r.countByState(ctx, q, entities.SessionFinished)
+	return counts, errors.Wrap(err, "count sessions by state")
+}
+
+func (r *repository) MachinesByState(ctx context.Context) (map[entities.MachineState]int, error) {
+	q := `SELECT state, COUNT(*) FROM machines GROUP BY state`
+	counts, err := r.countByState(ctx, q)
+	return counts, errors.Wrap(err, "count machines by state")
+}
+
+func (r *repository) countByState(ctx context.Context, q string, args ...any) (map[int]int, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var state, count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		counts[state] = count
+	}
+	return counts, rows.Err()
+}
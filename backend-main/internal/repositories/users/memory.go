@@ -0,0 +1,106 @@
+package users
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/pkg/errors"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.User.
+// It is used in demo mode, when the app runs without Postgres.
+type memoryRepository struct {
+	mu     sync.RWMutex
+	users  map[int]entities.User
+	lastId int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{users: make(map[int]entities.User)}
+}
+
+func (r *memoryRepository) InsertUser(ctx context.Context, name, phoneNumber, jobPosition, password string) (*entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.PhoneNumber == phoneNumber {
+			return nil, errors.Wrap(errs.ErrAlreadyExists, "inserting new user")
+		}
+	}
+
+	r.lastId++
+	user := entities.User{
+		Id:          r.lastId,
+		Name:        name,
+		PhoneNumber: phoneNumber,
+		JobPosition: jobPosition,
+		Password:    password,
+		SiteId:      entities.DefaultSiteId,
+	}
+	r.users[user.Id] = user
+
+	return &user, nil
+}
+
+func (r *memoryRepository) ListUsers(ctx context.Context, f entities.UserFilter) (*entities.Page[entities.User], error) {
+	if _, err := listing.Column(sortColumns, f.Sort); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	users := make([]entities.User, 0, len(r.users))
+	for _, u := range r.users {
+		if (f.JobPosition == "" || u.JobPosition == f.JobPosition) && (f.SiteId == 0 || u.SiteId == f.SiteId) {
+			users = append(users, u)
+		}
+	}
+	r.mu.RUnlock()
+
+	page, err := listing.Memory(users, f.ListParams, func(u entities.User) (any, any) {
+		return sortValue(u, f.Sort), u.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *memoryRepository) GetUserByID(ctx context.Context, userId int) (*entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[userId]
+	if !ok {
+		return nil, errors.Wrap(errs.ErrUserNotFound, "get user by id")
+	}
+	return &u, nil
+}
+
+func (r *memoryRepository) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.PhoneNumber == phoneNumber {
+			return &u, nil
+		}
+	}
+	return nil, errors.Wrap(errs.ErrUserNotFound, "get user by phone number")
+}
+
+func (r *memoryRepository) UpdateUserSiteId(ctx context.Context, userId, siteId int) (*entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[userId]
+	if !ok {
+		return nil, errors.Wrap(errs.ErrUserNotFound, "update user site")
+	}
+	u.SiteId = siteId
+	r.users[userId] = u
+	return &u, nil
+}
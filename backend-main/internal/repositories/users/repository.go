@@ -1,7 +1,10 @@
 package users
 
 import (
+	"context"
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
@@ -14,49 +17,86 @@ func NewRepository(db *sqlx.DB) *repository {
 	return &repository{db: db}
 }
 
-func (r *repository) InsertUser(name, phoneNumber, jobPosition, password string) (*entities.User, error) {
+func (r *repository) InsertUser(ctx context.Context, name, phoneNumber, jobPosition, password string) (*entities.User, error) {
 	var user entities.User
 	q := `
 		INSERT INTO users(name, phone_number, job_position, password)
 		VALUES ($1, $2, $3, $4)
 		RETURNING;
 	`
-	if err := r.db.QueryRowx(q, name, phoneNumber, jobPosition, password).StructScan(&user); err != nil {
-		return nil, errors.Wrap(err, "inserting new user")
+	if err := r.db.QueryRowxContext(ctx, q, name, phoneNumber, jobPosition, password).StructScan(&user); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, nil), "inserting new user")
 	}
 	return &user, nil
 }
 
-func (r *repository) GetAllUsers() ([]entities.User, error) {
-	users := make([]entities.User, 0)
+var sortColumns = map[string]string{
+	"id":   "id",
+	"name": "name",
+}
+
+func (r *repository) ListUsers(ctx context.Context, f entities.UserFilter) (*entities.Page[entities.User], error) {
+	var query listing.Query
 
-	q := `SELECT * FROM users`
-	err := r.db.Select(&users, q)
+	if f.JobPosition != "" {
+		query.Where("job_position = %s", f.JobPosition)
+	}
+	if f.SiteId != 0 {
+		query.Where("site_id = %s", f.SiteId)
+	}
 
+	q, args, limit, err := query.Build(`SELECT * FROM users`, sortColumns, "id", f.ListParams)
 	if err != nil {
-		return nil, errors.Wrap(err, "select all users")
+		return nil, err
 	}
-	return users, nil
+
+	users := make([]entities.User, 0)
+	if err := r.db.SelectContext(ctx, &users, q, args...); err != nil {
+		return nil, errors.Wrap(err, "list users")
+	}
+
+	page := listing.Page(users, limit, func(u entities.User) listing.Cursor {
+		return listing.NewCursor(sortValue(u, f.Sort), u.Id)
+	})
+	return &page, nil
 }
 
-func (r *repository) GetUserByID(userId int) (*entities.User, error) {
+func (r *repository) GetUserByID(ctx context.Context, userId int) (*entities.User, error) {
 	var u entities.User
 
 	q := `SELECT * FROM users WHERE id = $1`
-	err := r.db.Get(&u, q, userId)
+	err := r.db.GetContext(ctx, &u, q, userId)
 	if err != nil {
-		return nil, errors.Wrap(err, "get user by id")
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrUserNotFound), "get user by id")
 	}
 	return &u, err
 }
 
-func (r *repository) GetUserByPhoneNumber(phoneNumber string) (*entities.User, error) {
+func (r *repository) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*entities.User, error) {
 	var u entities.User
 
 	q := `SELECT * FROM users WHERE phone_number = $1`
-	err := r.db.Get(&u, q, phoneNumber)
+	err := r.db.GetContext(ctx, &u, q, phoneNumber)
 	if err != nil {
-		return nil, errors.Wrap(err, "get user by phone number")
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrUserNotFound), "get user by phone number")
 	}
 	return &u, err
 }
+
+func (r *repository) UpdateUserSiteId(ctx context.Context, userId, siteId int) (*entities.User, error) {
+	var user entities.User
+
+	q := `UPDATE users SET site_id = $1 WHERE id = $2 RETURNING *`
+	if err := r.db.QueryRowxContext(ctx, q, siteId, userId).StructScan(&user); err != nil {
+		return nil, errors.Wrap(errs.FromSQL(err, errs.ErrUserNotFound), "update user site")
+	}
+	return &user, nil
+}
+
+// sortValue returns value of user field used as sort key of list
+func sortValue(u entities.User, field string) any {
+	if field == "name" {
+		return u.Name
+	}
+	return u.Id
+}
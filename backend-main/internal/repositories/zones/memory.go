@@ -0,0 +1,40 @@
+package zones
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// memoryRepository is a thread-safe in-memory implementation of service.Zone
+type memoryRepository struct {
+	mu       sync.RWMutex
+	parkings map[int][]int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{parkings: make(map[int][]int)}
+}
+
+func (r *memoryRepository) ListUserParkingIds(ctx context.Context, userId int) ([]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append(make([]int, 0, len(r.parkings[userId])), r.parkings[userId]...), nil
+}
+
+func (r *memoryRepository) ReplaceUserParkings(ctx context.Context, userId int, parkingIds []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := slices.Clone(parkingIds)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	if len(ids) == 0 {
+		delete(r.parkings, userId)
+		return nil
+	}
+	r.parkings[userId] = ids
+	return nil
+}
@@ -0,0 +1,46 @@
+package zones
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+func (r *repository) ListUserParkingIds(ctx context.Context, userId int) ([]int, error) {
+	ids := make([]int, 0)
+
+	q := `SELECT parking_id FROM user_parkings WHERE user_id = $1 ORDER BY parking_id`
+	if err := r.db.SelectContext(ctx, &ids, q, userId); err != nil {
+		return nil, errors.Wrap(err, "select parkings of user")
+	}
+	return ids, nil
+}
+
+func (r *repository) ReplaceUserParkings(ctx context.Context, userId int, parkingIds []int) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM user_parkings WHERE user_id = $1`, userId); err != nil {
+		return errors.Wrap(err, "delete parkings of user")
+	}
+
+	for _, parkingId := range parkingIds {
+		q := `INSERT INTO user_parkings (user_id, parking_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		if _, err = tx.ExecContext(ctx, q, userId, parkingId); err != nil {
+			return errors.Wrap(err, "insert parking of user")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "commit parkings of user")
+}
@@ -1,72 +1,295 @@
 package service
 
 import (
+	"context"
 	"time"
 
 	"github.com/ecol-master/sharing-wh-machines/internal/entities"
 	"github.com/ecol-master/sharing-wh-machines/internal/libs/jwt"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/alerts"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/certifications"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/checklists"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/geofences"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/idempotency"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/incidents"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/locations"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/locks"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/logins"
 	"github.com/ecol-master/sharing-wh-machines/internal/repositories/machines"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/machinetypes"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/maintenance"
 	"github.com/ecol-master/sharing-wh-machines/internal/repositories/parkings"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/reports"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/roles"
 	"github.com/ecol-master/sharing-wh-machines/internal/repositories/sessions"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/shifts"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/sites"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/stats"
 	"github.com/ecol-master/sharing-wh-machines/internal/repositories/users"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/zones"
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 )
 
 type User interface {
-	GetAllUsers() ([]entities.User, error)
-	GetUserByID(userId int) (*entities.User, error)
-	GetUserByPhoneNumber(phoneNumber string) (*entities.User, error)
+	ListUsers(ctx context.Context, filter entities.UserFilter) (*entities.Page[entities.User], error)
+	GetUserByID(ctx context.Context, userId int) (*entities.User, error)
+	GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*entities.User, error)
+	UpdateUserSiteId(ctx context.Context, userId, siteId int) (*entities.User, error)
+}
+
+// Site stores warehouses which scope parkings, machines and users
+type Site interface {
+	ListSites(ctx context.Context) ([]entities.Site, error)
+	GetSite(ctx context.Context, siteId int) (*entities.Site, error)
+	InsertSite(ctx context.Context, name string) (*entities.Site, error)
+	UpdateSite(ctx context.Context, site entities.Site) (*entities.Site, error)
+}
+
+// Role stores job positions of users with their permissions
+type Role interface {
+	ListRoles(ctx context.Context) ([]entities.Role, error)
+	GetRole(ctx context.Context, name string) (*entities.Role, error)
+	// SaveRole creates role or replaces description and permissions of existing one
+	SaveRole(ctx context.Context, role entities.Role) (*entities.Role, error)
+	// DeleteRole returns errs.ErrRoleInUse if the role is assigned to users
+	DeleteRole(ctx context.Context, name string) error
+}
+
+// Zone restricts users to parkings where they unlock and return machines
+type Zone interface {
+	// ListUserParkingIds returns parkings allowed to the user, empty list means any parking
+	ListUserParkingIds(ctx context.Context, userId int) ([]int, error)
+	// ReplaceUserParkings replaces all parkings of the user, empty list removes restriction
+	ReplaceUserParkings(ctx context.Context, userId int, parkingIds []int) error
+}
+
+// Shift stores shifts and assigns them to users, users with shifts unlock machines only within them
+type Shift interface {
+	ListShifts(ctx context.Context) ([]entities.Shift, error)
+	GetShift(ctx context.Context, shiftId int) (*entities.Shift, error)
+	InsertShift(ctx context.Context, shift entities.Shift) (*entities.Shift, error)
+	UpdateShift(ctx context.Context, shift entities.Shift) (*entities.Shift, error)
+	DeleteShift(ctx context.Context, shiftId int) error
+
+	// ListUserShifts returns shifts of the user, empty list means the user is not restricted by time
+	ListUserShifts(ctx context.Context, userId int) ([]entities.Shift, error)
+	// ReplaceUserShifts replaces all shifts of the user, empty list removes restriction
+	ReplaceUserShifts(ctx context.Context, userId int, shiftIds []int) error
+}
+
+// Geofence stores allowed and forbidden areas of sites and alerts raised when machines in use leave permitted area
+type Geofence interface {
+	// ListGeofences returns geofences of the site, 0 means all sites
+	ListGeofences(ctx context.Context, siteId int) ([]entities.Geofence, error)
+	GetGeofence(ctx context.Context, geofenceId int) (*entities.Geofence, error)
+	InsertGeofence(ctx context.Context, geofence entities.Geofence) (*entities.Geofence, error)
+	// UpdateGeofence replaces name, kind and bssids, site is not changed
+	UpdateGeofence(ctx context.Context, geofence entities.Geofence) (*entities.Geofence, error)
+	DeleteGeofence(ctx context.Context, geofenceId int) error
+
+	// InsertGeofenceAlert returns errs.ErrAlreadyExists if the machine has unresolved alert
+	InsertGeofenceAlert(ctx context.Context, alert entities.GeofenceAlert) (*entities.GeofenceAlert, error)
+	ListGeofenceAlerts(ctx context.Context, filter entities.GeofenceAlertFilter) (*entities.Page[entities.GeofenceAlert], error)
+	// ResolveGeofenceAlerts resolves unresolved alerts of the machine and returns them
+	ResolveGeofenceAlerts(ctx context.Context, machineId string, at time.Time) ([]entities.GeofenceAlert, error)
+}
+
+// Location stores access points seen by controllers of machines
+type Location interface {
+	InsertSighting(ctx context.Context, sighting entities.Sighting) (*entities.Sighting, error)
+	ListSightings(ctx context.Context, filter entities.SightingFilter) (*entities.Page[entities.Sighting], error)
+	// LastSightings returns the latest sighting of every machine which has any
+	LastSightings(ctx context.Context, machineIds []string) (map[string]entities.Sighting, error)
+	// DeleteSightingsBefore deletes sightings seen before the time and returns their number
+	DeleteSightingsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Alert stores alerts about devices, sessions and parkings, there is at most one unresolved alert with type and key
+type Alert interface {
+	// RaiseAlert creates open alert or counts repeated occurrence of unresolved alert with the same type and key,
+	// its message and last_seen_at are replaced. True is returned if alert is created.
+	RaiseAlert(ctx context.Context, alert entities.Alert) (*entities.Alert, bool, error)
+	GetAlert(ctx context.Context, alertId int) (*entities.Alert, error)
+	ListAlerts(ctx context.Context, filter entities.AlertFilter) (*entities.Page[entities.Alert], error)
+	UnresolvedAlerts(ctx context.Context, alertType entities.AlertType) ([]entities.Alert, error)
+	SetAlertNotified(ctx context.Context, alertId int, at time.Time) error
+	// AcknowledgeAlert returns errs.ErrAlertResolved for resolved alert, acknowledged alert is returned as is
+	AcknowledgeAlert(ctx context.Context, alertId, userId int, at time.Time) (*entities.Alert, error)
+	// ResolveAlert resolves unresolved alert with type and key, nil is returned if there is none
+	ResolveAlert(ctx context.Context, alertType entities.AlertType, key string, at time.Time) (*entities.Alert, error)
 }
 
 type Parking interface {
-	InsertParking(name, mac string, capacity entities.Capacity, state entities.ParkingState) (*entities.Parking, error)
-	GetParkingById(parkingId int) (*entities.Parking, error)
-	GetParkingByName(name string) (*entities.Parking, error)
-	GetAllParkings() ([]entities.Parking, error)
+	InsertParking(ctx context.Context, name, mac string, capacity entities.Capacity, state entities.ParkingState, siteId int) (*entities.Parking, error)
+	GetParkingById(ctx context.Context, parkingId int) (*entities.Parking, error)
+	GetParkingByName(ctx context.Context, name string) (*entities.Parking, error)
+	ListParkings(ctx context.Context, filter entities.ParkingFilter) (*entities.Page[entities.Parking], error)
 
 	// Method for checking if machine in some parking zone
-	GetParkingByMacAddr(macAddr string) (*entities.Parking, error)
+	GetParkingByMacAddr(ctx context.Context, macAddr string) (*entities.Parking, error)
 
-	UpdateParkingState(state entities.ParkingState, parkingId int) (*entities.Parking, error)
-	UpdateParkingCapacity(capacity entities.Capacity, parkingId int) (*entities.Parking, error)
+	UpdateParkingState(ctx context.Context, state entities.ParkingState, parkingId int) (*entities.Parking, error)
+	UpdateParkingCapacity(ctx context.Context, capacity entities.Capacity, parkingId int) (*entities.Parking, error)
 
 	// Method for adding and removing machines from database
-	UpdateParkingMachines(machines int, parkingId int) (*entities.Parking, error)
+	UpdateParkingMachines(ctx context.Context, machines int, parkingId int) (*entities.Parking, error)
 }
 
 type Machine interface {
-	InsertMachine(machineId, ipAddr string) (*entities.Machine, error)
-	GetMachineByID(machineId string) (*entities.Machine, error)
-	GetAllMachines() ([]entities.Machine, error)
-	UpdateMachineIPAddr(machineId, ipAddr string) (*entities.Machine, error)
-	UpdateMachineState(machineId string, state entities.MachineState) (*entities.Machine, error)
+	InsertMachine(ctx context.Context, machineId, ipAddr string) (*entities.Machine, error)
+	GetMachineByID(ctx context.Context, machineId string) (*entities.Machine, error)
+	ListMachines(ctx context.Context, filter entities.MachineFilter) (*entities.Page[entities.Machine], error)
+	UpdateMachineIPAddr(ctx context.Context, machineId, ipAddr string) (*entities.Machine, error)
+	UpdateMachineState(ctx context.Context, machineId string, state entities.MachineState) (*entities.Machine, error)
+	UpdateMachineMaintenance(ctx context.Context, machineId string, maintenance bool) (*entities.Machine, error)
+	UpdateMachineTypeId(ctx context.Context, machineId string, typeId int) (*entities.Machine, error)
+	UpdateMachineSiteId(ctx context.Context, machineId string, siteId int) (*entities.Machine, error)
 
 	// New method for adding parking_id to database table
-	UpdateMachineParkingId(machineId string, parkingId int) (*entities.Machine, error)
+	UpdateMachineParkingId(ctx context.Context, machineId string, parkingId int) (*entities.Machine, error)
 
 	// New method for get machines for each parking
-	GetMachinesByParkingId(parkingId int) ([]entities.Machine, error)
+	GetMachinesByParkingId(ctx context.Context, parkingId int) ([]entities.Machine, error)
+}
+
+type MachineType interface {
+	InsertMachineType(ctx context.Context, name, description string) (*entities.MachineType, error)
+	GetMachineTypeById(ctx context.Context, typeId int) (*entities.MachineType, error)
+	ListMachineTypes(ctx context.Context) ([]entities.MachineType, error)
+	UpdateMachineType(ctx context.Context, typeId int, name, description string) (*entities.MachineType, error)
+	// DeleteMachineType returns errs.ErrMachineTypeInUse if machines have the type
+	DeleteMachineType(ctx context.Context, typeId int) error
+}
+
+// Certification stores licences of users to operate machine types
+type Certification interface {
+	InsertCertification(ctx context.Context, c entities.Certification) (*entities.Certification, error)
+	GetCertification(ctx context.Context, certificationId int) (*entities.Certification, error)
+	ListCertifications(ctx context.Context, filter entities.CertificationFilter) ([]entities.Certification, error)
+	// UpdateCertification replaces number and dates, user and type are not changed
+	UpdateCertification(ctx context.Context, c entities.Certification) (*entities.Certification, error)
+	DeleteCertification(ctx context.Context, certificationId int) error
+	// ExpiringCertifications lists certifications which expire within range
+	ExpiringCertifications(ctx context.Context, rng entities.ReportRange) ([]entities.ExpiringCertification, error)
+}
+
+// Checklist stores pre-use checklists of machine types and checklists filled by workers
+type Checklist interface {
+	ListChecklistItems(ctx context.Context, typeId int) ([]entities.ChecklistItem, error)
+	// ReplaceChecklist replaces all items of the type, positions follow the order of items
+	ReplaceChecklist(ctx context.Context, typeId int, items []entities.ChecklistItem) ([]entities.ChecklistItem, error)
+
+	InsertChecklistSubmission(ctx context.Context, submission entities.ChecklistSubmission) (*entities.ChecklistSubmission, error)
+	GetChecklistSubmission(ctx context.Context, submissionId int) (*entities.ChecklistSubmission, error)
+	ListChecklistSubmissions(ctx context.Context, filter entities.ChecklistSubmissionFilter) (*entities.Page[entities.ChecklistSubmission], error)
+}
+
+// Incident stores problems with machines reported by workers
+type Incident interface {
+	InsertIncident(ctx context.Context, incident entities.Incident) (*entities.Incident, error)
+	GetIncident(ctx context.Context, incidentId int) (*entities.Incident, error)
+	ListIncidents(ctx context.Context, filter entities.IncidentFilter) (*entities.Page[entities.Incident], error)
+	// UpdateIncident replaces severity, status, resolution, triaged_by, updated_at and closed_at
+	UpdateIncident(ctx context.Context, incident entities.Incident) (*entities.Incident, error)
+	// SetIncidentPhoto returns key of replaced photo, it is empty if incident had no photo
+	SetIncidentPhoto(ctx context.Context, incidentId int, key, contentType string) (*entities.Incident, string, error)
+}
+
+// Maintenance stores service intervals of machine types and work orders created when service of machine is due
+type Maintenance interface {
+	ListServiceIntervals(ctx context.Context, typeId int) ([]entities.ServiceInterval, error)
+	GetServiceInterval(ctx context.Context, intervalId int) (*entities.ServiceInterval, error)
+	InsertServiceInterval(ctx context.Context, interval entities.ServiceInterval) (*entities.ServiceInterval, error)
+	// UpdateServiceInterval replaces name and hours, type is not changed
+	UpdateServiceInterval(ctx context.Context, interval entities.ServiceInterval) (*entities.ServiceInterval, error)
+	// DeleteServiceInterval keeps work orders of the interval with interval id 0
+	DeleteServiceInterval(ctx context.Context, intervalId int) error
+
+	// InsertWorkOrder returns errs.ErrAlreadyExists if the machine has open order of the interval
+	InsertWorkOrder(ctx context.Context, order entities.WorkOrder) (*entities.WorkOrder, error)
+	GetWorkOrder(ctx context.Context, orderId int) (*entities.WorkOrder, error)
+	ListWorkOrders(ctx context.Context, filter entities.WorkOrderFilter) (*entities.Page[entities.WorkOrder], error)
+	// MachineWorkOrders returns all orders of the machine ordered by id
+	MachineWorkOrders(ctx context.Context, machineId string) ([]entities.WorkOrder, error)
+	// CloseWorkOrder sets status, notes, closed_at, closed_by and closed_at_hours of open order,
+	// errs.ErrWorkOrderClosed is returned if it is already closed
+	CloseWorkOrder(ctx context.Context, order entities.WorkOrder) (*entities.WorkOrder, error)
 }
 
 type Session interface {
-	InsertSession(workerId int, machineId string) (*entities.Session, error)
-	GetSessionByID(sessionId int) (*entities.Session, error)
-	GetAllSessions() ([]entities.Session, error)
+	InsertSession(ctx context.Context, workerId int, machineId string, parkingId int) (*entities.Session, error)
+	GetSessionByID(ctx context.Context, sessionId int) (*entities.Session, error)
+	ListSessions(ctx context.Context, filter entities.SessionFilter) (*entities.Page[entities.Session], error)
+
+	GetActiveSessionsByMachineID(ctx context.Context, machineId string) ([]entities.Session, error)
+	GetPausedSessionsByMachineID(ctx context.Context, machineId string) ([]entities.Session, error)
 
-	GetActiveSessionsByMachineID(machineId string) ([]entities.Session, error)
-	GetPausedSessionsByMachineID(machineId string) ([]entities.Session, error)
+	GetActiveSessionsByUserID(ctx context.Context, userId int) ([]entities.Session, error)
+	GetPauseSessionsByUserID(ctx context.Context, userId int) ([]entities.Session, error)
+	GetUnfinishedSessionsByUserId(ctx context.Context, userId int) ([]entities.Session, error)
 
-	GetActiveSessionsByUserID(userId int) ([]entities.Session, error)
-	GetPauseSessionsByUserID(userId int) ([]entities.Session, error)
-	GetUnfinishedSessionsByUserId(userId int) ([]entities.Session, error)
+	GetActiveSessionsByMachineAndUser(ctx context.Context, machineId string, userId int) ([]entities.Session, error)
+	GetPausedSessionsByMachineAndUser(ctx context.Context, machineId string, userId int) ([]entities.Session, error)
 
-	GetActiveSessionsByMachineAndUser(machineId string, userId int) ([]entities.Session, error)
-	GetPausedSessionsByMachineAndUser(machineId string, userId int) ([]entities.Session, error)
+	UpdateSessionState(ctx context.Context, sessionId int, state entities.SessionState) (*entities.Session, error)
 
-	UpdateSessionState(sessionId int, state entities.SessionState) (*entities.Session, error)
+	PauseSession(ctx context.Context, sessionId int) (*entities.Session, error)
+	FinishSession(ctx context.Context, sessionId int, parkingId int) (*entities.Session, error)
+
+	// OperatingSeconds sums time of all sessions with the machine, unfinished sessions last until now
+	OperatingSeconds(ctx context.Context, machineId string, now time.Time) (int64, error)
+}
 
-	PauseSession(sessionId int) (*entities.Session, error)
-	FinishSession(sessionId int) (*entities.Session, error)
+// Report aggregates sessions within range into utilisation reports
+type Report interface {
+	MachineUsage(ctx context.Context, rng entities.ReportRange) ([]entities.MachineUsage, error)
+	WorkerHours(ctx context.Context, rng entities.ReportRange) ([]entities.WorkerHours, error)
+	ParkingTurnover(ctx context.Context, rng entities.ReportRange) ([]entities.ParkingTurnover, error)
+	PeakHours(ctx context.Context, rng entities.ReportRange, loc *time.Location) ([]entities.PeakHour, error)
+	// ShiftUsage attributes sessions to shift of the worker in which session started
+	ShiftUsage(ctx context.Context, rng entities.ReportRange) ([]entities.ShiftUsage, error)
+}
+
+// Lock serialises state transitions of machines
+type Lock interface {
+	// AcquireMachine returns errs.ErrMachineBusy if other command with the machine is in progress.
+	// Returned function releases the machine and should always be called.
+	AcquireMachine(ctx context.Context, machineId string) (func(), error)
+	// AcquireMachineReport serialises reports of location of the machine, it does not block commands.
+	// errs.ErrMachineBusy is returned if other report of the machine is in progress.
+	AcquireMachineReport(ctx context.Context, machineId string) (func(), error)
+}
+
+// Idempotency keeps outcomes of commands sent with Idempotency-Key to replay them for repeated requests
+type Idempotency interface {
+	// ReserveKey creates pending record of the key. If the key is already used, its record and false are returned.
+	// Records created before expired and pending records created before abandoned are removed first.
+	ReserveKey(ctx context.Context, userId int, key, requestHash string, expired, abandoned time.Time) (*entities.IdempotencyRecord, bool, error)
+	CompleteKey(ctx context.Context, userId int, key string, status int, contentType string, body []byte) error
+	// ReleaseKey removes pending record, so the command can be retried with the same key
+	ReleaseKey(ctx context.Context, userId int, key string) error
+}
+
+// LoginAttempts counts failed logins by phone number to lock out password guessing
+type LoginAttempts interface {
+	// GetLoginFailures returns errs.ErrLockoutNotFound if there are no failures with the phone number
+	GetLoginFailures(ctx context.Context, phoneNumber string) (*entities.LoginFailures, error)
+	// ListLoginFailures returns phone numbers which failed after since or are locked now,
+	// non-zero siteId limits them to phone numbers of users of the site
+	ListLoginFailures(ctx context.Context, since time.Time, siteId int) ([]entities.LoginFailures, error)
+	// RegisterLoginFailure counts the failure and locks the phone number for lockout when maxFailures is reached.
+	// Failures before since are forgotten, the counter starts over after lockout is expired.
+	RegisterLoginFailure(ctx context.Context, phoneNumber string, since time.Time, maxFailures int, lockout time.Duration) (*entities.LoginFailures, error)
+	// ResetLoginFailures removes failures and lockout of the phone number
+	ResetLoginFailures(ctx context.Context, phoneNumber string) error
+}
+
+// Stats counts current state of machines and sessions, it is read on every metrics scrape
+type Stats interface {
+	// Unfinished sessions only
+	SessionsByState(ctx context.Context) (map[entities.SessionState]int, error)
+	MachinesByState(ctx context.Context) (map[entities.MachineState]int, error)
 }
 
 type Auth interface {
@@ -75,18 +298,103 @@ type Auth interface {
 
 type Service struct {
 	User
+	Role
+	Site
+	Zone
+	Shift
+	Geofence
+	Location
+	Alert
 	Parking
 	Machine
+	MachineType
+	Certification
+	Checklist
+	Incident
+	Maintenance
 	Session
+	Report
+	Stats
+	Idempotency
+	Lock
+	LoginAttempts
 	Auth
 }
 
+// New creates service backed by postgres repositories
 func New(db *sqlx.DB) *Service {
 	return &Service{
 		User:    users.NewRepository(db),
+		Role:    roles.NewRepository(db),
+		Site:    sites.NewRepository(db),
+		Zone:    zones.NewRepository(db),
+		Shift:   shifts.NewRepository(db),
 		Parking: parkings.NewRepository(db),
 		Machine: machines.NewRepository(db),
+
+		MachineType:   machinetypes.NewRepository(db),
+		Certification: certifications.NewRepository(db),
+		Checklist:     checklists.NewRepository(db),
+		Incident:      incidents.NewRepository(db),
+		Maintenance:   maintenance.NewRepository(db),
+		Geofence:      geofences.NewRepository(db),
+		Location:      locations.NewRepository(db),
+		Alert:         alerts.NewRepository(db),
+
 		Session: sessions.NewRepository(db),
-		Auth:    jwt.NewService(),
+		Report:  reports.NewRepository(db),
+		Stats:   stats.NewRepository(db),
+
+		Idempotency: idempotency.NewRepository(db),
+		Lock:        locks.NewRepository(db),
+
+		LoginAttempts: logins.NewRepository(db),
+		Auth:          jwt.NewService(),
 	}
 }
+
+// NewInMemory creates service backed by in-memory repositories.
+// Users are seeded from the given list, because there is no api to create them.
+func NewInMemory(ctx context.Context, seedUsers []entities.User) (*Service, error) {
+	userRepo := users.NewMemoryRepository()
+	for _, u := range seedUsers {
+		if _, err := userRepo.InsertUser(ctx, u.Name, u.PhoneNumber, u.JobPosition, u.Password); err != nil {
+			return nil, errors.Wrap(err, "seed in-memory users")
+		}
+	}
+
+	machineRepo := machines.NewMemoryRepository()
+	parkingRepo := parkings.NewMemoryRepository()
+	sessionRepo := sessions.NewMemoryRepository(machineRepo)
+	typeRepo := machinetypes.NewMemoryRepository(machineRepo)
+	shiftRepo := shifts.NewMemoryRepository()
+
+	return &Service{
+		User:    userRepo,
+		Role:    roles.NewMemoryRepository(userRepo),
+		Site:    sites.NewMemoryRepository(),
+		Zone:    zones.NewMemoryRepository(),
+		Shift:   shiftRepo,
+		Parking: parkingRepo,
+		Machine: machineRepo,
+
+		MachineType:   typeRepo,
+		Certification: certifications.NewMemoryRepository(userRepo, typeRepo),
+		Checklist:     checklists.NewMemoryRepository(machineRepo),
+		Incident:      incidents.NewMemoryRepository(machineRepo),
+		Maintenance:   maintenance.NewMemoryRepository(machineRepo),
+		Geofence:      geofences.NewMemoryRepository(),
+		Location:      locations.NewMemoryRepository(),
+		Alert:         alerts.NewMemoryRepository(),
+
+		Session: sessionRepo,
+		Report:  reports.NewMemoryRepository(userRepo, machineRepo, parkingRepo, sessionRepo, shiftRepo),
+		Stats:   stats.NewMemoryRepository(machineRepo, sessionRepo),
+
+		Idempotency: idempotency.NewMemoryRepository(),
+		Lock:        locks.NewMemoryRepository(),
+
+		LoginAttempts: logins.NewMemoryRepository(userRepo),
+		Auth:          jwt.NewService(),
+	}, nil
+}
@@ -0,0 +1,40 @@
+// Package sightings deletes reports of access points which are older than retention
+package sightings
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+)
+
+// Prune deletes sightings seen earlier than retention before now and returns their number
+func Prune(ctx context.Context, svc *service.Service, retention time.Duration, now time.Time) (int64, error) {
+	return svc.DeleteSightingsBefore(ctx, now.Add(-retention))
+}
+
+// Monitor prunes sightings at start and then every interval until ctx is done
+func Monitor(ctx context.Context, svc *service.Service, retention, interval time.Duration, hb *health.Heartbeat) {
+	op := slog.String("op", "sightings.Monitor")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := Prune(ctx, svc, retention, time.Now())
+		if err != nil && ctx.Err() == nil {
+			slog.Error("delete old sightings", op, slog.Int64("deleted", deleted), slog.String("error", err.Error()))
+		} else if deleted > 0 {
+			slog.Info("old sightings are deleted", op, slog.Int64("deleted", deleted), slog.Duration("retention", retention))
+		}
+		hb.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
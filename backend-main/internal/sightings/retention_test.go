@@ -0,0 +1,67 @@
+package sightings
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+)
+
+func TestPruneDeletesOnlyOldSightings(t *testing.T) {
+	ctx := context.Background()
+	svc, err := service.NewInMemory(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"M1", "M2"} {
+		if _, err = svc.InsertMachine(ctx, id, "127.0.0.1:1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	for _, s := range []entities.Sighting{
+		{MachineId: "M1", Bssid: "old", SeenAt: now.Add(-48 * time.Hour)},
+		{MachineId: "M1", Bssid: "new", SeenAt: now.Add(-time.Hour)},
+		{MachineId: "M2", Bssid: "old", SeenAt: now.Add(-48 * time.Hour)},
+	} {
+		if _, err = svc.InsertSighting(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := Prune(ctx, svc, 24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("%d sightings are deleted, want 2", deleted)
+	}
+
+	page, err := svc.ListSightings(ctx, entities.SightingFilter{ListParams: entities.ListParams{Limit: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Bssid != "new" {
+		t.Errorf("kept sightings %+v, want only the new one", page.Items)
+	}
+
+	last, err := svc.LastSightings(ctx, []string{"M1", "M2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := last["M2"]; ok || last["M1"].Bssid != "new" {
+		t.Errorf("last sightings %+v, want only the new one of M1", last)
+	}
+
+	// ids are not reused after deletion
+	inserted, err := svc.InsertSighting(ctx, entities.Sighting{MachineId: "M2", Bssid: "next", SeenAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted.Id <= page.Items[0].Id {
+		t.Errorf("id %d of new sighting is not greater than %d", inserted.Id, page.Items[0].Id)
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+)
+
+// ErrorResponse is the body of every failed response.
+// Code is machine-readable and is not changed with messages.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// StatusCode maps error kind to http status code
+func StatusCode(kind errs.Kind) int {
+	switch kind {
+	case errs.KindValidation:
+		return http.StatusBadRequest
+	case errs.KindUnauthorized:
+		return http.StatusUnauthorized
+	case errs.KindForbidden:
+		return http.StatusForbidden
+	case errs.KindNotFound:
+		return http.StatusNotFound
+	case errs.KindConflict:
+		return http.StatusConflict
+	case errs.KindDeviceUnreachable:
+		return http.StatusBadGateway
+	case errs.KindTooManyRequests:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// RespondWithAppError writes err to response with status code of its kind.
+// Errors without *errs.Error in chain are hidden behind 500 Internal Server Error.
+func RespondWithAppError(w http.ResponseWriter, err error) error {
+	e, ok := errs.As(err)
+	if !ok || e.Kind == errs.KindInternal {
+		e = errs.ErrInternal
+	}
+
+	if e.RetryAfter > 0 {
+		// round up, client should not retry before the limit is over
+		w.Header().Set("Retry-After", strconv.Itoa(int((e.RetryAfter+time.Second-1)/time.Second)))
+	}
+
+	return RespondWithJSON(w, StatusCode(e.Kind), ErrorResponse{
+		Error: e.Message,
+		Code:  e.Code,
+	})
+}
@@ -0,0 +1,164 @@
+// Package workorders plans service of machines by operating hours and creates work orders of services which are due.
+// Orders are created by Monitor in background and by commands which grow operating hours of the machine.
+package workorders
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/errs"
+	"github.com/ecol-master/sharing-wh-machines/internal/health"
+	"github.com/ecol-master/sharing-wh-machines/internal/repositories/listing"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+	"github.com/pkg/errors"
+)
+
+// Plan returns operating hours of the machine with the next service by every interval of machine type.
+// Services which are due but have no work order yet have zero work_order_id, nothing is changed.
+// All orders of the machine are returned too.
+func Plan(ctx context.Context, svc *service.Service, machine *entities.Machine) (*entities.MachineService, []entities.WorkOrder, error) {
+	seconds, err := svc.OperatingSeconds(ctx, machine.Id, time.Now())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get operating time of machine")
+	}
+
+	orders, err := svc.MachineWorkOrders(ctx, machine.Id)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get work orders of machine")
+	}
+
+	intervals := make([]entities.ServiceInterval, 0)
+	if machine.TypeId != 0 {
+		if intervals, err = svc.ListServiceIntervals(ctx, machine.TypeId); err != nil {
+			return nil, nil, errors.Wrap(err, "get service intervals")
+		}
+	}
+
+	machineService := &entities.MachineService{
+		MachineId:      machine.Id,
+		OperatingHours: entities.OperatingHours(seconds),
+		Schedule:       make([]entities.ServiceDue, 0, len(intervals)),
+	}
+	for _, interval := range intervals {
+		machineService.Schedule = append(machineService.Schedule, nextService(interval, orders))
+	}
+	return machineService, orders, nil
+}
+
+// Schedule creates work orders of services which are due by operating hours of the machine.
+// Returned schedule is the one of Plan with created orders, open orders of the machine are returned too.
+func Schedule(ctx context.Context, svc *service.Service, machine *entities.Machine) (*entities.MachineService, []entities.WorkOrder, error) {
+	machineService, orders, err := Plan(ctx, svc, machine)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	concurrent := false
+	for i, due := range machineService.Schedule {
+		if due.WorkOrderId != 0 || machineService.OperatingHours < due.DueHours {
+			continue
+		}
+
+		order, err := svc.InsertWorkOrder(ctx, entities.WorkOrder{
+			MachineId:    machine.Id,
+			IntervalId:   due.IntervalId,
+			Name:         due.Name,
+			DueHours:     due.DueHours,
+			OverdueHours: due.OverdueHours,
+			CreatedAt:    time.Now(),
+		})
+		// order is created by concurrent scheduling of the machine
+		if errors.Is(err, errs.ErrAlreadyExists) {
+			concurrent = true
+			continue
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "insert work order")
+		}
+
+		slog.InfoContext(ctx, "work order is created", slog.Int("work_order_id", order.Id), slog.String("machine_id", machine.Id),
+			slog.String("service", order.Name), slog.Float64("due_hours", order.DueHours))
+		orders = append(orders, *order)
+		machineService.Schedule[i].WorkOrderId = order.Id
+	}
+
+	if concurrent {
+		if machineService, orders, err = Plan(ctx, svc, machine); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	open := make([]entities.WorkOrder, 0)
+	for _, order := range orders {
+		if order.Status == entities.WorkOrderOpen {
+			open = append(open, order)
+		}
+	}
+	return machineService, open, nil
+}
+
+// Monitor creates work orders of all machines every interval until ctx is done,
+// so idle machines which became due get their orders without commands.
+func Monitor(ctx context.Context, svc *service.Service, interval time.Duration, hb *health.Heartbeat) {
+	op := slog.String("op", "workorders.Monitor")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := scheduleAll(ctx, svc); err != nil && ctx.Err() == nil {
+			slog.Error("schedule service of machines", op, slog.String("error", err.Error()))
+		}
+		hb.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleAll schedules service of every machine with type, failure of one machine does not stop others
+func scheduleAll(ctx context.Context, svc *service.Service) error {
+	machines, err := listing.All(func(p entities.ListParams) (*entities.Page[entities.Machine], error) {
+		return svc.ListMachines(ctx, entities.MachineFilter{ListParams: p})
+	})
+	if err != nil {
+		return errors.Wrap(err, "list machines")
+	}
+
+	for _, machine := range machines {
+		if machine.TypeId == 0 {
+			continue
+		}
+		if _, _, err = Schedule(ctx, svc, &machine); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.ErrorContext(ctx, "schedule service of machine", slog.String("op", "workorders.scheduleAll"),
+				slog.String("machine_id", machine.Id), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// nextService returns open order of the interval or the service due every_hours after the last closed order
+func nextService(interval entities.ServiceInterval, orders []entities.WorkOrder) entities.ServiceDue {
+	var lastService float64
+	for _, order := range orders {
+		if order.IntervalId != interval.Id {
+			continue
+		}
+		if order.Status == entities.WorkOrderOpen {
+			return entities.ServiceDue{IntervalId: interval.Id, Name: interval.Name, DueHours: order.DueHours,
+				OverdueHours: order.OverdueHours, WorkOrderId: order.Id}
+		}
+		lastService = order.ClosedAtHours
+	}
+
+	due := lastService + float64(interval.EveryHours)
+	return entities.ServiceDue{IntervalId: interval.Id, Name: interval.Name, DueHours: due, OverdueHours: due + float64(interval.GraceHours)}
+}
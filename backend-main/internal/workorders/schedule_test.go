@@ -0,0 +1,100 @@
+package workorders
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ecol-master/sharing-wh-machines/internal/entities"
+	"github.com/ecol-master/sharing-wh-machines/internal/service"
+)
+
+// newDueMachine creates machine with type which service is due at once
+func newDueMachine(t *testing.T) (*service.Service, *entities.Machine) {
+	t.Helper()
+	ctx := context.Background()
+
+	svc, err := service.NewInMemory(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machineType, err := svc.InsertMachineType(ctx, "forklift", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.InsertServiceInterval(ctx, entities.ServiceInterval{TypeId: machineType.Id, Name: "inspection"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.InsertMachine(ctx, "M1", "127.0.0.1:1"); err != nil {
+		t.Fatal(err)
+	}
+	machine, err := svc.UpdateMachineTypeId(ctx, "M1", machineType.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, machine
+}
+
+func TestPlanDoesNotCreateOrders(t *testing.T) {
+	svc, machine := newDueMachine(t)
+
+	plan, orders, err := Plan(context.Background(), svc, machine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 0 {
+		t.Errorf("%d orders are created", len(orders))
+	}
+	if len(plan.Schedule) != 1 || plan.Schedule[0].WorkOrderId != 0 {
+		t.Errorf("schedule %+v, want one service without order", plan.Schedule)
+	}
+}
+
+func TestConcurrentScheduleCreatesOneOrder(t *testing.T) {
+	svc, machine := newDueMachine(t)
+
+	var wg sync.WaitGroup
+	results := make([]*entities.MachineService, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plan, _, err := Schedule(context.Background(), svc, machine)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = plan
+		}()
+	}
+	wg.Wait()
+
+	orders, err := svc.MachineWorkOrders(context.Background(), machine.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("%d orders are created, want 1", len(orders))
+	}
+	for _, plan := range results {
+		if plan != nil && plan.Schedule[0].WorkOrderId != orders[0].Id {
+			t.Errorf("scheduled order %d, want %d", plan.Schedule[0].WorkOrderId, orders[0].Id)
+		}
+	}
+}
+
+func TestScheduleAllCreatesOrdersOfIdleMachines(t *testing.T) {
+	svc, machine := newDueMachine(t)
+
+	if err := scheduleAll(context.Background(), svc); err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := svc.MachineWorkOrders(context.Background(), machine.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Status != entities.WorkOrderOpen {
+		t.Errorf("orders %+v, want one open order", orders)
+	}
+}